@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// AccessibilityInfo summarises the tagged-PDF features assistive technology relies on
+type AccessibilityInfo struct {
+	Tagged          bool   `json:"tagged"`     // MarkInfo says the document is tagged and it has a structure tree
+	StructTree      bool   `json:"structTree"` // a structure tree is present
+	Lang            string `json:"lang"`       // natural language of the document, e.g. "en-US"
+	DisplayDocTitle bool   `json:"displayDocTitle"`
+	Figures         int    `json:"figures"`        // Figure elements in the structure tree
+	FiguresWithAlt  int    `json:"figuresWithAlt"` // Figure elements with alternate text
+}
+
+// PDFInfo describes a document
+type PDFInfo struct {
+	Path          string            `json:"path"`
+	Pages         int               `json:"pages"`
+	Version       string            `json:"version"`
+	Size          int64             `json:"size"`
+	Title         string            `json:"title"`
+	Author        string            `json:"author"`
+	Accessibility AccessibilityInfo `json:"accessibility"`
+	Quarantine    QuarantineInfo    `json:"quarantine"`
+	Signed        bool              `json:"signed"` // the document holds digital signatures, see VerifySignatures
+}
+
+// AccessibilityReport compares the tagging of a document before and after CapGo wrote it
+type AccessibilityReport struct {
+	Before    AccessibilityInfo `json:"before"`
+	After     AccessibilityInfo `json:"after"`
+	Preserved bool              `json:"preserved"`
+	Issues    []string          `json:"issues"`
+}
+
+// GetPDFInfo returns basic document information and an accessibility summary
+func (a *App) GetPDFInfo(pdfPath string) (PDFInfo, error) {
+	pdfPath = filepath.Clean(pdfPath)
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return PDFInfo{}, err
+	}
+
+	stat, err := os.Stat(pdfPath)
+	if err != nil {
+		return PDFInfo{}, classifyFileError("read", pdfPath, err)
+	}
+	ctx, err := api.ReadContextFile(pdfPath)
+	if err != nil {
+		return PDFInfo{}, fmt.Errorf("failed to read pdf: %v", err)
+	}
+	if err := ctx.EnsurePageCount(); err != nil {
+		return PDFInfo{}, fmt.Errorf("failed to count pages: %v", err)
+	}
+
+	info := PDFInfo{
+		Path:          pdfPath,
+		Pages:         ctx.PageCount,
+		Version:       ctx.VersionString(),
+		Size:          stat.Size(),
+		Accessibility: accessibilitySummary(ctx),
+		Quarantine:    readQuarantine(pdfPath),
+		Signed:        hasSignatures(ctx),
+	}
+	if ctx.Info != nil {
+		if d, err := ctx.DereferenceDict(*ctx.Info); err == nil && d != nil {
+			info.Title = infoText(ctx, d, "Title")
+			info.Author = infoText(ctx, d, "Author")
+		}
+	}
+	return info, nil
+}
+
+// CheckAccessibility reports whether writing output from source lost any of the tagged-PDF
+// structure of the source
+func (a *App) CheckAccessibility(sourcePath, outputPath string) (AccessibilityReport, error) {
+	before, err := readAccessibility(filepath.Clean(sourcePath))
+	if err != nil {
+		return AccessibilityReport{}, err
+	}
+	after, err := readAccessibility(filepath.Clean(outputPath))
+	if err != nil {
+		return AccessibilityReport{}, err
+	}
+	return compareAccessibility(before, after), nil
+}
+
+func readAccessibility(path string) (AccessibilityInfo, error) {
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		return AccessibilityInfo{}, fmt.Errorf("failed to read pdf: %v", err)
+	}
+	return accessibilitySummary(ctx), nil
+}
+
+// compareAccessibility lists what the source had and the output no longer has
+func compareAccessibility(before, after AccessibilityInfo) AccessibilityReport {
+	r := AccessibilityReport{Before: before, After: after, Issues: []string{}}
+	if before.Tagged && !after.Tagged {
+		r.Issues = append(r.Issues, "document is no longer tagged")
+	} else if before.StructTree && !after.StructTree {
+		r.Issues = append(r.Issues, "structure tree was removed")
+	}
+	if before.Lang != "" && after.Lang != before.Lang {
+		r.Issues = append(r.Issues, fmt.Sprintf("document language %q was lost", before.Lang))
+	}
+	if before.DisplayDocTitle && !after.DisplayDocTitle {
+		r.Issues = append(r.Issues, "viewer no longer displays the document title")
+	}
+	if after.FiguresWithAlt < before.FiguresWithAlt {
+		r.Issues = append(r.Issues, fmt.Sprintf("%d figures lost their alternate text", before.FiguresWithAlt-after.FiguresWithAlt))
+	}
+	r.Preserved = len(r.Issues) == 0
+	return r
+}
+
+// accessibilitySummary inspects the catalog and structure tree of a document
+func accessibilitySummary(ctx *model.Context) AccessibilityInfo {
+	var info AccessibilityInfo
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return info
+	}
+
+	marked := false
+	if o, found := catalog.Find("MarkInfo"); found {
+		if d, err := ctx.DereferenceDict(o); err == nil && d != nil {
+			if m := d.BooleanEntry("Marked"); m != nil {
+				marked = *m
+			}
+		}
+	}
+	if o, found := catalog.Find("Lang"); found {
+		info.Lang, _ = ctx.DereferenceText(o)
+	}
+	if o, found := catalog.Find("ViewerPreferences"); found {
+		if d, err := ctx.DereferenceDict(o); err == nil && d != nil {
+			if b := d.BooleanEntry("DisplayDocTitle"); b != nil {
+				info.DisplayDocTitle = *b
+			}
+		}
+	}
+
+	if o, found := catalog.Find("StructTreeRoot"); found {
+		if root, err := ctx.DereferenceDict(o); err == nil && root != nil {
+			info.StructTree = true
+			roles := map[string]string{}
+			if rm, found := root.Find("RoleMap"); found {
+				if d, err := ctx.DereferenceDict(rm); err == nil {
+					for k, v := range d {
+						if n, ok := v.(types.Name); ok {
+							roles[k] = string(n)
+						}
+					}
+				}
+			}
+			if k, found := root.Find("K"); found {
+				countFigures(ctx, k, roles, map[int]bool{}, &info)
+			}
+		}
+	}
+	info.Tagged = marked && info.StructTree
+	return info
+}
+
+// countFigures walks the structure tree below o and counts Figure elements with and
+// without alternate text. Custom structure types are resolved through the role map.
+func countFigures(ctx *model.Context, o types.Object, roles map[string]string, seen map[int]bool, info *AccessibilityInfo) {
+	if ir, ok := o.(types.IndirectRef); ok {
+		if seen[ir.ObjectNumber.Value()] {
+			return
+		}
+		seen[ir.ObjectNumber.Value()] = true
+	}
+	o, err := ctx.Dereference(o)
+	if err != nil || o == nil {
+		return
+	}
+
+	switch obj := o.(type) {
+	case types.Array:
+		for _, kid := range obj {
+			countFigures(ctx, kid, roles, seen, info)
+		}
+	case types.Dict:
+		// Marked-content and object references are leaves
+		if t := obj.Type(); t != nil && (*t == "MCR" || *t == "OBJR") {
+			return
+		}
+		if s := obj.NameEntry("S"); s != nil {
+			role := *s
+			for i := 0; i < 10; i++ {
+				mapped, ok := roles[role]
+				if !ok || mapped == role {
+					break
+				}
+				role = mapped
+			}
+			if role == "Figure" {
+				info.Figures++
+				if hasAltText(ctx, obj) {
+					info.FiguresWithAlt++
+				}
+			}
+		}
+		if k, found := obj.Find("K"); found {
+			countFigures(ctx, k, roles, seen, info)
+		}
+	}
+}
+
+func hasAltText(ctx *model.Context, d types.Dict) bool {
+	for _, key := range []string{"Alt", "ActualText"} {
+		if o, found := d.Find(key); found {
+			if s, err := ctx.DereferenceText(o); err == nil && strings.TrimSpace(s) != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func infoText(ctx *model.Context, d types.Dict, key string) string {
+	o, found := d.Find(key)
+	if !found {
+		return ""
+	}
+	s, err := ctx.DereferenceText(o)
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+// restoreAccessibility copies the document-level accessibility entries of the source
+// catalog that writing output dropped: the tagged flag, the language and the title
+// display preference. A lost structure tree cannot be rebuilt and is left to the report.
+func restoreAccessibility(sourcePath, outputPath string) error {
+	src, err := api.ReadContextFile(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to read pdf: %v", err)
+	}
+	before := accessibilitySummary(src)
+	if !before.Tagged && before.Lang == "" && !before.DisplayDocTitle {
+		return nil
+	}
+
+	ctx, err := api.ReadContextFile(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read pdf: %v", err)
+	}
+	after := accessibilitySummary(ctx)
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	if before.Tagged && after.StructTree && !after.Tagged {
+		catalog.Update("MarkInfo", types.Dict{"Marked": types.Boolean(true)})
+		changed = true
+	}
+	if before.Lang != "" && after.Lang == "" {
+		if lang, err := pdfTextString(before.Lang); err == nil {
+			catalog.Update("Lang", lang)
+			changed = true
+		}
+	}
+	if before.DisplayDocTitle && !after.DisplayDocTitle {
+		prefs := types.Dict{}
+		if o, found := catalog.Find("ViewerPreferences"); found {
+			if d, err := ctx.DereferenceDict(o); err == nil && d != nil {
+				prefs = d
+			}
+		}
+		prefs.Update("DisplayDocTitle", types.Boolean(true))
+		if _, found := catalog.Find("ViewerPreferences"); !found {
+			catalog.Insert("ViewerPreferences", prefs)
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return api.WriteContextFile(ctx, outputPath)
+}
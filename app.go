@@ -3,32 +3,38 @@ package main
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"image"
-	"image/png"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	goruntime "runtime"
+	"strconv"
 	"strings"
+	"sync"
 
-	_ "image/jpeg"
+	"CapGo/pkg/stamper"
 
-	"github.com/nfnt/resize"
 	"github.com/pdfcpu/pdfcpu/pkg/api"
-	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
-// GetFile reads a file and returns its contents
+// GetFile reads a file and returns its contents. A .pdf file that holds something else,
+// such as the HTML error page of a portal, is refused; see GetTypedFile.
 func (a *App) GetFile(path string) ([]byte, error) {
 	fmt.Printf("Backend: GetFile called for path: %s\n", path)
+	if err := a.ensureLocal(path); err != nil {
+		return nil, err
+	}
 	data, err := os.ReadFile(path)
 	if err != nil {
 		fmt.Printf("Backend: Error reading file: %v\n", err)
-		return nil, err
+		return nil, classifyFileError("read", path, err)
+	}
+	if t := fileType(path, data); t.Kind != FileKindPDF && strings.EqualFold(filepath.Ext(path), ".pdf") {
+		return nil, notPDFError(path, t)
 	}
 	fmt.Printf("Backend: Read %d bytes\n", len(data))
 	return data, nil
@@ -36,21 +42,62 @@ func (a *App) GetFile(path string) ([]byte, error) {
 
 // App struct
 type App struct {
-	ctx context.Context
+	ctx      context.Context
+	mu       sync.Mutex
+	settings Settings
+	saved    Settings // settings as last read from or written to the file, see saveSettings
+	preview  PreviewScale
+	window   WindowInfo    // the window this process hosts
+	windows  windowManager // windows opened from this one
+	menu     appMenu
+	doc      DocumentState
+	hidden   bool // the window is hidden, e.g. in menu-bar mode
+	badge    int
+	// onEmit sees every event, also before the app has started; tests listen with it
+	onEmit func(name string, data ...interface{})
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{}
+	settings := loadSettings()
+	if isLanguage(settings.Language) {
+		setLanguage(settings.Language)
+	}
+	return &App{settings: settings, saved: settings, window: windowFromArgs(os.Args[1:])}
 }
 
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
+	a.hidden = a.window.Kind == WindowMain && a.GetQuickStamp().MenuBarMode
+	runtime.OnFileDrop(ctx, a.onFileDrop)
+	if a.window.Kind == WindowMain {
+		a.scheduleRetention(ctx)
+	}
+}
+
+// shutdown is called when the window is closing
+func (a *App) shutdown(ctx context.Context) {
+	a.closeChildWindows()
+}
+
+// emit sends an event to the frontend; it is a no-op until the app has started.
+// Events must be described in eventCatalog.
+func (a *App) emit(name string, data ...interface{}) {
+	if _, ok := eventSpecs[name]; !ok {
+		fmt.Printf("Backend: Emitting %s, which is not in the event catalog\n", name)
+	}
+	if a.onEmit != nil {
+		a.onEmit(name, data...)
+	}
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, name, data...)
+	}
 }
 
-// SelectFile opens a file dialog and returns the selected path
+// SelectFile opens a file dialog and returns the selected path. A PDF that was picked
+// goes on the recent files list.
 func (a *App) SelectFile(title string, filter string) (string, error) {
 	selection, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
 		Title: title,
@@ -61,6 +108,9 @@ func (a *App) SelectFile(title string, filter string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if selection != "" {
+		addRecentFile(selection)
+	}
 	return selection, nil
 }
 
@@ -82,203 +132,435 @@ func (a *App) SelectFiles(title string, filter string) ([]string, error) {
 func (a *App) OpenFile(path string) error {
 	// Clean path
 	path = filepath.Clean(path)
-	// Use 'open' command on macOS
-	return exec.Command("open", path).Run()
+	if _, err := os.Stat(path); err != nil {
+		return classifyFileError("read", path, err)
+	}
+
+	// Try the platform's openers in order, skipping the ones that are not installed
+	var lastErr error
+	for _, args := range openCommands(path) {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		if args[0] == "explorer" {
+			// explorer exits with status 1 even when it opened the file
+			lastErr = cmd.Start()
+		} else {
+			lastErr = cmd.Run()
+		}
+		if lastErr == nil {
+			return nil
+		}
+		fmt.Printf("Backend: %s could not open %s: %v\n", args[0], path, lastErr)
+	}
+
+	if lastErr == nil {
+		return fmt.Errorf("no application found to open files on %s", goruntime.GOOS)
+	}
+	if readQuarantine(path).Quarantined {
+		return fmt.Errorf("failed to open %s: the file is quarantined as a download, clear the quarantine flag and try again: %v", filepath.Base(path), lastErr)
+	}
+	return fmt.Errorf("failed to open %s: %v", filepath.Base(path), lastErr)
 }
 
-// StampInfo represents the metadata for a single stamp
+// openCommands returns the commands that open a file with its default application, in
+// order of preference
+func openCommands(path string) [][]string {
+	switch goruntime.GOOS {
+	case "darwin":
+		return [][]string{{"open", path}}
+	case "windows":
+		var cmds [][]string
+		// cmd expands %VAR% and ^ even inside quotes, so such paths skip start
+		if !strings.ContainsAny(path, "%^") {
+			cmds = append(cmds, []string{"cmd", "/c", "start", "", path})
+		}
+		return append(cmds,
+			[]string{"rundll32", "url.dll,FileProtocolHandler", path},
+			[]string{"explorer", path})
+	}
+	return [][]string{
+		{"xdg-open", path},
+		{"gio", "open", path},
+		{"kde-open5", path},
+		{"gnome-open", path},
+	}
+}
+
+// StampInfo represents the metadata for a single stamp: a stamper.Stamp with how the
+// app measured and selected it
 type StampInfo struct {
-	Image   string  `json:"image"`
-	X       float64 `json:"x"`
-	Y       float64 `json:"y"`
-	Width   float64 `json:"width"`
-	Height  float64 `json:"height"`
-	PageNum int     `json:"pageNum"`
+	stamper.Stamp
+	// Pages puts the stamp on every page of a selection such as "all", "2-10", "odd"
+	// or "last" instead of on PageNum, see stamper.ParsePageSelection
+	Pages   string `json:"pages,omitempty"`
+	GroupID string `json:"groupId,omitempty"`
+	// TemplateID names the saved stamp this one was created from; it is used to
+	// detect the same stamp being applied twice
+	TemplateID string `json:"templateId,omitempty"`
+	// Units of X, Y, Width and Height, see StampUnitsPoints; Preview optionally holds the
+	// render scale the coordinates were measured at
+	Units   string        `json:"units,omitempty"`
+	Preview *PreviewScale `json:"preview,omitempty"`
+	// Origin is the corner Y is measured from, see StampOriginTopLeft
+	Origin string `json:"origin,omitempty"`
+}
+
+// StampOptions holds optional settings for a stamping run
+type StampOptions struct {
+	Groups    []StampGroup `json:"groups"`
+	MaxSizeMB float64      `json:"maxSizeMB,omitempty"` // shrink images until the output fits, 0 to disable
+	// Metadata is written into the output, e.g. to set the language and title display
+	Metadata *DocumentMetadata `json:"metadata,omitempty"`
+	// Password opens an encrypted PDF, see IsEncrypted. The output is encrypted again
+	// with the same password and permissions.
+	Password string `json:"password,omitempty"`
+	// Flatten burns the stamps into the page content. By default they are added as
+	// watermarks that RemoveCapGoWatermarks and other PDF tools can take off again.
+	Flatten bool `json:"flatten,omitempty"`
+	// SaveAs asks the user where to save the output, starting from the usual output
+	// path. The stamp functions return "" when the user cancels.
+	SaveAs bool `json:"saveAs,omitempty"`
 }
 
 // StampPDF stamps multiple images onto a PDF and returns the final file path
 func (a *App) StampPDF(pdfPath string, stamps []StampInfo) (string, error) {
+	return a.StampPDFWithOptions(pdfPath, stamps, StampOptions{})
+}
+
+// StampPDFWithOptions stamps multiple images onto a PDF using the given options and returns the final file path
+func (a *App) StampPDFWithOptions(pdfPath string, stamps []StampInfo, opts StampOptions) (string, error) {
+	return a.stampPDF(pdfPath, stamps, opts, nil)
+}
+
+// stampPDF is StampPDFWithOptions reporting its stages to progress, which can also
+// cancel it
+func (a *App) stampPDF(pdfPath string, stamps []StampInfo, opts StampOptions, progress *jobProgress) (_ string, err error) {
 	// Clean paths
 	pdfPath = filepath.Clean(pdfPath)
+	defer recoverDamaged(pdfPath, &err)
 
 	if len(stamps) == 0 {
 		return pdfPath, nil
 	}
-
-	// Final Output path: Downloads folder
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("could not get home directory: %v", err)
+	defer a.startJob("stamp")()
+	if err := a.runBeforeHooks("stamp", pdfPath); err != nil {
+		return "", err
 	}
+
+	// Name the output after the source without the _capgo of an earlier run
 	ext := filepath.Ext(pdfPath)
 	baseName := strings.TrimSuffix(filepath.Base(pdfPath), ext)
-
-	// Create a clean base name (remove previous _capgo if present)
-	cleanBase := strings.Split(baseName, "_capgo")[0]
-
-	// Generate unique name
-	outputPath := filepath.Join(homeDir, "Downloads", fmt.Sprintf("%s_capgo%s", cleanBase, ext))
-	counter := 1
-	for {
-		if _, err := os.Stat(outputPath); os.IsNotExist(err) {
-			break
+	outputPath, err := a.outputPath(filepath.Join(filepath.Dir(pdfPath), strings.Split(baseName, "_capgo")[0]+ext), "_capgo")
+	if err != nil {
+		return "", err
+	}
+	if samePath(outputPath, pdfPath) {
+		// Stamping an earlier output again in overwrite mode
+		outputPath = uniquePath(outputPath)
+	}
+	if opts.SaveAs {
+		outputPath, err = a.askOutputPath(pdfPath, outputPath)
+		if err != nil || outputPath == "" {
+			return "", err
 		}
-		outputPath = filepath.Join(homeDir, "Downloads", fmt.Sprintf("%s_capgo (%d)%s", cleanBase, counter, ext))
-		counter++
 	}
 
-	currentInput := pdfPath
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return "", err
+	}
+	source, err := unlockPDF(pdfPath, opts.Password)
+	if err != nil {
+		return "", err
+	}
+	defer source.close()
 
-	// Process each stamp
-	for i, stamp := range stamps {
-		// Output for this step
-		var stepOutput string
-		if i == len(stamps)-1 {
-			stepOutput = outputPath
-		} else {
-			tempFile, err := os.CreateTemp("", "intermediate_*.pdf")
-			if err != nil {
-				return "", fmt.Errorf("failed to create intermediate pdf: %v", err)
-			}
-			tempFile.Close()
-			stepOutput = tempFile.Name()
-			defer os.Remove(stepOutput)
-		}
+	result, err := a.withTimeout(progress.context(), "stamping", source.Path, outputPath, func(in, out string) (interface{}, error) {
+		return a.stampPDFTo(in, out, stamps, opts, progress)
+	})
+	if err != nil {
+		return "", err
+	}
+	applied := result.([]StampInfo)
+	if err := progress.stage(StageFinishing); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
 
-		// Log page dimensions for debugging
-		dims, err := api.PageDimsFile(currentInput)
+	if opts.MaxSizeMB > 0 {
+		res, err := optimizeToSize(outputPath, outputPath, int64(opts.MaxSizeMB*1024*1024))
 		if err != nil {
-			return "", fmt.Errorf("failed to get page dimensions for %s: %v", currentInput, err)
-		}
-		if len(dims) == 0 {
-			return "", fmt.Errorf("no page dimensions found for %s", currentInput)
+			return "", err
 		}
-		// Assuming all pages have the same dimensions, or we only care about the first page's dimensions
-		// for coordinate calculations.
-		pdfHeight := dims[0].Height
-
-		// Process image
-		var srcImage image.Image
-		if strings.Contains(stamp.Image, ";base64,") {
-			parts := strings.Split(stamp.Image, ",")
-			if len(parts) < 2 {
-				return "", fmt.Errorf("invalid base64 data format for stamp %d", i)
-			}
-			data, err := base64.StdEncoding.DecodeString(parts[1])
-			if err != nil {
-				return "", fmt.Errorf("failed to decode base64 image %d: %v", i, err)
-			}
-			srcImage, _, err = image.Decode(bytes.NewReader(data))
-			if err != nil {
-				return "", fmt.Errorf("failed to decode image %d from base64: %v", i, err)
-			}
-		} else {
-			imagePath := filepath.Clean(stamp.Image)
-			file, err := os.Open(imagePath)
-			if err != nil {
-				return "", fmt.Errorf("failed to open image file %d: %v", i, err)
-			}
-			srcImage, _, err = image.Decode(file)
-			file.Close()
-			if err != nil {
-				return "", fmt.Errorf("failed to decode image file %d: %v", i, err)
-			}
+		a.emit(EventStampSizeTarget, res)
+	}
+
+	if opts.Metadata != nil {
+		if err := applyDocumentMetadata(outputPath, outputPath, *opts.Metadata); err != nil {
+			os.Remove(outputPath)
+			return "", err
 		}
+	}
 
-		// Preserve Aspect Ratio (Equivalent to object-fit: contain)
-		imgWidth := float64(srcImage.Bounds().Dx())
-		imgHeight := float64(srcImage.Bounds().Dy())
+	// Keep the document tagged for assistive technology and warn about anything that was lost
+	if err := restoreAccessibility(source.Path, outputPath); err != nil {
+		fmt.Printf("Backend: Failed to restore accessibility entries for %s: %v\n", outputPath, err)
+	}
+	if report, err := a.CheckAccessibility(source.Path, outputPath); err == nil && !report.Preserved {
+		a.emit(EventStampAccessibility, report)
+	}
+	if err := a.finishOutput(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	if err := source.lock(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	a.recordStamps(pdfPath, outputPath, applied, opts.Flatten)
+	a.warnOutsideSafeArea(pdfPath, source.Path, stamps)
+	a.runAfterHooks("stamp", pdfPath, outputPath)
 
-		targetRatio := stamp.Width / stamp.Height
-		imgRatio := imgWidth / imgHeight
+	return outputPath, nil
+}
 
-		var finalW, finalH float64
-		var offX, offY float64 // Offset within the stamp.Width/Height box
+// askOutputPath shows the save dialog for the output of pdfPath, suggesting path. It
+// returns "" when the user cancels. The dialog asks before replacing another file.
+func (a *App) askOutputPath(pdfPath, path string) (string, error) {
+	if a.ctx == nil {
+		return "", fmt.Errorf("the save dialog needs the app window")
+	}
+	chosen, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:            tr("Save Stamped PDF"),
+		DefaultDirectory: filepath.Dir(path),
+		DefaultFilename:  filepath.Base(path),
+		Filters:          []runtime.FileFilter{{DisplayName: "PDF", Pattern: "*.pdf"}},
+	})
+	if err != nil || chosen == "" {
+		return "", err
+	}
+	chosen = filepath.Clean(chosen)
+	if !strings.EqualFold(filepath.Ext(chosen), ".pdf") {
+		chosen += ".pdf"
+	}
+	if samePath(chosen, pdfPath) {
+		return "", fmt.Errorf("the stamped PDF cannot replace the document it is made from")
+	}
+	if err := probeWritable(filepath.Dir(chosen)); err != nil {
+		return "", err
+	}
+	return chosen, nil
+}
 
-		if imgRatio > targetRatio {
-			// Image is wider than the target box aspect ratio, so its width will fill the box
-			finalW = stamp.Width
-			finalH = stamp.Width / imgRatio
-			offX = 0
-			offY = (stamp.Height - finalH) / 2
-		} else {
-			// Image is taller than or equal to the target box aspect ratio, so its height will fill the box
-			finalH = stamp.Height
-			finalW = stamp.Height * imgRatio
-			offX = (stamp.Width - finalW) / 2
-			offY = 0
-		}
+// stampPDFTo applies the stamps to pdfPath and writes the result to outputPath.
+// It returns the stamps as they were placed, after groups, numbering and clamping.
+func (a *App) stampPDFTo(pdfPath, outputPath string, stamps []StampInfo, opts StampOptions, progress *jobProgress) ([]StampInfo, error) {
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return nil, err
+	}
 
-		// HD Resizing (4x for sharpness)
-		qualityFactor := 4.0
-		resizedImg := resize.Resize(uint(finalW*qualityFactor), uint(finalH*qualityFactor), srcImage, resize.Lanczos3)
+	// Bring coordinates measured in the preview to PDF points
+	stamps, err := a.NormalizeStampCoordinates(stamps)
+	if err != nil {
+		return nil, err
+	}
 
-		// Create temp PNG for watermark
-		imgTemp, err := os.CreateTemp("", "stamp_*.png")
+	// Apply shared group transforms before any placement logic
+	stamps, err = resolveStampGroups(stamps, opts.Groups)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in numbering placeholders, consuming one number per sequence for this run
+	stamps, err = expandStampPlaceholders(stamps)
+	if err != nil {
+		return nil, err
+	}
+	stamps = a.expandDatePlaceholders(pdfPath, stamps)
+
+	// Copy stamps with a page selection to every page it selects, after the placeholders
+	// so that all copies show the same number
+	stamps, err = expandStampPagesFile(pdfPath, stamps)
+	if err != nil {
+		return nil, err
+	}
+	stamps, err = a.toPageCoordinates(pdfPath, stamps)
+	if err != nil {
+		return nil, err
+	}
+
+	// Move stamps inside the safe area when auto-clamping is enabled
+	if area := a.GetSafeArea(); area.Mode == SafeAreaClamp {
+		dims, err := stamper.VisiblePageDimsFile(pdfPath)
 		if err != nil {
-			return "", fmt.Errorf("failed to create temp stamp %d: %v", i, err)
+			return nil, fmt.Errorf("failed to get page dimensions for %s: %v", pdfPath, err)
 		}
-		if err := png.Encode(imgTemp, resizedImg); err != nil {
-			imgTemp.Close()
-			return "", fmt.Errorf("failed to encode stamp %d: %v", i, err)
-		}
-		imgTemp.Close()
-		defer os.Remove(imgTemp.Name())
-
-		// pdfcpu watermark description (Back to Bottom-Left origin)
-		// pos:bl = Bottom-Left origin
-		// off: x y = Offset from bottom-left (x=right, y=up)
-		// scale: factor abs = Absolute scaling relative to native points
-		scaleStr := fmt.Sprintf("%.4f abs", 1.0/qualityFactor)
-
-		// Calculate final X and Y coordinates for pdfcpu (bottom-left origin)
-		// stamp.X and stamp.Y are from top-left (browser coordinates)
-		// pdfcpu's Y increases upwards from the bottom.
-		// So, browser Y (top-down) needs to be converted to pdfcpu Y (bottom-up).
-		// The total height of the placed image is finalH.
-		// The browser Y coordinate (stamp.Y + offY) is the top edge of the placed image.
-		// To get the bottom edge from the bottom of the PDF: pdfHeight - (browser_Y + placed_image_height)
-		finalX := stamp.X + offX
-		finalY := pdfHeight - (stamp.Y + offY + finalH)
-
-		desc := fmt.Sprintf("pos:bl, off:%f %f, scale:%s, rot:0", finalX, finalY, scaleStr)
-
-		// Process staving (no log)
-
-		wm, err := api.ImageWatermark(imgTemp.Name(), desc, true, false, types.POINTS)
-		if err != nil {
-			return "", fmt.Errorf("failed to parse watermark %d details: %v", i, err)
+		clamped := make([]StampInfo, len(stamps))
+		for i, stamp := range stamps {
+			clamped[i] = stamp
+			if stamp.PageNum >= 1 && stamp.PageNum <= len(dims) {
+				clamped[i] = clampToSafeArea(stamp, dims[stamp.PageNum-1], area)
+			}
 		}
+		stamps = clamped
+	}
 
-		selectedPages := []string{fmt.Sprintf("%d", stamp.PageNum)}
-		err = api.AddWatermarksFile(currentInput, stepOutput, selectedPages, wm, nil)
-		if err != nil {
-			return "", fmt.Errorf("failed to add watermark %d: %v", i, err)
-		}
+	// All stamps go onto one in-memory copy of the document, which is written once,
+	// instead of rewriting the whole file for every stamp
+	if err := progress.stage(StageReading); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return nil, classifyFileError("read", pdfPath, err)
+	}
+	var ctx *model.Context
+	var conf *model.Configuration
+	err = withRepairFallback(pdfPath, func(c *model.Configuration) (err error) {
+		conf = c
+		conf.Cmd = model.ADDWATERMARKS
+		ctx, err = api.ReadValidateAndOptimize(bytes.NewReader(data), conf)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %v", err)
+	}
 
-		currentInput = stepOutput
+	if err := progress.stage(StageStamping); err != nil {
+		return nil, err
+	}
+	placed := make([]stamper.Stamp, len(stamps))
+	for i, stamp := range stamps {
+		placed[i] = stamp.Stamp
+	}
+	if err := stamper.Apply(ctx, placed, stamper.Options{Flatten: opts.Flatten}); err != nil {
+		return nil, err
 	}
 
-	return outputPath, nil
+	if err := progress.stage(StageWriting); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := api.Write(ctx, &out, conf); err != nil {
+		return nil, fmt.Errorf("failed to write pdf: %v", err)
+	}
+	if err := os.WriteFile(outputPath, out.Bytes(), 0644); err != nil {
+		return nil, classifyFileError("write", outputPath, err)
+	}
+
+	return stamps, nil
+}
+
+// recordStamps stores the history record of a stamped document. A failure only
+// costs the record, so it is logged rather than failing the stamping run.
+func (a *App) recordStamps(source, output string, stamps []StampInfo, flattened bool) {
+	added := historyStamps(stamps)
+	for i := range added {
+		added[i].Flattened = flattened
+	}
+	all := append(inheritedStamps(source), added...)
+	if err := recordHistory(source, output, "stamp", all); err != nil {
+		fmt.Printf("Backend: Failed to record history for %s: %v\n", output, err)
+	}
 }
 
 // UpdatePDFPages creates a new PDF with the specified sequence of pages from the source PDF
 func (a *App) UpdatePDFPages(pdfPath string, pages []string) (string, error) {
+	return a.UpdatePDFPagesWithPassword(pdfPath, pages, "")
+}
+
+// UpdatePDFPagesWithPassword is UpdatePDFPages for encrypted PDFs; the new file keeps
+// the password and permissions of the source
+func (a *App) UpdatePDFPagesWithPassword(pdfPath string, pages []string, password string) (_ string, err error) {
 	pdfPath = filepath.Clean(pdfPath)
+	defer recoverDamaged(pdfPath, &err)
 	// Create a unique temp file name to avoid collisions
-	tempDir := os.TempDir()
-	outputPath := filepath.Join(tempDir, fmt.Sprintf("capgo_mod_%d_%s", os.Getpid(), filepath.Base(pdfPath)))
+	outputPath := tempOutputPath("mod", pdfPath)
 
 	// Ensure we don't overwrite if it somehow exists
 	if _, err := os.Stat(outputPath); err == nil {
 		os.Remove(outputPath)
 	}
 
-	err := api.CollectFile(pdfPath, outputPath, pages, nil)
+	source, err := unlockPDF(pdfPath, password)
+	if err != nil {
+		return "", err
+	}
+	defer source.close()
+
+	_, err = a.withTimeout(context.Background(), "page update", source.Path, outputPath, func(in, out string) (interface{}, error) {
+		return nil, withRepairFallback(in, func(conf *model.Configuration) error {
+			return api.CollectFile(in, out, pages, conf)
+		})
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to collect pages: %v", err)
 	}
+	if err := restoreAccessibility(source.Path, outputPath); err != nil {
+		fmt.Printf("Backend: Failed to restore accessibility entries for %s: %v\n", outputPath, err)
+	}
+	if err := a.finishOutput(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	if err := source.lock(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+
+	return outputPath, nil
+}
+
+// RotatePages creates a copy of the PDF with the selected pages turned clockwise by a
+// multiple of 90 degrees; no pages selects all of them
+func (a *App) RotatePages(pdfPath string, pages []string, degrees int) (_ string, err error) {
+	pdfPath = filepath.Clean(pdfPath)
+	defer recoverDamaged(pdfPath, &err)
+	rotation, err := stamper.NormalizeRotation(degrees)
+	if err != nil {
+		return "", err
+	}
+	if rotation == 0 {
+		return "", fmt.Errorf("rotation of %d degrees leaves the pages unchanged", degrees)
+	}
+	if len(pages) == 0 {
+		pages = nil
+	} else {
+		pageCount, err := api.PageCountFile(pdfPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read pdf: %v", err)
+		}
+		selected, err := api.PagesForPageSelection(pageCount, pages, false, false)
+		if err != nil {
+			return "", fmt.Errorf("invalid page selection: %v", err)
+		}
+		if len(selected) == 0 {
+			return "", fmt.Errorf("none of the selected pages exist in the %d page document", pageCount)
+		}
+	}
+
+	outputPath := tempOutputPath("rot", pdfPath)
+	if _, err := os.Stat(outputPath); err == nil {
+		os.Remove(outputPath)
+	}
 
+	if err := a.runBeforeHooks("rotate", pdfPath); err != nil {
+		return "", err
+	}
+	_, err = a.withTimeout(context.Background(), "rotation", pdfPath, outputPath, func(in, out string) (interface{}, error) {
+		return nil, withRepairFallback(in, func(conf *model.Configuration) error {
+			return api.RotateFile(in, out, rotation, pages, conf)
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to rotate pages: %v", err)
+	}
+	if err := a.finishOutput(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	a.runAfterHooks("rotate", pdfPath, outputPath)
 	return outputPath, nil
 }
 
@@ -338,7 +620,7 @@ func (a *App) CheckForUpdates() UpdateResult {
 	}
 
 	if release.TagName != CurrentAppVersion {
-		return UpdateResult{
+		result := UpdateResult{
 			UpdateAvailable: true,
 			LatestVersion:   release.TagName,
 			ReleaseUrl:      release.HtmlUrl,
@@ -346,6 +628,8 @@ func (a *App) CheckForUpdates() UpdateResult {
 			CurrentVersion:  CurrentAppVersion,
 			DownloadUrl:     downloadUrl,
 		}
+		a.emit(EventUpdateAvailable, result)
+		return result
 	}
 
 	return UpdateResult{
@@ -357,8 +641,21 @@ func (a *App) CheckForUpdates() UpdateResult {
 
 // DownloadUpdate downloads the update file to the Downloads folder
 func (a *App) DownloadUpdate(url string) (string, error) {
-	resp, err := http.Get(url)
+	return a.downloadUpdate(url, nil)
+}
+
+// downloadUpdate is DownloadUpdate with a progress that can cancel it
+func (a *App) downloadUpdate(url string, progress *jobProgress) (string, error) {
+	defer a.startJob("download")()
+	req, err := http.NewRequestWithContext(progress.context(), http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
+		if cerr := progress.cancelled(); cerr != nil {
+			return "", cerr
+		}
 		return "", err
 	}
 	defer resp.Body.Close()
@@ -368,7 +665,7 @@ func (a *App) DownloadUpdate(url string) (string, error) {
 		return "", err
 	}
 
-	downloadPath := filepath.Join(homeDir, "Downloads", fmt.Sprintf("CapGo-Update-%d.dmg", os.Getpid()))
+	downloadPath := filepath.Join(homeDir, "Downloads", fmt.Sprintf("%s%d.dmg", updateDownloadPrefix, os.Getpid()))
 	out, err := os.Create(downloadPath)
 	if err != nil {
 		return "", err
@@ -376,10 +673,18 @@ func (a *App) DownloadUpdate(url string) (string, error) {
 	defer out.Close()
 
 	_, err = out.ReadFrom(resp.Body)
+	if cerr := progress.cancelled(); err != nil && cerr != nil {
+		// Leave no partial installer behind
+		out.Close()
+		os.Remove(downloadPath)
+		return "", cerr
+	}
 	if err != nil {
+		a.jobFinished("download", tr("Update download failed"), true)
 		return "", err
 	}
 
+	a.jobFinished("download", tr("CapGo update downloaded"), false)
 	return downloadPath, nil
 }
 
@@ -398,54 +703,27 @@ func (a *App) InstallUpdate(dmgPath string) error {
 
 	appBundlePath := filepath.Dir(filepath.Dir(filepath.Dir(exePath))) // Path/to/CapGo.app
 
-	// Create a shell script to handle the swap after this process quits
-	scriptContent := fmt.Sprintf(`#!/bin/bash
-PID=%d
-DMG_PATH="%s"
-DEST_APP="%s"
-MOUNT_POINT="/tmp/CapGo_Update_Mnt_%d"
-
-# 1. Wait for the main app to terminate
-while kill -0 $PID 2>/dev/null; do sleep 0.5; done
-
-# 2. Mount the DMG
-mkdir -p "$MOUNT_POINT"
-hdiutil attach "$DMG_PATH" -mountpoint "$MOUNT_POINT" -nobrowse -readonly
-
-# 3. Swap the Application
-SOURCE_APP="$MOUNT_POINT/CapGo.app"
-
-if [ -d "$SOURCE_APP" ]; then
-    echo "Replacing App..."
-    rm -rf "$DEST_APP"
-    cp -R "$SOURCE_APP" "$DEST_APP"
-    
-    # 3.5. Fix Permissions (Quarantine)
-    xattr -cr "$DEST_APP"
-
-    # 4. Relaunch
-    open "$DEST_APP"
-else
-    echo "Update failed: App not found in DMG"
-fi
-
-# 5. Cleanup
-hdiutil detach "$MOUNT_POINT" -force
-rm -rf "$MOUNT_POINT"
-`, os.Getpid(), dmgPath, appBundlePath, os.Getpid())
-
-	scriptPath := filepath.Join(os.TempDir(), "capgo_updater.sh")
-	if err := os.WriteFile(scriptPath, []byte(scriptContent), 0755); err != nil {
+	// The swap is done by a copy of this binary running in updater mode, so it can
+	// replace the bundle after this process quits. Paths travel as plain arguments and
+	// never pass through a shell.
+	helper, err := copyUpdaterBinary(exePath)
+	if err != nil {
+		return err
+	}
+	logFile, err := os.Create(filepath.Join(filepath.Dir(helper), "update.log"))
+	if err != nil {
 		return err
 	}
+	defer logFile.Close()
 
-	// Run the updater script detached
-	cmd := exec.Command("sh", scriptPath)
+	cmd := exec.Command(helper, updaterArg, strconv.Itoa(os.Getpid()), filepath.Clean(dmgPath), appBundlePath)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
 	if err := cmd.Start(); err != nil {
 		return err
 	}
 
-	// Quit the app immediately so the script can overwrite it
+	// Quit the app immediately so the updater can overwrite it
 	runtime.Quit(a.ctx)
 	return nil
 }
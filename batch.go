@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// BatchResult is the outcome of a mail merge or certificate job. A failing item does
+// not stop the others; its error is listed in Failed and RetryFailed runs just those
+// items again.
+type BatchResult struct {
+	JobID   string       `json:"jobId"`
+	Outputs []string     `json:"outputs"` // files produced, in item order
+	Failed  []BatchError `json:"failed"`
+}
+
+// BatchError is the error of one batch item
+type BatchError struct {
+	Index int    `json:"index"` // 0-based item index
+	Item  string `json:"item"`  // output file name of the item
+	Error string `json:"error"`
+}
+
+// runBatch calls fn for every index from 0 to total-1 on up to workers goroutines.
+// Every index is processed even when some fail; the returned slice holds the error
+// of each index, nil for the ones that succeeded.
+func runBatch(total, workers int, fn func(i int) error) []error {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > total {
+		workers = total
+	}
+
+	var (
+		next int32 = -1
+		errs       = make([]error, total)
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt32(&next, 1))
+				if i >= total {
+					return
+				}
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// runMergeJobs runs the pending items of a merge job through stamp, recording each
+// outcome in the checkpoint and emitting progress as event
+func (a *App) runMergeJobs(run *batchRun, jobs []mergeJob, workers int, event string, stamp func(r int) error) BatchResult {
+	todo := run.pending()
+	done := int32(len(jobs) - len(todo))
+	errs := runBatch(len(todo), workers, func(i int) error {
+		r := todo[i]
+		progress := MailMergeProgress{JobID: run.cp.ID, Total: len(jobs), Output: jobs[r].output}
+		if err := stamp(r); err != nil {
+			progress.Current = int(atomic.LoadInt32(&done))
+			progress.Error = err.Error()
+			a.emit(event, progress)
+			return err
+		}
+		if err := run.complete(r); err != nil {
+			fmt.Printf("Backend: Failed to save checkpoint: %v\n", err)
+		}
+		progress.Current = int(atomic.AddInt32(&done, 1))
+		a.emit(event, progress)
+		return nil
+	})
+
+	for i, err := range errs {
+		if err != nil {
+			r := todo[i]
+			fmt.Printf("Backend: Batch job %s item %d failed: %v\n", run.cp.ID, r+1, err)
+			if err := run.fail(BatchError{Index: r, Item: filepath.Base(jobs[r].output), Error: err.Error()}); err != nil {
+				fmt.Printf("Backend: Failed to save checkpoint: %v\n", err)
+			}
+		}
+	}
+	return run.result()
+}
+
+// batchWorkersFor returns the worker pool size for a batch applying the given stamps.
+// Numbering sequences must hand out numbers in row order, so those runs stay sequential.
+func (a *App) batchWorkersFor(stamps []StampInfo) int {
+	for _, s := range stamps {
+		if s.Kind == StampKindText && seqPlaceholder.MatchString(s.Text) {
+			return 1
+		}
+	}
+	return a.batchWorkerCount()
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"CapGo/pkg/stamper"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Placement anchors of BatchStampPDFs. The stamp layout is moved as a whole so its
+// bounding box sits in that corner of the page, inside the safe area margin.
+const (
+	PlacementAsPlaced    = "" // keep the coordinates of the stamps
+	PlacementTopLeft     = "top-left"
+	PlacementTopRight    = "top-right"
+	PlacementBottomLeft  = "bottom-left"
+	PlacementBottomRight = "bottom-right"
+	PlacementCenter      = "center"
+)
+
+// Placement pages, given after a colon as in "bottom-right:last". Without one the
+// stamps stay on their own pages.
+const (
+	PlacementFirstPage = "first"
+	PlacementLastPage  = "last"
+	PlacementAllPages  = "all"
+)
+
+// BatchStampItem is the outcome of stamping one file with BatchStampPDFs
+type BatchStampItem struct {
+	Source string `json:"source"`
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchStampProgress is sent after every file of BatchStampPDFs
+type BatchStampProgress struct {
+	JobID   string         `json:"jobId,omitempty"` // set for StartBatchStampPDFs
+	Current int            `json:"current"`
+	Total   int            `json:"total"`
+	Item    BatchStampItem `json:"item"`
+}
+
+// placement is a parsed placement string
+type placement struct {
+	anchor string
+	pages  string
+}
+
+// BatchStampPDFs applies the same stamp layout to many PDFs, e.g. "bottom-right:last"
+// to stamp the bottom-right corner of every last page. Each file gets its own output
+// next to the other CapGo output; a file that fails does not stop the others. A .zip
+// among the files stands for the PDFs in it.
+func (a *App) BatchStampPDFs(pdfPaths []string, stamps []StampInfo, placementSpec string) ([]BatchStampItem, error) {
+	return a.batchStampPDFs(pdfPaths, stamps, placementSpec, nil)
+}
+
+// batchStampPDFs is BatchStampPDFs reporting every file to progress. Once the job is
+// cancelled the files not started yet fail with errCancelled.
+func (a *App) batchStampPDFs(pdfPaths []string, stamps []StampInfo, placementSpec string, progress *jobProgress) ([]BatchStampItem, error) {
+	if len(pdfPaths) == 0 {
+		return nil, fmt.Errorf("no documents given")
+	}
+	if len(stamps) == 0 {
+		return nil, fmt.Errorf("no stamps given")
+	}
+	place, err := parsePlacement(placementSpec)
+	if err != nil {
+		return nil, err
+	}
+	// Placement works in points, whatever the preview scale is when the files are done
+	stamps, err = a.NormalizeStampCoordinates(stamps)
+	if err != nil {
+		return nil, err
+	}
+	pdfPaths, zipped, err := expandZipInputs(pdfPaths)
+	if err != nil {
+		return nil, err
+	}
+	defer zipped.close()
+	defer a.startJob("batchstamp")()
+
+	// Outputs are named after the sources, so two sources with the same name must not
+	// pick their output name at the same time
+	workers := a.batchWorkersFor(stamps)
+	names := map[string]bool{}
+	for _, p := range pdfPaths {
+		name := strings.ToLower(filepath.Base(p))
+		if names[name] {
+			workers = 1
+		}
+		names[name] = true
+	}
+
+	items := make([]BatchStampItem, len(pdfPaths))
+	var done int32
+	errs := runBatch(len(pdfPaths), workers, func(i int) error {
+		items[i].Source = filepath.Clean(pdfPaths[i])
+		err := catchCrash(func() error {
+			if err := progress.cancelled(); err != nil {
+				return err
+			}
+			if _, err := os.Stat(items[i].Source); err != nil {
+				return classifyFileError("read", items[i].Source, err)
+			}
+			frame, err := a.pageFrameFor(items[i].Source, nil)
+			if err != nil {
+				return err
+			}
+			placed, err := placeStamps(items[i].Source, stamps, place, a.GetSafeArea().MarginMM*pointsPerMM, frame.offsets)
+			if err != nil {
+				return err
+			}
+			items[i].Output, err = a.stampPDF(items[i].Source, placed, StampOptions{}, progress.quiet())
+			return err
+		})
+		var crash *pdfCrash
+		if errors.As(err, &crash) {
+			err = damagedFileError(items[i].Source)
+		}
+		if err != nil {
+			items[i].Error = err.Error()
+		}
+		items[i].Source = zipped.source(items[i].Source)
+		current := int(atomic.AddInt32(&done, 1))
+		progress.update(StageStamping, current, len(items))
+		a.emit(EventBatchStampProgress, BatchStampProgress{JobID: progress.id(), Current: current, Total: len(items), Item: items[i]})
+		return err
+	})
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			fmt.Printf("Backend: Batch stamping %s failed: %v\n", items[i].Source, err)
+		}
+	}
+	fmt.Printf("Backend: Batch stamped %d of %d documents\n", len(items)-failed, len(items))
+	if failed > 0 {
+		a.jobFinished("batchstamp", tr("Stamped %d documents, %d failed", len(items)-failed, failed), true)
+	} else {
+		a.jobFinished("batchstamp", tr("Stamped %d documents", len(items)), false)
+	}
+	// The items tell which files were done before the cancel
+	return items, progress.cancelled()
+}
+
+// parsePlacement splits "anchor:pages" and checks both parts
+func parsePlacement(spec string) (placement, error) {
+	anchor, pages, _ := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+	switch anchor {
+	case PlacementAsPlaced, PlacementTopLeft, PlacementTopRight, PlacementBottomLeft, PlacementBottomRight, PlacementCenter:
+	default:
+		return placement{}, fmt.Errorf("unknown placement: %s", spec)
+	}
+	switch pages {
+	case "", PlacementFirstPage, PlacementLastPage, PlacementAllPages:
+	default:
+		return placement{}, fmt.Errorf("unknown placement pages: %s", pages)
+	}
+	return placement{anchor: anchor, pages: pages}, nil
+}
+
+// placeStamps returns the stamps for one document: moved to the anchor and copied to
+// the placement pages. Stamps are in points. Anchors are taken from the crop box and
+// anchored stamps come back measured from the top left of the box the input is
+// measured from, see offsets.
+func placeStamps(pdfPath string, stamps []StampInfo, place placement, margin float64, offsets cropOffsets) ([]StampInfo, error) {
+	if place.anchor == PlacementAsPlaced && place.pages == "" {
+		return stamps, nil
+	}
+	dims, err := stamper.VisiblePageDimsFile(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page dimensions for %s: %v", pdfPath, err)
+	}
+	if len(dims) == 0 {
+		return nil, fmt.Errorf("no page dimensions found for %s", pdfPath)
+	}
+
+	// An anchored layout only keeps its shape, so stamps with a bottom left origin are
+	// measured from the top of a page of no height
+	if place.anchor != PlacementAsPlaced {
+		flipped := make([]StampInfo, len(stamps))
+		for i, s := range stamps {
+			flipped[i] = s
+			if s.Origin == StampOriginBottomLeft {
+				flipped[i].Y, flipped[i].Origin = -s.Y-s.Height, ""
+			}
+		}
+		stamps = flipped
+	}
+
+	// Bounding box of the whole layout
+	minX, minY := stamps[0].X, stamps[0].Y
+	maxX, maxY := stamps[0].X+stamps[0].Width, stamps[0].Y+stamps[0].Height
+	for _, s := range stamps[1:] {
+		minX, minY = min(minX, s.X), min(minY, s.Y)
+		maxX, maxY = max(maxX, s.X+s.Width), max(maxY, s.Y+s.Height)
+	}
+	offset := func(dim types.Dim) (float64, float64) {
+		var dx, dy float64
+		switch place.anchor {
+		case PlacementAsPlaced:
+			return 0, 0
+		case PlacementCenter:
+			return (dim.Width-(maxX-minX))/2 - minX, (dim.Height-(maxY-minY))/2 - minY
+		}
+		if strings.HasSuffix(place.anchor, "left") {
+			dx = margin - minX
+		} else {
+			dx = dim.Width - margin - maxX
+		}
+		if strings.HasPrefix(place.anchor, "top") {
+			dy = margin - minY
+		} else {
+			dy = dim.Height - margin - maxY
+		}
+		return dx, dy
+	}
+
+	var pages []int
+	switch place.pages {
+	case PlacementFirstPage:
+		pages = []int{1}
+	case PlacementLastPage:
+		pages = []int{len(dims)}
+	case PlacementAllPages:
+		for p := 1; p <= len(dims); p++ {
+			pages = append(pages, p)
+		}
+	}
+
+	var placed []StampInfo
+	for _, s := range stamps {
+		targets := pages
+		if targets == nil && s.Pages != "" {
+			sel, err := stamper.ParsePageSelection(s.Pages)
+			if err != nil {
+				return nil, err
+			}
+			if targets, err = sel.Pages(len(dims)); err != nil {
+				return nil, err
+			}
+		} else if targets == nil {
+			if s.PageNum < 1 || s.PageNum > len(dims) {
+				continue
+			}
+			targets = []int{s.PageNum}
+		}
+		for _, p := range targets {
+			dx, dy := offset(dims[p-1])
+			moved := s
+			moved.PageNum = p
+			moved.Pages = ""
+			moved.X += dx
+			moved.Y += dy
+			placed = append(placed, moved)
+		}
+	}
+	if len(placed) == 0 {
+		return nil, fmt.Errorf("none of the stamps fit the %d page document", len(dims))
+	}
+	if place.anchor != PlacementAsPlaced {
+		placed = offsets.fromCropBox(placed)
+	}
+	return placed, nil
+}
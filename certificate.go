@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"golang.org/x/image/font/sfnt"
+)
+
+// CertificateRequest describes a batch of certificates rendered from one template
+type CertificateRequest struct {
+	Template    string              `json:"template"`    // background PDF, PNG or JPEG
+	TemplateDPI int                 `json:"templateDpi"` // resolution of an image template, defaults to 300
+	Stamps      []StampInfo         `json:"stamps"`      // text stamps with {field} placeholders
+	FontName    string              `json:"fontName"`    // used by text stamps without a font of their own
+	FontFile    string              `json:"fontFile"`    // optional TrueType font installed and used instead of FontName
+	Recipients  []map[string]string `json:"recipients"`  // one field set per certificate, e.g. name and date
+	OutputDir   string              `json:"outputDir"`   // defaults to a folder in Downloads
+	FileName    string              `json:"fileName"`    // pattern such as "certificate_{name}", without extension
+	Combine     bool                `json:"combine"`     // merge all certificates into a single PDF
+}
+
+// GenerateCertificates renders one certificate per recipient on top of the template and
+// returns the generated files, or the single combined file when Combine is set. Failed
+// certificates are reported in the result; a combined file is only made once all succeed.
+func (a *App) GenerateCertificates(req CertificateRequest) (BatchResult, error) {
+	if len(req.Recipients) == 0 {
+		return BatchResult{}, fmt.Errorf("no recipients given")
+	}
+	if len(req.Stamps) == 0 {
+		return BatchResult{}, fmt.Errorf("no stamps given")
+	}
+	run, err := newBatchRun(BatchCertificates, req)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	defer run.release()
+	return a.generateCertificates(req, run)
+}
+
+// generateCertificates renders the certificates that the checkpoint has not marked done
+func (a *App) generateCertificates(req CertificateRequest, run *batchRun) (BatchResult, error) {
+	defer a.startJob("certificates")()
+
+	fontName := req.FontName
+	if req.FontFile != "" {
+		installed, err := installFontFile(req.FontFile)
+		if err != nil {
+			return BatchResult{}, err
+		}
+		fontName = installed
+	}
+	if fontName != "" && !font.SupportedFont(fontName) {
+		return BatchResult{}, fmt.Errorf("unsupported font: %s", fontName)
+	}
+	stamps := make([]StampInfo, len(req.Stamps))
+	for i, s := range req.Stamps {
+		stamps[i] = s
+		if s.Kind == StampKindText && s.FontName == "" {
+			stamps[i].FontName = fontName
+		}
+	}
+
+	template, cleanup, err := certificateTemplate(req.Template, req.TemplateDPI)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	defer cleanup()
+
+	outDir, err := a.mergeOutputDir(req.Template, req.OutputDir, "_certificates")
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	// Combined runs render into a temp folder and only keep the merged result. The
+	// folder is kept until the job finishes so that an interrupted run can be resumed.
+	renderDir := outDir
+	if req.Combine {
+		renderDir, err = run.workDir("capgo_certificates_*")
+		if err != nil {
+			return BatchResult{}, err
+		}
+	}
+
+	jobs := make([]mergeJob, len(req.Recipients))
+	reserved := map[string]bool{}
+	for r, fields := range req.Recipients {
+		lookup := func(name string) (string, error) {
+			v, ok := fields[name]
+			if !ok {
+				return "", fmt.Errorf("unknown field %q", name)
+			}
+			return strings.TrimSpace(v), nil
+		}
+
+		merged, err := mergeStamps(stamps, lookup)
+		if err != nil {
+			return BatchResult{}, fmt.Errorf("recipient %d: %v", r+1, err)
+		}
+
+		name, err := mergeFileName(req.FileName, req.Template, r+1, lookup)
+		if err != nil {
+			return BatchResult{}, fmt.Errorf("recipient %d: %v", r+1, err)
+		}
+		jobs[r] = mergeJob{stamps: merged, output: reservePath(filepath.Join(renderDir, name+".pdf"), reserved)}
+	}
+
+	if err := planJobs(run, jobs); err != nil {
+		return BatchResult{}, err
+	}
+	res := a.runMergeJobs(run, jobs, a.batchWorkersFor(stamps), EventCertificateProgress, func(r int) error {
+		applied, err := a.stampPDFTo(template, jobs[r].output, jobs[r].stamps, StampOptions{}, nil)
+		if err != nil {
+			return fmt.Errorf("recipient %d: %v", r+1, err)
+		}
+		if err := a.finishOutput(jobs[r].output); err != nil {
+			return fmt.Errorf("recipient %d: %v", r+1, err)
+		}
+		if !req.Combine {
+			a.recordStamps(req.Template, jobs[r].output, applied, false)
+		}
+		return nil
+	})
+
+	if len(res.Failed) > 0 {
+		a.jobFinished("certificates", tr("Generated %d certificates, %d failed", len(res.Outputs), len(res.Failed)), true)
+		if req.Combine {
+			res.Outputs = []string{}
+		}
+		return res, nil
+	}
+	if len(res.Outputs) < len(jobs) {
+		// Only part of an interrupted job was retried
+		return res, nil
+	}
+
+	if !req.Combine {
+		run.finish()
+		a.jobFinished("certificates", tr("Generated %d certificates", len(res.Outputs)), false)
+		return res, nil
+	}
+
+	combined := uniquePath(filepath.Join(outDir, outputName(req.Template, "_certificates", ".pdf")))
+	if err := api.MergeCreateFile(res.Outputs, combined, false, nil); err != nil {
+		return BatchResult{}, fmt.Errorf("failed to combine certificates: %v", err)
+	}
+	if err := a.finishOutput(combined); err != nil {
+		return BatchResult{}, err
+	}
+	run.finish()
+	a.jobFinished("certificates", tr("Generated %d certificates", len(res.Outputs)), false)
+	res.Outputs = []string{combined}
+	return res, nil
+}
+
+// certificateTemplate returns a PDF to stamp on. Image templates are converted to a
+// single page sized from the image resolution; the returned cleanup removes that file.
+func certificateTemplate(path string, dpi int) (string, func(), error) {
+	path = filepath.Clean(path)
+	noop := func() {}
+
+	if strings.EqualFold(filepath.Ext(path), ".pdf") {
+		return path, noop, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to open template: %v", err)
+	}
+	cfg, _, err := image.DecodeConfig(f)
+	f.Close()
+	if err != nil {
+		return "", noop, fmt.Errorf("unsupported template image: %v", err)
+	}
+
+	if dpi <= 0 {
+		dpi = 300
+	}
+	w := float64(cfg.Width) * 72 / float64(dpi)
+	h := float64(cfg.Height) * 72 / float64(dpi)
+
+	imp, err := api.Import(fmt.Sprintf("dim:%.2f %.2f, pos:c, sc:1", w, h), types.POINTS)
+	if err != nil {
+		return "", noop, err
+	}
+
+	// ImportImagesFile appends to an existing file, so the template goes into a fresh folder
+	dir, err := os.MkdirTemp("", "capgo_template_*")
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to create template pdf: %v", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	out := filepath.Join(dir, "template.pdf")
+
+	if err := api.ImportImagesFile([]string{path}, out, imp, nil); err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("failed to convert template image: %v", err)
+	}
+	return out, cleanup, nil
+}
+
+// installFontFile installs a TrueType font for use in text stamps and returns its name
+func installFontFile(path string) (string, error) {
+	path = filepath.Clean(path)
+	if !strings.EqualFold(filepath.Ext(path), ".ttf") {
+		return "", fmt.Errorf("only TrueType (.ttf) fonts are supported")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read font: %v", err)
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse font: %v", err)
+	}
+	// pdfcpu registers fonts under their PostScript name
+	name, err := f.Name(nil, sfnt.NameIDPostScript)
+	if err != nil || name == "" {
+		return "", fmt.Errorf("font has no PostScript name")
+	}
+
+	if font.IsUserFont(name) {
+		return name, nil
+	}
+	// Makes sure the pdfcpu font folder is set up
+	api.LoadConfiguration()
+	if err := font.InstallTrueTypeFont(font.UserFontDir, path); err != nil {
+		return "", fmt.Errorf("failed to install font: %v", err)
+	}
+	if err := font.LoadUserFonts(); err != nil {
+		return "", fmt.Errorf("failed to load fonts: %v", err)
+	}
+	if !font.IsUserFont(name) {
+		return "", fmt.Errorf("font %s could not be installed", name)
+	}
+	return name, nil
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Batch job kinds that can be resumed. BatchStampPDFs, ProcessFolder and RunScript keep
+// no checkpoint; they list the error of every file in their result.
+const (
+	BatchMailMerge    = "mailmerge"
+	BatchCertificates = "certificates"
+)
+
+// BatchCheckpoint records the progress of a batch job. It is saved after every file,
+// so a job interrupted by a crash or quit can be resumed with ResumeBatch.
+type BatchCheckpoint struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"`
+	Request   json.RawMessage `json:"request"` // the original job arguments
+	Outputs   []string        `json:"outputs"` // planned output of every item
+	Done      []int           `json:"done"`    // indices of the finished items
+	Failed    []BatchError    `json:"failed"`  // items that failed in the last run
+	WorkDir   string          `json:"workDir,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+// mailMergeRequest holds the arguments of MailMergeStamp for resuming
+type mailMergeRequest struct {
+	Template string           `json:"template"`
+	CSV      string           `json:"csv"`
+	Mapping  MailMergeMapping `json:"mapping"`
+}
+
+// batchIDPattern matches the ids handed out by newBatchRun
+var batchIDPattern = regexp.MustCompile(`^[a-z]+-[0-9]+$`)
+
+// activeBatches holds the ids of the batch jobs running in this process
+var activeBatches struct {
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+// batchRun tracks a running batch job and keeps its checkpoint on disk
+type batchRun struct {
+	mu         sync.Mutex
+	cp         BatchCheckpoint
+	done       map[int]bool
+	failedOnly bool // run only the items that failed before
+}
+
+// newBatchRun starts the checkpoint of a new batch job
+func newBatchRun(kind string, req interface{}) (*batchRun, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	run := &batchRun{
+		cp:   BatchCheckpoint{ID: fmt.Sprintf("%s-%d", kind, now.UnixNano()), Kind: kind, Request: data, CreatedAt: now},
+		done: map[int]bool{},
+	}
+	if !claimBatch(run.cp.ID) {
+		return nil, fmt.Errorf("batch job %s is already running", run.cp.ID)
+	}
+	return run, nil
+}
+
+// loadBatchRun reopens the checkpoint of an interrupted batch job
+func loadBatchRun(id string) (*batchRun, error) {
+	if !batchIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid batch job id: %q", id)
+	}
+	var cp BatchCheckpoint
+	if err := readConfigJSON(checkpointName(id), &cp); err != nil {
+		return nil, err
+	}
+	if cp.ID != id {
+		return nil, fmt.Errorf("batch job %s not found", id)
+	}
+	if !claimBatch(id) {
+		return nil, fmt.Errorf("batch job %s is already running", id)
+	}
+	run := &batchRun{cp: cp, done: map[int]bool{}}
+	for _, i := range cp.Done {
+		run.done[i] = true
+	}
+	return run, nil
+}
+
+func claimBatch(id string) bool {
+	activeBatches.mu.Lock()
+	defer activeBatches.mu.Unlock()
+	if activeBatches.ids == nil {
+		activeBatches.ids = map[string]bool{}
+	}
+	if activeBatches.ids[id] {
+		return false
+	}
+	activeBatches.ids[id] = true
+	return true
+}
+
+// release marks the job as no longer running. Its checkpoint stays unless finish was
+// called or the job failed before any output was planned.
+func (b *batchRun) release() {
+	b.mu.Lock()
+	if b.cp.Outputs == nil {
+		removeCheckpoint(b.cp)
+	}
+	b.mu.Unlock()
+
+	activeBatches.mu.Lock()
+	delete(activeBatches.ids, b.cp.ID)
+	activeBatches.mu.Unlock()
+}
+
+// plan records the output of every item. A resumed job keeps the outputs of its first
+// run, so the remaining items land where they would have originally.
+func (b *batchRun) plan(outputs []string) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cp.Outputs != nil {
+		if len(b.cp.Outputs) != len(outputs) {
+			return nil, fmt.Errorf("the job input changed: %d items now, %d when the job started", len(outputs), len(b.cp.Outputs))
+		}
+		return b.cp.Outputs, nil
+	}
+	b.cp.Outputs = outputs
+	return outputs, b.save()
+}
+
+// workDir returns the job's temp folder, creating it on first use
+func (b *batchRun) workDir(pattern string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cp.WorkDir != "" {
+		if err := os.MkdirAll(b.cp.WorkDir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create temp folder: %v", err)
+		}
+		return b.cp.WorkDir, nil
+	}
+	dir, err := os.MkdirTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp folder: %v", err)
+	}
+	b.cp.WorkDir = dir
+	return dir, nil
+}
+
+// pending returns the items still to do. Finished items whose output has since been
+// removed are done again, unless only the failed items are retried.
+func (b *batchRun) pending() []int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var todo []int
+	if b.failedOnly {
+		for _, f := range b.cp.Failed {
+			if !b.done[f.Index] {
+				todo = append(todo, f.Index)
+			}
+		}
+		sort.Ints(todo)
+		return todo
+	}
+	for i, out := range b.cp.Outputs {
+		if b.done[i] {
+			if _, err := os.Stat(out); err == nil {
+				continue
+			}
+			delete(b.done, i)
+		}
+		todo = append(todo, i)
+	}
+	return todo
+}
+
+// complete marks an item finished and saves the checkpoint
+func (b *batchRun) complete(i int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done[i] = true
+	b.clearFailure(i)
+	return b.save()
+}
+
+// fail records the error of an item and saves the checkpoint
+func (b *batchRun) fail(e BatchError) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clearFailure(e.Index)
+	b.cp.Failed = append(b.cp.Failed, e)
+	sort.Slice(b.cp.Failed, func(i, j int) bool { return b.cp.Failed[i].Index < b.cp.Failed[j].Index })
+	return b.save()
+}
+
+func (b *batchRun) clearFailure(i int) {
+	for n, f := range b.cp.Failed {
+		if f.Index == i {
+			b.cp.Failed = append(b.cp.Failed[:n], b.cp.Failed[n+1:]...)
+			return
+		}
+	}
+}
+
+// result summarises the job. The checkpoint stays; callers remove it with finish once
+// every item succeeded.
+func (b *batchRun) result() BatchResult {
+	res := BatchResult{JobID: b.cp.ID, Outputs: b.completed()}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res.Failed = append([]BatchError{}, b.cp.Failed...)
+	return res
+}
+
+// completed returns the outputs of the finished items in item order
+func (b *batchRun) completed() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := []string{}
+	for i, path := range b.cp.Outputs {
+		if b.done[i] {
+			out = append(out, path)
+		}
+	}
+	return out
+}
+
+// finish removes the checkpoint and temp folder of a job that ran to the end
+func (b *batchRun) finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	removeCheckpoint(b.cp)
+}
+
+func (b *batchRun) save() error {
+	b.cp.Done = b.cp.Done[:0]
+	for i := range b.done {
+		b.cp.Done = append(b.cp.Done, i)
+	}
+	sort.Ints(b.cp.Done)
+	b.cp.UpdatedAt = time.Now()
+	if _, err := checkpointDir(); err != nil {
+		return err
+	}
+	return writeConfigJSON(checkpointName(b.cp.ID), b.cp)
+}
+
+// ResumeBatch continues an interrupted batch job, processing only the files that were
+// not finished, and returns the job's result
+func (a *App) ResumeBatch(jobID string) (BatchResult, error) {
+	run, err := loadBatchRun(jobID)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	defer run.release()
+	fmt.Printf("Backend: Resuming batch job %s, %d of %d done\n", jobID, len(run.done), len(run.cp.Outputs))
+	return a.rerunBatch(run)
+}
+
+// RetryFailed runs the failed items of a mail merge or certificate job again, leaving
+// the rest alone
+func (a *App) RetryFailed(jobID string) (BatchResult, error) {
+	run, err := loadBatchRun(jobID)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	defer run.release()
+	if len(run.cp.Failed) == 0 {
+		return BatchResult{}, fmt.Errorf("batch job %s has no failed items", jobID)
+	}
+	run.failedOnly = true
+	fmt.Printf("Backend: Retrying %d failed items of batch job %s\n", len(run.cp.Failed), jobID)
+	return a.rerunBatch(run)
+}
+
+func (a *App) rerunBatch(run *batchRun) (BatchResult, error) {
+	switch run.cp.Kind {
+	case BatchMailMerge:
+		var req mailMergeRequest
+		if err := json.Unmarshal(run.cp.Request, &req); err != nil {
+			return BatchResult{}, fmt.Errorf("invalid checkpoint: %v", err)
+		}
+		return a.mailMerge(req, run)
+	case BatchCertificates:
+		var req CertificateRequest
+		if err := json.Unmarshal(run.cp.Request, &req); err != nil {
+			return BatchResult{}, fmt.Errorf("invalid checkpoint: %v", err)
+		}
+		return a.generateCertificates(req, run)
+	}
+	return BatchResult{}, fmt.Errorf("unknown batch job kind: %s", run.cp.Kind)
+}
+
+// ListBatches returns the interrupted or partly failed batch jobs, newest first
+func (a *App) ListBatches() ([]BatchCheckpoint, error) {
+	dir, err := checkpointDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	activeBatches.mu.Lock()
+	defer activeBatches.mu.Unlock()
+	batches := []BatchCheckpoint{}
+	for _, f := range files {
+		id := strings.TrimSuffix(filepath.Base(f), ".json")
+		if !batchIDPattern.MatchString(id) || activeBatches.ids[id] {
+			continue
+		}
+		var cp BatchCheckpoint
+		if err := readConfigJSON(checkpointName(id), &cp); err != nil {
+			fmt.Printf("Backend: Skipping checkpoint %s: %v\n", id, err)
+			continue
+		}
+		batches = append(batches, cp)
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].UpdatedAt.After(batches[j].UpdatedAt) })
+	return batches, nil
+}
+
+// DiscardBatch deletes the checkpoint of an interrupted batch job. Files it already
+// produced are kept.
+func (a *App) DiscardBatch(jobID string) error {
+	run, err := loadBatchRun(jobID)
+	if err != nil {
+		return err
+	}
+	defer run.release()
+	removeCheckpoint(run.cp)
+	return nil
+}
+
+func removeCheckpoint(cp BatchCheckpoint) {
+	if cp.WorkDir != "" {
+		os.RemoveAll(cp.WorkDir)
+	}
+	if dir, err := checkpointDir(); err == nil {
+		os.Remove(filepath.Join(dir, cp.ID+".json"))
+	}
+}
+
+func checkpointName(id string) string {
+	return filepath.Join("batches", id+".json")
+}
+
+func checkpointDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "batches")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create batch checkpoint directory: %v", err)
+	}
+	return dir, nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+// CMS (RFC 5652) object identifiers used by PAdES signatures
+var (
+	oidData                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSHA256               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSAEncryption        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA256      = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+)
+
+// signingIdentity is a private key with its certificate and the chain above it
+type signingIdentity struct {
+	key   crypto.Signer
+	cert  *x509.Certificate
+	chain []*x509.Certificate
+}
+
+// loadSigningIdentity reads a PKCS#12 (.p12 or .pfx) file. Only the legacy encryption
+// that Windows and macOS export is supported; OpenSSL 3 writes it with -legacy.
+func loadSigningIdentity(path, password string) (*signingIdentity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, classifyFileError("read", path, err)
+	}
+	blocks, err := pkcs12.ToPEM(data, password)
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		return nil, fmt.Errorf("wrong password for the certificate")
+	}
+	var notImplemented pkcs12.NotImplementedError
+	if errors.As(err, &notImplemented) {
+		return nil, fmt.Errorf("the certificate file uses encryption CapGo cannot read, export it again with legacy encryption")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %v", err)
+	}
+
+	id := &signingIdentity{}
+	var certs []*x509.Certificate
+	for _, b := range blocks {
+		switch b.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(b.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read certificate: %v", err)
+			}
+			certs = append(certs, cert)
+		case "PRIVATE KEY":
+			if id.key, err = parsePEMKey(b); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if id.key == nil {
+		return nil, fmt.Errorf("the certificate file holds no private key")
+	}
+	// The signer is the certificate that belongs to the key; the rest is its chain
+	for _, cert := range certs {
+		if id.cert == nil && publicKeyMatches(cert.PublicKey, id.key.Public()) {
+			id.cert = cert
+		} else {
+			id.chain = append(id.chain, cert)
+		}
+	}
+	if id.cert == nil {
+		return nil, fmt.Errorf("the certificate file holds no certificate for its private key")
+	}
+	return id, nil
+}
+
+// parsePEMKey decodes a key from pkcs12.ToPEM, which is PKCS#1 for RSA and SEC 1 for EC
+func parsePEMKey(b *pem.Block) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(b.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(b.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported private key, use an RSA or ECDSA key")
+}
+
+func publicKeyMatches(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
+
+type cmsAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue // a SET holding one value
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+// essCertIDv2 identifies the signing certificate; the hash algorithm is left out
+// because SHA-256 is its default
+type essCertIDv2 struct {
+	CertHash     []byte
+	IssuerSerial struct {
+		Issuer []asn1.RawValue // GeneralNames
+		Serial *big.Int
+	}
+}
+
+type signerInfo struct {
+	Version            int
+	SID                issuerAndSerial
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue // [0] IMPLICIT SET OF Attribute
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue // SET OF AlgorithmIdentifier
+	EncapContentInfo struct {
+		ContentType asn1.ObjectIdentifier
+	}
+	Certificates asn1.RawValue // [0] IMPLICIT SET OF Certificate
+	SignerInfos  asn1.RawValue // SET OF SignerInfo
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue // [0] EXPLICIT
+}
+
+// signDetached returns a detached CMS signature of data as PAdES baseline B-B expects:
+// SHA-256, the signing certificate attribute and no signing time, which belongs in
+// the signature dictionary instead
+func (id *signingIdentity) signDetached(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	certHash := sha256.Sum256(id.cert.Raw)
+
+	var essCert essCertIDv2
+	essCert.CertHash = certHash[:]
+	essCert.IssuerSerial.Issuer = []asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: id.cert.RawIssuer}}
+	essCert.IssuerSerial.Serial = id.cert.SerialNumber
+	signingCert := struct{ Certs []essCertIDv2 }{[]essCertIDv2{essCert}}
+
+	var attrs [][]byte
+	for _, a := range []struct {
+		oid   asn1.ObjectIdentifier
+		value interface{}
+	}{
+		{oidContentType, oidData},
+		{oidMessageDigest, digest[:]},
+		{oidSigningCertificateV2, signingCert},
+	} {
+		value, err := asn1.Marshal(a.value)
+		if err != nil {
+			return nil, err
+		}
+		attr, err := asn1.Marshal(cmsAttribute{Type: a.oid, Values: derSet(value)})
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+	// The signature covers the attributes encoded as a SET, the signer info holds them
+	// with an implicit [0] tag instead
+	attrSet := derSet(attrs...)
+	signedAttrs, err := asn1.Marshal(attrSet)
+	if err != nil {
+		return nil, err
+	}
+	attrsDigest := sha256.Sum256(signedAttrs)
+
+	var sigAlg pkix.AlgorithmIdentifier
+	switch id.key.(type) {
+	case *rsa.PrivateKey:
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue}
+	case *ecdsa.PrivateKey:
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}
+	default:
+		return nil, fmt.Errorf("unsupported private key, use an RSA or ECDSA key")
+	}
+	signature, err := id.key.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %v", err)
+	}
+
+	info := signerInfo{
+		Version:            1,
+		SID:                issuerAndSerial{Issuer: asn1.RawValue{FullBytes: id.cert.RawIssuer}, Serial: id.cert.SerialNumber},
+		DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+		SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrSet.Bytes},
+		SignatureAlgorithm: sigAlg,
+		Signature:          signature,
+	}
+	infoDER, err := asn1.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	digestAlg, err := asn1.Marshal(pkix.AlgorithmIdentifier{Algorithm: oidSHA256})
+	if err != nil {
+		return nil, err
+	}
+	var certs [][]byte
+	for _, c := range append([]*x509.Certificate{id.cert}, id.chain...) {
+		certs = append(certs, c.Raw)
+	}
+
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: derSet(digestAlg),
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: bytes.Join(certs, nil)},
+		SignerInfos:      derSet(infoDER),
+	}
+	sd.EncapContentInfo.ContentType = oidData
+	sdDER, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sdDER},
+	})
+}
+
+// derSet wraps encoded members in a SET, sorted as DER requires
+func derSet(members ...[]byte) asn1.RawValue {
+	sort.Slice(members, func(i, j int) bool { return bytes.Compare(members[i], members[j]) < 0 })
+	return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(members, nil)}
+}
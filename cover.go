@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"CapGo/internal/pdfcanvas"
+	"CapGo/pkg/stamper"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// CoverPageFields holds the content of a generated cover page
+type CoverPageFields struct {
+	Title   string   `json:"title"`
+	Parties []string `json:"parties"`
+	Date    string   `json:"date"`  // defaults to today
+	Logo    string   `json:"logo"`  // image path or data URL
+	Notes   string   `json:"notes"` // small print at the bottom of the page
+	// PaperSize is the page format of the cover, see GetPaperSizes; empty matches the
+	// first page of the document
+	PaperSize string `json:"paperSize,omitempty"`
+	Template  string `json:"template,omitempty"` // saved template that fills in empty fields
+	SaveAs    string `json:"saveAs,omitempty"`   // store these fields as a template under this name
+}
+
+// CoverTemplate is a named, reusable set of cover page fields
+type CoverTemplate struct {
+	Name   string          `json:"name"`
+	Fields CoverPageFields `json:"fields"`
+}
+
+const coverTemplatesFile = "cover_templates.json"
+
+var coverTemplatesMu sync.Mutex
+
+func loadCoverTemplates() (map[string]CoverPageFields, error) {
+	templates := map[string]CoverPageFields{}
+	if err := readConfigJSON(coverTemplatesFile, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// GenerateCoverPage creates a cover page sized like the first page of the document,
+// prepends it and returns the path of the new file, written next to the other CapGo output
+func (a *App) GenerateCoverPage(pdfPath string, fields CoverPageFields) (string, error) {
+	pdfPath = filepath.Clean(pdfPath)
+
+	if fields.Template != "" {
+		coverTemplatesMu.Lock()
+		templates, err := loadCoverTemplates()
+		coverTemplatesMu.Unlock()
+		if err != nil {
+			return "", err
+		}
+		saved, ok := templates[fields.Template]
+		if !ok {
+			return "", fmt.Errorf("unknown cover template: %s", fields.Template)
+		}
+		fields = mergeCoverFields(fields, saved)
+	}
+	if fields.SaveAs != "" {
+		if err := a.SaveCoverTemplate(fields.SaveAs, fields); err != nil {
+			return "", err
+		}
+	}
+	if strings.TrimSpace(fields.Title) == "" {
+		return "", fmt.Errorf("cover page needs a title")
+	}
+	if fields.Date == "" {
+		fields.Date = formatDate(a.now())
+	}
+
+	var width, height float64
+	if fields.PaperSize != "" {
+		paper, err := a.paperSize(fields.PaperSize)
+		if err != nil {
+			return "", err
+		}
+		width, height = paper.Width, paper.Height
+	} else {
+		dims, err := api.PageDimsFile(pdfPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to get page dimensions for %s: %v", pdfPath, err)
+		}
+		if len(dims) == 0 {
+			return "", fmt.Errorf("no page dimensions found for %s", pdfPath)
+		}
+		width, height = dims[0].Width, dims[0].Height
+	}
+
+	tmpDir, err := os.MkdirTemp("", "capgo_cover_*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp folder: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cover := filepath.Join(tmpDir, "cover.pdf")
+	canvas, logoBox := coverPageCanvas(width, height, fields)
+	if err := os.WriteFile(cover, pdfcanvas.Render(canvas), 0644); err != nil {
+		return "", fmt.Errorf("failed to write cover page: %v", err)
+	}
+
+	// The logo goes on through the regular image stamp path
+	if fields.Logo != "" {
+		logoBox.Image = fields.Logo
+		withLogo := filepath.Join(tmpDir, "cover_logo.pdf")
+		if _, err := a.stampPDFTo(cover, withLogo, []StampInfo{logoBox}, StampOptions{}, nil); err != nil {
+			return "", fmt.Errorf("failed to place logo: %v", err)
+		}
+		cover = withLogo
+	}
+
+	outputPath, err := a.outputPath(pdfPath, "_cover")
+	if err != nil {
+		return "", err
+	}
+	if err := api.MergeCreateFile([]string{cover, pdfPath}, outputPath, false, nil); err != nil {
+		os.Remove(outputPath)
+		return "", fmt.Errorf("failed to prepend cover page: %v", err)
+	}
+	if err := a.finishOutput(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	return outputPath, nil
+}
+
+// mergeCoverFields fills the empty fields of f from the saved template
+func mergeCoverFields(f, saved CoverPageFields) CoverPageFields {
+	if f.Title == "" {
+		f.Title = saved.Title
+	}
+	if len(f.Parties) == 0 {
+		f.Parties = saved.Parties
+	}
+	if f.Date == "" {
+		f.Date = saved.Date
+	}
+	if f.Logo == "" {
+		f.Logo = saved.Logo
+	}
+	if f.Notes == "" {
+		f.Notes = saved.Notes
+	}
+	if f.PaperSize == "" {
+		f.PaperSize = saved.PaperSize
+	}
+	return f
+}
+
+// coverPageCanvas lays out the cover page and returns it together with the logo box
+// (top-left coordinates, as used by stamps)
+func coverPageCanvas(width, height float64, fields CoverPageFields) (*pdfcanvas.Canvas, StampInfo) {
+	const (
+		bold    = "Helvetica-Bold"
+		regular = "Helvetica"
+	)
+	c := pdfcanvas.New(width, height)
+	margin := width * 0.12
+	textW := width - 2*margin
+	black := pdfcanvas.RGB{}
+	gray := pdfcanvas.RGB{R: 0.4, G: 0.4, B: 0.4}
+
+	centered := func(y float64, fontName string, size float64, s string) {
+		s = fitText(s, fontName, size, textW)
+		c.Text((width-pdfcanvas.CoreTextWidth(s, fontName, size))/2, y, fontName, size, s, pdfcanvas.TextFill)
+	}
+
+	logo := StampInfo{
+		Stamp: stamper.Stamp{
+			Kind:    StampKindImage,
+			Width:   width * 0.3,
+			Height:  height * 0.1,
+			X:       width * 0.35,
+			Y:       height * 0.08,
+			PageNum: 1,
+		},
+	}
+
+	// Title block starts a third of the way down; long titles wrap onto several lines
+	y := height * 0.65
+	titleSize := 28.0
+	lines := wrapText(fields.Title, bold, titleSize, textW)
+	c.SetFillColor(black)
+	for _, line := range lines {
+		centered(y, bold, titleSize, line)
+		y -= titleSize * 1.25
+	}
+
+	if len(fields.Parties) > 0 {
+		y -= 24
+		c.SetFillColor(gray)
+		centered(y, regular, 12, tr("between"))
+		c.SetFillColor(black)
+		for i, p := range fields.Parties {
+			if i > 0 {
+				y -= 20
+				c.SetFillColor(gray)
+				centered(y, regular, 12, tr("and"))
+				c.SetFillColor(black)
+			}
+			y -= 22
+			centered(y, bold, 15, p)
+		}
+	}
+
+	y -= 40
+	c.SetFillColor(gray)
+	centered(y, regular, 12, fields.Date)
+
+	if fields.Notes != "" {
+		notes := wrapText(fields.Notes, regular, 9, textW)
+		ny := margin*0.6 + float64(len(notes)-1)*11
+		for _, line := range notes {
+			centered(ny, regular, 9, line)
+			ny -= 11
+		}
+	}
+
+	return c, logo
+}
+
+// wrapText breaks s into lines no wider than maxWidth, honouring explicit line breaks
+func wrapText(s, fontName string, size, maxWidth float64) []string {
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && pdfcanvas.CoreTextWidth(candidate, fontName, size) > maxWidth {
+				lines = append(lines, line)
+				candidate = word
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// ListCoverTemplates returns the saved cover page templates sorted by name
+func (a *App) ListCoverTemplates() ([]CoverTemplate, error) {
+	coverTemplatesMu.Lock()
+	defer coverTemplatesMu.Unlock()
+
+	templates, err := loadCoverTemplates()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]CoverTemplate, 0, len(templates))
+	for name, fields := range templates {
+		list = append(list, CoverTemplate{Name: name, Fields: fields})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// SaveCoverTemplate stores cover page fields under a name for reuse
+func (a *App) SaveCoverTemplate(name string, fields CoverPageFields) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("template name cannot be empty")
+	}
+	fields.Template = ""
+	fields.SaveAs = ""
+
+	coverTemplatesMu.Lock()
+	defer coverTemplatesMu.Unlock()
+
+	templates, err := loadCoverTemplates()
+	if err != nil {
+		return err
+	}
+	templates[name] = fields
+	return writeConfigJSON(coverTemplatesFile, templates)
+}
+
+// DeleteCoverTemplate removes a saved cover page template
+func (a *App) DeleteCoverTemplate(name string) error {
+	coverTemplatesMu.Lock()
+	defer coverTemplatesMu.Unlock()
+
+	templates, err := loadCoverTemplates()
+	if err != nil {
+		return err
+	}
+	delete(templates, name)
+	return writeConfigJSON(coverTemplatesFile, templates)
+}
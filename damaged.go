@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime/debug"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// pdfCrash is a panic inside pdfcpu. Damaged files can make it index past the end of
+// an object stream or dereference objects that are missing, see fuzz_test.go.
+type pdfCrash struct {
+	value interface{}
+}
+
+func (c *pdfCrash) Error() string {
+	return fmt.Sprintf("pdfcpu crashed: %v", c.value)
+}
+
+// catchCrash runs f and returns a panic in it as a *pdfCrash
+func catchCrash(f func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			fmt.Printf("Backend: Recovered from a crash: %v\n%s", p, debug.Stack())
+			err = &pdfCrash{value: p}
+		}
+	}()
+	return f()
+}
+
+// recoverDamaged is deferred by the methods that read user documents, so a damaged
+// file fails with an error instead of taking the app down
+func recoverDamaged(path string, err *error) {
+	if p := recover(); p != nil {
+		fmt.Printf("Backend: Recovered from a crash on %s: %v\n%s", path, p, debug.Stack())
+		*err = damagedFileError(path)
+	}
+}
+
+func damagedFileError(path string) error {
+	return fmt.Errorf("%s is damaged and cannot be processed", filepath.Base(path))
+}
+
+// withRepairFallback runs op with pdfcpu's default configuration and, when pdfcpu
+// crashes on the file, once more without its optimization step. Optimizing walks every
+// resource dictionary, which is where damaged but readable files trip it up most.
+func withRepairFallback(path string, op func(conf *model.Configuration) error) error {
+	err := catchCrash(func() error { return op(model.NewDefaultConfiguration()) })
+	var crash *pdfCrash
+	if !errors.As(err, &crash) {
+		return err
+	}
+	fmt.Printf("Backend: Retrying %s without optimization\n", path)
+	conf := model.NewDefaultConfiguration()
+	conf.Optimize = false
+	if err := catchCrash(func() error { return op(conf) }); err != nil {
+		if errors.As(err, &crash) {
+			return damagedFileError(path)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package main
+
+import "CapGo/pkg/stamper"
+
+// RenderStampDesign renders the design as a transparent PNG at print resolution
+// and returns it as a data URL usable as a stamp image
+func (a *App) RenderStampDesign(design stamper.Design) (string, error) {
+	img, err := stamper.RenderDesign(design)
+	if err != nil {
+		return "", err
+	}
+	return pngDataURL(img)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// deterministicProducer replaces the pdfcpu producer string in deterministic output,
+// without a version so an update alone does not change the files
+const deterministicProducer = "CapGo"
+
+// GetDeterministicOutput reports whether generated PDFs are written byte for byte
+// reproducibly
+func (a *App) GetDeterministicOutput() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.settings.DeterministicOutput
+}
+
+// SetDeterministicOutput turns deterministic output on or off. When on, generated PDFs
+// carry a fixed date, producer and file ID, so running the same operation on the same
+// inputs gives identical files.
+func (a *App) SetDeterministicOutput(enabled bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settings.DeterministicOutput = enabled
+	return a.saveSettings()
+}
+
+// deterministicTime is the date written into deterministic output: SOURCE_DATE_EPOCH
+// when set, as for reproducible builds, otherwise the start of 2000
+func deterministicTime() time.Time {
+	if v := os.Getenv("SOURCE_DATE_EPOCH"); v != "" {
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Unix(secs, 0).UTC()
+		}
+	}
+	return time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// now is the time to print on generated pages, fixed in deterministic mode
+func (a *App) now() time.Time {
+	if a.GetDeterministicOutput() {
+		return deterministicTime()
+	}
+	return time.Now()
+}
+
+// finishOutput makes a generated PDF reproducible when deterministic output is on and
+// does nothing otherwise
+func (a *App) finishOutput(path string) error {
+	if !a.GetDeterministicOutput() {
+		return nil
+	}
+	if err := makeDeterministic(path, deterministicTime()); err != nil {
+		return fmt.Errorf("failed to write %s deterministically: %v", path, err)
+	}
+	return nil
+}
+
+// makeDeterministic rewrites a PDF with a fixed date, producer and file ID. pdfcpu
+// stamps the current time and a time based ID into every file it writes, and numbers
+// and orders objects as it creates them, so the file is written here instead: objects
+// numbered in the order they are reached from the catalog, a plain cross-reference
+// table and an ID hashed from the content. Encrypted files are left alone, their keys
+// are random anyway.
+func makeDeterministic(path string, date time.Time) error {
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		return err
+	}
+	if ctx.Encrypt != nil {
+		return nil
+	}
+
+	info := types.NewDict()
+	if ctx.Info != nil {
+		if d, err := ctx.DereferenceDict(*ctx.Info); err == nil && d != nil {
+			info = d
+		}
+	}
+	info.Update("CreationDate", types.StringLiteral(types.DateString(date.UTC())))
+	info.Update("ModDate", types.StringLiteral(types.DateString(date.UTC())))
+	info.Update("Producer", types.StringLiteral(deterministicProducer))
+	if ctx.Info, err = ctx.IndRefForNewObject(info); err != nil {
+		return err
+	}
+	renumberObjects(ctx)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%%PDF-%s\n%%\xe2\xe3\xcf\xd3\n", ctx.XRefTable.Version())
+	offsets := make([]int, len(ctx.Table))
+	for nr := 1; nr < len(ctx.Table); nr++ {
+		offsets[nr] = buf.Len()
+		if err := writeIndirectObject(&buf, nr, ctx.Table[nr].Object); err != nil {
+			return err
+		}
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(ctx.Table))
+	for nr := 1; nr < len(ctx.Table); nr++ {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offsets[nr])
+	}
+
+	// Both ID entries are the content hash. The first one would name the original
+	// document, but pdfcpu makes up a time based one for every new document.
+	sum := md5.Sum(buf.Bytes())
+	id := types.HexLiteral(hex.EncodeToString(sum[:]))
+	trailer := types.NewDict()
+	trailer.Insert("Size", types.Integer(len(ctx.Table)))
+	trailer.Insert("Root", *ctx.Root)
+	trailer.Insert("Info", *ctx.Info)
+	trailer.Insert("ID", types.Array{id, id})
+	fmt.Fprintf(&buf, "trailer\n%s\nstartxref\n%d\n%%%%EOF\n", trailer.PDFString(), xref)
+
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// writeIndirectObject writes object nr with generation 0. Streams are written with
+// their encoded data and a direct length.
+func writeIndirectObject(buf *bytes.Buffer, nr int, o types.Object) error {
+	fmt.Fprintf(buf, "%d 0 obj\n", nr)
+	switch o := o.(type) {
+	case types.StreamDict:
+		if o.Raw == nil {
+			if err := o.Encode(); err != nil {
+				return err
+			}
+		}
+		o.Dict["Length"] = types.Integer(len(o.Raw))
+		buf.WriteString(o.Dict.PDFString())
+		buf.WriteString("\nstream\n")
+		buf.Write(o.Raw)
+		buf.WriteString("\nendstream")
+	case nil:
+		buf.WriteString("null")
+	default:
+		buf.WriteString(o.PDFString())
+	}
+	buf.WriteString("\nendobj\n")
+	return nil
+}
+
+// renumberObjects numbers the objects in the order they are reached from the catalog
+// and the info dictionary. pdfcpu numbers new objects in the order it creates them,
+// which for stamps on several pages follows map iteration. Unreachable objects are
+// dropped.
+func renumberObjects(ctx *model.Context) {
+	numbers := map[int]int{}
+	var order []int
+	var visit func(o types.Object)
+	visit = func(o types.Object) {
+		switch o := o.(type) {
+		case types.IndirectRef:
+			nr := o.ObjectNumber.Value()
+			if _, ok := numbers[nr]; ok {
+				return
+			}
+			entry, ok := ctx.Table[nr]
+			if !ok || entry.Free || entry.Object == nil {
+				return
+			}
+			order = append(order, nr)
+			numbers[nr] = len(order)
+			visit(entry.Object)
+		case types.Dict:
+			keys := make([]string, 0, len(o))
+			for k := range o {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				visit(o[k])
+			}
+		case types.StreamDict:
+			visit(o.Dict)
+		case types.Array:
+			for _, e := range o {
+				visit(e)
+			}
+		}
+	}
+	visit(*ctx.Root)
+	if ctx.Info != nil {
+		visit(*ctx.Info)
+	}
+
+	// Direct objects may be shared between objects, so they are copied rather than
+	// changed in place
+	var rewrite func(o types.Object) types.Object
+	rewrite = func(o types.Object) types.Object {
+		switch o := o.(type) {
+		case types.IndirectRef:
+			nr, ok := numbers[o.ObjectNumber.Value()]
+			if !ok {
+				return nil // points at nothing, the same as null
+			}
+			return *types.NewIndirectRef(nr, 0)
+		case types.Dict:
+			d := types.NewDict()
+			for k, v := range o {
+				d[k] = rewrite(v)
+			}
+			return d
+		case types.StreamDict:
+			o.Dict = rewrite(o.Dict).(types.Dict)
+			return o
+		case types.Array:
+			a := make(types.Array, len(o))
+			for i, v := range o {
+				a[i] = rewrite(v)
+			}
+			return a
+		}
+		return o
+	}
+
+	table := map[int]*model.XRefTableEntry{0: model.NewFreeHeadXRefTableEntry()}
+	for _, nr := range order {
+		table[numbers[nr]] = model.NewXRefTableEntryGen0(rewrite(ctx.Table[nr].Object))
+	}
+	ctx.Table = table
+	size := len(table)
+	ctx.Size = &size
+	ctx.Root = types.NewIndirectRef(numbers[ctx.Root.ObjectNumber.Value()], 0)
+	if ctx.Info != nil {
+		ctx.Info = types.NewIndirectRef(numbers[ctx.Info.ObjectNumber.Value()], 0)
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Where the language of a document came from
+const (
+	LanguageSourceOverride = "override" // the document language setting
+	LanguageSourceText     = "text"     // detected from the text of the document
+	LanguageSourceMetadata = "metadata" // the /Lang entry of the document
+	LanguageSourceDefault  = "default"  // nothing to go by, the language of the app
+)
+
+// DocumentLanguage is the language of a document, used for the OCR languages and the
+// format of date placeholders
+type DocumentLanguage struct {
+	Code   string `json:"code"` // ISO 639-1, e.g. "vi"
+	Name   string `json:"name"` // in that language
+	OCR    string `json:"ocr"`  // Tesseract languages, e.g. "vie+eng"
+	Source string `json:"source"`
+	// Confidence is the share of the evidence that speaks for the language, from 0 to 1.
+	// It is 1 for an override and 0 when the language was not detected from the text.
+	Confidence float64 `json:"confidence"`
+}
+
+// documentLocale is a language CapGo can detect, with its OCR model and date formats.
+// Dates are layouts with {d}, {dd}, {m}, {mm}, {month} and {yyyy}.
+type documentLocale struct {
+	code, name, ocr string
+	longDate        string
+	shortDate       string
+	months          string // month names as written in a date, separated by commas
+}
+
+var documentLocales = []documentLocale{
+	{"en", "English", "eng", "{d} {month} {yyyy}", "{dd}/{mm}/{yyyy}",
+		"January,February,March,April,May,June,July,August,September,October,November,December"},
+	{"vi", "Tiếng Việt", "vie", "ngày {d} tháng {m} năm {yyyy}", "{dd}/{mm}/{yyyy}", ""},
+	{"fr", "Français", "fra", "{d} {month} {yyyy}", "{dd}/{mm}/{yyyy}",
+		"janvier,février,mars,avril,mai,juin,juillet,août,septembre,octobre,novembre,décembre"},
+	{"de", "Deutsch", "deu", "{d}. {month} {yyyy}", "{dd}.{mm}.{yyyy}",
+		"Januar,Februar,März,April,Mai,Juni,Juli,August,September,Oktober,November,Dezember"},
+	{"es", "Español", "spa", "{d} de {month} de {yyyy}", "{dd}/{mm}/{yyyy}",
+		"enero,febrero,marzo,abril,mayo,junio,julio,agosto,septiembre,octubre,noviembre,diciembre"},
+	{"it", "Italiano", "ita", "{d} {month} {yyyy}", "{dd}/{mm}/{yyyy}",
+		"gennaio,febbraio,marzo,aprile,maggio,giugno,luglio,agosto,settembre,ottobre,novembre,dicembre"},
+	{"pt", "Português", "por", "{d} de {month} de {yyyy}", "{dd}/{mm}/{yyyy}",
+		"janeiro,fevereiro,março,abril,maio,junho,julho,agosto,setembro,outubro,novembro,dezembro"},
+	{"nl", "Nederlands", "nld", "{d} {month} {yyyy}", "{dd}-{mm}-{yyyy}",
+		"januari,februari,maart,april,mei,juni,juli,augustus,september,oktober,november,december"},
+	{"ru", "Русский", "rus", "{d} {month} {yyyy} г.", "{dd}.{mm}.{yyyy}",
+		"января,февраля,марта,апреля,мая,июня,июля,августа,сентября,октября,ноября,декабря"},
+	{"zh", "中文", "chi_sim", "{yyyy}年{m}月{d}日", "{yyyy}/{mm}/{dd}", ""},
+	{"ja", "日本語", "jpn", "{yyyy}年{m}月{d}日", "{yyyy}/{mm}/{dd}", ""},
+	{"ko", "한국어", "kor", "{yyyy}년 {m}월 {d}일", "{yyyy}. {mm}. {dd}.", ""},
+}
+
+// stopwords are frequent short words that tell the languages written in Latin letters
+// apart. Words shared by languages count for each of them.
+var stopwords = map[string]map[string]bool{
+	"en": wordSet("the and of to in is that for with on are this be by as at from it an or was not have which will"),
+	"vi": wordSet("của và các là có trong được cho với không này một những người theo đã để về từ khi tại đến như nhưng"),
+	"fr": wordSet("le la les des et est une du en que qui dans pour pas sur au avec sont par ce cette il nous vous aux"),
+	"de": wordSet("der die das und ist nicht mit den von zu ein eine sich auf für dem des im werden auch wird bei oder"),
+	"es": wordSet("el la los las de que y en del por con para una es se al lo como más pero sus este esta"),
+	"it": wordSet("il di che e la per un una del della sono non con le gli nel alla dei anche questo è da"),
+	"pt": wordSet("o a os as de que e do da em um uma para com não por dos das se mais ao é foi"),
+	"nl": wordSet("de het een en van is dat in op te met voor niet zijn aan er ook als bij door wordt"),
+}
+
+// vietnameseLetters only occur in Vietnamese among the languages detected
+const vietnameseLetters = "ăđơưạảấầẩẫậắằẳẵặẹẻẽếềểễệỉịọỏốồổỗộớờởỡợụủứừửữựỳỵỷỹ"
+
+// Bounds of the text sampled for language detection
+const (
+	languageSamplePages = 10
+	languageSampleBytes = 32 << 10
+	minLanguageLetters  = 40 // fewer letters are not enough to tell
+	minLanguageWords    = 3  // stopwords needed before a Latin language is picked
+)
+
+// datePlaceholder matches {date} and {date:short} in text stamps
+var datePlaceholder = regexp.MustCompile(`\{date(?::(short|long))?\}`)
+
+func wordSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// findDocumentLocale returns the locale of an ISO 639-1 code or a language tag such as "en-US"
+func findDocumentLocale(tag string) (documentLocale, bool) {
+	code := strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	for _, l := range documentLocales {
+		if l.code == code {
+			return l, true
+		}
+	}
+	return documentLocale{}, false
+}
+
+// GetDocumentLanguages returns the languages CapGo detects in documents
+func (a *App) GetDocumentLanguages() []LanguageInfo {
+	list := make([]LanguageInfo, len(documentLocales))
+	for i, l := range documentLocales {
+		list[i] = LanguageInfo{Code: l.code, Name: l.name}
+	}
+	return list
+}
+
+// GetDocumentLanguageOverride returns the language used for every document instead of
+// the detected one, "" when the language is detected
+func (a *App) GetDocumentLanguageOverride() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.settings.DocumentLanguage
+}
+
+// SetDocumentLanguageOverride sets and persists the language used for every document.
+// An empty code detects the language of each document again.
+func (a *App) SetDocumentLanguageOverride(code string) error {
+	if code != "" {
+		if _, ok := findDocumentLocale(code); !ok {
+			return fmt.Errorf("unsupported document language: %s", code)
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settings.DocumentLanguage = code
+	return a.saveSettings()
+}
+
+// DetectDocumentLanguage returns the language of a document: the override when one is
+// set, otherwise the language of its text, its /Lang entry or the language of the app
+func (a *App) DetectDocumentLanguage(pdfPath string) (_ DocumentLanguage, err error) {
+	if override := a.GetDocumentLanguageOverride(); override != "" {
+		l, _ := findDocumentLocale(override)
+		return l.language(LanguageSourceOverride, 1), nil
+	}
+	pdfPath = filepath.Clean(pdfPath)
+	defer recoverDamaged(pdfPath, &err)
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return DocumentLanguage{}, err
+	}
+	ctx, err := api.ReadContextFile(pdfPath)
+	if err != nil {
+		return DocumentLanguage{}, fmt.Errorf("failed to read pdf: %v", err)
+	}
+	if err := ctx.EnsurePageCount(); err != nil {
+		return DocumentLanguage{}, fmt.Errorf("failed to read pdf: %v", err)
+	}
+	lang := detectDocumentLanguage(ctx)
+	fmt.Printf("Backend: Document language of %s is %s (%s)\n", filepath.Base(pdfPath), lang.Code, lang.Source)
+	return lang, nil
+}
+
+// documentLanguage is DetectDocumentLanguage for operations that only use the language
+// for defaults; a document that cannot be read gets the fallback language
+func (a *App) documentLanguage(pdfPath string) DocumentLanguage {
+	lang, err := a.DetectDocumentLanguage(pdfPath)
+	if err != nil {
+		l, _ := findDocumentLocale(language())
+		return l.language(LanguageSourceDefault, 0)
+	}
+	return lang
+}
+
+// detectDocumentLanguage samples the text of the first pages and falls back to the
+// /Lang entry of the catalog and then to the language of the app
+func detectDocumentLanguage(ctx *model.Context) DocumentLanguage {
+	x := newTextExtractor(ctx, languageSampleBytes)
+	for p := 1; p <= ctx.PageCount && p <= languageSamplePages && !x.full(); p++ {
+		catchCrash(func() error { x.page(p); return nil })
+	}
+	if code, confidence := detectLanguage(x.text.String()); code != "" {
+		l, _ := findDocumentLocale(code)
+		return l.language(LanguageSourceText, confidence)
+	}
+	if catalog, err := ctx.Catalog(); err == nil {
+		if l, ok := findDocumentLocale(infoText(ctx, catalog, "Lang")); ok {
+			return l.language(LanguageSourceMetadata, 0)
+		}
+	}
+	l, _ := findDocumentLocale(language())
+	return l.language(LanguageSourceDefault, 0)
+}
+
+// detectLanguage returns the language of a text and the confidence, "" when the text
+// is too short or in no language CapGo knows. The script decides first; Latin text is
+// told apart by counting stopwords.
+func detectLanguage(text string) (string, float64) {
+	var letters, latin, cyrillic, han, kana, hangul int
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		}
+	}
+	if letters < minLanguageLetters {
+		return "", 0
+	}
+	share := func(n int) float64 { return roundConfidence(float64(n) / float64(letters)) }
+	switch {
+	case hangul*2 > letters:
+		return "ko", share(hangul)
+	// Japanese mixes kana with kanji; Chinese has no kana at all
+	case kana*10 > letters && (kana+han)*2 > letters:
+		return "ja", share(kana + han)
+	case han*2 > letters:
+		return "zh", share(han)
+	case cyrillic*2 > letters:
+		return "ru", share(cyrillic)
+	case latin*2 <= letters:
+		return "", 0
+	}
+
+	scores := map[string]int{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		for code, set := range stopwords {
+			if set[word] {
+				scores[code]++
+			}
+		}
+		if strings.ContainsAny(word, vietnameseLetters) {
+			scores["vi"]++
+		}
+	}
+	best, total := "", 0
+	for _, l := range documentLocales {
+		total += scores[l.code]
+		if scores[l.code] > scores[best] {
+			best = l.code
+		}
+	}
+	if best == "" || scores[best] < minLanguageWords {
+		return "", 0
+	}
+	return best, roundConfidence(float64(scores[best]) / float64(total))
+}
+
+func roundConfidence(f float64) float64 {
+	return float64(int(f*100+0.5)) / 100
+}
+
+// language describes the locale as a DocumentLanguage
+func (l documentLocale) language(source string, confidence float64) DocumentLanguage {
+	ocr := l.ocr
+	if ocr != "eng" {
+		// Documents in other languages often quote English names and terms
+		ocr += "+eng"
+	}
+	return DocumentLanguage{Code: l.code, Name: l.name, OCR: ocr, Source: source, Confidence: confidence}
+}
+
+// formatDate writes a date in the long or short format of the locale
+func (l documentLocale) formatDate(t time.Time, short bool) string {
+	layout := l.longDate
+	if short {
+		layout = l.shortDate
+	}
+	month := fmt.Sprint(int(t.Month()))
+	if names := strings.Split(l.months, ","); len(names) == 12 {
+		month = names[t.Month()-1]
+	}
+	return strings.NewReplacer(
+		"{dd}", fmt.Sprintf("%02d", t.Day()),
+		"{d}", fmt.Sprint(t.Day()),
+		"{mm}", fmt.Sprintf("%02d", int(t.Month())),
+		"{month}", month,
+		"{m}", fmt.Sprint(int(t.Month())),
+		"{yyyy}", fmt.Sprint(t.Year()),
+	).Replace(layout)
+}
+
+// expandDatePlaceholders replaces {date} and {date:short} with today's date in the
+// language of the document. The document is only read when a stamp has a date.
+func (a *App) expandDatePlaceholders(pdfPath string, stamps []StampInfo) []StampInfo {
+	var locale *documentLocale
+	out := make([]StampInfo, len(stamps))
+	for i, s := range stamps {
+		out[i] = s
+		if s.Kind != StampKindText || !datePlaceholder.MatchString(s.Text) {
+			continue
+		}
+		if locale == nil {
+			l, _ := findDocumentLocale(a.documentLanguage(pdfPath).Code)
+			locale = &l
+		}
+		now := a.now()
+		out[i].Text = datePlaceholder.ReplaceAllStringFunc(s.Text, func(m string) string {
+			return locale.formatDate(now, datePlaceholder.FindStringSubmatch(m)[1] == "short")
+		})
+	}
+	return out
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"CapGo/pkg/stamper"
+)
+
+// DroppedFile describes a file dragged onto the window from Finder or Explorer
+type DroppedFile struct {
+	Path  string     `json:"path"`
+	Type  FileType   `json:"type"`
+	Info  *PDFInfo   `json:"info,omitempty"`  // for PDFs that could be read
+	Pages []PageSize `json:"pages,omitempty"` // size of every page of a PDF as displayed
+	Error string     `json:"error,omitempty"` // why the file cannot be opened
+}
+
+// PageSize is the size of a page in points
+type PageSize struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// FileDrop is the payload of EventFileDropped
+type FileDrop struct {
+	X     int           `json:"x"` // where the files were dropped, in window coordinates
+	Y     int           `json:"y"`
+	Files []DroppedFile `json:"files"`
+}
+
+// onFileDrop receives the files dropped onto the window and sends what they are to the
+// frontend as EventFileDropped
+func (a *App) onFileDrop(x, y int, paths []string) {
+	fmt.Printf("Backend: %d files dropped onto the window\n", len(paths))
+	a.emit(EventFileDropped, FileDrop{X: x, Y: y, Files: a.IngestFiles(paths)})
+}
+
+// IngestFiles checks files the user wants to open by their content. PDFs come with
+// their document information and page sizes and go on the recent files list; images and
+// office documents are only typed, since they are converted when opened. A file that
+// cannot be opened has Error set.
+func (a *App) IngestFiles(paths []string) []DroppedFile {
+	files := make([]DroppedFile, len(paths))
+	for i, p := range paths {
+		files[i] = a.ingestFile(filepath.Clean(p))
+	}
+	return files
+}
+
+func (a *App) ingestFile(path string) DroppedFile {
+	f := DroppedFile{Path: path}
+	if info, err := os.Stat(path); err != nil {
+		f.Error = classifyFileError("read", path, err).Error()
+		return f
+	} else if info.IsDir() {
+		f.Error = tr("%s is a folder", filepath.Base(path))
+		return f
+	}
+	t, err := a.SniffFile(path)
+	if err != nil {
+		f.Error = err.Error()
+		return f
+	}
+	f.Type = t
+	switch t.Kind {
+	case FileKindPDF:
+	case FileKindImage, FileKindOffice:
+		return f
+	default:
+		f.Error = tr("%s is not a PDF, image or office document", filepath.Base(path))
+		return f
+	}
+
+	if err := catchCrash(func() error {
+		info, err := a.GetPDFInfo(path)
+		if err != nil {
+			return err
+		}
+		dims, err := stamper.VisiblePageDimsFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to get page dimensions for %s: %v", path, err)
+		}
+		f.Info = &info
+		addRecentFile(path)
+		f.Pages = make([]PageSize, len(dims))
+		for i, d := range dims {
+			f.Pages[i] = PageSize{Width: d.Width, Height: d.Height}
+		}
+		return nil
+	}); err != nil {
+		f.Info, f.Pages = nil, nil
+		f.Error = err.Error()
+		var crash *pdfCrash
+		if errors.As(err, &crash) {
+			f.Error = damagedFileError(path).Error()
+		}
+	}
+	return f
+}
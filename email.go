@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// EmailAttachment is one file prepared by PackageForEmail
+type EmailAttachment struct {
+	Path    string   `json:"path"`
+	Size    int64    `json:"size"`
+	Sources []string `json:"sources"` // the files it was made from
+	// Fits is false when the file is still over the limit, e.g. a single page with a
+	// huge image or a file that is not a PDF
+	Fits bool `json:"fits"`
+}
+
+// EmailPackage is the outcome of PackageForEmail
+type EmailPackage struct {
+	Folder      string            `json:"folder"` // where the attachments were written
+	Attachments []EmailAttachment `json:"attachments"`
+	TotalSize   int64             `json:"totalSize"`
+}
+
+// PackageForEmail prepares files to be sent as attachments of at most maxAttachmentMB
+// each. PDFs over the limit are optimized and, when that is not enough, split into
+// parts by page. With zipFiles the results are packed into as few archives under the
+// limit as possible. The sources are left unchanged; everything is written to a new
+// folder in the output folder.
+func (a *App) PackageForEmail(paths []string, maxAttachmentMB float64, zipFiles bool) (EmailPackage, error) {
+	if len(paths) == 0 {
+		return EmailPackage{}, fmt.Errorf("no files given")
+	}
+	if maxAttachmentMB <= 0 {
+		return EmailPackage{}, fmt.Errorf("size target must be positive")
+	}
+	limit := int64(maxAttachmentMB * 1024 * 1024)
+	for i, p := range paths {
+		paths[i] = filepath.Clean(p)
+		if err := a.ensureLocal(paths[i]); err != nil {
+			return EmailPackage{}, err
+		}
+		if info, err := os.Stat(paths[i]); err != nil {
+			return EmailPackage{}, classifyFileError("read", paths[i], err)
+		} else if info.IsDir() {
+			return EmailPackage{}, fmt.Errorf("%s is a folder", paths[i])
+		}
+	}
+
+	base, err := a.outputBaseDir()
+	if err != nil {
+		return EmailPackage{}, err
+	}
+	dir, err := a.outputDir(uniquePath(filepath.Join(base, outputName(paths[0], "_email", ""))))
+	if err != nil {
+		return EmailPackage{}, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return EmailPackage{}, classifyFileError("write", dir, err)
+	}
+
+	defer a.startJob("email")()
+	var attachments []EmailAttachment
+	for _, p := range paths {
+		prepared, err := prepareAttachment(p, dir, limit)
+		if err != nil {
+			os.RemoveAll(dir)
+			return EmailPackage{}, fmt.Errorf("failed to prepare %s: %v", filepath.Base(p), err)
+		}
+		attachments = append(attachments, prepared...)
+	}
+	if zipFiles {
+		if attachments, err = zipAttachments(attachments, dir, outputName(paths[0], "_email", ""), limit); err != nil {
+			os.RemoveAll(dir)
+			return EmailPackage{}, err
+		}
+	}
+
+	pkg := EmailPackage{Folder: dir, Attachments: attachments}
+	for _, at := range attachments {
+		pkg.TotalSize += at.Size
+	}
+	fmt.Printf("Backend: Packaged %d files into %d attachments in %s\n", len(paths), len(attachments), dir)
+	return pkg, nil
+}
+
+// prepareAttachment writes a source to dir as one or more attachments under limit
+func prepareAttachment(src, dir string, limit int64) ([]EmailAttachment, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, classifyFileError("read", src, err)
+	}
+	out := uniquePath(filepath.Join(dir, filepath.Base(src)))
+	isPDF := strings.EqualFold(filepath.Ext(src), ".pdf")
+	if info.Size() <= limit || !isPDF {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return nil, classifyFileError("read", src, err)
+		}
+		if err := os.WriteFile(out, data, 0644); err != nil {
+			return nil, classifyFileError("write", out, err)
+		}
+		return []EmailAttachment{{Path: out, Size: info.Size(), Sources: []string{src}, Fits: info.Size() <= limit}}, nil
+	}
+
+	var res SizeTargetResult
+	if err := catchCrash(func() error {
+		res, err = optimizeToSize(src, out, limit)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if res.Reached {
+		fmt.Printf("Backend: Optimized %s to %d bytes for email\n", src, res.Size)
+		return []EmailAttachment{{Path: out, Size: res.Size, Sources: []string{src}, Fits: true}}, nil
+	}
+
+	// The smallest version is split, so the parts are as few as they can be
+	defer os.Remove(out)
+	parts, err := splitToSize(out, limit)
+	if err != nil {
+		return nil, err
+	}
+	attachments := make([]EmailAttachment, len(parts))
+	for i, part := range parts {
+		path := uniquePath(filepath.Join(dir, outputName(src, fmt.Sprintf("_part%d", i+1), ".pdf")))
+		if err := os.WriteFile(path, part, 0644); err != nil {
+			return nil, classifyFileError("write", path, err)
+		}
+		size := int64(len(part))
+		attachments[i] = EmailAttachment{Path: path, Size: size, Sources: []string{src}, Fits: size <= limit}
+	}
+	fmt.Printf("Backend: Split %s into %d parts for email\n", src, len(parts))
+	return attachments, nil
+}
+
+// splitToSize returns the pages of a PDF as documents of consecutive pages no larger
+// than limit. A range that is too large is halved until it fits or is a single page.
+func splitToSize(pdfPath string, limit int64) ([][]byte, error) {
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return nil, classifyFileError("read", pdfPath, err)
+	}
+	pageCount, err := api.PageCount(bytes.NewReader(data), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %v", err)
+	}
+
+	var parts [][]byte
+	var split func(r pageRange) error
+	split = func(r pageRange) error {
+		var buf bytes.Buffer
+		if err := api.Trim(bytes.NewReader(data), &buf, []string{r.String()}, nil); err != nil {
+			return fmt.Errorf("failed to extract pages %s: %v", r, err)
+		}
+		if int64(buf.Len()) <= limit || r.From == r.To {
+			parts = append(parts, buf.Bytes())
+			return nil
+		}
+		mid := (r.From + r.To) / 2
+		if err := split(pageRange{From: r.From, To: mid}); err != nil {
+			return err
+		}
+		return split(pageRange{From: mid + 1, To: r.To})
+	}
+	if err := split(pageRange{From: 1, To: pageCount}); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// zipAttachments packs the attachments in order into archives named after name, starting
+// a new archive whenever the next file would take the current one over limit. PDFs
+// hardly compress, so their size is what they add to an archive.
+func zipAttachments(attachments []EmailAttachment, dir, name string, limit int64) ([]EmailAttachment, error) {
+	var groups [][]EmailAttachment
+	var size int64
+	for _, at := range attachments {
+		if len(groups) == 0 || size+at.Size > limit {
+			groups = append(groups, nil)
+			size = 0
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], at)
+		size += at.Size
+	}
+
+	zipped := make([]EmailAttachment, len(groups))
+	for i, group := range groups {
+		suffix := ""
+		if len(groups) > 1 {
+			suffix = fmt.Sprintf("_%d", i+1)
+		}
+		output := uniquePath(filepath.Join(dir, name+suffix+".zip"))
+		files := make([]string, len(group))
+		var sources []string
+		for j, at := range group {
+			files[j] = at.Path
+			sources = append(sources, at.Sources...)
+		}
+		if err := writeZipOutputs(files, output); err != nil {
+			os.Remove(output)
+			return nil, err
+		}
+		info, err := os.Stat(output)
+		if err != nil {
+			return nil, classifyFileError("read", output, err)
+		}
+		zipped[i] = EmailAttachment{Path: output, Size: info.Size(), Sources: uniqueStrings(sources), Fits: info.Size() <= limit}
+		for _, f := range files {
+			os.Remove(f)
+		}
+	}
+	return zipped, nil
+}
+
+// uniqueStrings returns s without repeated values, in order
+func uniqueStrings(s []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
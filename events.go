@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Events sent from the backend to the frontend. Every event is described in
+// eventCatalog; GetEventCatalog lists them for the frontend.
+const (
+	EventJobStarted          = "job:started"
+	EventJobEnded            = "job:ended"
+	EventJobFinished         = "job:finished"
+	EventJobResult           = "job:result"
+	EventDocumentChanged     = "document:changed"
+	EventUpdateAvailable     = "update:available"
+	EventWatchActivity       = "watch:activity"
+	EventMailMergeProgress   = "mailmerge:progress"
+	EventCertificateProgress = "certificates:progress"
+	EventBatchStampProgress  = "batchstamp:progress"
+	EventStampProgress       = "stamp:progress"
+	EventOptimizeProgress    = "optimize:progress"
+	EventScriptProgress      = "script:progress"
+	EventICloudProgress      = "icloud:progress"
+	EventStampSizeTarget     = "stamp:sizeTarget"
+	EventStampAccessibility  = "stamp:accessibility"
+	EventStampSafeArea       = "stamp:safeArea"
+	EventOutputFallback      = "output:fallback"
+	EventHookFailed          = "hook:failed"
+	EventHotkeyError         = "hotkey:error"
+	EventCaptureSignature    = "hotkey:captureSignature"
+	EventQuickStampDone      = "quickstamp:done"
+	EventInstanceLaunched    = "instance:launched"
+	EventBadgeChanged        = "badge:changed"
+	EventWindowClosed        = "window:closed"
+	EventMenuOpen            = "menu:open"
+	EventMenuSave            = "menu:save"
+	EventMenuUndo            = "menu:undo"
+	EventMenuRedo            = "menu:redo"
+	EventMenuSplit           = "menu:split"
+	EventMenuMerged          = "menu:merged"
+	EventMenuError           = "menu:error"
+	EventFileDropped         = "file:dropped"
+)
+
+// JobEnded is the payload of EventJobEnded
+type JobEnded struct {
+	ActiveJob
+	EndedAt time.Time `json:"endedAt"`
+}
+
+// WatchActivity is the payload of EventWatchActivity, sent when a watched folder
+// picks up or finishes a file
+type WatchActivity struct {
+	Folder string `json:"folder"`
+	File   string `json:"file"`
+	Action string `json:"action"` // "added", "processed" or "failed"
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// EventField is one field of an object payload
+type EventField struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // JSON type: string, number, boolean, array or object
+}
+
+// EventSpec describes an event and its payload
+type EventSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Version goes up whenever the payload changes in a way listeners have to handle
+	Version int          `json:"version"`
+	Payload string       `json:"payload,omitempty"` // JSON type of the payload, empty for none
+	Fields  []EventField `json:"fields,omitempty"`  // fields of an object payload
+	// sample is sent by EmitTest and defines the payload type
+	sample interface{}
+}
+
+var eventCatalog = []EventSpec{
+	{Name: EventJobStarted, Version: 1, Description: "an operation started, see GetResourceStats",
+		sample: ActiveJob{ID: 1, Kind: "stamp", StartedAt: time.Now()}},
+	{Name: EventJobEnded, Version: 1, Description: "an operation ended, successfully or not",
+		sample: JobEnded{ActiveJob: ActiveJob{ID: 1, Kind: "stamp", StartedAt: time.Now()}, EndedAt: time.Now()}},
+	{Name: EventJobFinished, Version: 1, Description: "a background job finished and the user should be told",
+		sample: JobFinished{Job: "mailmerge", Message: "Mail merge created 3 documents"}},
+	{Name: EventJobResult, Version: 1, Description: "a job started with StartStampPDF, StartOptimizePDF or StartBatchStampPDFs is done",
+		sample: JobResult{JobID: "stamp-1", Kind: "stamp", Result: "/Users/example/Downloads/contract_capgo.pdf"}},
+	{Name: EventDocumentChanged, Version: 1, Description: "the open document or its state changed",
+		sample: DocumentState{Path: "/Users/example/contract.pdf", Pages: 3}},
+	{Name: EventUpdateAvailable, Version: 1, Description: "CheckForUpdates found a newer version",
+		sample: UpdateResult{UpdateAvailable: true, LatestVersion: "v9.9.9", CurrentVersion: "v1.0.0"}},
+	{Name: EventWatchActivity, Version: 1, Description: "a watched folder picked up or finished a file",
+		sample: WatchActivity{Folder: "/Users/example/Inbox", File: "invoice.pdf", Action: "added"}},
+	{Name: EventMailMergeProgress, Version: 1, Description: "a mail merge item finished or failed",
+		sample: MailMergeProgress{JobID: "mailmerge-1", Current: 1, Total: 3, Output: "letter_1.pdf"}},
+	{Name: EventCertificateProgress, Version: 1, Description: "a certificate was generated or failed",
+		sample: MailMergeProgress{JobID: "certificates-1", Current: 1, Total: 3, Output: "certificate_1.pdf"}},
+	{Name: EventBatchStampProgress, Version: 1, Description: "BatchStampPDFs finished or failed one file",
+		sample: BatchStampProgress{JobID: "batchstamp-1", Current: 1, Total: 50, Item: BatchStampItem{Source: "/Users/example/invoice.pdf", Output: "/Users/example/Downloads/invoice_capgo.pdf"}}},
+	{Name: EventStampProgress, Version: 1, Description: "a job started with StartStampPDF entered a stage",
+		sample: JobProgress{JobID: "stamp-1", Stage: StageStamping, Current: 2, Total: len(stampStages)}},
+	{Name: EventOptimizeProgress, Version: 1, Description: "a job started with StartOptimizePDF entered a stage",
+		sample: JobProgress{JobID: "optimize-1", Stage: StageImages, Current: 2, Total: len(optimizeStages)}},
+	{Name: EventScriptProgress, Version: 1, Description: "a script finished one file",
+		sample: ScriptProgress{Script: "Approve", Done: 1, Total: 2}},
+	{Name: EventICloudProgress, Version: 1, Description: "an iCloud document is being downloaded",
+		sample: ICloudProgress{Path: "/Users/example/contract.pdf", Size: 2048, Downloaded: 1024}},
+	{Name: EventStampSizeTarget, Version: 1, Description: "a stamped file was shrunk to the size limit",
+		sample: SizeTargetResult{Output: "contract_capgo.pdf", Size: 900000, TargetSize: 1000000, Reached: true}},
+	{Name: EventStampAccessibility, Version: 1, Description: "stamping lost accessibility information",
+		sample: AccessibilityReport{Issues: []string{"the document is no longer tagged"}}},
+	{Name: EventStampSafeArea, Version: 1, Description: "stamps were placed closer to the page edges than the safe area allows, which only warns",
+		sample: SafeAreaReport{Document: "/Users/example/contract.pdf", Warnings: []StampWarning{{Index: 0, PageNum: 1, Code: "outside_safe_area", Message: "stamp 0 is closer than 10 mm to the edge of page 1"}}}},
+	{Name: EventOutputFallback, Version: 1, Description: "the output folder was not writable and another was used",
+		sample: OutputFallback{Requested: "/Volumes/Share", Used: "/Users/example/Downloads", Kind: FileErrorOffline}},
+	{Name: EventHookFailed, Version: 1, Description: "an after hook failed",
+		sample: HookFailure{Hook: Hook{Operation: "stamp", Stage: HookAfter, Command: "upload"}, File: "contract_capgo.pdf", Error: "exit status 1"}},
+	{Name: EventHotkeyError, Version: 1, Description: "a global hotkey could not be registered",
+		sample: "Ctrl+Shift+S is used by another application"},
+	{Name: EventCaptureSignature, Version: 1, Description: "the capture signature hotkey was pressed"},
+	{Name: EventQuickStampDone, Version: 1, Description: "a quick stamp was applied; the payload is the output path",
+		sample: "/Users/example/Downloads/contract_capgo.pdf"},
+	{Name: EventInstanceLaunched, Version: 1, Description: "CapGo was launched again, possibly with files to open",
+		sample: SecondInstance{Files: []string{"/Users/example/contract.pdf"}}},
+	{Name: EventBadgeChanged, Version: 1, Description: "the number of finished jobs waiting for the user changed",
+		sample: 2},
+	{Name: EventWindowClosed, Version: 1, Description: "a child window was closed",
+		sample: WindowInfo{ID: "preview-1", Kind: WindowPreview, Title: "Preview"}},
+	{Name: EventMenuOpen, Version: 1, Description: "a document was picked from the File menu; the payload is its path",
+		sample: "/Users/example/contract.pdf"},
+	{Name: EventMenuSave, Version: 1, Description: "File > Save was chosen"},
+	{Name: EventMenuUndo, Version: 1, Description: "Edit > Undo was chosen"},
+	{Name: EventMenuRedo, Version: 1, Description: "Edit > Redo was chosen"},
+	{Name: EventMenuSplit, Version: 1, Description: "Tools > Split was chosen"},
+	{Name: EventMenuMerged, Version: 1, Description: "documents were merged from the Tools menu; the payload is the output path",
+		sample: "/Users/example/Downloads/merged.pdf"},
+	{Name: EventMenuError, Version: 1, Description: "a menu action failed; the payload is the message",
+		sample: "failed to count pages"},
+	{Name: EventFileDropped, Version: 1, Description: "files were dropped onto the window, see IngestFiles",
+		sample: FileDrop{X: 120, Y: 80, Files: []DroppedFile{{Path: "/Users/example/contract.pdf", Type: FileType{Kind: FileKindPDF, MIME: "application/pdf"}, Pages: []PageSize{{Width: 595, Height: 842}}}}}},
+}
+
+// eventSpecs indexes eventCatalog by name
+var eventSpecs = func() map[string]EventSpec {
+	specs := map[string]EventSpec{}
+	for _, spec := range eventCatalog {
+		if spec.sample != nil {
+			spec.Payload, spec.Fields = payloadSchema(reflect.TypeOf(spec.sample))
+		}
+		specs[spec.Name] = spec
+	}
+	return specs
+}()
+
+// GetEventCatalog returns every event the backend sends, sorted as in the catalog
+func (a *App) GetEventCatalog() []EventSpec {
+	specs := make([]EventSpec, len(eventCatalog))
+	for i, spec := range eventCatalog {
+		specs[i] = eventSpecs[spec.Name]
+	}
+	return specs
+}
+
+// EmitTest sends an event with a sample payload, so the frontend can be developed
+// without triggering the real operation
+func (a *App) EmitTest(name string) error {
+	spec, ok := eventSpecs[name]
+	if !ok {
+		return fmt.Errorf("unknown event: %s", name)
+	}
+	if spec.sample == nil {
+		a.emit(name)
+	} else {
+		a.emit(name, spec.sample)
+	}
+	return nil
+}
+
+// payloadSchema returns the JSON type of a payload and the fields of an object
+func payloadSchema(t reflect.Type) (string, []EventField) {
+	if t.Kind() != reflect.Struct || t == reflect.TypeOf(time.Time{}) {
+		return jsonType(t), nil
+	}
+	fields := []EventField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if f.Anonymous {
+			_, embedded := payloadSchema(f.Type)
+			fields = append(fields, embedded...)
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, EventField{Name: name, Type: jsonType(f.Type)})
+	}
+	return "object", fields
+}
+
+// jsonType returns the JSON type a Go type is encoded as
+func jsonType(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Time{}) {
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return jsonType(t.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
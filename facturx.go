@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Factur-X / ZUGFeRD profiles
+var invoiceProfiles = map[string]string{
+	"MINIMUM":   "MINIMUM",
+	"BASICWL":   "BASIC WL",
+	"BASIC":     "BASIC",
+	"EN16931":   "EN 16931",
+	"COMFORT":   "EN 16931", // ZUGFeRD name of EN 16931
+	"EXTENDED":  "EXTENDED",
+	"XRECHNUNG": "XRECHNUNG",
+}
+
+// AttachInvoiceXML embeds a Factur-X / ZUGFeRD invoice XML into the PDF and adds the
+// PDF/A-3 structure the standard requires: the XML as associated file, XMP metadata with
+// the Factur-X extension schema and an sRGB output intent. Content level PDF/A rules such
+// as embedded fonts are not changed, so the source PDF should already be PDF/A compatible.
+// The result is written next to the other CapGo output and its path returned.
+func (a *App) AttachInvoiceXML(pdfPath string, xmlPath string, profile string) (string, error) {
+	pdfPath = filepath.Clean(pdfPath)
+	xmlPath = filepath.Clean(xmlPath)
+
+	level, ok := invoiceProfiles[strings.ToUpper(strings.NewReplacer(" ", "", "_", "", "-", "").Replace(profile))]
+	if !ok {
+		return "", fmt.Errorf("unknown invoice profile: %s", profile)
+	}
+
+	invoice, err := os.ReadFile(xmlPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read invoice xml: %v", err)
+	}
+	if err := xml.Unmarshal(invoice, new(struct{})); err != nil {
+		return "", fmt.Errorf("invoice is not valid xml: %v", err)
+	}
+
+	ctx, err := api.ReadContextFile(pdfPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read pdf: %v", err)
+	}
+	if ctx.Encrypt != nil {
+		return "", fmt.Errorf("encrypted documents cannot be converted to PDF/A-3")
+	}
+
+	// XRechnung uses its own file name; all other profiles use factur-x.xml
+	fileName := "factur-x.xml"
+	relationship := "Alternative"
+	switch level {
+	case "XRECHNUNG":
+		fileName = "xrechnung.xml"
+	case "MINIMUM", "BASIC WL":
+		// These profiles are not full invoices in the legal sense
+		relationship = "Data"
+	}
+
+	// The XMP has to match the info dictionary, which deterministic output rewrites
+	now, producer := a.now(), "pdfcpu "+model.VersionStr
+	if a.GetDeterministicOutput() {
+		producer = deterministicProducer
+	}
+	if err := attachAssociatedFile(ctx, fileName, invoice, relationship, now); err != nil {
+		return "", fmt.Errorf("failed to attach invoice: %v", err)
+	}
+	if err := setPDFAMetadata(ctx, fileName, level, now, producer); err != nil {
+		return "", fmt.Errorf("failed to write metadata: %v", err)
+	}
+	if err := ensureOutputIntent(ctx); err != nil {
+		return "", fmt.Errorf("failed to add output intent: %v", err)
+	}
+
+	outputPath, err := a.outputPath(pdfPath, "_facturx")
+	if err != nil {
+		return "", err
+	}
+	if err := api.WriteContextFile(ctx, outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", fmt.Errorf("failed to write pdf: %v", err)
+	}
+	if err := a.finishOutput(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	return outputPath, nil
+}
+
+// attachAssociatedFile embeds data as a PDF/A-3 associated file, replacing an earlier
+// attachment with the same name. modDate is the modification date of the embedded file.
+func attachAssociatedFile(ctx *model.Context, fileName string, data []byte, relationship string, modDate time.Time) error {
+	xRefTable := ctx.XRefTable
+	if err := xRefTable.LocateNameTree("EmbeddedFiles", false); err != nil {
+		return err
+	}
+	if xRefTable.Names["EmbeddedFiles"] != nil {
+		if _, err := ctx.RemoveAttachments([]string{fileName}); err != nil {
+			return err
+		}
+	}
+	// Removing the last attachment drops the name tree, so it is located again
+	if err := xRefTable.LocateNameTree("EmbeddedFiles", true); err != nil {
+		return err
+	}
+
+	sd, err := xRefTable.NewStreamDictForBuf(data)
+	if err != nil {
+		return err
+	}
+	sd.InsertName("Type", "EmbeddedFile")
+	sd.InsertName("Subtype", "text/xml")
+	params := types.NewDict()
+	params.InsertInt("Size", len(data))
+	params.Insert("ModDate", types.StringLiteral(types.DateString(modDate)))
+	sd.Insert("Params", params)
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+	streamRef, err := xRefTable.IndRefForNewObject(*sd)
+	if err != nil {
+		return err
+	}
+
+	fileSpec, err := xRefTable.NewFileSpecDict(fileName, fileName, "Invoice", *streamRef)
+	if err != nil {
+		return err
+	}
+	fileSpec.Delete("CI")
+	fileSpec.InsertName("AFRelationship", relationship)
+	specRef, err := xRefTable.IndRefForNewObject(fileSpec)
+	if err != nil {
+		return err
+	}
+
+	m := model.NameMap{fileName: []types.Dict{fileSpec}}
+	if err := xRefTable.Names["EmbeddedFiles"].Add(xRefTable, fileName, *specRef, m, []string{"F", "UF"}); err != nil {
+		return err
+	}
+
+	// The catalog lists the associated files of the document
+	catalog, err := xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+	catalog.Update("AF", types.Array{*specRef})
+	return nil
+}
+
+// setPDFAMetadata replaces the XMP metadata with a PDF/A-3B packet declaring the Factur-X
+// schema. date and producer are the values the info dictionary is written with.
+func setPDFAMetadata(ctx *model.Context, fileName, level string, date time.Time, producer string) error {
+	catalog, err := ctx.XRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	now := date.Format(time.RFC3339)
+
+	var dc strings.Builder
+	if ctx.Title != "" {
+		fmt.Fprintf(&dc, `<dc:title><rdf:Alt><rdf:li xml:lang="x-default">%s</rdf:li></rdf:Alt></dc:title>`, xmlEscape(ctx.Title))
+	}
+	if ctx.Author != "" {
+		fmt.Fprintf(&dc, `<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>`, xmlEscape(ctx.Author))
+	}
+	if ctx.Subject != "" {
+		fmt.Fprintf(&dc, `<dc:description><rdf:Alt><rdf:li xml:lang="x-default">%s</rdf:li></rdf:Alt></dc:description>`, xmlEscape(ctx.Subject))
+	}
+
+	xmp := fmt.Sprintf(facturXMP, dc.String(), producer, now, now, now, xmlEscape(fileName), level)
+
+	// PDF/A requires the metadata stream to stay uncompressed
+	sd := types.StreamDict{Dict: types.NewDict(), Content: []byte(xmp)}
+	sd.InsertName("Type", "Metadata")
+	sd.InsertName("Subtype", "XML")
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+	ref, err := ctx.XRefTable.IndRefForNewObject(sd)
+	if err != nil {
+		return err
+	}
+	catalog.Update("Metadata", *ref)
+	return nil
+}
+
+// ensureOutputIntent adds a PDF/A sRGB output intent unless the document already has one
+func ensureOutputIntent(ctx *model.Context) error {
+	catalog, err := ctx.XRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+	if o, found := catalog.Find("OutputIntents"); found {
+		if arr, err := ctx.XRefTable.DereferenceArray(o); err == nil && len(arr) > 0 {
+			return nil
+		}
+	}
+
+	sd, err := ctx.XRefTable.NewStreamDictForBuf(srgbICCProfile())
+	if err != nil {
+		return err
+	}
+	sd.InsertInt("N", 3)
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+	profileRef, err := ctx.XRefTable.IndRefForNewObject(*sd)
+	if err != nil {
+		return err
+	}
+
+	intent := types.NewDict()
+	intent.InsertName("Type", "OutputIntent")
+	intent.InsertName("S", "GTS_PDFA1")
+	intent.InsertString("OutputConditionIdentifier", "sRGB IEC61966-2.1")
+	intent.InsertString("Info", "sRGB IEC61966-2.1")
+	intent.Insert("DestOutputProfile", *profileRef)
+
+	catalog.Update("OutputIntents", types.Array{intent})
+	return nil
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// facturXMP is the XMP packet for a PDF/A-3B Factur-X invoice, including the extension
+// schema description PDF/A validators require for the fx namespace
+const facturXMP = `<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">%s</rdf:Description>
+<rdf:Description rdf:about="" xmlns:pdf="http://ns.adobe.com/pdf/1.3/">
+<pdf:Producer>%s</pdf:Producer>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/">
+<xmp:CreateDate>%s</xmp:CreateDate>
+<xmp:ModifyDate>%s</xmp:ModifyDate>
+<xmp:MetadataDate>%s</xmp:MetadataDate>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/">
+<pdfaid:part>3</pdfaid:part>
+<pdfaid:conformance>B</pdfaid:conformance>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:fx="urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#">
+<fx:DocumentType>INVOICE</fx:DocumentType>
+<fx:DocumentFileName>%s</fx:DocumentFileName>
+<fx:Version>1.0</fx:Version>
+<fx:ConformanceLevel>%s</fx:ConformanceLevel>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:pdfaExtension="http://www.aiim.org/pdfa/ns/extension/" xmlns:pdfaSchema="http://www.aiim.org/pdfa/ns/schema#" xmlns:pdfaProperty="http://www.aiim.org/pdfa/ns/property#">
+<pdfaExtension:schemas>
+<rdf:Bag>
+<rdf:li rdf:parseType="Resource">
+<pdfaSchema:schema>Factur-X PDFA Extension Schema</pdfaSchema:schema>
+<pdfaSchema:namespaceURI>urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#</pdfaSchema:namespaceURI>
+<pdfaSchema:prefix>fx</pdfaSchema:prefix>
+<pdfaSchema:property>
+<rdf:Seq>
+<rdf:li rdf:parseType="Resource">
+<pdfaProperty:name>DocumentFileName</pdfaProperty:name>
+<pdfaProperty:valueType>Text</pdfaProperty:valueType>
+<pdfaProperty:category>external</pdfaProperty:category>
+<pdfaProperty:description>name of the embedded XML invoice file</pdfaProperty:description>
+</rdf:li>
+<rdf:li rdf:parseType="Resource">
+<pdfaProperty:name>DocumentType</pdfaProperty:name>
+<pdfaProperty:valueType>Text</pdfaProperty:valueType>
+<pdfaProperty:category>external</pdfaProperty:category>
+<pdfaProperty:description>INVOICE</pdfaProperty:description>
+</rdf:li>
+<rdf:li rdf:parseType="Resource">
+<pdfaProperty:name>Version</pdfaProperty:name>
+<pdfaProperty:valueType>Text</pdfaProperty:valueType>
+<pdfaProperty:category>external</pdfaProperty:category>
+<pdfaProperty:description>The actual version of the Factur-X XML schema</pdfaProperty:description>
+</rdf:li>
+<rdf:li rdf:parseType="Resource">
+<pdfaProperty:name>ConformanceLevel</pdfaProperty:name>
+<pdfaProperty:valueType>Text</pdfaProperty:valueType>
+<pdfaProperty:category>external</pdfaProperty:category>
+<pdfaProperty:description>The conformance level of the embedded Factur-X data</pdfaProperty:description>
+</rdf:li>
+</rdf:Seq>
+</pdfaSchema:property>
+</rdf:li>
+</rdf:Bag>
+</pdfaExtension:schemas>
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	goruntime "runtime"
+	"strings"
+	"sync"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// feedbackIssueURL is where feedback reports are filed
+const feedbackIssueURL = "https://github.com/lelehuy/CapGo/issues/new"
+
+// maxFeedbackURL keeps prefilled issue links below the length GitHub accepts
+const maxFeedbackURL = 8000
+
+// recentLogLines is how much of the log a feedback report can include
+const recentLogLines = 200
+
+// FeedbackReport is the issue prepared by PrepareFeedback
+type FeedbackReport struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url"` // GitHub "new issue" link with title and body filled in
+}
+
+// DocumentTraits describes the open document without revealing its content or name
+type DocumentTraits struct {
+	SHA256    string `json:"sha256"`
+	Size      int64  `json:"size"`
+	Pages     int    `json:"pages"`
+	Version   string `json:"version"`
+	Encrypted bool   `json:"encrypted"`
+	Tagged    bool   `json:"tagged"`
+	Form      bool   `json:"form"`
+}
+
+// logBuffer keeps the last lines written to stdout, where the backend logs
+type logBuffer struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+var recentLog logBuffer
+
+func (l *logBuffer) add(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, line)
+	if len(l.lines) > recentLogLines {
+		l.lines = l.lines[len(l.lines)-recentLogLines:]
+	}
+}
+
+func (l *logBuffer) snapshot() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+// captureLog copies everything written to stdout into recentLog while still passing it
+// through, so a feedback report can include what happened before the problem
+func captureLog() {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return
+	}
+	out := os.Stdout
+	os.Stdout = w
+	go func() {
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadString('\n')
+			if line != "" {
+				io.WriteString(out, line) // a GUI process may have no console; ignore failures
+				recentLog.add(strings.TrimRight(line, "\r\n"))
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// PrepareFeedback builds an issue report from the user's text for the user to review;
+// nothing is sent until they pass it to SubmitFeedback. With includeDiagnostics the
+// recent log and the traits of the open document are attached.
+func (a *App) PrepareFeedback(text string, includeDiagnostics bool) (FeedbackReport, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return FeedbackReport{}, fmt.Errorf("feedback text is empty")
+	}
+
+	var doc *DocumentTraits
+	var logLines []string
+	if includeDiagnostics {
+		a.mu.Lock()
+		path := a.doc.Path
+		a.mu.Unlock()
+		if path != "" {
+			traits, err := documentTraits(path)
+			if err != nil {
+				fmt.Printf("Backend: Leaving document out of feedback: %v\n", err)
+			} else {
+				doc = &traits
+			}
+		}
+		logLines = redactLog(recentLog.snapshot())
+	}
+
+	report := feedbackReport(text, doc, logLines)
+	fmt.Printf("Backend: Prepared feedback report (%d bytes)\n", len(report.Body))
+	return report, nil
+}
+
+// SubmitFeedback opens the reviewed report in the browser as a new GitHub issue. The
+// link is built again from the title and body the user saw, so it carries nothing else.
+func (a *App) SubmitFeedback(report FeedbackReport) (FeedbackReport, error) {
+	if strings.TrimSpace(report.Body) == "" {
+		return FeedbackReport{}, fmt.Errorf("feedback text is empty")
+	}
+	report.URL = feedbackURL(report.Title, report.Body)
+	if len(report.URL) > maxFeedbackURL {
+		return FeedbackReport{}, fmt.Errorf("the feedback report is too long, shorten it or leave out the diagnostics")
+	}
+	if a.ctx != nil {
+		runtime.BrowserOpenURL(a.ctx, report.URL)
+	}
+	return report, nil
+}
+
+// feedbackReport builds the issue, dropping the oldest log lines until the link is short
+// enough to open
+func feedbackReport(text string, doc *DocumentTraits, logLines []string) FeedbackReport {
+	title := strings.SplitN(text, "\n", 2)[0]
+	title = truncateUTF8(title, 80)
+
+	for {
+		body := feedbackBody(text, doc, logLines)
+		link := feedbackURL(title, body)
+		if len(link) <= maxFeedbackURL || len(logLines) == 0 {
+			return FeedbackReport{Title: title, Body: body, URL: link}
+		}
+		logLines = logLines[(len(logLines)+1)/4:]
+	}
+}
+
+// feedbackURL is the GitHub "new issue" link with title and body filled in
+func feedbackURL(title, body string) string {
+	q := url.Values{}
+	q.Set("title", title)
+	q.Set("body", body)
+	return feedbackIssueURL + "?" + q.Encode()
+}
+
+func feedbackBody(text string, doc *DocumentTraits, logLines []string) string {
+	var b strings.Builder
+	b.WriteString(text)
+	b.WriteString("\n\n### Environment\n")
+	fmt.Fprintf(&b, "- CapGo: %s\n", CurrentAppVersion)
+	fmt.Fprintf(&b, "- OS: %s/%s\n", goruntime.GOOS, goruntime.GOARCH)
+	if doc != nil {
+		b.WriteString("\n### Document\n")
+		fmt.Fprintf(&b, "- SHA-256: %s\n", doc.SHA256)
+		fmt.Fprintf(&b, "- Size: %d bytes, %d pages, PDF %s\n", doc.Size, doc.Pages, doc.Version)
+		fmt.Fprintf(&b, "- Encrypted: %t, tagged: %t, form: %t\n", doc.Encrypted, doc.Tagged, doc.Form)
+	}
+	if len(logLines) > 0 {
+		b.WriteString("\n### Log\n```\n")
+		b.WriteString(strings.Join(logLines, "\n"))
+		b.WriteString("\n```\n")
+	}
+	return b.String()
+}
+
+// documentTraits reads the structural properties of a document; its name, metadata and
+// content stay out of the report
+func documentTraits(path string) (DocumentTraits, error) {
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return DocumentTraits{}, classifyFileError("read", path, err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return DocumentTraits{}, classifyFileError("read", path, err)
+	}
+	traits := DocumentTraits{SHA256: sum, Size: stat.Size()}
+
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		// A document pdfcpu cannot read is worth reporting as such
+		traits.Version = "unreadable"
+		return traits, nil
+	}
+	if err := ctx.EnsurePageCount(); err == nil {
+		traits.Pages = ctx.PageCount
+	}
+	traits.Version = ctx.VersionString()
+	traits.Encrypted = ctx.Encrypt != nil
+	traits.Tagged = accessibilitySummary(ctx).Tagged
+	if catalog, err := ctx.Catalog(); err == nil {
+		_, traits.Form = catalog.Find("AcroForm")
+	}
+	return traits, nil
+}
+
+// logPath matches absolute Unix, Windows and UNC paths in a log line, including names
+// with spaces. A path ends at its file extension or, without one, at the first space.
+var logPath = regexp.MustCompile(`(^|[\s("'=])((?:[A-Za-z]:[\\/]|\\\\|/)(?:[^\n\\/:*?"<>|]*[\\/])*(?:[^\n\\/:*?"<>|]*\.\w{1,8}|[^\s\\/:*?"<>|]+)[\\/]?)([\s:,)"']|$)`)
+
+// redactLog replaces every absolute path in the log with <file>: folder and file names
+// often contain the user name, client names or the subject of a document
+func redactLog(lines []string) []string {
+	for i, line := range lines {
+		lines[i] = logPath.ReplaceAllString(line, "${1}<file>${3}")
+	}
+	return lines
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactLog(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	inHome := filepath.Join(home, "Clients", "Jane Doe", "contract.pdf")
+	for _, c := range []struct {
+		name, line, want string
+	}{
+		{"outside home", "Backend: Batch stamping /srv/scans/Acme Corp/contract final.pdf failed: boom", "Backend: Batch stamping <file> failed: boom"},
+		{"inside home", "Backend: Quick stamped " + inHome, "Backend: Quick stamped <file>"},
+		{"two paths", "Backend: Exported 3 pages of /data/a.pdf to /data/out/a.zip", "Backend: Exported 3 pages of <file>"},
+		{"folder", "Backend: Running script sign on 2 files in /mnt/share/invoices/", "Backend: Running script sign on 2 files in <file>"},
+		{"windows", `Backend: Skipping C:\Users\jdoe\Desktop\scan 01.pdf: not a PDF`, "Backend: Skipping <file>: not a PDF"},
+		{"unc", `Backend: Could not remove \\fileserver\legal\draft.pdf: access denied`, "Backend: Could not remove <file>: access denied"},
+		{"quoted", `Backend: failed to read "/tmp/x.pdf"`, `Backend: failed to read "<file>"`},
+		{"no path", "Backend: Optimized 3/4 pages, 100 -> 50 bytes", "Backend: Optimized 3/4 pages, 100 -> 50 bytes"},
+		{"url", "Backend: Downloading https://example.com/a.pdf", "Backend: Downloading https://example.com/a.pdf"},
+	} {
+		if got := redactLog([]string{c.line})[0]; got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSubmitFeedbackSendsReviewedReport(t *testing.T) {
+	a := goldenApp(t)
+	report, err := a.PrepareFeedback("Stamp vanished\nafter saving", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Title != "Stamp vanished" || !strings.HasPrefix(report.Body, "Stamp vanished\nafter saving") {
+		t.Fatalf("got %+v", report)
+	}
+	// The user removed a line while reviewing; the link must carry the reviewed body
+	report.Body = "Stamp vanished"
+	report.URL = "https://example.com"
+	sent, err := a.SubmitFeedback(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := feedbackURL("Stamp vanished", "Stamp vanished"); sent.URL != want {
+		t.Errorf("got %s, want %s", sent.URL, want)
+	}
+
+	report.Body = strings.Repeat("x", maxFeedbackURL)
+	if _, err := a.SubmitFeedback(report); err == nil {
+		t.Error("a report longer than GitHub accepts was submitted")
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"syscall"
+)
+
+// Kinds of file errors
+const (
+	FileErrorPermission = "permission" // the user may not read or write the location
+	FileErrorReadOnly   = "read_only"  // the volume is mounted read-only
+	FileErrorOffline    = "offline"    // a network share or removable volume is not reachable
+	FileErrorDiskFull   = "disk_full"
+	FileErrorNotFound   = "not_found"
+	FileErrorOther      = "other"
+)
+
+// FileError is a failed read or write together with its likely cause
+type FileError struct {
+	Op   string `json:"op"` // "read" or "write"
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+	Err  error  `json:"-"`
+}
+
+func (e *FileError) Error() string {
+	name := filepath.Base(e.Path)
+	switch e.Kind {
+	case FileErrorPermission:
+		return fmt.Sprintf("cannot %s %s: permission denied; choose another location or grant CapGo access to the folder", e.Op, name)
+	case FileErrorReadOnly:
+		return fmt.Sprintf("cannot %s %s: the volume is read-only; choose another output location", e.Op, name)
+	case FileErrorOffline:
+		return fmt.Sprintf("cannot %s %s: the network share or volume is not available; reconnect it and try again", e.Op, name)
+	case FileErrorDiskFull:
+		return fmt.Sprintf("cannot %s %s: the disk is full; free up space or choose another location", e.Op, name)
+	case FileErrorNotFound:
+		return fmt.Sprintf("cannot %s %s: the file or folder does not exist", e.Op, name)
+	}
+	return fmt.Sprintf("cannot %s %s: %v", e.Op, name, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// OutputFallback is emitted as "output:fallback" when the output location could not be
+// written and the files went to a local folder instead
+type OutputFallback struct {
+	Requested string `json:"requested"`
+	Used      string `json:"used"`
+	Reason    string `json:"reason"`
+	Kind      string `json:"kind"`
+}
+
+// windowsOfflineErrors are the Win32 error codes of unreachable network paths
+var windowsOfflineErrors = map[syscall.Errno]bool{
+	53:   true, // ERROR_BAD_NETPATH
+	59:   true, // ERROR_UNEXP_NET_ERR
+	64:   true, // ERROR_NETNAME_DELETED
+	67:   true, // ERROR_BAD_NET_NAME
+	121:  true, // ERROR_SEM_TIMEOUT
+	1222: true, // ERROR_NO_NETWORK
+	1231: true, // ERROR_NETWORK_UNREACHABLE
+}
+
+// classifyFileError wraps err in a FileError describing its cause; nil stays nil
+func classifyFileError(op, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var fe *FileError
+	if errors.As(err, &fe) {
+		return err
+	}
+	return &FileError{Op: op, Path: path, Kind: fileErrorKind(path, err), Err: err}
+}
+
+func fileErrorKind(path string, err error) string {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		if goruntime.GOOS == "windows" {
+			switch {
+			case windowsOfflineErrors[errno]:
+				return FileErrorOffline
+			case errno == 19: // ERROR_WRITE_PROTECT
+				return FileErrorReadOnly
+			case errno == 112: // ERROR_DISK_FULL
+				return FileErrorDiskFull
+			}
+		} else {
+			switch errno {
+			case syscall.EROFS:
+				return FileErrorReadOnly
+			case syscall.ENOSPC, syscall.EDQUOT:
+				return FileErrorDiskFull
+			case syscall.ENOTCONN, syscall.EHOSTDOWN, syscall.EHOSTUNREACH, syscall.ENETDOWN,
+				syscall.ENETUNREACH, syscall.ETIMEDOUT, syscall.ESTALE:
+				return FileErrorOffline
+			}
+		}
+	}
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return FileErrorPermission
+	case errors.Is(err, fs.ErrNotExist):
+		// A missing share looks like a missing file
+		if networkPath(path) && !volumeMounted(path) {
+			return FileErrorOffline
+		}
+		return FileErrorNotFound
+	}
+	return FileErrorOther
+}
+
+// networkPath reports whether path is on a UNC share or a mounted volume
+func networkPath(path string) bool {
+	switch goruntime.GOOS {
+	case "windows":
+		return strings.HasPrefix(path, `\\`)
+	case "darwin":
+		return strings.HasPrefix(path, "/Volumes/")
+	}
+	return strings.HasPrefix(path, "/mnt/") || strings.HasPrefix(path, "/media/")
+}
+
+// volumeMounted reports whether the share or volume holding path is reachable
+func volumeMounted(path string) bool {
+	root := filepath.VolumeName(path)
+	if root == "" {
+		// /Volumes/<name>, /mnt/<name> or /media/<user>/<name>
+		parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 4)
+		n := 2
+		if parts[0] == "media" {
+			n = 3
+		}
+		if len(parts) < n {
+			return true
+		}
+		root = "/" + strings.Join(parts[:n], "/")
+	}
+	_, err := os.Stat(root)
+	return err == nil
+}
+
+// outputDir makes sure dir exists and can be written. When it cannot, the output goes to
+// a local fallback folder and "output:fallback" tells the user why.
+func (a *App) outputDir(dir string) (string, error) {
+	err := probeWritable(dir)
+	if err == nil {
+		return dir, nil
+	}
+	var fe *FileError
+	errors.As(err, &fe)
+
+	fallback, ferr := fallbackOutputDir()
+	if ferr != nil || fallback == dir {
+		return "", err
+	}
+	fmt.Printf("Backend: %v, writing to %s instead\n", err, fallback)
+	a.emit(EventOutputFallback, OutputFallback{Requested: dir, Used: fallback, Reason: err.Error(), Kind: fe.Kind})
+	return fallback, nil
+}
+
+// probeWritable creates dir if needed and writes a test file into it
+func probeWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return classifyFileError("write", dir, err)
+	}
+	f, err := os.CreateTemp(dir, ".capgo_probe_*")
+	if err != nil {
+		return classifyFileError("write", dir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
+
+// fallbackOutputDir returns a local folder for output that could not be written where
+// requested: Documents/CapGo, or the CapGo config folder when Documents is unavailable
+func fallbackOutputDir() (string, error) {
+	if home, err := os.UserHomeDir(); err == nil {
+		dir := filepath.Join(home, "Documents", "CapGo")
+		if probeWritable(dir) == nil {
+			return dir, nil
+		}
+	}
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "Output")
+	if err := probeWritable(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxFileNameBytes is the longest file name most file systems accept (255 bytes on
+// APFS, ext4 and NTFS, where NTFS counts UTF-16 units and is therefore never stricter)
+const maxFileNameBytes = 255
+
+// uniqueSuffixBytes is the room kept for the " (n)" that uniquePath may append
+const uniqueSuffixBytes = len(" (9999)")
+
+// fileBase prepares a name derived from user files for use in an output file name:
+// it is converted to NFC, so names from macOS (which stores NFD) match names typed
+// elsewhere, control characters are dropped and the name is shortened so that reserve
+// more bytes still fit in a file name. Trailing dots and spaces, which Windows drops,
+// are removed, and Windows device names like CON get an underscore.
+func fileBase(name string, reserve int) string {
+	name = norm.NFC.String(name)
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(name, " .")
+	if isDeviceName(name) {
+		name += "_"
+	}
+	return truncateUTF8(name, maxFileNameBytes-reserve)
+}
+
+// isDeviceName reports whether Windows reads a file name as a device such as NUL or
+// COM1, which it does with any extension and trailing spaces as well
+func isDeviceName(name string) bool {
+	stem, _, _ := strings.Cut(name, ".")
+	switch stem = strings.ToUpper(strings.TrimRight(stem, " ")); stem {
+	case "CON", "PRN", "AUX", "NUL":
+		return true
+	}
+	return len(stem) == 4 && (strings.HasPrefix(stem, "COM") || strings.HasPrefix(stem, "LPT")) && stem[3] >= '1' && stem[3] <= '9'
+}
+
+// truncateUTF8 shortens s to at most n bytes without splitting a character or leaving
+// a dangling zero width joiner from a cut emoji sequence
+func truncateUTF8(s string, n int) string {
+	if n < 0 {
+		n = 0
+	}
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for len(s) > 0 {
+		r, size := utf8.DecodeLastRuneInString(s)
+		if (r == utf8.RuneError && size <= 1) || r == '\u200d' {
+			s = s[:len(s)-size]
+			continue
+		}
+		break
+	}
+	return strings.TrimRight(s, " .")
+}
+
+// outputName builds "<base><suffix><ext>" for a file derived from srcPath
+func outputName(srcPath, suffix, ext string) string {
+	base := strings.TrimSuffix(filepath.Base(srcPath), filepath.Ext(srcPath))
+	return fileBase(base, len(suffix)+len(ext)+uniqueSuffixBytes) + suffix + ext
+}
+
+// tempOutputPath returns the path in the temp folder of an intermediate PDF made from
+// srcPath by the operation op, tagged with the process ID
+func tempOutputPath(op, srcPath string) string {
+	prefix := fmt.Sprintf("capgo_%s_%d_", op, os.Getpid())
+	name := strings.TrimSuffix(filepath.Base(srcPath), filepath.Ext(srcPath))
+	return filepath.Join(os.TempDir(), prefix+fileBase(name, len(prefix)+len(".pdf"))+".pdf")
+}
+
+// samePath reports whether two paths name the same file, ignoring differences in
+// Unicode normalization
+func samePath(a, b string) bool {
+	return norm.NFC.String(filepath.Clean(a)) == norm.NFC.String(filepath.Clean(b))
+}
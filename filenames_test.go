@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFileBase(t *testing.T) {
+	for _, c := range []struct {
+		name, in, want string
+	}{
+		{"plain", "invoice 2026", "invoice 2026"},
+		{"nfd", "Re\u0301sume\u0301", "Résumé"},
+		{"control characters", "a\x00b\nc\td\x7f", "abcd"},
+		{"trailing dots and spaces", "report. . ", "report"},
+		{"only dots", "...", ""},
+		{"device name", "CON", "CON_"},
+		{"device name in lower case", "nul", "nul_"},
+		{"device name with extension", "aux.tar", "aux.tar_"},
+		{"device name with space", "LPT9 ", "LPT9_"},
+		{"numbered device", "com1", "com1_"},
+		{"not a device", "CONSOLE", "CONSOLE"},
+		{"port zero", "COM0", "COM0"},
+	} {
+		if got := fileBase(c.in, 0); got != c.want {
+			t.Errorf("%s: fileBase(%q) = %q, want %q", c.name, c.in, got, c.want)
+		}
+	}
+}
+
+func TestOutputNameHostile(t *testing.T) {
+	for _, c := range []struct {
+		name, base string
+	}{
+		{"long", strings.Repeat("a", 300)},
+		{"long with trailing dots", strings.Repeat("a", 240) + strings.Repeat(". ", 20)},
+		{"two byte characters", strings.Repeat("é", 200)},
+		{"decomposed", strings.Repeat("e\u0301", 200)},
+		{"three byte characters", strings.Repeat("契約書", 60)},
+		{"emoji sequences", strings.Repeat("👩‍👩‍👧", 40)},
+		{"device name", "CON"},
+	} {
+		src := filepath.Join("docs", c.base+".pdf")
+		// Output names keep room for the " (n)" of uniquePath
+		for name, limit := range map[string]int{
+			outputName(src, "_stamped", ".pdf"):       maxFileNameBytes - uniqueSuffixBytes,
+			filepath.Base(tempOutputPath("mod", src)): maxFileNameBytes,
+		} {
+			if len(name) > limit {
+				t.Errorf("%s: %q is %d bytes long", c.name, name, len(name))
+			}
+			if !utf8.ValidString(name) || strings.Contains(name, "\u200d.") || strings.Contains(name, "\u200d_") {
+				t.Errorf("%s: %q has a cut character", c.name, name)
+			}
+			if !strings.HasSuffix(name, ".pdf") {
+				t.Errorf("%s: %q lost its extension", c.name, name)
+			}
+			if isDeviceName(name) {
+				t.Errorf("%s: %q is a Windows device", c.name, name)
+			}
+		}
+	}
+}
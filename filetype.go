@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"CapGo/pkg/stamper"
+)
+
+// Kinds of file told apart by their content
+const (
+	FileKindPDF     = "pdf"
+	FileKindImage   = "image"
+	FileKindOffice  = "office"
+	FileKindUnknown = "unknown"
+)
+
+// sniffLen is how much of a file is looked at to tell its kind. PDF readers accept the
+// header anywhere in the first KB, and ZIP based office files name their parts early.
+const sniffLen = 8 << 10
+
+// FileType is the kind of a file as told by its content
+type FileType struct {
+	Kind string `json:"kind"`
+	MIME string `json:"mime"`
+	// Mislabeled means the extension promises another kind, e.g. a .pdf that is really
+	// the HTML error page of a portal
+	Mislabeled bool `json:"mislabeled"`
+}
+
+// TypedFile is a file read by GetTypedFile
+type TypedFile struct {
+	FileType
+	Path string `json:"path"`
+	Data []byte `json:"data"`
+}
+
+// extensionKinds maps the extensions CapGo opens or converts to their kind
+var extensionKinds = map[string]string{
+	".pdf":  FileKindPDF,
+	".png":  FileKindImage,
+	".jpg":  FileKindImage,
+	".jpeg": FileKindImage,
+	".gif":  FileKindImage,
+	".webp": FileKindImage,
+	".bmp":  FileKindImage,
+	".tif":  FileKindImage,
+	".tiff": FileKindImage,
+	".heic": FileKindImage,
+	".heif": FileKindImage,
+	".svg":  FileKindImage,
+	".doc":  FileKindOffice,
+	".docx": FileKindOffice,
+	".xls":  FileKindOffice,
+	".xlsx": FileKindOffice,
+	".ppt":  FileKindOffice,
+	".pptx": FileKindOffice,
+	".odt":  FileKindOffice,
+	".ods":  FileKindOffice,
+	".odp":  FileKindOffice,
+	".rtf":  FileKindOffice,
+}
+
+// GetTypedFile reads a file and reports what it contains, so a mislabeled file is not
+// handed to the PDF viewer
+func (a *App) GetTypedFile(path string) (TypedFile, error) {
+	path = filepath.Clean(path)
+	if err := a.ensureLocal(path); err != nil {
+		return TypedFile{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return TypedFile{}, classifyFileError("read", path, err)
+	}
+	return TypedFile{FileType: fileType(path, data), Path: path, Data: data}, nil
+}
+
+// SniffFile reports what a file contains from its first bytes, without reading all of it
+func (a *App) SniffFile(path string) (FileType, error) {
+	path = filepath.Clean(path)
+	if err := a.ensureLocal(path); err != nil {
+		return FileType{}, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return FileType{}, classifyFileError("read", path, err)
+	}
+	defer f.Close()
+	head := make([]byte, sniffLen)
+	n, _ := f.Read(head)
+	return fileType(path, head[:n]), nil
+}
+
+// fileType tells the kind of a file from its content and compares it with the extension
+func fileType(path string, data []byte) FileType {
+	kind, mime := sniffContent(data)
+	expected, ok := extensionKinds[strings.ToLower(filepath.Ext(path))]
+	return FileType{Kind: kind, MIME: mime, Mislabeled: ok && expected != kind}
+}
+
+// sniffContent looks for the magic bytes of the kinds CapGo knows and falls back to
+// the content sniffing of net/http for everything else
+func sniffContent(data []byte) (string, string) {
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+	switch {
+	case bytes.Contains(data[:min(len(data), 1024)], []byte("%PDF-")):
+		return FileKindPDF, "application/pdf"
+	case isTIFF(data):
+		return FileKindImage, "image/tiff"
+	case stamper.IsHEIF(data):
+		return FileKindImage, "image/heic"
+	case bytes.HasPrefix(data, []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")):
+		// Compound file of Word, Excel and PowerPoint before 2007
+		return FileKindOffice, "application/x-ole-storage"
+	case bytes.HasPrefix(data, []byte(`{\rtf`)):
+		return FileKindOffice, "application/rtf"
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		if mime := officeZipMIME(data); mime != "" {
+			return FileKindOffice, mime
+		}
+	}
+	mime := http.DetectContentType(data)
+	if strings.HasPrefix(mime, "image/") {
+		return FileKindImage, mime
+	}
+	if strings.HasPrefix(mime, "text/xml") && bytes.Contains(data, []byte("<svg")) {
+		return FileKindImage, "image/svg+xml"
+	}
+	return FileKindUnknown, mime
+}
+
+// isTIFF reports whether data starts like a TIFF image, e.g. a scan
+func isTIFF(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*"))
+}
+
+// officeZipMIME tells Office Open XML and OpenDocument files from other ZIP archives
+// by the part names near the start
+func officeZipMIME(data []byte) string {
+	switch {
+	case bytes.Contains(data, []byte("word/")):
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	case bytes.Contains(data, []byte("xl/")):
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case bytes.Contains(data, []byte("ppt/")):
+		return "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	case bytes.Contains(data, []byte("[Content_Types].xml")):
+		return "application/vnd.openxmlformats-officedocument"
+	}
+	// OpenDocument files start with an uncompressed "mimetype" entry
+	for _, sub := range []string{"text", "spreadsheet", "presentation"} {
+		mime := "application/vnd.oasis.opendocument." + sub
+		if bytes.Contains(data, []byte("mimetype"+mime)) {
+			return mime
+		}
+	}
+	return ""
+}
+
+// notPDFError describes what a file that should be a PDF turned out to be
+func notPDFError(path string, t FileType) error {
+	if strings.HasPrefix(t.MIME, "text/html") {
+		return fmt.Errorf("%s is not a PDF but a web page, download it again", filepath.Base(path))
+	}
+	return fmt.Errorf("%s is not a PDF, it contains %s", filepath.Base(path), strings.SplitN(t.MIME, ";", 2)[0])
+}
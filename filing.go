@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	goruntime "runtime"
+	"sort"
+	"strings"
+
+	"CapGo/pkg/stamper"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// FilingProfile describes the requirements a court e-filing system puts on a PDF
+type FilingProfile struct {
+	MaxSizeMB         float64 `json:"maxSizeMB"` // 0 disables the size check
+	RequirePDFA       bool    `json:"requirePdfa"`
+	NoJavaScript      bool    `json:"noJavaScript"`
+	RequireTextLayer  bool    `json:"requireTextLayer"`
+	StampFreeMarginIn float64 `json:"stampFreeMarginIn"` // inches along every edge that must stay free of stamps, 0 disables
+}
+
+// FilingCheck is the outcome of a single preflight rule
+type FilingCheck struct {
+	Rule    string `json:"rule"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message"`
+}
+
+// Filing preflight rules
+const (
+	FilingRuleMaxSize      = "max_size"
+	FilingRulePDFA         = "pdfa"
+	FilingRuleNoJavaScript = "no_javascript"
+	FilingRuleTextLayer    = "text_layer"
+	FilingRuleMargins      = "stamp_free_margins"
+	FilingRuleQuarantine   = "quarantine"
+)
+
+// defaultFilingProfile follows common US federal court rules
+func defaultFilingProfile() FilingProfile {
+	return FilingProfile{
+		MaxSizeMB:         35,
+		NoJavaScript:      true,
+		RequireTextLayer:  true,
+		StampFreeMarginIn: 1,
+	}
+}
+
+// GetFilingProfile returns the configured e-filing preflight profile
+func (a *App) GetFilingProfile() FilingProfile {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.settings.FilingProfile
+}
+
+// SetFilingProfile updates and persists the e-filing preflight profile
+func (a *App) SetFilingProfile(profile FilingProfile) error {
+	if profile.MaxSizeMB < 0 || profile.StampFreeMarginIn < 0 {
+		return fmt.Errorf("filing profile limits cannot be negative")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settings.FilingProfile = profile
+	return a.saveSettings()
+}
+
+// CheckFilingCompliance runs the e-filing preflight profile against a PDF and reports
+// pass or fail per enabled rule. Stamps that are about to be applied are included in the
+// margin check together with the stamps CapGo already applied to the document.
+func (a *App) CheckFilingCompliance(pdfPath string, stamps []StampInfo) (_ []FilingCheck, err error) {
+	pdfPath = filepath.Clean(pdfPath)
+	defer recoverDamaged(pdfPath, &err)
+	profile := a.GetFilingProfile()
+
+	ctx, err := api.ReadContextFile(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %v", err)
+	}
+	if err := ctx.EnsurePageCount(); err != nil {
+		return nil, fmt.Errorf("failed to count pages: %v", err)
+	}
+
+	checks := []FilingCheck{}
+
+	// Only macOS and Windows flag downloaded files
+	if goruntime.GOOS == "darwin" || goruntime.GOOS == "windows" {
+		checks = append(checks, quarantineCheck(pdfPath))
+	}
+
+	if profile.MaxSizeMB > 0 {
+		info, err := os.Stat(pdfPath)
+		if err != nil {
+			return nil, err
+		}
+		size := float64(info.Size()) / (1024 * 1024)
+		checks = append(checks, FilingCheck{
+			Rule:    FilingRuleMaxSize,
+			Passed:  size <= profile.MaxSizeMB,
+			Message: fmt.Sprintf("file size is %.1f MB, the limit is %.1f MB", size, profile.MaxSizeMB),
+		})
+	}
+
+	if profile.RequirePDFA {
+		part := pdfaPart(ctx)
+		check := FilingCheck{Rule: FilingRulePDFA, Passed: part != ""}
+		if part != "" {
+			check.Message = "document declares PDF/A-" + part
+		} else {
+			check.Message = "document does not declare PDF/A conformance"
+		}
+		checks = append(checks, check)
+	}
+
+	if profile.NoJavaScript {
+		found := hasJavaScript(ctx)
+		check := FilingCheck{Rule: FilingRuleNoJavaScript, Passed: !found, Message: "no JavaScript found"}
+		if found {
+			check.Message = "document contains JavaScript"
+		}
+		checks = append(checks, check)
+	}
+
+	if profile.RequireTextLayer {
+		missing := pagesWithoutText(ctx)
+		check := FilingCheck{Rule: FilingRuleTextLayer, Passed: len(missing) == 0, Message: "every page has a text layer"}
+		if len(missing) > 0 {
+			check.Message = fmt.Sprintf("no text layer on %s; run OCR before filing", pageList(missing))
+		}
+		checks = append(checks, check)
+	}
+
+	if profile.StampFreeMarginIn > 0 {
+		dims, err := stamper.VisiblePageDims(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get page dimensions: %v", err)
+		}
+		normalized, err := a.NormalizeStampCoordinates(stamps)
+		if err != nil {
+			return nil, err
+		}
+		normalized, err = a.toPageCoordinates(pdfPath, normalized)
+		if err != nil {
+			return nil, err
+		}
+		boxes := historyStamps(normalized)
+		if history, err := lookupHistory(pdfPath); err == nil && history != nil {
+			boxes = append(history.Stamps, boxes...)
+		}
+		margin := SafeArea{MarginMM: profile.StampFreeMarginIn * 25.4}
+		var offending []int
+		for _, b := range boxes {
+			if b.PageNum < 1 || b.PageNum > len(dims) {
+				continue
+			}
+			stamp := StampInfo{Stamp: stamper.Stamp{X: b.X, Y: b.Y, Width: b.Width, Height: b.Height}}
+			if !insideSafeArea(stamp, dims[b.PageNum-1], margin) {
+				offending = appendPage(offending, b.PageNum)
+			}
+		}
+		sort.Ints(offending)
+		check := FilingCheck{Rule: FilingRuleMargins, Passed: len(offending) == 0,
+			Message: fmt.Sprintf("no stamps within %g in of the page edges", profile.StampFreeMarginIn)}
+		if len(offending) > 0 {
+			check.Message = fmt.Sprintf("stamps within %g in of the edge on %s", profile.StampFreeMarginIn, pageList(offending))
+		}
+		checks = append(checks, check)
+	}
+
+	return checks, nil
+}
+
+var pdfaPartPattern = regexp.MustCompile(`pdfaid:part(?:>|\s*=\s*["'])(\d)`)
+var pdfaConformancePattern = regexp.MustCompile(`pdfaid:conformance(?:>|\s*=\s*["'])([A-Za-z])`)
+
+// pdfaPart returns the PDF/A part and conformance declared in the XMP metadata, e.g. "3B"
+func pdfaPart(ctx *model.Context) string {
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return ""
+	}
+	ir := catalog.IndirectRefEntry("Metadata")
+	if ir == nil {
+		return ""
+	}
+	sd, _, err := ctx.DereferenceStreamDict(*ir)
+	if err != nil || sd == nil {
+		return ""
+	}
+	if err := sd.Decode(); err != nil {
+		return ""
+	}
+	m := pdfaPartPattern.FindSubmatch(sd.Content)
+	if m == nil {
+		return ""
+	}
+	part := string(m[1])
+	if c := pdfaConformancePattern.FindSubmatch(sd.Content); c != nil {
+		part += strings.ToUpper(string(c[1]))
+	}
+	return part
+}
+
+// hasJavaScript looks for JavaScript actions anywhere in the document and for the
+// document level JavaScript name tree
+func hasJavaScript(ctx *model.Context) bool {
+	if catalog, err := ctx.Catalog(); err == nil {
+		if o, found := catalog.Find("Names"); found {
+			if names, err := ctx.DereferenceDict(o); err == nil && names != nil {
+				if _, found := names.Find("JavaScript"); found {
+					return true
+				}
+			}
+		}
+	}
+	for _, entry := range ctx.Table {
+		if entry == nil || entry.Free || entry.Object == nil {
+			continue
+		}
+		var d types.Dict
+		switch o := entry.Object.(type) {
+		case types.Dict:
+			d = o
+		case types.StreamDict:
+			d = o.Dict
+		default:
+			continue
+		}
+		if s := d.NameEntry("S"); s != nil && *s == "JavaScript" {
+			return true
+		}
+		if _, found := d.Find("JS"); found {
+			return true
+		}
+	}
+	return false
+}
+
+var textShowPattern = regexp.MustCompile(`(?:^|[\s\]\)>])(?:Tj|TJ|'|")(?:\s|$)`)
+
+// pagesWithoutText returns the pages whose content shows no text with a page font
+func pagesWithoutText(ctx *model.Context) []int {
+	var missing []int
+	for p := 1; p <= ctx.PageCount; p++ {
+		// A page pdfcpu cannot parse counts as one without text
+		hasText := false
+		if err := catchCrash(func() error { hasText = pageHasText(ctx, p); return nil }); err != nil || !hasText {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// pageHasText reports whether the content of page p shows text with a page font
+func pageHasText(ctx *model.Context, p int) bool {
+	d, _, inh, err := ctx.PageDict(p, true)
+	if err != nil || d == nil {
+		return false
+	}
+	hasFonts := false
+	if inh != nil && inh.Resources != nil {
+		if fonts, found := inh.Resources.Find("Font"); found {
+			if fd, err := ctx.DereferenceDict(fonts); err == nil && len(fd) > 0 {
+				hasFonts = true
+			}
+		}
+	}
+	content, err := ctx.PageContent(d, p)
+	return err == nil && hasFonts && textShowPattern.Match(content)
+}
+
+// appendPage adds a page number to the list unless it is already present
+func appendPage(pages []int, p int) []int {
+	for _, q := range pages {
+		if q == p {
+			return pages
+		}
+	}
+	return append(pages, p)
+}
+
+// pageList formats page numbers for messages, e.g. "pages 1, 3 and 4"
+func pageList(pages []int) string {
+	if len(pages) == 1 {
+		return fmt.Sprintf("page %d", pages[0])
+	}
+	parts := make([]string, len(pages))
+	for i, p := range pages {
+		parts[i] = fmt.Sprint(p)
+	}
+	if len(parts) > 10 {
+		return fmt.Sprintf("pages %s and %d more", strings.Join(parts[:10], ", "), len(parts)-10)
+	}
+	return fmt.Sprintf("pages %s and %s", strings.Join(parts[:len(parts)-1], ", "), parts[len(parts)-1])
+}
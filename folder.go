@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// folderSummaryFile is the name of the summary ProcessFolder writes into its output
+const folderSummaryFile = "summary.csv"
+
+// FolderResult is the outcome of ProcessFolder
+type FolderResult struct {
+	Folder  string             `json:"folder"`  // the folder that was processed
+	Output  string             `json:"output"`  // where the results mirror its structure
+	Summary string             `json:"summary"` // the summary CSV
+	Failed  int                `json:"failed"`
+	Files   []ScriptFileResult `json:"files"`
+}
+
+// ProcessFolder runs a saved script, the pipeline, on every PDF in a folder whose name
+// matches glob ("*.pdf" when empty), including subfolders when recursive. The results
+// go to a new folder in the output folder that mirrors the structure of the source,
+// together with a summary CSV. A failing file does not stop the others.
+func (a *App) ProcessFolder(path string, recursive bool, glob, pipeline string) (FolderResult, error) {
+	s, err := a.GetScript(pipeline)
+	if err != nil {
+		return FolderResult{}, err
+	}
+	if err := validateScript(s); err != nil {
+		return FolderResult{}, err
+	}
+	if glob == "" {
+		glob = "*.pdf"
+	}
+	if _, err := filepath.Match(glob, ""); err != nil {
+		return FolderResult{}, fmt.Errorf("invalid file pattern %q", glob)
+	}
+	root := filepath.Clean(path)
+	if info, err := os.Stat(root); err != nil {
+		return FolderResult{}, classifyFileError("read", root, err)
+	} else if !info.IsDir() {
+		return FolderResult{}, fmt.Errorf("%s is not a folder", root)
+	}
+
+	files, err := findFolderPDFs(root, recursive, glob)
+	if err != nil {
+		return FolderResult{}, err
+	}
+	if len(files) == 0 {
+		return FolderResult{}, fmt.Errorf("no PDF in %s matches %s", filepath.Base(root), glob)
+	}
+
+	base, err := a.outputBaseDir()
+	if err != nil {
+		return FolderResult{}, err
+	}
+	// Every run gets a folder of its own, so results of earlier runs are never mixed in
+	outDir, err := a.outputDir(uniquePath(filepath.Join(base, outputName(root, "_"+fileBase(s.Name, 0), ""))))
+	if err != nil {
+		return FolderResult{}, err
+	}
+
+	defer a.startJob("folder")()
+	fmt.Printf("Backend: Running script %s on %d files in %s\n", s.Name, len(files), root)
+
+	var stamps []StampInfo
+	for _, step := range s.Steps {
+		stamps = append(stamps, step.Stamps...)
+	}
+	res := FolderResult{Folder: root, Output: outDir, Files: make([]ScriptFileResult, len(files))}
+	var done int32
+	runBatch(len(files), a.batchWorkersFor(stamps), func(i int) error {
+		rel, _ := filepath.Rel(root, files[i])
+		output := filepath.Join(outDir, rel)
+		r := ScriptFileResult{Source: files[i], Skipped: []int{}}
+		if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+			r.Error = classifyFileError("write", filepath.Dir(output), err).Error()
+		} else {
+			r = a.runScriptFile(s, files[i], output)
+		}
+		if r.Error != "" {
+			fmt.Printf("Backend: Script %s failed on %s: %s\n", s.Name, files[i], r.Error)
+		}
+		res.Files[i] = r
+		a.emit(EventScriptProgress, ScriptProgress{Script: s.Name, Done: int(atomic.AddInt32(&done, 1)), Total: len(files), Result: r})
+		return nil
+	})
+	for _, r := range res.Files {
+		if r.Error != "" {
+			res.Failed++
+		}
+	}
+
+	res.Summary = filepath.Join(outDir, folderSummaryFile)
+	if err := writeFolderSummary(res.Summary, root, outDir, res.Files); err != nil {
+		return FolderResult{}, err
+	}
+	if res.Failed > 0 {
+		a.jobFinished("folder", tr("Processed %d documents, %d failed", len(files)-res.Failed, res.Failed), true)
+	} else {
+		a.jobFinished("folder", tr("Processed %d documents", len(files)), false)
+	}
+	return res, nil
+}
+
+// findFolderPDFs lists the PDFs in root whose name matches glob, sorted by path.
+// Hidden files and folders are skipped.
+func findFolderPDFs(root string, recursive bool, glob string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			// An unreadable subfolder does not stop the others
+			fmt.Printf("Backend: Skipping %s: %v\n", path, err)
+			return nil
+		}
+		hidden := strings.HasPrefix(d.Name(), ".") && path != root
+		if d.IsDir() {
+			if path != root && (hidden || !recursive) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if hidden || !d.Type().IsRegular() || !strings.EqualFold(filepath.Ext(path), ".pdf") {
+			return nil
+		}
+		if ok, _ := filepath.Match(strings.ToLower(glob), strings.ToLower(d.Name())); ok {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, classifyFileError("read", root, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// writeFolderSummary writes one CSV row per file with paths relative to the folders
+func writeFolderSummary(path, root, outDir string, results []ScriptFileResult) error {
+	var buf bytes.Buffer
+	// The byte order mark makes Excel read the file as UTF-8
+	buf.WriteString("\ufeff")
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"source", "output", "status", "skipped_steps", "error"})
+	for _, r := range results {
+		source, _ := filepath.Rel(root, r.Source)
+		output := ""
+		if r.Output != "" {
+			output, _ = filepath.Rel(outDir, r.Output)
+		}
+		status := "ok"
+		if r.Error != "" {
+			status = "failed"
+		}
+		skipped := make([]string, len(r.Skipped))
+		for i, n := range r.Skipped {
+			skipped[i] = strconv.Itoa(n)
+		}
+		w.Write([]string{filepath.ToSlash(source), filepath.ToSlash(output), status, strings.Join(skipped, " "), r.Error})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write summary: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return classifyFileError("write", path, err)
+	}
+	return nil
+}
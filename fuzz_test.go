@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"CapGo/pkg/stamper"
+)
+
+// Fuzz tests feed malformed documents through the operations that read user files.
+// Errors are expected, panics are not. Run one with e.g.
+//
+//	go test -run '^$' -fuzz FuzzDocumentOperations -fuzztime 1m
+//
+// When it finds a crash, reduce the input to a small file in testdata/damaged that
+// shows the same fault and add it to TestDamagedInputs.
+
+const damagedDir = "testdata/damaged"
+
+// fuzzSeeds returns the golden reference input with some typical damage and the
+// damaged files that crashed pdfcpu before
+func fuzzSeeds(f *testing.F) [][]byte {
+	data, err := os.ReadFile(filepath.Join(goldenDir, "mixed_sizes.pdf"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	seeds := [][]byte{
+		data,
+		data[:len(data)/2], // truncated download
+		data[:bytes.LastIndex(data, []byte("startxref"))], // no trailer
+		bytes.Replace(data, []byte("/Page"), []byte("/Pagf"), 1),
+		[]byte("%PDF-1.7\n%%EOF\n"),
+		{},
+	}
+	damaged, _ := filepath.Glob(filepath.Join(damagedDir, "*.pdf"))
+	for _, path := range damaged {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		seeds = append(seeds, data)
+	}
+	return seeds
+}
+
+// fuzzStamps is the stamp the document operations place
+var fuzzStamps = []StampInfo{{Stamp: stamper.Stamp{Kind: StampKindText, Text: "FUZZ", FontSize: 12, X: 20, Y: 20, Width: 80, Height: 20, PageNum: 1}}}
+
+// documentOperations are the operations under test, by name
+func documentOperations(a *App, input string) map[string]func() (string, error) {
+	return map[string]func() (string, error){
+		"validate": func() (string, error) { _, err := a.ValidateStamps(input, fuzzStamps); return "", err },
+		"filing":   func() (string, error) { _, err := a.CheckFilingCompliance(input, fuzzStamps); return "", err },
+		"stamp":    func() (string, error) { return a.StampPDF(input, fuzzStamps) },
+		"pages":    func() (string, error) { return a.UpdatePDFPages(input, []string{"1", "1"}) },
+		"rotate":   func() (string, error) { return a.RotatePages(input, []string{"1"}, 90) },
+		"analyze":  func() (string, error) { _, err := a.AnalyzePage(input, 1); return "", err },
+		"language": func() (string, error) { _, err := a.DetectDocumentLanguage(input); return "", err },
+	}
+}
+
+func FuzzDocumentOperations(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+	a := goldenApp(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		input := filepath.Join(t.TempDir(), "input.pdf")
+		if err := os.WriteFile(input, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, run := range documentOperations(a, input) {
+			if output, err := run(); err == nil && output != "" {
+				os.Remove(output)
+			}
+		}
+	})
+}
+
+// TestDamagedInputs runs the document operations on files that crashed pdfcpu. They
+// fail with an error, or succeed where the repair fallback gets around the damage.
+func TestDamagedInputs(t *testing.T) {
+	cases := []struct {
+		file string
+		fail []string // operations that cannot get around the damage
+	}{
+		// An object stream offset past the end of the stream
+		{"object_stream_offset.pdf", []string{"validate", "filing", "stamp", "pages", "rotate", "analyze", "language"}},
+		// An XObject without /Subtype, which only pdfcpu's optimization step trips over
+		{"xobject_subtype.pdf", nil},
+		// A TJ array that ends without "]"; the text layer check counts the page as one
+		// without text
+		{"unterminated_tj.pdf", []string{"pages"}},
+		// An annotation reference to a missing object
+		{"missing_annotation.pdf", []string{"pages"}},
+	}
+	for _, c := range cases {
+		t.Run(c.file, func(t *testing.T) {
+			a := goldenApp(t)
+			a.settings.FilingProfile.RequireTextLayer = true
+			input, _ := filepath.Abs(filepath.Join(damagedDir, c.file))
+			for name, run := range documentOperations(a, input) {
+				output, err := run()
+				switch {
+				case slices.Contains(c.fail, name) && err == nil:
+					t.Errorf("%s: succeeded, expected it to fail", name)
+				case slices.Contains(c.fail, name) && !strings.Contains(err.Error(), "is damaged"):
+					t.Errorf("%s: %v, expected the damaged file error", name, err)
+				case !slices.Contains(c.fail, name) && err != nil:
+					t.Errorf("%s: %v", name, err)
+				}
+				if err == nil && output != "" {
+					os.Remove(output)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"CapGo/pkg/stamper"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// StampGhost is where a stamp will land on one page, so the frontend can show it on
+// every page before stamping. Coordinates are in points from the top left corner of the
+// page as it is displayed, i.e. after the page rotation.
+type StampGhost struct {
+	Index        int     `json:"index"` // of the stamp in the request
+	PageNum      int     `json:"pageNum"`
+	X            float64 `json:"x"`
+	Y            float64 `json:"y"`
+	Width        float64 `json:"width"`
+	Height       float64 `json:"height"`
+	PageWidth    float64 `json:"pageWidth"`
+	PageHeight   float64 `json:"pageHeight"`
+	PageRotation int     `json:"pageRotation"` // degrees clockwise the page is displayed turned
+	Clamped      bool    `json:"clamped"`      // moved inside the safe area
+	OffPage      bool    `json:"offPage"`      // reaches beyond the page edge
+}
+
+// GetStampGhosts resolves the stamps, including those with a page selection, to their
+// rectangle on each page they will be applied to, after the safe area is applied
+func (a *App) GetStampGhosts(pdfPath string, stamps []StampInfo, groups []StampGroup) (_ []StampGhost, err error) {
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return nil, err
+	}
+	pdfPath = filepath.Clean(pdfPath)
+	defer recoverDamaged(pdfPath, &err)
+
+	stamps, err = a.NormalizeStampCoordinates(stamps)
+	if err != nil {
+		return nil, err
+	}
+	stamps, err = resolveStampGroups(stamps, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err := api.ReadContextFile(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %v", err)
+	}
+	boundaries, err := ctx.PageBoundaries(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page dimensions for %s: %v", pdfPath, err)
+	}
+	dims, err := stamper.VisiblePageDims(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page dimensions for %s: %v", pdfPath, err)
+	}
+	if len(dims) == 0 {
+		return nil, fmt.Errorf("no page dimensions found for %s", pdfPath)
+	}
+
+	frame, err := a.pageFrameFor(pdfPath, stamps)
+	if err != nil {
+		return nil, err
+	}
+	area := a.GetSafeArea()
+	ghosts := []StampGhost{}
+	for i, stamp := range stamps {
+		copies, err := stampCopies(stamp, len(dims))
+		if err != nil {
+			return nil, fmt.Errorf("stamp %d: %v", i, err)
+		}
+		for _, s := range frame.convert(copies) {
+			if s.PageNum < 1 || s.PageNum > len(dims) {
+				continue
+			}
+			dim := dims[s.PageNum-1]
+			placed := s
+			if area.Mode == SafeAreaClamp {
+				placed = clampToSafeArea(s, dim, area)
+			}
+			g := StampGhost{
+				Index:        i,
+				PageNum:      s.PageNum,
+				X:            placed.X,
+				Y:            placed.Y,
+				Width:        placed.Width,
+				Height:       placed.Height,
+				PageWidth:    dim.Width,
+				PageHeight:   dim.Height,
+				PageRotation: boundaries[s.PageNum-1].Rot,
+				Clamped:      placed != s,
+			}
+			g.OffPage = g.X < 0 || g.Y < 0 || g.X+g.Width > dim.Width || g.Y+g.Height > dim.Height
+			ghosts = append(ghosts, g)
+		}
+	}
+	return ghosts, nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"CapGo/internal/pdfcanvas"
+	"CapGo/internal/pdfgolden"
+	"CapGo/pkg/stamper"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Golden tests run document operations on the reference inputs in testdata/golden and
+// compare the outputs with the descriptions stored next to them. After an intended
+// change of the output, rewrite the descriptions with
+//
+//	go test -run TestGolden -update
+//
+// and review the diff of the .json files.
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+const goldenDir = "testdata/golden"
+
+// goldenCases are the operations under test; each returns the path of its output
+var goldenCases = []struct {
+	name string
+	run  func(a *App, input, signature string) (string, error)
+}{
+	{"stamp_coordinates", func(a *App, input, signature string) (string, error) {
+		return a.StampPDF(input, []StampInfo{
+			{Stamp: stamper.Stamp{Image: signature, X: 72, Y: 600, Width: 180, Height: 60, PageNum: 1}},
+			{Stamp: stamper.Stamp{Kind: StampKindText, Text: "APPROVED", FontSize: 18, Color: "#c00000", X: 400, Y: 72, Width: 140, Height: 40, PageNum: 1}},
+		})
+	}},
+	{"stamp_mixed_sizes", func(a *App, input, signature string) (string, error) {
+		// The same box on pages of three sizes, the last one rotated
+		var stamps []StampInfo
+		for p := 1; p <= 3; p++ {
+			stamps = append(stamps, StampInfo{Stamp: stamper.Stamp{Image: signature, X: 36, Y: 36, Width: 120, Height: 40, PageNum: p}})
+		}
+		return a.StampPDF(input, stamps)
+	}},
+	{"stamp_rotated", func(a *App, input, signature string) (string, error) {
+		return a.StampPDF(input, []StampInfo{
+			{Stamp: stamper.Stamp{Kind: StampKindText, Text: "COPY", FontSize: 24, Rotation: 90, X: 20, Y: 100, Width: 40, Height: 120, PageNum: 2}},
+		})
+	}},
+	{"stamp_cropped_rotated", func(a *App, input, signature string) (string, error) {
+		// Measured from the crop box, which pdfcpu keeps for the first page and turns
+		// with the rotation on the second
+		return a.StampPDF(croppedInput(input), []StampInfo{
+			{Stamp: stamper.Stamp{Image: signature, X: 36, Y: 36, Width: 120, Height: 40}, Pages: "all"},
+		})
+	}},
+	{"stamp_media_box_coordinates", func(a *App, input, signature string) (string, error) {
+		// The boxes of stamp_cropped_rotated measured from the media box instead
+		a.settings.CropBoxCoordinates = false
+		return a.StampPDF(croppedInput(input), []StampInfo{
+			{Stamp: stamper.Stamp{Image: signature, X: 86, Y: 135.89, Width: 120, Height: 40, PageNum: 1}},
+			{Stamp: stamper.Stamp{Image: signature, X: 135.89, Y: 86.28, Width: 120, Height: 40, PageNum: 2}},
+		})
+	}},
+	{"stamp_bottom_left_origin", func(a *App, input, signature string) (string, error) {
+		// The boxes of stamp_cropped_rotated measured from the bottom of the crop box
+		return a.StampPDF(croppedInput(input), []StampInfo{
+			{Stamp: stamper.Stamp{Image: signature, X: 36, Y: 566, Width: 120, Height: 40, PageNum: 1}, Origin: StampOriginBottomLeft},
+			{Stamp: stamper.Stamp{Image: signature, X: 36, Y: 419, Width: 120, Height: 40, PageNum: 2}, Origin: StampOriginBottomLeft},
+		})
+	}},
+	{"stamp_flattened", func(a *App, input, signature string) (string, error) {
+		return a.StampPDFWithOptions(input, []StampInfo{
+			{Stamp: stamper.Stamp{Image: signature, X: 72, Y: 600, Width: 180, Height: 60, PageNum: 1}},
+		}, StampOptions{Flatten: true})
+	}},
+	{"stamp_svg", func(a *App, input, signature string) (string, error) {
+		// Drawn as vectors, with a transparent fill and a stroke
+		svg := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 120 40">` +
+			`<rect x="2" y="2" width="116" height="36" rx="6" fill="#1f4e99" fill-opacity="0.3" stroke="#1f4e99" stroke-width="2"/>` +
+			`<path d="M10 30 C 30 5, 50 35, 70 12 S 100 30, 110 10" fill="none" stroke="#c00000" stroke-width="3"/></svg>`
+		return a.StampPDF(input, []StampInfo{
+			{Stamp: stamper.Stamp{Image: "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(svg)), X: 72, Y: 600, Width: 180, Height: 60, PageNum: 1}},
+		})
+	}},
+	{"rotate_pages", func(a *App, input, signature string) (string, error) {
+		return a.RotatePages(input, []string{"1", "2"}, 90)
+	}},
+	{"reorder_pages", func(a *App, input, signature string) (string, error) {
+		return a.UpdatePDFPages(input, []string{"3", "1", "2"})
+	}},
+	{"drop_and_repeat_pages", func(a *App, input, signature string) (string, error) {
+		return a.UpdatePDFPages(input, []string{"2", "2", "3"})
+	}},
+}
+
+func TestGolden(t *testing.T) {
+	input := filepath.Join(goldenDir, "mixed_sizes.pdf")
+	signature := filepath.Join(goldenDir, "signature.png")
+	if *updateGolden {
+		if err := writeGoldenInputs(input, signature); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input, _ = filepath.Abs(input)
+	signature, _ = filepath.Abs(signature)
+
+	for _, c := range goldenCases {
+		t.Run(c.name, func(t *testing.T) {
+			a := goldenApp(t)
+			output, err := c.run(a, input, signature)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := pdfgolden.Summarize(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join(goldenDir, c.name+".json")
+			if *updateGolden {
+				if err := pdfgolden.Save(golden, got); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := pdfgolden.Load(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			for _, d := range pdfgolden.Compare(got, want, 0.01) {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+// goldenApp returns an app whose settings, history and Downloads folder live in a
+// temporary home directory
+func goldenApp(t testing.TB) *App {
+	home := t.TempDir()
+	for _, env := range []string{"HOME", "USERPROFILE"} {
+		t.Setenv(env, home)
+	}
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("APPDATA", filepath.Join(home, "config"))
+	if err := os.MkdirAll(filepath.Join(home, "Downloads"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &App{settings: defaultSettings()}
+}
+
+// writeGoldenInputs creates the reference inputs unless they exist. They are kept in
+// the repository, so the golden files do not depend on how CapGo draws pages.
+func writeGoldenInputs(input, signature string) error {
+	if err := os.MkdirAll(goldenDir, 0755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(input); os.IsNotExist(err) {
+		// A4 portrait, Letter landscape and A5 shown rotated by 90 degrees
+		var pages []*pdfcanvas.Canvas
+		for i, size := range [][2]float64{{595.28, 841.89}, {792, 612}, {419.53, 595.28}} {
+			c := pdfcanvas.New(size[0], size[1])
+			c.SetStrokeColor(pdfcanvas.RGB{R: 0.6, G: 0.6, B: 0.6})
+			c.Op("%.2f %.2f %.2f %.2f re S", 18.0, 18.0, size[0]-36, size[1]-36)
+			c.Text(36, size[1]-60, "Helvetica-Bold", 24, "Page "+string(rune('1'+i)), pdfcanvas.TextFill)
+			pages = append(pages, c)
+		}
+		if err := os.WriteFile(input, pdfcanvas.Render(pages...), 0644); err != nil {
+			return err
+		}
+		if err := api.RotateFile(input, "", 90, []string{"3"}, nil); err != nil {
+			return err
+		}
+	}
+	cropped := croppedInput(input)
+	if _, err := os.Stat(cropped); os.IsNotExist(err) {
+		// Two A4 pages cropped to a smaller area, the second shown rotated by 270 degrees
+		pages := []*pdfcanvas.Canvas{}
+		for i := 0; i < 2; i++ {
+			c := pdfcanvas.New(595.28, 841.89)
+			c.Text(72, 700, "Helvetica-Bold", 24, "Cropped "+string(rune('1'+i)), pdfcanvas.TextFill)
+			pages = append(pages, c)
+		}
+		if err := os.WriteFile(cropped, pdfcanvas.Render(pages...), 0644); err != nil {
+			return err
+		}
+		box, err := api.PageBoundaries("crop:[50 100 545 742]", types.POINTS)
+		if err != nil {
+			return err
+		}
+		if err := api.AddBoxesFile(cropped, "", nil, box, nil); err != nil {
+			return err
+		}
+		if err := api.RotateFile(cropped, "", 270, []string{"2"}, nil); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(signature); os.IsNotExist(err) {
+		if err := writePNG(signature, sampleSignature()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// croppedInput is the reference input with cropped and rotated pages next to input
+func croppedInput(input string) string {
+	return filepath.Join(filepath.Dir(input), "cropped_rotated.pdf")
+}
+
+// TestDeterministicOutput runs every golden case twice with deterministic output on and
+// expects identical files
+func TestDeterministicOutput(t *testing.T) {
+	input, _ := filepath.Abs(filepath.Join(goldenDir, "mixed_sizes.pdf"))
+	signature, _ := filepath.Abs(filepath.Join(goldenDir, "signature.png"))
+
+	for _, c := range goldenCases {
+		t.Run(c.name, func(t *testing.T) {
+			checkDeterministic(t, func(a *App) (string, error) { return c.run(a, input, signature) })
+		})
+	}
+}
+
+// TestDeterministicOutputOfOtherOperations covers operations without a golden case that
+// write their output on their own
+func TestDeterministicOutputOfOtherOperations(t *testing.T) {
+	input, _ := filepath.Abs(filepath.Join(goldenDir, "mixed_sizes.pdf"))
+	invoice := filepath.Join(t.TempDir(), "invoice.xml")
+	if err := os.WriteFile(invoice, []byte(`<rsm:CrossIndustryInvoice xmlns:rsm="urn:un:unece:uncefact:data:standard:CrossIndustryInvoice:100"/>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("facturx", func(t *testing.T) {
+		checkDeterministic(t, func(a *App) (string, error) { return a.AttachInvoiceXML(input, invoice, "EN16931") })
+	})
+	t.Run("script", func(t *testing.T) {
+		checkDeterministic(t, func(a *App) (string, error) {
+			s := Script{Name: "turn", Steps: []ScriptStep{{Op: ScriptRotate, Degrees: 90}}}
+			if err := a.SaveScript(s); err != nil {
+				return "", err
+			}
+			res, err := a.RunScript(s.Name, []string{input})
+			if err != nil {
+				return "", err
+			}
+			if res[0].Error != "" {
+				return "", errors.New(res[0].Error)
+			}
+			return res[0].Output, nil
+		})
+	})
+}
+
+// checkDeterministic runs an operation twice with deterministic output on and expects
+// identical files
+func checkDeterministic(t *testing.T, run func(a *App) (string, error)) {
+	var runs [2][]byte
+	for i := range runs {
+		a := goldenApp(t)
+		a.settings.DeterministicOutput = true
+		output, err := run(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runs[i], err = os.ReadFile(output); err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(output)
+	}
+	if !bytes.Equal(runs[0], runs[1]) {
+		t.Error("two runs wrote different files")
+	}
+}
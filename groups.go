@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// StampGroup is a shared transform applied to every stamp with a matching GroupID,
+// e.g. a signature, printed name and date that must move and scale together
+type StampGroup struct {
+	ID      string  `json:"id"`
+	OffsetX float64 `json:"offsetX"`
+	OffsetY float64 `json:"offsetY"`
+	Scale   float64 `json:"scale"`
+}
+
+// ResolveStampGroups returns the stamps with their group transforms applied,
+// so the frontend can preview and validate the final placement
+func (a *App) ResolveStampGroups(stamps []StampInfo, groups []StampGroup) ([]StampInfo, error) {
+	return resolveStampGroups(stamps, groups)
+}
+
+// resolveStampGroups scales each group around the top-left corner of its bounding box
+// and then moves it by the group offset. Stamps without a group are left untouched.
+func resolveStampGroups(stamps []StampInfo, groups []StampGroup) ([]StampInfo, error) {
+	if len(groups) == 0 {
+		return stamps, nil
+	}
+
+	byID := make(map[string]StampGroup, len(groups))
+	for _, g := range groups {
+		if g.ID == "" {
+			return nil, fmt.Errorf("stamp group is missing an id")
+		}
+		if g.Scale < 0 {
+			return nil, fmt.Errorf("stamp group %s has a negative scale", g.ID)
+		}
+		if g.Scale == 0 {
+			g.Scale = 1
+		}
+		byID[g.ID] = g
+	}
+
+	// Bounding box origin of each group (per page, since a block never spans pages)
+	type groupKey struct {
+		id   string
+		page int
+	}
+	origins := make(map[groupKey][2]float64)
+	for _, s := range stamps {
+		if s.GroupID == "" {
+			continue
+		}
+		if _, ok := byID[s.GroupID]; !ok {
+			return nil, fmt.Errorf("stamp references unknown group %s", s.GroupID)
+		}
+		key := groupKey{s.GroupID, s.PageNum}
+		o, ok := origins[key]
+		if !ok {
+			o = [2]float64{s.X, s.Y}
+		}
+		origins[key] = [2]float64{math.Min(o[0], s.X), math.Min(o[1], s.Y)}
+	}
+
+	resolved := make([]StampInfo, len(stamps))
+	for i, s := range stamps {
+		resolved[i] = s
+		if s.GroupID == "" {
+			continue
+		}
+		g := byID[s.GroupID]
+		o := origins[groupKey{s.GroupID, s.PageNum}]
+		resolved[i].X = o[0] + (s.X-o[0])*g.Scale + g.OffsetX
+		resolved[i].Y = o[1] + (s.Y-o[1])*g.Scale + g.OffsetY
+		resolved[i].Width = s.Width * g.Scale
+		resolved[i].Height = s.Height * g.Scale
+	}
+	return resolved, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryStamp records a stamp CapGo applied to a document
+type HistoryStamp struct {
+	Kind    string  `json:"kind"`
+	Text    string  `json:"text,omitempty"`
+	PageNum int     `json:"pageNum"`
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	Width   float64 `json:"width"`
+	Height  float64 `json:"height"`
+	GroupID string  `json:"groupId,omitempty"`
+	// Template identifies what was stamped, see stampTemplateKey
+	Template  string    `json:"template,omitempty"`
+	AppliedAt time.Time `json:"appliedAt"`
+	Flattened bool      `json:"flattened,omitempty"` // part of the page content, cannot be removed
+
+}
+
+// DocumentHistory is the sidecar record CapGo keeps for every file it writes.
+// Records are keyed by the SHA-256 of the file, so they survive renames and moves
+// but never apply to a document that was changed by another program.
+type DocumentHistory struct {
+	SHA256    string         `json:"sha256"`
+	Path      string         `json:"path"`   // where the file was written
+	Source    string         `json:"source"` // the document it was made from
+	Operation string         `json:"operation"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Stamps    []HistoryStamp `json:"stamps"` // every CapGo stamp in the document, including earlier runs
+}
+
+func historyDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "history")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create history directory: %v", err)
+	}
+	return dir, nil
+}
+
+// fileSHA256 returns the hex encoded SHA-256 of a file
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// lookupHistory returns the record for a document, or nil when CapGo did not write it
+func lookupHistory(path string) (*DocumentHistory, error) {
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	var h *DocumentHistory
+	if err := readConfigJSON(filepath.Join("history", sum+".json"), &h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// recordHistory stores the record for a newly written document
+func recordHistory(source, output, operation string, stamps []HistoryStamp) error {
+	if _, err := historyDir(); err != nil {
+		return err
+	}
+	sum, err := fileSHA256(output)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", output, err)
+	}
+
+	all := append([]HistoryStamp{}, stamps...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].PageNum < all[j].PageNum })
+
+	h := DocumentHistory{
+		SHA256:    sum,
+		Path:      output,
+		Source:    source,
+		Operation: operation,
+		CreatedAt: time.Now(),
+		Stamps:    all,
+	}
+	return writeConfigJSON(filepath.Join("history", sum+".json"), h)
+}
+
+// inheritedStamps returns the stamps recorded for a source document. They are still
+// part of anything written from it, so new records start from them.
+func inheritedStamps(source string) []HistoryStamp {
+	prev, err := lookupHistory(source)
+	if err != nil || prev == nil {
+		return nil
+	}
+	return prev.Stamps
+}
+
+// recordDerivedHistory records a copy of a document that keeps its pages and stamps,
+// so the copy is still recognised as CapGo output. Documents without a record are skipped.
+func recordDerivedHistory(source, output, operation string) {
+	prev, err := lookupHistory(source)
+	if err != nil || prev == nil {
+		return
+	}
+	if err := recordHistory(source, output, operation, prev.Stamps); err != nil {
+		fmt.Printf("Backend: Failed to record history for %s: %v\n", output, err)
+	}
+}
+
+// historyStamps converts applied stamps into history records
+func historyStamps(stamps []StampInfo) []HistoryStamp {
+	now := time.Now()
+	out := make([]HistoryStamp, len(stamps))
+	for i, s := range stamps {
+		kind := s.Kind
+		if kind == "" {
+			kind = StampKindImage
+		}
+		out[i] = HistoryStamp{
+			Kind:      kind,
+			Text:      s.Text,
+			PageNum:   s.PageNum,
+			X:         s.X,
+			Y:         s.Y,
+			Width:     s.Width,
+			Height:    s.Height,
+			GroupID:   s.GroupID,
+			Template:  stampTemplateKey(s),
+			AppliedAt: now,
+		}
+	}
+	return out
+}
+
+// stampTemplateKey identifies the stamp template: the template ID when the stamp came
+// from a saved template, otherwise the image content or the text
+func stampTemplateKey(s StampInfo) string {
+	if s.TemplateID != "" {
+		return "template:" + s.TemplateID
+	}
+	if s.Kind == StampKindText {
+		return "text:" + s.Text
+	}
+	if s.Image == "" {
+		return ""
+	}
+	var sum string
+	if strings.Contains(s.Image, ";base64,") {
+		h := sha256.Sum256([]byte(s.Image))
+		sum = hex.EncodeToString(h[:])
+	} else if fileSum, err := fileSHA256(s.Image); err == nil {
+		sum = fileSum
+	} else {
+		return "image:" + filepath.Clean(s.Image)
+	}
+	return "image:" + sum[:16]
+}
+
+// regionsOverlap reports whether two stamp boxes on the same page cover mostly the same area
+func regionsOverlap(ax, ay, aw, ah, bx, by, bw, bh float64) bool {
+	w := math.Min(ax+aw, bx+bw) - math.Max(ax, bx)
+	h := math.Min(ay+ah, by+bh) - math.Max(ay, by)
+	if w <= 0 || h <= 0 {
+		return false
+	}
+	return w*h >= 0.5*math.Min(aw*ah, bw*bh)
+}
+
+// GetDocumentHistory returns the CapGo record for a document, or nil when there is none
+func (a *App) GetDocumentHistory(pdfPath string) (*DocumentHistory, error) {
+	return lookupHistory(filepath.Clean(pdfPath))
+}
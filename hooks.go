@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Hook stages
+const (
+	HookBefore = "before" // a failing hook cancels the operation
+	HookAfter  = "after"  // a failing hook is reported but the output is kept
+)
+
+// hookOperations are the operations hooks can be attached to
+var hookOperations = map[string]bool{
+	"stamp":     true,
+	"split":     true,
+	"rotate":    true,
+	"insert":    true,
+	"portfolio": true,
+	"plugin":    true,
+}
+
+// hookTimeout bounds a single hook run
+const hookTimeout = 2 * time.Minute
+
+// Hook runs a command before or after an operation. The command is started with Args
+// followed by the file path and a JSON encoded HookContext.
+type Hook struct {
+	Operation string   `json:"operation"` // see hookOperations, or "*" for all of them
+	Stage     string   `json:"stage"`     // HookBefore or HookAfter
+	Command   string   `json:"command"`
+	Args      []string `json:"args,omitempty"`
+	Enabled   bool     `json:"enabled"`
+}
+
+// HookContext describes the operation to the hook command
+type HookContext struct {
+	Operation string   `json:"operation"`
+	Stage     string   `json:"stage"`
+	Source    string   `json:"source"`
+	Outputs   []string `json:"outputs,omitempty"` // after hooks only
+}
+
+// HookFailure is emitted as "hook:failed" when an after hook fails
+type HookFailure struct {
+	Hook  Hook   `json:"hook"`
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// GetHooks returns the configured hooks
+func (a *App) GetHooks() []Hook {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Hook{}, a.settings.Hooks...)
+}
+
+// SetHooks replaces and persists the configured hooks
+func (a *App) SetHooks(hooks []Hook) error {
+	for i, h := range hooks {
+		if h.Operation != "*" && !hookOperations[h.Operation] {
+			return fmt.Errorf("hook %d: unknown operation %q", i, h.Operation)
+		}
+		if h.Stage != HookBefore && h.Stage != HookAfter {
+			return fmt.Errorf("hook %d: stage must be %q or %q", i, HookBefore, HookAfter)
+		}
+		if strings.TrimSpace(h.Command) == "" {
+			return fmt.Errorf("hook %d: no command", i)
+		}
+		if !filepath.IsAbs(h.Command) {
+			if _, err := exec.LookPath(h.Command); err != nil {
+				return fmt.Errorf("hook %d: command %s not found", i, h.Command)
+			}
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settings.Hooks = append([]Hook{}, hooks...)
+	return a.saveSettings()
+}
+
+// hooksFor returns the enabled hooks of an operation stage
+func (a *App) hooksFor(operation, stage string) []Hook {
+	var hooks []Hook
+	for _, h := range a.GetHooks() {
+		if h.Enabled && h.Stage == stage && (h.Operation == operation || h.Operation == "*") {
+			hooks = append(hooks, h)
+		}
+	}
+	return hooks
+}
+
+// runBeforeHooks runs the before hooks of an operation on its source file and returns
+// the first failure, which cancels the operation
+func (a *App) runBeforeHooks(operation, source string) error {
+	for _, h := range a.hooksFor(operation, HookBefore) {
+		hc := HookContext{Operation: operation, Stage: HookBefore, Source: source}
+		if err := runHook(h, source, hc); err != nil {
+			return fmt.Errorf("%s cancelled by hook: %v", operation, err)
+		}
+	}
+	return nil
+}
+
+// runAfterHooks runs the after hooks of an operation once per output file. Failures
+// are logged and emitted, since the output has already been written.
+func (a *App) runAfterHooks(operation, source string, outputs ...string) {
+	hooks := a.hooksFor(operation, HookAfter)
+	for _, h := range hooks {
+		for _, out := range outputs {
+			hc := HookContext{Operation: operation, Stage: HookAfter, Source: source, Outputs: outputs}
+			if err := runHook(h, out, hc); err != nil {
+				fmt.Printf("Backend: After hook for %s failed: %v\n", operation, err)
+				a.emit(EventHookFailed, HookFailure{Hook: h, File: out, Error: err.Error()})
+			}
+		}
+	}
+}
+
+// runHook starts the hook command without a shell and waits for it to finish
+func runHook(h Hook, path string, hc HookContext) error {
+	data, err := json.Marshal(hc)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	args := append(append([]string{}, h.Args...), path, string(data))
+	out, err := exec.CommandContext(ctx, h.Command, args...).CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s did not finish within %v", filepath.Base(h.Command), hookTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s failed: %v: %s", filepath.Base(h.Command), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"sort"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/menu"
+	"github.com/wailsapp/wails/v2/pkg/menu/keys"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Hotkey actions
+const (
+	HotkeyQuickStamp       = "quick_stamp"       // stamp the open or last downloaded document
+	HotkeyStampClipboard   = "stamp_clipboard"   // quick stamp the PDF whose path is on the clipboard
+	HotkeyCaptureSignature = "capture_signature" // ask the frontend to capture a signature
+)
+
+// HotkeyStatus reports a configured hotkey and whether it could be registered
+type HotkeyStatus struct {
+	Action      string `json:"action"`
+	Label       string `json:"label"`
+	Accelerator string `json:"accelerator"` // e.g. "cmdorctrl+shift+k", empty when unassigned
+	Display     string `json:"display"`     // accelerator as shown on this platform
+	Registered  bool   `json:"registered"`
+	Conflict    string `json:"conflict,omitempty"` // why the hotkey is not registered
+}
+
+// hotkeyLabels names the hotkey actions in the order they appear in settings
+var hotkeyLabels = []struct{ action, label string }{
+	{HotkeyQuickStamp, "Stamp Document"},
+	{HotkeyStampClipboard, "Stamp Clipboard PDF"},
+	{HotkeyCaptureSignature, "Capture Signature"},
+}
+
+// defaultHotkeys returns the hotkeys used on first launch
+func defaultHotkeys() map[string]string {
+	return map[string]string{
+		HotkeyQuickStamp:       "cmdorctrl+shift+k",
+		HotkeyStampClipboard:   "cmdorctrl+shift+l",
+		HotkeyCaptureSignature: "cmdorctrl+shift+g",
+	}
+}
+
+// systemShortcuts are taken by the operating system or by standard text editing
+var systemShortcuts = map[string]string{
+	"cmdorctrl+q":     "Quit",
+	"cmdorctrl+w":     "Close Window",
+	"cmdorctrl+h":     "Hide",
+	"cmdorctrl+m":     "Minimize",
+	"cmdorctrl+c":     "Copy",
+	"cmdorctrl+v":     "Paste",
+	"cmdorctrl+x":     "Cut",
+	"cmdorctrl+a":     "Select All",
+	"cmdorctrl+tab":   "Switch Applications",
+	"cmdorctrl+space": "Spotlight",
+}
+
+// GetHotkeys returns the configured hotkeys with any conflicts
+func (a *App) GetHotkeys() []HotkeyStatus {
+	a.mu.Lock()
+	configured := a.settings.Hotkeys
+	a.mu.Unlock()
+	return a.hotkeyStatus(configured)
+}
+
+// SetHotkey assigns an accelerator such as "cmdorctrl+shift+k" to an action, or clears it
+// when accelerator is empty. The hotkey is saved even when it conflicts; the returned
+// statuses show which hotkeys could not be registered and why.
+func (a *App) SetHotkey(action, accelerator string) ([]HotkeyStatus, error) {
+	if hotkeyLabel(action) == "" {
+		return nil, fmt.Errorf("unknown hotkey action: %s", action)
+	}
+	accelerator = strings.ToLower(strings.TrimSpace(accelerator))
+	if accelerator != "" {
+		acc, err := keys.Parse(accelerator)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hotkey: %v", err)
+		}
+		if len(acc.Modifiers) == 0 {
+			return nil, fmt.Errorf("a hotkey needs at least one modifier")
+		}
+		accelerator = canonicalAccelerator(acc)
+	}
+
+	a.mu.Lock()
+	hotkeys := map[string]string{}
+	for k, v := range a.settings.Hotkeys {
+		hotkeys[k] = v
+	}
+	hotkeys[action] = accelerator
+	a.settings.Hotkeys = hotkeys
+	err := a.saveSettings()
+	a.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return a.registerHotkeys(), nil
+}
+
+// hotkeyMenu adds a menu item for every hotkey action; registering a hotkey assigns its
+// accelerator to the item. Wails v2 has no system-wide shortcuts, so hotkeys fire while
+// CapGo is the active application, including in menu-bar mode with the window hidden.
+func (a *App) hotkeyMenu(sub *menu.Menu) {
+	a.menu.hotkeys = map[string]*menu.MenuItem{}
+	for _, h := range hotkeyLabels {
+		action := h.action
+		a.menu.hotkeys[action] = sub.AddText(tr(h.label), nil, func(*menu.CallbackData) {
+			if err := a.runHotkey(action); err != nil {
+				msg := localizeMessage(err.Error())
+				a.emit(EventHotkeyError, msg)
+				a.jobFinished(action, msg, true)
+				fmt.Printf("Backend: Hotkey %s failed: %v\n", action, err)
+			}
+		})
+	}
+	a.registerHotkeys()
+}
+
+// registerHotkeys assigns the configured accelerators to the hotkey menu items,
+// leaving out the ones that conflict
+func (a *App) registerHotkeys() []HotkeyStatus {
+	a.mu.Lock()
+	configured := a.settings.Hotkeys
+	a.mu.Unlock()
+
+	statuses := a.hotkeyStatus(configured)
+	if a.menu.hotkeys == nil {
+		return statuses
+	}
+	for _, s := range statuses {
+		item := a.menu.hotkeys[s.Action]
+		if s.Registered {
+			acc, _ := keys.Parse(s.Accelerator)
+			item.SetAccelerator(acc)
+		} else {
+			item.Accelerator = nil
+		}
+	}
+	if a.ctx != nil {
+		runtime.MenuUpdateApplicationMenu(a.ctx)
+	}
+	return statuses
+}
+
+// hotkeyStatus checks the configured hotkeys against the system shortcuts, the fixed
+// menu shortcuts and each other
+func (a *App) hotkeyStatus(configured map[string]string) []HotkeyStatus {
+	taken := map[string]string{}
+	for k, v := range systemShortcuts {
+		taken[k] = v
+	}
+	if a.menu.bar != nil {
+		for k, v := range menuAccelerators(a.menu.bar, a.menu.hotkeys) {
+			taken[k] = v
+		}
+	}
+
+	// Assigned before the loop so that both sides of a clash are reported
+	owners := map[string][]string{}
+	for _, h := range hotkeyLabels {
+		if acc := configured[h.action]; acc != "" {
+			owners[acc] = append(owners[acc], h.label)
+		}
+	}
+
+	statuses := make([]HotkeyStatus, 0, len(hotkeyLabels))
+	for _, h := range hotkeyLabels {
+		s := HotkeyStatus{Action: h.action, Label: h.label, Accelerator: configured[h.action]}
+		if s.Accelerator != "" {
+			if acc, err := keys.Parse(s.Accelerator); err == nil {
+				s.Display = keys.Stringify(acc, goruntime.GOOS)
+			}
+			if used, ok := taken[s.Accelerator]; ok {
+				s.Conflict = "already used by " + used
+			} else if len(owners[s.Accelerator]) > 1 {
+				s.Conflict = "assigned to " + strings.Join(owners[s.Accelerator], " and ")
+			} else {
+				s.Registered = true
+			}
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// menuAccelerators returns the accelerators in use by the menu bar, apart from the hotkey items
+func menuAccelerators(m *menu.Menu, skip map[string]*menu.MenuItem) map[string]string {
+	own := map[*menu.MenuItem]bool{}
+	for _, item := range skip {
+		own[item] = true
+	}
+	used := map[string]string{}
+	var walk func(*menu.Menu)
+	walk = func(m *menu.Menu) {
+		for _, item := range m.Items {
+			if item.SubMenu != nil {
+				walk(item.SubMenu)
+			}
+			if item.Accelerator != nil && !own[item] {
+				used[canonicalAccelerator(item.Accelerator)] = item.Label
+			}
+		}
+	}
+	walk(m)
+	return used
+}
+
+// canonicalAccelerator formats an accelerator with its modifiers in a fixed order
+func canonicalAccelerator(acc *keys.Accelerator) string {
+	parts := make([]string, 0, len(acc.Modifiers)+1)
+	for _, m := range acc.Modifiers {
+		parts = append(parts, string(m))
+	}
+	sort.Strings(parts)
+	return strings.Join(append(parts, strings.ToLower(acc.Key)), "+")
+}
+
+// runHotkey performs a hotkey action
+func (a *App) runHotkey(action string) error {
+	switch action {
+	case HotkeyQuickStamp:
+		_, err := a.QuickStampDocument()
+		return err
+	case HotkeyStampClipboard:
+		if a.ctx == nil {
+			return fmt.Errorf("clipboard is not available")
+		}
+		text, err := runtime.ClipboardGetText(a.ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read clipboard: %v", err)
+		}
+		path := filepath.Clean(strings.Trim(strings.TrimSpace(text), `"'`))
+		if !strings.EqualFold(filepath.Ext(path), ".pdf") {
+			return fmt.Errorf("the clipboard does not hold a PDF path")
+		}
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+		_, err = a.quickStamp(path)
+		return err
+	case HotkeyCaptureSignature:
+		a.showWindow()
+		a.emit(EventCaptureSignature)
+		return nil
+	}
+	return fmt.Errorf("unknown hotkey action: %s", action)
+}
+
+func hotkeyLabel(action string) string {
+	for _, h := range hotkeyLabels {
+		if h.action == action {
+			return h.label
+		}
+	}
+	return ""
+}
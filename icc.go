@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// srgbICCProfile builds a compact ICC v2 display profile for sRGB. PDF/A output intents
+// need an embedded profile, and generating it avoids shipping a binary asset.
+func srgbICCProfile() []byte {
+	s15 := func(v float64) uint32 { return uint32(int32(math.Round(v * 65536))) }
+
+	xyz := func(x, y, z float64) []byte {
+		var b bytes.Buffer
+		b.WriteString("XYZ ")
+		binary.Write(&b, binary.BigEndian, [4]uint32{0, s15(x), s15(y), s15(z)})
+		return b.Bytes()
+	}
+
+	text := func(s string) []byte {
+		var b bytes.Buffer
+		b.WriteString("text")
+		b.Write(make([]byte, 4))
+		b.WriteString(s)
+		b.WriteByte(0)
+		return b.Bytes()
+	}
+
+	desc := func(s string) []byte {
+		var b bytes.Buffer
+		b.WriteString("desc")
+		b.Write(make([]byte, 4))
+		binary.Write(&b, binary.BigEndian, uint32(len(s)+1))
+		b.WriteString(s)
+		b.WriteByte(0)
+		// Empty Unicode and ScriptCode descriptions
+		b.Write(make([]byte, 4+4+2+1+67))
+		return b.Bytes()
+	}
+
+	// Sampled sRGB transfer curve
+	var curve bytes.Buffer
+	curve.WriteString("curv")
+	curve.Write(make([]byte, 4))
+	const samples = 1024
+	binary.Write(&curve, binary.BigEndian, uint32(samples))
+	for i := 0; i < samples; i++ {
+		v := float64(i) / (samples - 1)
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		binary.Write(&curve, binary.BigEndian, uint16(math.Round(v*65535)))
+	}
+
+	// Primaries adapted to the D50 profile connection space
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", desc("sRGB IEC61966-2.1")},
+		{"cprt", text("No copyright, use freely")},
+		{"wtpt", xyz(0.9642, 1.0, 0.8249)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", curve.Bytes()},
+		{"gTRC", curve.Bytes()},
+		{"bTRC", curve.Bytes()},
+	}
+
+	// Tag data follows the header and tag table, each entry aligned to 4 bytes.
+	// The three curves share one copy of the data.
+	var table, data bytes.Buffer
+	offset := 128 + 4 + 12*len(tags)
+	shared := map[string][2]uint32{}
+	for _, t := range tags {
+		key := string(t.data)
+		loc, ok := shared[key]
+		if !ok {
+			loc = [2]uint32{uint32(offset + data.Len()), uint32(len(t.data))}
+			shared[key] = loc
+			data.Write(t.data)
+			for data.Len()%4 != 0 {
+				data.WriteByte(0)
+			}
+		}
+		table.WriteString(t.sig)
+		binary.Write(&table, binary.BigEndian, loc)
+	}
+
+	size := offset + data.Len()
+	var header bytes.Buffer
+	binary.Write(&header, binary.BigEndian, uint32(size))
+	header.Write(make([]byte, 4))                               // preferred CMM
+	binary.Write(&header, binary.BigEndian, uint32(0x02100000)) // version 2.1
+	header.WriteString("mntrRGB XYZ ")
+	header.Write(make([]byte, 12)) // creation date
+	header.WriteString("acsp")
+	header.Write(make([]byte, 4+4+4+4+8+4)) // platform, flags, manufacturer, model, attributes, intent
+	binary.Write(&header, binary.BigEndian, [3]uint32{s15(0.9642), s15(1.0), s15(0.8249)})
+	header.Write(make([]byte, 4+16+28)) // creator, profile ID, reserved
+
+	var out bytes.Buffer
+	out.Write(header.Bytes())
+	binary.Write(&out, binary.BigEndian, uint32(len(tags)))
+	out.Write(table.Bytes())
+	out.Write(data.Bytes())
+	return out.Bytes()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// ICloudStatus describes whether a file in iCloud Drive is stored locally
+type ICloudStatus struct {
+	Placeholder bool  `json:"placeholder"` // only a placeholder is on disk, the content is in iCloud
+	Size        int64 `json:"size"`        // 0 when unknown
+	Downloaded  int64 `json:"downloaded"`  // bytes already on disk
+}
+
+// ICloudProgress is emitted as "icloud:progress" while a placeholder is downloaded
+type ICloudProgress struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	Downloaded int64  `json:"downloaded"`
+	Done       bool   `json:"done"`
+}
+
+const (
+	icloudPollInterval = 500 * time.Millisecond
+	icloudTimeout      = 5 * time.Minute
+)
+
+// GetICloudStatus reports whether a file is an iCloud Drive placeholder
+func (a *App) GetICloudStatus(path string) (ICloudStatus, error) {
+	return cloudStatus(filepath.Clean(path))
+}
+
+// DownloadICloudFile downloads an iCloud Drive placeholder and waits until the file is
+// available, emitting "icloud:progress"
+func (a *App) DownloadICloudFile(path string) error {
+	return a.ensureLocal(path)
+}
+
+// ensureLocal makes sure a file's content is on disk before it is read. Reading an
+// iCloud placeholder directly fails with short reads, so it is downloaded first.
+func (a *App) ensureLocal(path string) error {
+	path = filepath.Clean(path)
+	status, err := cloudStatus(path)
+	if err != nil || !status.Placeholder {
+		// Missing files are reported by the caller's own read
+		return nil
+	}
+
+	fmt.Printf("Backend: Downloading %s from iCloud\n", path)
+	if err := requestCloudDownload(path); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(icloudTimeout)
+	for {
+		a.emit(EventICloudProgress, ICloudProgress{Path: path, Size: status.Size, Downloaded: status.Downloaded})
+		time.Sleep(icloudPollInterval)
+
+		status, err = cloudStatus(path)
+		if err != nil {
+			return fmt.Errorf("failed to download %s from iCloud: %v", filepath.Base(path), err)
+		}
+		if !status.Placeholder {
+			a.emit(EventICloudProgress, ICloudProgress{Path: path, Size: status.Size, Downloaded: status.Size, Done: true})
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out downloading %s from iCloud", filepath.Base(path))
+		}
+	}
+}
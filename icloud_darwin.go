@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// sfDataless is the st_flags bit of files whose content has been evicted to iCloud
+const sfDataless = 0x40000000
+
+// cloudStatus detects both kinds of iCloud placeholder: dataless files (macOS 14 and
+// later), which keep their name and size, and the hidden ".name.icloud" stub files of
+// older releases, which replace the file
+func cloudStatus(path string) (ICloudStatus, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		if _, stubErr := os.Stat(icloudStubPath(path)); stubErr == nil {
+			return ICloudStatus{Placeholder: true}, nil
+		}
+	}
+	if err != nil {
+		return ICloudStatus{}, err
+	}
+
+	status := ICloudStatus{Size: info.Size(), Downloaded: info.Size()}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Flags&sfDataless != 0 {
+		status.Placeholder = true
+		status.Downloaded = st.Blocks * 512
+		if status.Downloaded > status.Size {
+			status.Downloaded = status.Size
+		}
+	}
+	return status, nil
+}
+
+// requestCloudDownload asks the iCloud daemon to download a placeholder. If brctl is
+// unavailable, reading the file also makes the file provider fetch it.
+func requestCloudDownload(path string) error {
+	out, err := exec.Command("brctl", "download", path).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if _, statErr := os.Stat(path); statErr != nil {
+		return fmt.Errorf("failed to download from iCloud: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	go func() {
+		if f, err := os.Open(path); err == nil {
+			f.Read(make([]byte, 1))
+			f.Close()
+		}
+	}()
+	return nil
+}
+
+func icloudStubPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".icloud")
+}
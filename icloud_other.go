@@ -0,0 +1,22 @@
+//go:build !darwin
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// cloudStatus reports every existing file as local; iCloud Drive placeholders only
+// exist on macOS
+func cloudStatus(path string) (ICloudStatus, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ICloudStatus{}, err
+	}
+	return ICloudStatus{Size: info.Size(), Downloaded: info.Size()}, nil
+}
+
+func requestCloudDownload(path string) error {
+	return fmt.Errorf("iCloud Drive is not supported on this platform")
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// Defaults of ExportPagesAsImages; pasted into a mail or a slide a page should stay
+// sharp when zoomed in a little
+const (
+	defaultExportDPI  = 150
+	exportJPEGQuality = 90
+)
+
+// ExportPagesAsImages renders the selected pages, e.g. "1" or "3-5", to PNG or JPEG
+// files at dpi (150 when 0) in a new folder in the output folder and returns their
+// paths in page order. No pages exports all of them. Like RenderPage it needs
+// Ghostscript.
+func (a *App) ExportPagesAsImages(pdfPath string, pages []string, format string, dpi int) (_ []string, err error) {
+	pdfPath = filepath.Clean(pdfPath)
+	switch format = strings.ToLower(format); format {
+	case "", PreviewFormatPNG:
+		format = PreviewFormatPNG
+	case PreviewFormatJPEG, "jpg":
+		format = PreviewFormatJPEG
+	default:
+		return nil, fmt.Errorf("unsupported image format: %s", format)
+	}
+	if dpi == 0 {
+		dpi = defaultExportDPI
+	}
+	if dpi < 0 || dpi > maxRenderDPI {
+		return nil, fmt.Errorf("resolution must be between 1 and %d dpi", maxRenderDPI)
+	}
+	gs := ghostscriptPath()
+	if gs == "" {
+		return nil, fmt.Errorf("rendering pages needs Ghostscript, which is not installed")
+	}
+	defer recoverDamaged(pdfPath, &err)
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return nil, err
+	}
+	pageCount, err := api.PageCountFile(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", filepath.Base(pdfPath), err)
+	}
+	var selected []int
+	if len(pages) == 0 {
+		for p := 1; p <= pageCount; p++ {
+			selected = append(selected, p)
+		}
+	} else {
+		set, err := api.PagesForPageSelection(pageCount, pages, false, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page selection: %v", err)
+		}
+		for p, ok := range set {
+			if ok {
+				selected = append(selected, p)
+			}
+		}
+		sort.Ints(selected)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("none of the selected pages exist in the %d page document", pageCount)
+	}
+
+	base, err := a.outputBaseDir()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := a.outputDir(uniquePath(filepath.Join(base, outputName(pdfPath, "_images", ""))))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, classifyFileError("write", dir, err)
+	}
+	tmpDir, err := os.MkdirTemp("", "capgo_export_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp folder: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	defer a.startJob("export")()
+	timeout := time.Duration(a.GetOperationTimeouts().Seconds) * time.Second
+	ext := "." + format
+	if format == PreviewFormatJPEG {
+		ext = ".jpg"
+	}
+	var outputs []string
+	// Each run of consecutive pages is one Ghostscript call, and pages that are not
+	// selected are not rendered
+	for i := 0; i < len(selected); {
+		first, last := selected[i], selected[i]
+		for i++; i < len(selected) && selected[i] == last+1; i++ {
+			last = selected[i]
+		}
+		if _, err := ghostscriptPNG(gs, pdfPath, first, last, dpi, filepath.Join(tmpDir, "%d.png"), timeout); err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("failed to render pages %s of %s: %v", pageRange{From: first, To: last}, filepath.Base(pdfPath), err)
+		}
+		for p := first; p <= last; p++ {
+			rendered := filepath.Join(tmpDir, fmt.Sprintf("%d.png", p-first+1))
+			output := filepath.Join(dir, outputName(pdfPath, fmt.Sprintf("_page_%d", p), ext))
+			if err := exportImage(rendered, output, format); err != nil {
+				os.RemoveAll(dir)
+				return nil, fmt.Errorf("failed to export page %d: %v", p, err)
+			}
+			outputs = append(outputs, output)
+		}
+	}
+	fmt.Printf("Backend: Exported %d pages of %s to %s\n", len(outputs), pdfPath, dir)
+	return outputs, nil
+}
+
+// exportImage writes a PNG rendered by Ghostscript to output in format
+func exportImage(rendered, output, format string) error {
+	if format == PreviewFormatPNG {
+		if err := os.Rename(rendered, output); err == nil {
+			return nil
+		}
+		data, err := os.ReadFile(rendered)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(output, data, 0644)
+	}
+	img, err := readPNG(rendered)
+	if err != nil {
+		return err
+	}
+	data, err := encodePreview(img, PreviewSettings{Format: PreviewFormatJPEG, Quality: exportJPEGQuality})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, data, 0644)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+
+	"CapGo/pkg/stamper"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// imagePageScale is how much of the page an image covers, leaving a thin white border
+// like a scan has
+const imagePageScale = 0.95
+
+// ImagesToPDF builds a PDF with a page for every image, e.g. photographed or scanned
+// documents, so they can be stamped like any PDF. pageSize names a paper size, see
+// GetPaperSizes; empty uses the default. Each image is centered and scaled to fit, on
+// the landscape version of the size when it is wider than high. Multi-page TIFF files
+// give a page for each of their images, HEIC photos are read like stamp images. The PDF is named after the first image.
+func (a *App) ImagesToPDF(imagePaths []string, pageSize string) (string, error) {
+	if len(imagePaths) == 0 {
+		return "", fmt.Errorf("no images given")
+	}
+	paper, err := a.paperSize(pageSize)
+	if err != nil {
+		return "", err
+	}
+	// HEIC/HEIF images are converted to PNG files in tmpDir first
+	tmpDir, err := os.MkdirTemp("", "capgo_images_*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp folder: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	paths := make([]string, len(imagePaths))
+	imps := make([]*pdfcpu.Import, len(imagePaths))
+	for i, path := range imagePaths {
+		path = filepath.Clean(path)
+		if err := a.ensureLocal(path); err != nil {
+			return "", err
+		}
+		heif, err := stamper.IsHEIFFile(path)
+		if err != nil {
+			return "", classifyFileError("read", path, err)
+		}
+		paths[i] = path
+		if heif {
+			paths[i] = filepath.Join(tmpDir, fmt.Sprintf("%d.png", i))
+			if err := stamper.ConvertHEIF(path, paths[i]); err != nil {
+				return "", fmt.Errorf("failed to convert %s: %v", filepath.Base(path), err)
+			}
+		}
+		cfg, err := imageConfig(paths[i])
+		if err != nil {
+			return "", err
+		}
+		dim := types.Dim{Width: paper.Width, Height: paper.Height}
+		if cfg.Width > cfg.Height {
+			dim.Width, dim.Height = dim.Height, dim.Width
+		}
+		imps[i] = &pdfcpu.Import{
+			PageDim: &dim,
+			UserDim: true,
+			Pos:     types.Center,
+			Scale:   imagePageScale,
+			InpUnit: types.POINTS,
+		}
+	}
+	defer a.startJob("convert")()
+
+	output, err := a.outputPath(filepath.Clean(imagePaths[0]), "")
+	if err != nil {
+		return "", err
+	}
+	if err := writeImagesPDF(paths, imps, output); err != nil {
+		os.Remove(output)
+		return "", fmt.Errorf("failed to convert images: %v", err)
+	}
+	if err := a.finishOutput(output); err != nil {
+		os.Remove(output)
+		return "", err
+	}
+	fmt.Printf("Backend: Converted %d images to %s\n", len(paths), output)
+	return output, nil
+}
+
+// imageConfig reads the size of the image at path
+func imageConfig(path string) (image.Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Config{}, classifyFileError("read", path, err)
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(bufio.NewReader(f))
+	if err != nil {
+		return image.Config{}, fmt.Errorf("unsupported image %s: %v", filepath.Base(path), err)
+	}
+	return cfg, nil
+}
+
+// writeImagesPDF writes a new PDF with the pages of each image placed as imps says.
+// api.ImportImagesFile places every image the same way, so the pages are added here.
+func writeImagesPDF(imagePaths []string, imps []*pdfcpu.Import, output string) error {
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.IMPORTIMAGES
+	ctx, err := pdfcpu.CreateContextWithXRefTable(conf, imps[0].PageDim)
+	if err != nil {
+		return err
+	}
+	pagesIndRef, err := ctx.Pages()
+	if err != nil {
+		return err
+	}
+	pagesDict, err := ctx.DereferenceDict(*pagesIndRef)
+	if err != nil {
+		return err
+	}
+	for i, path := range imagePaths {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		indRefs, err := pdfcpu.NewPagesForImage(ctx.XRefTable, bufio.NewReader(f), pagesIndRef, imps[i])
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", filepath.Base(path), err)
+		}
+		for _, indRef := range indRefs {
+			if err := ctx.SetValid(*indRef); err != nil {
+				return err
+			}
+			if err := model.AppendPageTree(indRef, 1, pagesDict); err != nil {
+				return err
+			}
+			ctx.PageCount++
+		}
+	}
+	return api.WriteContextFile(ctx, output)
+}
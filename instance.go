@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/options"
+)
+
+// singleInstanceID identifies CapGo to the Wails single instance lock
+const singleInstanceID = "com.lelehuy.capgo"
+
+// SecondInstance is emitted as "instance:launched" when CapGo is started again while
+// it is already running; the new process hands over its files and exits
+type SecondInstance struct {
+	Files []string `json:"files"` // PDFs to open, empty when CapGo was just launched again
+}
+
+// singleInstanceLock keeps one main CapGo process per user. Additional windows run as
+// child processes of the main one (see OpenWindow) and are not locked.
+func (a *App) singleInstanceLock() *options.SingleInstanceLock {
+	if a.window.Kind != WindowMain {
+		return nil
+	}
+	return &options.SingleInstanceLock{
+		UniqueId:               singleInstanceID,
+		OnSecondInstanceLaunch: a.onSecondInstance,
+	}
+}
+
+// onSecondInstance brings the running window forward and passes on the files the
+// second launch was asked to open
+func (a *App) onSecondInstance(data options.SecondInstanceData) {
+	files := fileArgs(data.Args, data.WorkingDirectory)
+	fmt.Printf("Backend: Second instance launched with %d files\n", len(files))
+	a.showWindow()
+	a.emit(EventInstanceLaunched, SecondInstance{Files: files})
+}
+
+// GetLaunchFiles returns the PDFs CapGo was started with, e.g. via "Open With"
+func (a *App) GetLaunchFiles() []string {
+	if a.window.Kind != WindowMain {
+		return []string{}
+	}
+	wd, _ := os.Getwd()
+	return fileArgs(os.Args[1:], wd)
+}
+
+// fileArgs returns the existing PDFs among the command line arguments, skipping CapGo's
+// own flags. Relative paths are resolved against dir.
+func fileArgs(args []string, dir string) []string {
+	files := []string{}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") || !strings.EqualFold(filepath.Ext(arg), ".pdf") {
+			continue
+		}
+		if !filepath.IsAbs(arg) && dir != "" {
+			arg = filepath.Join(dir, arg)
+		}
+		arg = filepath.Clean(arg)
+		if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+			files = append(files, arg)
+		}
+	}
+	return files
+}
@@ -0,0 +1,291 @@
+// Package pdfcanvas draws vector pages and SVG images as PDF content for the stamping
+// engine and the pages CapGo generates, like cover sheets and sample documents.
+package pdfcanvas
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// RGB is a colour with components in the range 0..1
+type RGB struct {
+	R, G, B float64
+}
+
+// ParseHexColor parses "#RRGGBB" or "#RGB"
+func ParseHexColor(s string) (RGB, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return RGB{}, fmt.Errorf("invalid color: %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return RGB{}, fmt.Errorf("invalid color: %q", s)
+	}
+	return RGB{
+		R: float64(v>>16&0xFF) / 255,
+		G: float64(v>>8&0xFF) / 255,
+		B: float64(v&0xFF) / 255,
+	}, nil
+}
+
+// NRGBA converts the colour to an opaque image colour
+func (c RGB) NRGBA() color.NRGBA {
+	return color.NRGBA{R: uint8(c.R * 255), G: uint8(c.G * 255), B: uint8(c.B * 255), A: 255}
+}
+
+// Canvas collects vector drawing operators for a single PDF page.
+// Text is limited to the standard 14 PDF fonts, which need no embedding.
+type Canvas struct {
+	width, height float64
+	content       bytes.Buffer
+	fonts         map[string]string  // base font name -> resource name
+	opacities     map[float64]string // alpha -> ExtGState resource name
+}
+
+// New returns an empty canvas for a page of the given size in points
+func New(width, height float64) *Canvas {
+	return &Canvas{
+		width:     width,
+		height:    height,
+		fonts:     map[string]string{},
+		opacities: map[float64]string{},
+	}
+}
+
+// Width and Height return the page size
+func (c *Canvas) Width() float64  { return c.width }
+func (c *Canvas) Height() float64 { return c.height }
+
+// Content returns the drawing operators
+func (c *Canvas) Content() []byte { return c.content.Bytes() }
+
+// Fonts returns the resource names of the fonts used, by base font name
+func (c *Canvas) Fonts() map[string]string { return c.fonts }
+
+// Opacities returns the resource names of the graphics states used, by alpha
+func (c *Canvas) Opacities() map[float64]string { return c.opacities }
+
+// Op appends a content stream operator
+func (c *Canvas) Op(format string, args ...interface{}) {
+	fmt.Fprintf(&c.content, format, args...)
+	c.content.WriteByte('\n')
+}
+
+func (c *Canvas) Save()    { c.Op("q") }
+func (c *Canvas) Restore() { c.Op("Q") }
+
+func (c *Canvas) SetFillColor(col RGB) {
+	c.Op("%.4f %.4f %.4f rg", col.R, col.G, col.B)
+}
+
+func (c *Canvas) SetStrokeColor(col RGB) {
+	c.Op("%.4f %.4f %.4f RG", col.R, col.G, col.B)
+}
+
+func (c *Canvas) SetLineWidth(w float64) {
+	c.Op("%.4f w", w)
+}
+
+// SetOpacity applies a constant fill and stroke alpha until the next restore
+func (c *Canvas) SetOpacity(alpha float64) {
+	name, ok := c.opacities[alpha]
+	if !ok {
+		name = fmt.Sprintf("GS%d", len(c.opacities)+1)
+		c.opacities[alpha] = name
+	}
+	c.Op("/%s gs", name)
+}
+
+func (c *Canvas) Translate(dx, dy float64) {
+	c.Op("1 0 0 1 %.4f %.4f cm", dx, dy)
+}
+
+// RoundedRect adds a rectangle path with corner radius r
+func (c *Canvas) RoundedRect(x, y, w, h, r float64) {
+	r = math.Max(0, math.Min(r, math.Min(w, h)/2))
+	if r == 0 {
+		c.Op("%.4f %.4f %.4f %.4f re", x, y, w, h)
+		return
+	}
+	// Bezier approximation of a quarter circle
+	k := r * 0.5523
+	c.Op("%.4f %.4f m", x+r, y)
+	c.Op("%.4f %.4f l", x+w-r, y)
+	c.Op("%.4f %.4f %.4f %.4f %.4f %.4f c", x+w-r+k, y, x+w, y+r-k, x+w, y+r)
+	c.Op("%.4f %.4f l", x+w, y+h-r)
+	c.Op("%.4f %.4f %.4f %.4f %.4f %.4f c", x+w, y+h-r+k, x+w-r+k, y+h, x+w-r, y+h)
+	c.Op("%.4f %.4f l", x+r, y+h)
+	c.Op("%.4f %.4f %.4f %.4f %.4f %.4f c", x+r-k, y+h, x, y+h-r+k, x, y+h-r)
+	c.Op("%.4f %.4f l", x, y+r)
+	c.Op("%.4f %.4f %.4f %.4f %.4f %.4f c", x, y+r-k, x+r-k, y, x+r, y)
+	c.Op("h")
+}
+
+func (c *Canvas) Fill()       { c.Op("f") }
+func (c *Canvas) Stroke()     { c.Op("S") }
+func (c *Canvas) FillStroke() { c.Op("B") }
+
+// Text render modes
+const (
+	TextFill       = 0
+	TextStroke     = 1
+	TextFillStroke = 2
+)
+
+// Text draws a single line with its baseline starting at x, y
+func (c *Canvas) Text(x, y float64, fontName string, size float64, s string, mode int) {
+	name, ok := c.fonts[fontName]
+	if !ok {
+		name = fmt.Sprintf("F%d", len(c.fonts)+1)
+		c.fonts[fontName] = name
+	}
+	c.Op("BT /%s %.4f Tf %d Tr %.4f %.4f Td (%s) Tj ET", name, size, mode, x, y, escapePDFString(winAnsi(s)))
+}
+
+// winAnsi converts text to the single byte encoding used by the standard fonts. Letters
+// it cannot encode lose the accents that are not in the encoding, so Vietnamese "ế"
+// becomes "ê" rather than "?".
+func winAnsi(s string) string {
+	var b strings.Builder
+	enc := charmap.Windows1252
+	for _, r := range s {
+		if c, ok := enc.EncodeRune(r); ok {
+			b.WriteByte(c)
+		} else if c, ok := winAnsiFallback(r); ok {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// winAnsiFallback encodes the base letter of r with as many of its accents as the
+// encoding has
+func winAnsiFallback(r rune) (byte, bool) {
+	switch r {
+	case 'đ':
+		return 'd', true
+	case 'Đ':
+		return 'D', true
+	}
+	d := []rune(norm.NFD.String(string(r)))
+	for n := len(d) - 1; n > 0; n-- {
+		composed := []rune(norm.NFC.String(string(d[:n])))
+		if len(composed) != 1 {
+			continue
+		}
+		if c, ok := charmap.Windows1252.EncodeRune(composed[0]); ok {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+func escapePDFString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// CoreTextWidth returns the width of s in points for a standard font at the given size
+func CoreTextWidth(s, fontName string, size float64) float64 {
+	return font.TextWidth(winAnsi(s), fontName, 1000) * size / 1000
+}
+
+// CoreAscent and CoreDescent return the font extents above and below the baseline in points
+func CoreAscent(fontName string, size float64) float64 {
+	return font.Ascent(fontName, 1000) * size / 1000
+}
+
+func CoreDescent(fontName string, size float64) float64 {
+	return font.Descent(fontName, 1000) * size / 1000
+}
+
+// Render serialises the canvases as a PDF document, one page per canvas
+func Render(pages ...*Canvas) []byte {
+	var buf bytes.Buffer
+	var offsets []int
+
+	addObj := func(body string) int {
+		offsets = append(offsets, buf.Len())
+		n := len(offsets)
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", n, body)
+		return n
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+
+	// Object numbers: 1 catalog, 2 page tree, then pages and their resources
+	offsets = append(offsets, 0, 0)
+	var kids []string
+	for _, p := range pages {
+		var res strings.Builder
+		res.WriteString("<< /ProcSet [/PDF /Text]")
+
+		if len(p.fonts) > 0 {
+			res.WriteString(" /Font <<")
+			for _, base := range sortedKeys(p.fonts) {
+				fontObj := addObj(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", base))
+				fmt.Fprintf(&res, " /%s %d 0 R", p.fonts[base], fontObj)
+			}
+			res.WriteString(" >>")
+		}
+		if len(p.opacities) > 0 {
+			res.WriteString(" /ExtGState <<")
+			alphas := make([]float64, 0, len(p.opacities))
+			for a := range p.opacities {
+				alphas = append(alphas, a)
+			}
+			sort.Float64s(alphas)
+			for _, a := range alphas {
+				gsObj := addObj(fmt.Sprintf("<< /Type /ExtGState /ca %.4f /CA %.4f >>", a, a))
+				fmt.Fprintf(&res, " /%s %d 0 R", p.opacities[a], gsObj)
+			}
+			res.WriteString(" >>")
+		}
+		res.WriteString(" >>")
+
+		content := p.content.Bytes()
+		contentObj := addObj(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+		pageObj := addObj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.4f %.4f] /Resources %s /Contents %d 0 R >>",
+			p.width, p.height, res.String(), contentObj))
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageObj))
+	}
+
+	// Catalog and page tree are written last but keep their reserved numbers
+	offsets[0] = buf.Len()
+	buf.WriteString("1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	offsets[1] = buf.Len()
+	fmt.Fprintf(&buf, "2 0 obj\n<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(kids, " "), len(pages))
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.Bytes()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,1054 @@
+package pdfcanvas
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// maxSVGDepth stops <use> elements that refer to themselves
+const maxSVGDepth = 64
+
+// xlinkNamespace is where SVG 1.1 puts href
+const xlinkNamespace = "http://www.w3.org/1999/xlink"
+
+// SVG is an SVG drawing parsed for a vector stamp, so a logo stays sharp at any
+// print resolution. CapGo draws the shapes itself: paths, basic shapes, groups, <use>,
+// transforms, solid colours, opacity and clip paths, styled by attributes, style
+// attributes and simple CSS rules. Text, embedded images, gradients, patterns, masks
+// and filters are refused rather than left out.
+type SVG struct {
+	root          svgNode
+	namespace     string  // of the SVG elements
+	minX, minY    float64 // of the view box
+	width, height float64
+	rules         map[string]string // CSS declarations by ".class" or element name
+	ids           map[string]svgNode
+}
+
+type svgNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []svgNode  `xml:",any"`
+	Text     string     `xml:",chardata"`
+}
+
+// attr returns an SVG attribute of the node, or ""
+func (n svgNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name && (a.Name.Space == "" || a.Name.Space == xlinkNamespace || a.Name.Space == "xlink") {
+			return strings.TrimSpace(a.Value)
+		}
+	}
+	return ""
+}
+
+// IsSVG reports whether data looks like an SVG document
+func IsSVG(data []byte) bool {
+	head := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	return bytes.HasPrefix(head, []byte("<")) && bytes.Contains(head, []byte("<svg"))
+}
+
+// ParseSVG reads an SVG document and its size
+func ParseSVG(data []byte) (*SVG, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	// Illustrator declares its namespaces as entities in the DOCTYPE
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	d.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(label) {
+		case "iso-8859-1", "latin1":
+			return charmap.ISO8859_1.NewDecoder().Reader(input), nil
+		case "windows-1252":
+			return charmap.Windows1252.NewDecoder().Reader(input), nil
+		}
+		return nil, fmt.Errorf("unsupported encoding %s", label)
+	}
+	var root svgNode
+	if err := d.Decode(&root); err != nil {
+		return nil, fmt.Errorf("invalid SVG: %v", err)
+	}
+	if root.XMLName.Local != "svg" {
+		return nil, fmt.Errorf("invalid SVG: the root element is <%s>", root.XMLName.Local)
+	}
+
+	img := &SVG{root: root, namespace: root.XMLName.Space, rules: map[string]string{}, ids: map[string]svgNode{}}
+	if vb := root.attr("viewBox"); vb != "" {
+		nums, err := svgNumbers(vb)
+		if err != nil || len(nums) != 4 || nums[2] <= 0 || nums[3] <= 0 {
+			return nil, fmt.Errorf("invalid SVG view box %q", vb)
+		}
+		img.minX, img.minY, img.width, img.height = nums[0], nums[1], nums[2], nums[3]
+	} else {
+		w, errW := svgLength(root.attr("width"))
+		h, errH := svgLength(root.attr("height"))
+		if errW != nil || errH != nil || w <= 0 || h <= 0 {
+			return nil, fmt.Errorf("SVG has neither a view box nor a width and height")
+		}
+		img.width, img.height = w, h
+	}
+	img.index(root)
+	return img, nil
+}
+
+// Size returns the size of the view box
+func (img *SVG) Size() (width, height float64) {
+	return img.width, img.height
+}
+
+// index collects the CSS rules and the elements with an id
+func (img *SVG) index(n svgNode) {
+	if id := n.attr("id"); id != "" {
+		img.ids[id] = n
+	}
+	if n.XMLName.Local == "style" {
+		img.parseCSS(n.Text)
+	}
+	for _, child := range n.Children {
+		img.index(child)
+	}
+}
+
+// parseCSS keeps the rules for class and element selectors, the ones SVG editors write
+func (img *SVG) parseCSS(css string) {
+	for {
+		start := strings.Index(css, "/*")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(css[start:], "*/")
+		if end < 0 {
+			css = css[:start]
+			break
+		}
+		css = css[:start] + css[start+end+2:]
+	}
+	for _, rule := range strings.Split(css, "}") {
+		selectors, decls, ok := strings.Cut(rule, "{")
+		if !ok {
+			continue
+		}
+		for _, sel := range strings.Split(selectors, ",") {
+			sel = strings.TrimSpace(sel)
+			if sel != "" && !strings.ContainsAny(sel, " >+~:[#*") && strings.Count(sel, ".") <= 1 {
+				img.rules[sel] += decls + ";"
+			}
+		}
+	}
+}
+
+// svgStyleProperties are the presentation attributes that are drawn
+var svgStyleProperties = []string{
+	"color", "fill", "fill-opacity", "fill-rule", "stroke", "stroke-width", "stroke-opacity",
+	"stroke-linecap", "stroke-linejoin", "stroke-miterlimit", "opacity", "display",
+	"visibility", "clip-path", "clip-rule", "mask", "filter",
+}
+
+// properties returns the style of n: presentation attributes, overridden by CSS rules,
+// overridden by the style attribute
+func (img *SVG) properties(n svgNode) map[string]string {
+	props := map[string]string{}
+	for _, name := range svgStyleProperties {
+		if v := n.attr(name); v != "" {
+			props[name] = v
+		}
+	}
+	setDeclarations(props, img.rules[n.XMLName.Local])
+	for _, class := range strings.Fields(n.attr("class")) {
+		setDeclarations(props, img.rules["."+class])
+	}
+	setDeclarations(props, n.attr("style"))
+	return props
+}
+
+func setDeclarations(props map[string]string, decls string) {
+	for _, decl := range strings.Split(decls, ";") {
+		name, value, ok := strings.Cut(decl, ":")
+		if ok {
+			value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important"))
+			props[strings.ToLower(strings.TrimSpace(name))] = value
+		}
+	}
+}
+
+// svgStyle is the inherited paint state while drawing
+type svgStyle struct {
+	color                RGB
+	fill, stroke         *RGB // nil for none
+	fillOpacity          float64
+	strokeOpacity        float64
+	opacity              float64
+	strokeWidth          float64
+	evenOdd, clipEvenOdd bool
+	lineCap, lineJoin    int
+	miterLimit           float64
+	hidden               bool
+}
+
+func defaultSVGStyle() svgStyle {
+	return svgStyle{fill: &RGB{}, fillOpacity: 1, strokeOpacity: 1, opacity: 1, strokeWidth: 1, miterLimit: 4}
+}
+
+// with returns the style of an element with the given properties inside st. Group
+// opacity is passed on to the shapes, which only differs where they overlap.
+func (st svgStyle) with(props map[string]string) (svgStyle, error) {
+	if v, ok := props["color"]; ok && v != "inherit" && v != "currentColor" {
+		col, err := svgColor(v)
+		if err != nil {
+			return st, err
+		}
+		st.color = col
+	}
+	for _, p := range []struct {
+		name  string
+		paint **RGB
+	}{{"fill", &st.fill}, {"stroke", &st.stroke}} {
+		v, ok := props[p.name]
+		if !ok || v == "inherit" {
+			continue
+		}
+		switch {
+		case v == "none" || v == "transparent":
+			*p.paint = nil
+		case v == "currentColor":
+			col := st.color
+			*p.paint = &col
+		case strings.HasPrefix(v, "url("):
+			return st, fmt.Errorf("SVG gradients and patterns are not supported, use solid colors")
+		default:
+			col, err := svgColor(v)
+			if err != nil {
+				return st, err
+			}
+			*p.paint = &col
+		}
+	}
+	for _, p := range []struct {
+		name  string
+		value *float64
+	}{{"fill-opacity", &st.fillOpacity}, {"stroke-opacity", &st.strokeOpacity}, {"opacity", nil}} {
+		v, ok := props[p.name]
+		if !ok || v == "inherit" {
+			continue
+		}
+		f, err := svgOpacity(v)
+		if err != nil {
+			return st, err
+		}
+		if p.value == nil {
+			st.opacity *= f
+		} else {
+			*p.value = f
+		}
+	}
+	if v, ok := props["stroke-width"]; ok && v != "inherit" {
+		w, err := svgLength(v)
+		if err != nil {
+			return st, fmt.Errorf("invalid SVG stroke width %q", v)
+		}
+		st.strokeWidth = w
+	}
+	if v, ok := props["stroke-miterlimit"]; ok && v != "inherit" {
+		m, err := strconv.ParseFloat(v, 64)
+		if err != nil || m < 1 {
+			return st, fmt.Errorf("invalid SVG miter limit %q", v)
+		}
+		st.miterLimit = m
+	}
+	if v, ok := props["fill-rule"]; ok && v != "inherit" {
+		st.evenOdd = v == "evenodd"
+	}
+	if v, ok := props["clip-rule"]; ok && v != "inherit" {
+		st.clipEvenOdd = v == "evenodd"
+	}
+	if v, ok := props["stroke-linecap"]; ok {
+		st.lineCap = map[string]int{"butt": 0, "round": 1, "square": 2}[v]
+	}
+	if v, ok := props["stroke-linejoin"]; ok {
+		st.lineJoin = map[string]int{"miter": 0, "round": 1, "bevel": 2}[v]
+	}
+	if v, ok := props["visibility"]; ok && v != "inherit" {
+		st.hidden = v == "hidden" || v == "collapse"
+	}
+	return st, nil
+}
+
+// svgNamedColors are the colour keywords SVG editors commonly write
+var svgNamedColors = map[string]string{
+	"black": "#000000", "white": "#ffffff", "red": "#ff0000", "lime": "#00ff00",
+	"green": "#008000", "blue": "#0000ff", "yellow": "#ffff00", "cyan": "#00ffff",
+	"aqua": "#00ffff", "magenta": "#ff00ff", "fuchsia": "#ff00ff", "gray": "#808080",
+	"grey": "#808080", "silver": "#c0c0c0", "maroon": "#800000", "olive": "#808000",
+	"navy": "#000080", "purple": "#800080", "teal": "#008080", "orange": "#ffa500",
+}
+
+// svgColor parses "#RGB", "#RRGGBB", "RGB(r, g, b)" with numbers or percentages, and
+// the common colour keywords
+func svgColor(s string) (RGB, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if hex, ok := svgNamedColors[s]; ok {
+		s = hex
+	}
+	if strings.HasPrefix(s, "#") {
+		return ParseHexColor(s)
+	}
+	if args, ok := strings.CutPrefix(s, "RGB("); ok && strings.HasSuffix(args, ")") {
+		parts := strings.Split(strings.TrimSuffix(args, ")"), ",")
+		if len(parts) == 3 {
+			var c [3]float64
+			for i, p := range parts {
+				p = strings.TrimSpace(p)
+				scale := 255.0
+				if v, ok := strings.CutSuffix(p, "%"); ok {
+					p, scale = v, 100
+				}
+				v, err := strconv.ParseFloat(p, 64)
+				if err != nil {
+					return RGB{}, fmt.Errorf("invalid color: %q", s)
+				}
+				c[i] = math.Max(0, math.Min(1, v/scale))
+			}
+			return RGB{R: c[0], G: c[1], B: c[2]}, nil
+		}
+	}
+	return RGB{}, fmt.Errorf("invalid color: %q", s)
+}
+
+// svgOpacity parses an opacity as a number or a percentage, clamped to 0..1
+func svgOpacity(s string) (float64, error) {
+	scale := 1.0
+	if v, ok := strings.CutSuffix(s, "%"); ok {
+		s, scale = v, 100
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid SVG opacity %q", s)
+	}
+	return math.Max(0, math.Min(1, f/scale)), nil
+}
+
+// svgLengthUnits are the absolute units in CSS pixels, the SVG user unit
+var svgLengthUnits = map[string]float64{
+	"": 1, "px": 1, "pt": 4.0 / 3, "pc": 16, "mm": 96 / 25.4, "cm": 96 / 2.54, "in": 96,
+}
+
+// svgLength parses a length in user units; percentages are not supported
+func svgLength(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	i := len(s)
+	for i > 0 && s[i-1] >= 'a' && s[i-1] <= 'z' {
+		i--
+	}
+	unit, ok := svgLengthUnits[s[i:]]
+	if !ok {
+		return 0, fmt.Errorf("unsupported unit %q", s[i:])
+	}
+	v, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, err
+	}
+	return v * unit, nil
+}
+
+// svgNumbers parses a list of numbers separated by spaces or commas
+func svgNumbers(s string) ([]float64, error) {
+	sc := pathScanner{s: s}
+	var nums []float64
+	for sc.more() {
+		v, err := sc.number()
+		if err != nil {
+			return nil, err
+		}
+		nums = append(nums, v)
+	}
+	if sc.skip(); sc.i < len(sc.s) {
+		return nil, fmt.Errorf("unexpected %q", sc.s[sc.i:])
+	}
+	return nums, nil
+}
+
+// svgMatrix is an affine transform [a b c d e f], the same as a PDF "cm" operand
+type svgMatrix [6]float64
+
+var svgIdentity = svgMatrix{1, 0, 0, 1, 0, 0}
+
+// then returns the transform that applies n first and m after it
+func (m svgMatrix) then(n svgMatrix) svgMatrix {
+	return svgMatrix{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+func (m svgMatrix) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// parseTransform parses a transform list such as "translate(10 20) rotate(45)"
+func parseTransform(s string) (svgMatrix, error) {
+	m := svgIdentity
+	rest := strings.TrimSpace(s)
+	for rest != "" {
+		open := strings.Index(rest, "(")
+		end := strings.Index(rest, ")")
+		if open < 0 || end < open {
+			return m, fmt.Errorf("invalid SVG transform %q", s)
+		}
+		name := strings.TrimSpace(strings.Trim(rest[:open], ", \t\r\n"))
+		args, err := svgNumbers(rest[open+1 : end])
+		if err != nil {
+			return m, fmt.Errorf("invalid SVG transform %q", s)
+		}
+		rest = strings.TrimLeft(rest[end+1:], ", \t\r\n")
+
+		arg := func(i int, def float64) float64 {
+			if i < len(args) {
+				return args[i]
+			}
+			return def
+		}
+		var t svgMatrix
+		switch {
+		case name == "matrix" && len(args) == 6:
+			copy(t[:], args)
+		case name == "translate" && len(args) >= 1:
+			t = svgMatrix{1, 0, 0, 1, args[0], arg(1, 0)}
+		case name == "scale" && len(args) >= 1:
+			t = svgMatrix{args[0], 0, 0, arg(1, args[0]), 0, 0}
+		case name == "rotate" && len(args) >= 1:
+			a := args[0] * math.Pi / 180
+			cx, cy := arg(1, 0), arg(2, 0)
+			t = svgMatrix{1, 0, 0, 1, cx, cy}.
+				then(svgMatrix{math.Cos(a), math.Sin(a), -math.Sin(a), math.Cos(a), 0, 0}).
+				then(svgMatrix{1, 0, 0, 1, -cx, -cy})
+		case name == "skewX" && len(args) == 1:
+			t = svgMatrix{1, 0, math.Tan(args[0] * math.Pi / 180), 1, 0, 0}
+		case name == "skewY" && len(args) == 1:
+			t = svgMatrix{1, math.Tan(args[0] * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			return m, fmt.Errorf("invalid SVG transform %q", s)
+		}
+		m = m.then(t)
+	}
+	return m, nil
+}
+
+// pathSegment is a path command in absolute coordinates: 'M', 'L' and 'C' with their
+// points, or 'Z'
+type pathSegment struct {
+	op  byte
+	pts []float64
+}
+
+type svgPath []pathSegment
+
+// emit adds the path to the canvas
+func (p svgPath) emit(c *Canvas) {
+	for _, s := range p {
+		switch s.op {
+		case 'M':
+			c.Op("%.4f %.4f m", s.pts[0], s.pts[1])
+		case 'L':
+			c.Op("%.4f %.4f l", s.pts[0], s.pts[1])
+		case 'C':
+			c.Op("%.4f %.4f %.4f %.4f %.4f %.4f c", s.pts[0], s.pts[1], s.pts[2], s.pts[3], s.pts[4], s.pts[5])
+		case 'Z':
+			c.Op("h")
+		}
+	}
+}
+
+// transformed returns the path with every point moved by m
+func (p svgPath) transformed(m svgMatrix) svgPath {
+	out := make(svgPath, len(p))
+	for i, s := range p {
+		pts := make([]float64, len(s.pts))
+		for j := 0; j+1 < len(s.pts); j += 2 {
+			pts[j], pts[j+1] = m.apply(s.pts[j], s.pts[j+1])
+		}
+		out[i] = pathSegment{op: s.op, pts: pts}
+	}
+	return out
+}
+
+// pathScanner reads the numbers and commands of path data
+type pathScanner struct {
+	s string
+	i int
+}
+
+func (sc *pathScanner) skip() {
+	for sc.i < len(sc.s) && strings.IndexByte(" \t\r\n,", sc.s[sc.i]) >= 0 {
+		sc.i++
+	}
+}
+
+func isPathCommand(c byte) bool {
+	return (c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') && c != 'e' && c != 'E'
+}
+
+// more reports whether a number follows
+func (sc *pathScanner) more() bool {
+	sc.skip()
+	return sc.i < len(sc.s) && !isPathCommand(sc.s[sc.i])
+}
+
+func (sc *pathScanner) number() (float64, error) {
+	sc.skip()
+	start := sc.i
+	if sc.i < len(sc.s) && (sc.s[sc.i] == '+' || sc.s[sc.i] == '-') {
+		sc.i++
+	}
+	digits, dot := false, false
+	for ; sc.i < len(sc.s); sc.i++ {
+		c := sc.s[sc.i]
+		if c >= '0' && c <= '9' {
+			digits = true
+		} else if c == '.' && !dot {
+			dot = true
+		} else {
+			break
+		}
+	}
+	// An exponent only counts when digits follow, "1e" is a number and a command
+	if digits && sc.i < len(sc.s) && (sc.s[sc.i] == 'e' || sc.s[sc.i] == 'E') {
+		j := sc.i + 1
+		if j < len(sc.s) && (sc.s[j] == '+' || sc.s[j] == '-') {
+			j++
+		}
+		if j < len(sc.s) && sc.s[j] >= '0' && sc.s[j] <= '9' {
+			for j < len(sc.s) && sc.s[j] >= '0' && sc.s[j] <= '9' {
+				j++
+			}
+			sc.i = j
+		}
+	}
+	if !digits {
+		return 0, fmt.Errorf("expected a number at %q", sc.s[start:])
+	}
+	return strconv.ParseFloat(sc.s[start:sc.i], 64)
+}
+
+// flag reads an arc flag, which may be written without a separator
+func (sc *pathScanner) flag() (bool, error) {
+	sc.skip()
+	if sc.i < len(sc.s) && (sc.s[sc.i] == '0' || sc.s[sc.i] == '1') {
+		sc.i++
+		return sc.s[sc.i-1] == '1', nil
+	}
+	return false, fmt.Errorf("expected an arc flag at %q", sc.s[sc.i:])
+}
+
+// parsePathData converts SVG path data to absolute moves, lines and cubic curves
+func parsePathData(d string) (svgPath, error) {
+	sc := pathScanner{s: d}
+	var path svgPath
+	var x, y, startX, startY float64
+	var ctrlX, ctrlY float64 // last control point, for S and T
+	var prev byte
+	for {
+		sc.skip()
+		if sc.i >= len(sc.s) {
+			break
+		}
+		cmd := sc.s[sc.i]
+		if !isPathCommand(cmd) {
+			return nil, fmt.Errorf("invalid SVG path data at %q", sc.s[sc.i:])
+		}
+		sc.i++
+		if len(path) == 0 && cmd != 'M' && cmd != 'm' {
+			return nil, fmt.Errorf("SVG path data must start with a move")
+		}
+		rel := cmd >= 'a'
+		upper := cmd &^ 0x20
+		nums := func(n int) ([]float64, error) {
+			v := make([]float64, n)
+			for i := range v {
+				var err error
+				if v[i], err = sc.number(); err != nil {
+					return nil, err
+				}
+			}
+			return v, nil
+		}
+		first := true
+		for first || sc.more() {
+			var err error
+			var v []float64
+			ox, oy := 0.0, 0.0
+			if rel {
+				ox, oy = x, y
+			}
+			switch upper {
+			case 'Z':
+				path = append(path, pathSegment{op: 'Z'})
+				x, y = startX, startY
+			case 'M', 'L', 'T':
+				if v, err = nums(2); err != nil {
+					return nil, err
+				}
+				nx, ny := v[0]+ox, v[1]+oy
+				switch {
+				case upper == 'M' && first:
+					path = append(path, pathSegment{op: 'M', pts: []float64{nx, ny}})
+					startX, startY = nx, ny
+				case upper == 'T':
+					qx, qy := x, y
+					if prev == 'Q' || prev == 'T' {
+						qx, qy = 2*x-ctrlX, 2*y-ctrlY
+					}
+					path = append(path, quadSegment(x, y, qx, qy, nx, ny))
+					ctrlX, ctrlY = qx, qy
+				default:
+					path = append(path, pathSegment{op: 'L', pts: []float64{nx, ny}})
+				}
+				x, y = nx, ny
+			case 'H', 'V':
+				if v, err = nums(1); err != nil {
+					return nil, err
+				}
+				if upper == 'H' {
+					x = v[0] + ox
+				} else {
+					y = v[0] + oy
+				}
+				path = append(path, pathSegment{op: 'L', pts: []float64{x, y}})
+			case 'C', 'S':
+				n := 6
+				if upper == 'S' {
+					n = 4
+				}
+				if v, err = nums(n); err != nil {
+					return nil, err
+				}
+				var c1x, c1y float64
+				if upper == 'C' {
+					c1x, c1y, v = v[0]+ox, v[1]+oy, v[2:]
+				} else if prev == 'C' || prev == 'S' {
+					c1x, c1y = 2*x-ctrlX, 2*y-ctrlY
+				} else {
+					c1x, c1y = x, y
+				}
+				c2x, c2y, nx, ny := v[0]+ox, v[1]+oy, v[2]+ox, v[3]+oy
+				path = append(path, pathSegment{op: 'C', pts: []float64{c1x, c1y, c2x, c2y, nx, ny}})
+				ctrlX, ctrlY, x, y = c2x, c2y, nx, ny
+			case 'Q':
+				if v, err = nums(4); err != nil {
+					return nil, err
+				}
+				qx, qy, nx, ny := v[0]+ox, v[1]+oy, v[2]+ox, v[3]+oy
+				path = append(path, quadSegment(x, y, qx, qy, nx, ny))
+				ctrlX, ctrlY, x, y = qx, qy, nx, ny
+			case 'A':
+				if v, err = nums(3); err != nil {
+					return nil, err
+				}
+				large, err := sc.flag()
+				if err != nil {
+					return nil, err
+				}
+				sweep, err := sc.flag()
+				if err != nil {
+					return nil, err
+				}
+				end, err := nums(2)
+				if err != nil {
+					return nil, err
+				}
+				nx, ny := end[0]+ox, end[1]+oy
+				path = append(path, arcSegments(x, y, v[0], v[1], v[2], large, sweep, nx, ny)...)
+				x, y = nx, ny
+			default:
+				return nil, fmt.Errorf("invalid SVG path command %q", cmd)
+			}
+			prev = upper
+			if upper == 'M' {
+				// Further pairs after a move are lines
+				upper, prev = 'L', 'L'
+			}
+			first = false
+			if upper == 'Z' {
+				break
+			}
+		}
+	}
+	return path, nil
+}
+
+// quadSegment converts a quadratic curve to a cubic one
+func quadSegment(x0, y0, qx, qy, x, y float64) pathSegment {
+	return pathSegment{op: 'C', pts: []float64{
+		x0 + 2.0/3*(qx-x0), y0 + 2.0/3*(qy-y0),
+		x + 2.0/3*(qx-x), y + 2.0/3*(qy-y),
+		x, y,
+	}}
+}
+
+// arcSegments converts an elliptical arc to cubic curves of at most a quarter turn,
+// following the endpoint to center conversion of the SVG specification
+func arcSegments(x1, y1, rx, ry, angle float64, large, sweep bool, x2, y2 float64) []pathSegment {
+	if x1 == x2 && y1 == y2 {
+		return nil
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		return []pathSegment{{op: 'L', pts: []float64{x2, y2}}}
+	}
+	phi := angle * math.Pi / 180
+	cos, sin := math.Cos(phi), math.Sin(phi)
+	dx, dy := (x1-x2)/2, (y1-y2)/2
+	x1p, y1p := cos*dx+sin*dy, -sin*dx+cos*dy
+
+	// Radii too small to reach the end point are scaled up
+	if l := x1p*x1p/(rx*rx) + y1p*y1p/(ry*ry); l > 1 {
+		rx, ry = rx*math.Sqrt(l), ry*math.Sqrt(l)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1p*y1p - ry*ry*x1p*x1p
+	den := rx*rx*y1p*y1p + ry*ry*x1p*x1p
+	coef := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		coef = -coef
+	}
+	cxp, cyp := coef*rx*y1p/ry, -coef*ry*x1p/rx
+	cx, cy := cos*cxp-sin*cyp+(x1+x2)/2, sin*cxp+cos*cyp+(y1+y2)/2
+
+	vecAngle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := vecAngle(1, 0, (x1p-cxp)/rx, (y1p-cyp)/ry)
+	delta := vecAngle((x1p-cxp)/rx, (y1p-cyp)/ry, (-x1p-cxp)/rx, (-y1p-cyp)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	n := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	step := delta / float64(n)
+	k := 4.0 / 3 * math.Tan(step/4)
+	point := func(ux, uy float64) (float64, float64) {
+		return cx + rx*ux*cos - ry*uy*sin, cy + rx*ux*sin + ry*uy*cos
+	}
+	segs := make([]pathSegment, 0, n)
+	for i := 0; i < n; i++ {
+		t1 := theta + float64(i)*step
+		t2 := t1 + step
+		c1x, c1y := point(math.Cos(t1)-k*math.Sin(t1), math.Sin(t1)+k*math.Cos(t1))
+		c2x, c2y := point(math.Cos(t2)+k*math.Sin(t2), math.Sin(t2)-k*math.Cos(t2))
+		ex, ey := point(math.Cos(t2), math.Sin(t2))
+		segs = append(segs, pathSegment{op: 'C', pts: []float64{c1x, c1y, c2x, c2y, ex, ey}})
+	}
+	// End exactly on the end point
+	segs[n-1].pts[4], segs[n-1].pts[5] = x2, y2
+	return segs
+}
+
+// ellipseOutline returns an ellipse as four cubic curves
+func ellipseOutline(cx, cy, rx, ry float64) svgPath {
+	k := 0.5523
+	return svgPath{
+		{op: 'M', pts: []float64{cx + rx, cy}},
+		{op: 'C', pts: []float64{cx + rx, cy + k*ry, cx + k*rx, cy + ry, cx, cy + ry}},
+		{op: 'C', pts: []float64{cx - k*rx, cy + ry, cx - rx, cy + k*ry, cx - rx, cy}},
+		{op: 'C', pts: []float64{cx - rx, cy - k*ry, cx - k*rx, cy - ry, cx, cy - ry}},
+		{op: 'C', pts: []float64{cx + k*rx, cy - ry, cx + rx, cy - k*ry, cx + rx, cy}},
+		{op: 'Z'},
+	}
+}
+
+// rectPath returns a rectangle with corners rounded by rx and ry
+func rectPath(x, y, w, h, rx, ry float64) svgPath {
+	if rx <= 0 || ry <= 0 {
+		return svgPath{
+			{op: 'M', pts: []float64{x, y}},
+			{op: 'L', pts: []float64{x + w, y}},
+			{op: 'L', pts: []float64{x + w, y + h}},
+			{op: 'L', pts: []float64{x, y + h}},
+			{op: 'Z'},
+		}
+	}
+	rx, ry = math.Min(rx, w/2), math.Min(ry, h/2)
+	kx, ky := 0.5523*rx, 0.5523*ry
+	return svgPath{
+		{op: 'M', pts: []float64{x + rx, y}},
+		{op: 'L', pts: []float64{x + w - rx, y}},
+		{op: 'C', pts: []float64{x + w - rx + kx, y, x + w, y + ry - ky, x + w, y + ry}},
+		{op: 'L', pts: []float64{x + w, y + h - ry}},
+		{op: 'C', pts: []float64{x + w, y + h - ry + ky, x + w - rx + kx, y + h, x + w - rx, y + h}},
+		{op: 'L', pts: []float64{x + rx, y + h}},
+		{op: 'C', pts: []float64{x + rx - kx, y + h, x, y + h - ry + ky, x, y + h - ry}},
+		{op: 'L', pts: []float64{x, y + ry}},
+		{op: 'C', pts: []float64{x, y + ry - ky, x + rx - kx, y, x + rx, y}},
+		{op: 'Z'},
+	}
+}
+
+// shapePath returns the outline of a shape element, or nil for other elements
+func shapePath(n svgNode) (svgPath, error) {
+	num := func(name string) (float64, error) {
+		v := n.attr(name)
+		if v == "" {
+			return 0, nil
+		}
+		f, err := svgLength(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid SVG %s %s %q", n.XMLName.Local, name, v)
+		}
+		return f, nil
+	}
+	nums := func(names ...string) ([]float64, error) {
+		v := make([]float64, len(names))
+		for i, name := range names {
+			var err error
+			if v[i], err = num(name); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	}
+	switch n.XMLName.Local {
+	case "path":
+		p, err := parsePathData(n.attr("d"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SVG path data: %v", err)
+		}
+		return p, nil
+	case "rect":
+		v, err := nums("x", "y", "width", "height", "rx", "ry")
+		if err != nil {
+			return nil, err
+		}
+		rx, ry := v[4], v[5]
+		if n.attr("rx") == "" {
+			rx = ry
+		} else if n.attr("ry") == "" {
+			ry = rx
+		}
+		if v[2] <= 0 || v[3] <= 0 {
+			return svgPath{}, nil
+		}
+		return rectPath(v[0], v[1], v[2], v[3], rx, ry), nil
+	case "circle", "ellipse":
+		v, err := nums("cx", "cy", "r", "rx", "ry")
+		if err != nil {
+			return nil, err
+		}
+		rx, ry := v[3], v[4]
+		if n.XMLName.Local == "circle" {
+			rx, ry = v[2], v[2]
+		}
+		if rx <= 0 || ry <= 0 {
+			return svgPath{}, nil
+		}
+		return ellipseOutline(v[0], v[1], rx, ry), nil
+	case "line":
+		v, err := nums("x1", "y1", "x2", "y2")
+		if err != nil {
+			return nil, err
+		}
+		return svgPath{{op: 'M', pts: v[:2]}, {op: 'L', pts: v[2:]}}, nil
+	case "polyline", "polygon":
+		pts, err := svgNumbers(n.attr("points"))
+		if err != nil || len(pts)%2 != 0 {
+			return nil, fmt.Errorf("invalid SVG %s points", n.XMLName.Local)
+		}
+		var path svgPath
+		for i := 0; i+1 < len(pts); i += 2 {
+			op := byte('L')
+			if i == 0 {
+				op = 'M'
+			}
+			path = append(path, pathSegment{op: op, pts: pts[i : i+2]})
+		}
+		if n.XMLName.Local == "polygon" && len(path) > 0 {
+			path = append(path, pathSegment{op: 'Z'})
+		}
+		return path, nil
+	}
+	return nil, nil
+}
+
+// Draw adds the drawing to the canvas, scaled by scale with the top left corner of the
+// view box at the top left corner of the canvas
+func (img *SVG) Draw(c *Canvas, scale float64) error {
+	c.Save()
+	defer c.Restore()
+	// SVG measures y downwards
+	c.Op("%.6f 0 0 %.6f %.4f %.4f cm", scale, -scale, -scale*img.minX, img.height*scale+scale*img.minY)
+	return img.drawNode(c, img.root, defaultSVGStyle(), 0)
+}
+
+// svgSkipped are elements that are only drawn when referenced, or not drawn at all
+var svgSkipped = map[string]bool{
+	"defs": true, "title": true, "desc": true, "metadata": true, "style": true,
+	"clipPath": true, "symbol": true, "linearGradient": true, "radialGradient": true,
+	"pattern": true, "marker": true, "mask": true, "filter": true, "script": true,
+}
+
+func (img *SVG) drawNode(c *Canvas, n svgNode, parent svgStyle, depth int) error {
+	name := n.XMLName.Local
+	if depth > maxSVGDepth {
+		return fmt.Errorf("SVG elements are nested too deeply")
+	}
+	// Elements of editor namespaces, e.g. Inkscape's, are not part of the drawing
+	if (n.XMLName.Space != "" && n.XMLName.Space != img.namespace) || svgSkipped[name] {
+		return nil
+	}
+	switch name {
+	case "text", "image", "foreignObject":
+		return fmt.Errorf("SVG <%s> elements are not supported", name)
+	}
+	props := img.properties(n)
+	if props["display"] == "none" {
+		return nil
+	}
+	for _, p := range []string{"mask", "filter"} {
+		if v := props[p]; v != "" && v != "none" {
+			return fmt.Errorf("SVG %ss are not supported", p)
+		}
+	}
+	st, err := parent.with(props)
+	if err != nil {
+		return err
+	}
+
+	c.Save()
+	defer c.Restore()
+	if t := n.attr("transform"); t != "" {
+		m, err := parseTransform(t)
+		if err != nil {
+			return err
+		}
+		c.Op("%.6f %.6f %.6f %.6f %.4f %.4f cm", m[0], m[1], m[2], m[3], m[4], m[5])
+	}
+	if clip := props["clip-path"]; clip != "" && clip != "none" {
+		if err := img.clip(c, clip, st.clipEvenOdd); err != nil {
+			return err
+		}
+	}
+
+	switch name {
+	case "svg", "g", "a", "switch":
+		for _, child := range n.Children {
+			if err := img.drawNode(c, child, st, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "use":
+		ref, ok := img.ids[strings.TrimPrefix(n.attr("href"), "#")]
+		if !ok || !strings.HasPrefix(n.attr("href"), "#") {
+			return fmt.Errorf("SVG <use> refers to a missing element %q", n.attr("href"))
+		}
+		x, _ := svgLength(n.attr("x"))
+		y, _ := svgLength(n.attr("y"))
+		c.Translate(x, y)
+		if ref.XMLName.Local == "symbol" {
+			ref.XMLName.Local = "g"
+		}
+		return img.drawNode(c, ref, st, depth+1)
+	}
+
+	path, err := shapePath(n)
+	if err != nil || path == nil || st.hidden {
+		return err
+	}
+	if st.fill != nil {
+		c.Save()
+		setSVGOpacity(c, st.opacity*st.fillOpacity)
+		c.SetFillColor(*st.fill)
+		path.emit(c)
+		if st.evenOdd {
+			c.Op("f*")
+		} else {
+			c.Fill()
+		}
+		c.Restore()
+	}
+	if st.stroke != nil && st.strokeWidth > 0 {
+		c.Save()
+		setSVGOpacity(c, st.opacity*st.strokeOpacity)
+		c.SetStrokeColor(*st.stroke)
+		c.SetLineWidth(st.strokeWidth)
+		c.Op("%d J %d j %.4f M", st.lineCap, st.lineJoin, st.miterLimit)
+		path.emit(c)
+		c.Stroke()
+		c.Restore()
+	}
+	return nil
+}
+
+// setSVGOpacity sets the alpha of the following painting when it is not opaque
+func setSVGOpacity(c *Canvas, alpha float64) {
+	if alpha < 1 {
+		c.SetOpacity(math.Round(alpha*1000) / 1000)
+	}
+}
+
+// clip restricts the following drawing to the shapes of a <clipPath>
+func (img *SVG) clip(c *Canvas, ref string, evenOdd bool) error {
+	id := strings.Trim(strings.TrimSuffix(strings.TrimPrefix(ref, "url("), ")"), `"' `)
+	n, ok := img.ids[strings.TrimPrefix(id, "#")]
+	if !ok || !strings.HasPrefix(id, "#") || n.XMLName.Local != "clipPath" {
+		return fmt.Errorf("SVG clip path %q not found", ref)
+	}
+	if n.attr("clipPathUnits") == "objectBoundingBox" {
+		return fmt.Errorf("SVG clip paths relative to the bounding box are not supported")
+	}
+	outer, err := parseTransform(n.attr("transform"))
+	if err != nil {
+		return err
+	}
+	var area svgPath
+	for _, child := range n.Children {
+		path, err := shapePath(child)
+		if err != nil {
+			return err
+		}
+		if path == nil {
+			continue
+		}
+		m, err := parseTransform(child.attr("transform"))
+		if err != nil {
+			return err
+		}
+		area = append(area, path.transformed(outer.then(m))...)
+		if r := child.attr("clip-rule"); r != "" {
+			evenOdd = r == "evenodd"
+		}
+	}
+	if len(area) == 0 {
+		// An empty clip path hides everything
+		c.Op("0 0 0 0 re W n")
+		return nil
+	}
+	area.emit(c)
+	if evenOdd {
+		c.Op("W* n")
+	} else {
+		c.Op("W n")
+	}
+	return nil
+}
+
+// CheckSVG reports whether the SVG in data can be drawn
+func CheckSVG(data []byte) error {
+	img, err := ParseSVG(data)
+	if err != nil {
+		return err
+	}
+	return img.Draw(New(img.width, img.height), 1)
+}
@@ -0,0 +1,318 @@
+package pdfgolden
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// maxFormDepth stops forms that draw themselves
+const maxFormDepth = 16
+
+// matrix is a PDF transformation [a b c d e f]
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// times returns m followed by n, the order of "m cm" inside a state with CTM n
+func (m matrix) times(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func (m matrix) rounded() [6]float64 {
+	var r [6]float64
+	for i, v := range m {
+		r[i] = round(v)
+	}
+	return r
+}
+
+// resourceOperands are the operators whose name operand refers to a resource, by
+// operand position. Resource names depend on what else is on the page, so they are
+// not hashed.
+var resourceOperands = map[string]int{
+	"Do": 0, "gs": 0, "Tf": 0, "sh": 0, "cs": 0, "CS": 0, "BDC": 1, "DP": 1,
+}
+
+type token struct {
+	op    bool // an operator, otherwise an operand
+	name  bool
+	text  string
+	value float64 // of numbers
+	num   bool
+}
+
+// walker hashes content streams and records the images and forms they draw
+type walker struct {
+	ctx        *model.Context
+	hash       hash.Hash
+	placements *[]Placement
+}
+
+func (w *walker) walk(content []byte, resources types.Dict, ctm matrix, depth int) error {
+	if depth > maxFormDepth {
+		return fmt.Errorf("forms nested more than %d deep", maxFormDepth)
+	}
+	var stack []matrix
+	var operands []token
+	for _, t := range tokenize(content) {
+		if !t.op {
+			operands = append(operands, t)
+			continue
+		}
+		ref, isRef := resourceOperands[t.text]
+		for i, o := range operands {
+			if isRef && i == ref && o.name {
+				w.hash.Write([]byte("/R "))
+			} else {
+				w.hash.Write([]byte(o.text + " "))
+			}
+		}
+		w.hash.Write([]byte(t.text + "\n"))
+
+		switch t.text {
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if len(stack) > 0 {
+				ctm, stack = stack[len(stack)-1], stack[:len(stack)-1]
+			}
+		case "cm":
+			if m, ok := matrixOperands(operands); ok {
+				ctm = m.times(ctm)
+			}
+		case "Do":
+			if len(operands) > 0 && operands[0].name {
+				if err := w.draw(operands[0].text[1:], resources, ctm, depth); err != nil {
+					return err
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return nil
+}
+
+// draw records and hashes the XObject named by the Do operand
+func (w *walker) draw(name string, resources types.Dict, ctm matrix, depth int) error {
+	if resources == nil {
+		return nil
+	}
+	o, ok := resources.Find("XObject")
+	if !ok {
+		return nil
+	}
+	xobjects, err := w.ctx.DereferenceDict(o)
+	if err != nil || xobjects == nil {
+		return err
+	}
+	sd, _, err := w.ctx.DereferenceStreamDict(xobjects[name])
+	if err != nil || sd == nil {
+		return err
+	}
+
+	if sd.Subtype() == nil {
+		return nil
+	}
+	switch *sd.Subtype() {
+	case "Image":
+		p := Placement{Kind: "image", Matrix: ctm.rounded()}
+		if v := sd.IntEntry("Width"); v != nil {
+			p.Width = float64(*v)
+		}
+		if v := sd.IntEntry("Height"); v != nil {
+			p.Height = float64(*v)
+		}
+		*w.placements = append(*w.placements, p)
+		sum := sha256.Sum256(sd.Raw)
+		w.hash.Write([]byte("image " + hex.EncodeToString(sum[:]) + "\n"))
+	case "Form":
+		m := identity
+		if a := sd.ArrayEntry("Matrix"); len(a) == 6 {
+			if fm, ok := numberArray(a); ok {
+				m = fm
+			}
+		}
+		ctm = m.times(ctm)
+		p := Placement{Kind: "form", Matrix: ctm.rounded()}
+		if a := sd.ArrayEntry("BBox"); len(a) == 4 {
+			if bb, ok := numberArray(a); ok {
+				p.Width, p.Height = round(bb[2]-bb[0]), round(bb[3]-bb[1])
+			}
+		}
+		*w.placements = append(*w.placements, p)
+		if err := sd.Decode(); err != nil {
+			return err
+		}
+		formResources := resources
+		if r, ok := sd.Find("Resources"); ok {
+			if formResources, err = w.ctx.DereferenceDict(r); err != nil {
+				return err
+			}
+		}
+		w.hash.Write([]byte("form {\n"))
+		if err := w.walk(sd.Content, formResources, ctm, depth+1); err != nil {
+			return err
+		}
+		w.hash.Write([]byte("}\n"))
+	}
+	return nil
+}
+
+func matrixOperands(operands []token) (matrix, bool) {
+	if len(operands) < 6 {
+		return matrix{}, false
+	}
+	var m matrix
+	for i, o := range operands[len(operands)-6:] {
+		if !o.num {
+			return matrix{}, false
+		}
+		m[i] = o.value
+	}
+	return m, true
+}
+
+func numberArray(a types.Array) (matrix, bool) {
+	var m matrix
+	for i, o := range a {
+		switch v := o.(type) {
+		case types.Integer:
+			m[i] = float64(v)
+		case types.Float:
+			m[i] = float64(v)
+		default:
+			return m, false
+		}
+	}
+	return m, true
+}
+
+// tokenize splits a content stream into operands and operators. Strings are kept as
+// hex, numbers are rounded and inline image data is replaced by its hash.
+func tokenize(b []byte) []token {
+	var tokens []token
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case isWhitespace(c):
+			i++
+		case c == '%':
+			for i < len(b) && b[i] != '\n' && b[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			s, end := literalString(b, i)
+			tokens = append(tokens, token{text: "<" + hex.EncodeToString(s) + ">"})
+			i = end
+		case c == '<' && i+1 < len(b) && b[i+1] == '<', c == '>' && i+1 < len(b) && b[i+1] == '>':
+			tokens = append(tokens, token{text: string(b[i : i+2])})
+			i += 2
+		case c == '<':
+			end := bytes.IndexByte(b[i:], '>')
+			if end < 0 {
+				end = len(b) - i - 1
+			}
+			tokens = append(tokens, token{text: string(b[i : i+end+1])})
+			i += end + 1
+		case c == '[', c == ']', c == '{', c == '}':
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case c == '/':
+			j := i + 1
+			for j < len(b) && !isWhitespace(b[j]) && !isDelimiter(b[j]) {
+				j++
+			}
+			tokens = append(tokens, token{name: true, text: string(b[i:j])})
+			i = j
+		default:
+			j := i
+			for j < len(b) && !isWhitespace(b[j]) && !isDelimiter(b[j]) {
+				j++
+			}
+			if j == i {
+				j++ // a stray delimiter
+			}
+			word := string(b[i:j])
+			i = j
+			if f, err := strconv.ParseFloat(word, 64); err == nil {
+				tokens = append(tokens, token{num: true, value: f, text: strconv.FormatFloat(round(f), 'f', -1, 64)})
+				continue
+			}
+			if word == "ID" {
+				data, end := inlineImageData(b, i)
+				sum := sha256.Sum256(data)
+				tokens = append(tokens, token{op: true, text: "ID " + hex.EncodeToString(sum[:])})
+				i = end
+				continue
+			}
+			tokens = append(tokens, token{op: true, text: word})
+		}
+	}
+	return tokens
+}
+
+// literalString returns the bytes of the string starting at the "(" at i and the
+// index after its closing parenthesis
+func literalString(b []byte, i int) ([]byte, int) {
+	var s []byte
+	depth := 0
+	for i < len(b) {
+		c := b[i]
+		switch {
+		case c == '\\' && i+1 < len(b):
+			s = append(s, c, b[i+1])
+			i += 2
+			continue
+		case c == '(':
+			depth++
+			if depth == 1 {
+				i++
+				continue
+			}
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return s, i + 1
+			}
+		}
+		s = append(s, c)
+		i++
+	}
+	return s, i
+}
+
+// inlineImageData returns the data after an ID operator at i and the index after the
+// closing EI
+func inlineImageData(b []byte, i int) ([]byte, int) {
+	if i < len(b) && isWhitespace(b[i]) {
+		i++
+	}
+	for j := i; j+2 <= len(b); j++ {
+		if b[j] == 'E' && b[j+1] == 'I' && j > i && isWhitespace(b[j-1]) && (j+2 == len(b) || isWhitespace(b[j+2])) {
+			return b[i : j-1], j + 2
+		}
+	}
+	return b[i:], len(b)
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
@@ -0,0 +1,58 @@
+package pdfgolden
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	content := []byte("q 1 0 0 1 10.00049 20 cm /Fm0 Do Q % comment\n(a\\)b (c)) Tj <0A0b> [(x) -3] TJ BI /W 1 /H 1 ID \x00\x01 EI Q")
+	var ops []string
+	for _, tok := range tokenize(content) {
+		if tok.op {
+			ops = append(ops, tok.text)
+		}
+	}
+	image := sha256.Sum256([]byte{0, 1})
+	want := []string{"q", "cm", "Do", "Q", "Tj", "TJ", "BI", "ID " + hex.EncodeToString(image[:]), "Q"}
+	if len(ops) != len(want) {
+		t.Fatalf("got operators %q, want %q", ops, want)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Errorf("operator %d is %q, want %q", i, ops[i], want[i])
+		}
+	}
+	if tok := tokenize([]byte("10.00049"))[0]; tok.text != "10" || tok.value != 10.00049 {
+		t.Errorf("number token %+v, want text 10 and the exact value", tok)
+	}
+}
+
+func TestMatrixTimes(t *testing.T) {
+	// Scale by 2, then move by (10, 20): the origin lands on (10, 20), (1, 1) on (12, 22)
+	m := matrix{2, 0, 0, 2, 0, 0}.times(matrix{1, 0, 0, 1, 10, 20})
+	if m != (matrix{2, 0, 0, 2, 10, 20}) {
+		t.Errorf("got %v", m)
+	}
+	// A quarter turn inside a translated state
+	m = matrix{0, 1, -1, 0, 0, 0}.times(matrix{1, 0, 0, 1, 5, 0})
+	if m != (matrix{0, 1, -1, 0, 5, 0}) {
+		t.Errorf("got %v", m)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	want := Document{Pages: []Page{{MediaBox: [4]float64{0, 0, 612, 792}, Placements: []Placement{{Kind: "image", Matrix: [6]float64{100, 0, 0, 50, 72, 72}}}, ContentHash: "a"}}}
+	got := want
+	got.Pages = []Page{want.Pages[0]}
+	got.Pages[0].Placements = []Placement{{Kind: "image", Matrix: [6]float64{100, 0, 0, 50, 72.005, 72}}}
+	if d := Compare(got, want, 0.01); len(d) != 0 {
+		t.Errorf("differences within the tolerance: %q", d)
+	}
+	got.Pages[0].Placements = []Placement{{Kind: "image", Matrix: [6]float64{100, 0, 0, 50, 80, 72}}}
+	got.Pages[0].ContentHash = "b"
+	if d := Compare(got, want, 0.01); len(d) != 2 {
+		t.Errorf("got differences %q, want the placement and the hash", d)
+	}
+}
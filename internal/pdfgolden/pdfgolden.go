@@ -0,0 +1,166 @@
+// Package pdfgolden reduces PDFs to a stable description for golden tests: the page
+// geometry, where each image and form is drawn, and a hash of the drawing operations.
+//
+// The hash stands in for a rendered page hash. CapGo bundles no PDF rasterizer, so the
+// decoded content streams are hashed instead, with forms drawn by a page hashed in
+// place and resource names left out. Two pages with the same hash draw the same thing;
+// IDs, dates and object numbers, which change on every write, are not part of it.
+package pdfgolden
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Document describes a PDF page by page
+type Document struct {
+	Pages []Page `json:"pages"`
+}
+
+// Page holds what a golden test compares for one page
+type Page struct {
+	MediaBox    [4]float64  `json:"mediaBox"`
+	CropBox     [4]float64  `json:"cropBox"`
+	Rotate      int         `json:"rotate"`
+	Placements  []Placement `json:"placements"`
+	ContentHash string      `json:"contentHash"`
+}
+
+// Placement is an image or form drawn by the page content
+type Placement struct {
+	Kind string `json:"kind"` // "image" or "form"
+	// Matrix maps the unit square of an image, or the form space of a form, to the
+	// page, as [a b c d e f]
+	Matrix [6]float64 `json:"matrix"`
+	Width  float64    `json:"width"` // pixels of an image, bounding box of a form
+	Height float64    `json:"height"`
+}
+
+// Summarize reads a PDF and describes it
+func Summarize(path string) (Document, error) {
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	doc := Document{Pages: []Page{}}
+	for p := 1; p <= ctx.PageCount; p++ {
+		page, err := summarizePage(ctx, p)
+		if err != nil {
+			return Document{}, fmt.Errorf("page %d: %v", p, err)
+		}
+		doc.Pages = append(doc.Pages, page)
+	}
+	return doc, nil
+}
+
+func summarizePage(ctx *model.Context, pageNr int) (Page, error) {
+	d, _, inherited, err := ctx.PageDict(pageNr, false)
+	if err != nil {
+		return Page{}, err
+	}
+	page := Page{Rotate: inherited.Rotate, Placements: []Placement{}}
+	if inherited.MediaBox != nil {
+		page.MediaBox = rectArray(inherited.MediaBox)
+		page.CropBox = page.MediaBox
+	}
+	if inherited.CropBox != nil {
+		page.CropBox = rectArray(inherited.CropBox)
+	}
+
+	content, err := ctx.PageContent(d, pageNr)
+	if err != nil && err != model.ErrNoContent {
+		return Page{}, err
+	}
+	w := &walker{ctx: ctx, hash: sha256.New(), placements: &page.Placements}
+	if err := w.walk(content, inherited.Resources, identity, 0); err != nil {
+		return Page{}, err
+	}
+	page.ContentHash = hex.EncodeToString(w.hash.Sum(nil))
+	return page, nil
+}
+
+func rectArray(r *types.Rectangle) [4]float64 {
+	return [4]float64{round(r.LL.X), round(r.LL.Y), round(r.UR.X), round(r.UR.Y)}
+}
+
+// round keeps three decimals, enough for positions in points and stable across writers
+func round(f float64) float64 {
+	return math.Round(f*1000) / 1000
+}
+
+// Load reads a golden description
+func Load(path string) (Document, error) {
+	var doc Document
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return doc, err
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return doc, fmt.Errorf("invalid golden file %s: %v", path, err)
+	}
+	return doc, nil
+}
+
+// Save writes a golden description
+func Save(path string, doc Document) error {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Compare lists the differences between two descriptions; positions may differ by tol
+// points. An empty list means they match.
+func Compare(got, want Document, tol float64) []string {
+	var diffs []string
+	if len(got.Pages) != len(want.Pages) {
+		return []string{fmt.Sprintf("got %d pages, want %d", len(got.Pages), len(want.Pages))}
+	}
+	for i := range want.Pages {
+		g, w := got.Pages[i], want.Pages[i]
+		prefix := "page " + strconv.Itoa(i+1)
+		if !near(g.MediaBox[:], w.MediaBox[:], tol) {
+			diffs = append(diffs, fmt.Sprintf("%s: media box %v, want %v", prefix, g.MediaBox, w.MediaBox))
+		}
+		if !near(g.CropBox[:], w.CropBox[:], tol) {
+			diffs = append(diffs, fmt.Sprintf("%s: crop box %v, want %v", prefix, g.CropBox, w.CropBox))
+		}
+		if g.Rotate != w.Rotate {
+			diffs = append(diffs, fmt.Sprintf("%s: rotation %d, want %d", prefix, g.Rotate, w.Rotate))
+		}
+		if len(g.Placements) != len(w.Placements) {
+			diffs = append(diffs, fmt.Sprintf("%s: %d images and forms, want %d", prefix, len(g.Placements), len(w.Placements)))
+		} else {
+			for j := range w.Placements {
+				gp, wp := g.Placements[j], w.Placements[j]
+				if gp.Kind != wp.Kind || !near(gp.Matrix[:], wp.Matrix[:], tol) ||
+					!near([]float64{gp.Width, gp.Height}, []float64{wp.Width, wp.Height}, tol) {
+					diffs = append(diffs, fmt.Sprintf("%s: placement %d is %+v, want %+v", prefix, j+1, gp, wp))
+				}
+			}
+		}
+		if g.ContentHash != w.ContentHash {
+			diffs = append(diffs, fmt.Sprintf("%s: content hash %.12s…, want %.12s…", prefix, g.ContentHash, w.ContentHash))
+		}
+	}
+	return diffs
+}
+
+func near(a, b []float64, tol float64) bool {
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > tol {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kinds of job StartJob runs
+const (
+	JobStamp      = "stamp"
+	JobBatchStamp = "batchstamp"
+	JobMerge      = "merge"
+	JobOptimize   = "optimize"
+	JobDownload   = "download"
+)
+
+// Job states reported by JobStatus
+const (
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// keepFinishedJobs is how many finished jobs JobStatus still knows about
+const keepFinishedJobs = 50
+
+// errCancelled is returned by operations stopped with CancelJob
+var errCancelled = fmt.Errorf("the operation was cancelled")
+
+// JobRequest describes a job for StartJob. Only the fields of its kind are used:
+// stamp takes PdfPath, Stamps and Options; batchstamp takes Files, Stamps and
+// Placement; merge takes Files; optimize takes PdfPath and Preset; download takes URL.
+type JobRequest struct {
+	Kind      string       `json:"kind"`
+	PdfPath   string       `json:"pdfPath,omitempty"`
+	Files     []string     `json:"files,omitempty"`
+	Stamps    []StampInfo  `json:"stamps,omitempty"`
+	Options   StampOptions `json:"options"`
+	Placement string       `json:"placement,omitempty"`
+	Preset    string       `json:"preset,omitempty"`
+	URL       string       `json:"url,omitempty"`
+}
+
+// JobInfo is the state of a job started with StartJob
+type JobInfo struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"`
+	State   string `json:"state"`
+	Stage   string `json:"stage,omitempty"`
+	Current int    `json:"current"`
+	Total   int    `json:"total"`
+	// Result is what the blocking call returns, set once the job is done
+	Result    interface{} `json:"result,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	StartedAt time.Time   `json:"startedAt"`
+	EndedAt   *time.Time  `json:"endedAt,omitempty"`
+}
+
+// backgroundJob is a job known to the job manager
+type backgroundJob struct {
+	info   JobInfo
+	cancel context.CancelFunc
+}
+
+var jobManager struct {
+	mu   sync.Mutex
+	next int
+	jobs map[string]*backgroundJob
+}
+
+// StartJob runs a stamp, batch stamp, merge, optimize or download job in the
+// background and returns its id at once. Its state is reported by JobStatus and its
+// outcome is sent as EventJobResult.
+func (a *App) StartJob(req JobRequest) (string, error) {
+	var run func(progress *jobProgress) (interface{}, error)
+	switch req.Kind {
+	case JobStamp:
+		run = func(progress *jobProgress) (interface{}, error) {
+			progress.event, progress.stages = EventStampProgress, stampStages
+			return a.stampPDF(req.PdfPath, req.Stamps, req.Options, progress)
+		}
+	case JobBatchStamp:
+		run = func(progress *jobProgress) (interface{}, error) {
+			return a.batchStampPDFs(req.Files, req.Stamps, req.Placement, progress)
+		}
+	case JobMerge:
+		run = func(progress *jobProgress) (interface{}, error) {
+			return a.mergePDFs(req.Files, progress)
+		}
+	case JobOptimize:
+		run = func(progress *jobProgress) (interface{}, error) {
+			progress.event, progress.stages = EventOptimizeProgress, optimizeStages
+			return a.optimizePDF(req.PdfPath, req.Preset, progress)
+		}
+	case JobDownload:
+		run = func(progress *jobProgress) (interface{}, error) {
+			return a.downloadUpdate(req.URL, progress)
+		}
+	default:
+		return "", fmt.Errorf("unknown job kind: %s", req.Kind)
+	}
+	return a.runJob(req.Kind, run), nil
+}
+
+// CancelJob stops a running job. Work already written is removed; a batch keeps the
+// files it finished before the cancel.
+func (a *App) CancelJob(id string) error {
+	jobManager.mu.Lock()
+	defer jobManager.mu.Unlock()
+	job, ok := jobManager.jobs[id]
+	if !ok {
+		return fmt.Errorf("unknown job: %s", id)
+	}
+	if job.info.State != JobRunning {
+		return fmt.Errorf("job %s is not running", id)
+	}
+	fmt.Printf("Backend: Cancelling job %s\n", id)
+	job.cancel()
+	return nil
+}
+
+// JobStatus reports the state of a job started with StartJob
+func (a *App) JobStatus(id string) (JobInfo, error) {
+	jobManager.mu.Lock()
+	defer jobManager.mu.Unlock()
+	job, ok := jobManager.jobs[id]
+	if !ok {
+		return JobInfo{}, fmt.Errorf("unknown job: %s", id)
+	}
+	return job.info, nil
+}
+
+// runJob runs fn on its own goroutine and returns the job id. The outcome is recorded
+// for JobStatus and sent as EventJobResult, with the error in the language of the user.
+func (a *App) runJob(kind string, fn func(progress *jobProgress) (interface{}, error)) string {
+	ctx, cancel := context.WithCancel(context.Background())
+	jobManager.mu.Lock()
+	if jobManager.jobs == nil {
+		jobManager.jobs = map[string]*backgroundJob{}
+	}
+	jobManager.next++
+	job := &backgroundJob{
+		info:   JobInfo{ID: fmt.Sprintf("%s-%d", kind, jobManager.next), Kind: kind, State: JobRunning, StartedAt: time.Now()},
+		cancel: cancel,
+	}
+	jobManager.jobs[job.info.ID] = job
+	jobManager.mu.Unlock()
+
+	id := job.info.ID
+	go func() {
+		defer cancel()
+		var result interface{}
+		err := catchCrash(func() (err error) {
+			result, err = fn(&jobProgress{a: a, ctx: ctx, job: job})
+			return err
+		})
+		res := JobResult{JobID: id, Kind: kind, Result: result}
+		state := JobDone
+		switch {
+		case err != nil && ctx.Err() != nil:
+			// Result keeps what a batch finished before the cancel
+			fmt.Printf("Backend: Job %s was cancelled\n", id)
+			res.Cancelled, state = true, JobCancelled
+			res.Error = localizeMessage(errCancelled.Error())
+		case err != nil:
+			fmt.Printf("Backend: Job %s failed: %v\n", id, err)
+			res.Result, state = nil, JobFailed
+			res.Error = localizeMessage(err.Error())
+		}
+		finishJob(job, state, res)
+		a.emit(EventJobResult, res)
+	}()
+	return id
+}
+
+// finishJob records the outcome of a job and forgets the oldest finished jobs
+func finishJob(job *backgroundJob, state string, res JobResult) {
+	jobManager.mu.Lock()
+	defer jobManager.mu.Unlock()
+	now := time.Now()
+	job.info.State = state
+	job.info.Result = res.Result
+	job.info.Error = res.Error
+	job.info.EndedAt = &now
+
+	var finished []*backgroundJob
+	for _, j := range jobManager.jobs {
+		if j.info.EndedAt != nil {
+			finished = append(finished, j)
+		}
+	}
+	if len(finished) <= keepFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].info.EndedAt.Before(*finished[j].info.EndedAt) })
+	for _, j := range finished[:len(finished)-keepFinishedJobs] {
+		delete(jobManager.jobs, j.info.ID)
+	}
+}
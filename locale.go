@@ -0,0 +1,706 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Languages of the backend generated text: error messages, notifications, menus and
+// the pages CapGo writes into documents. The frontend translates its own strings.
+const (
+	LanguageEnglish    = "en"
+	LanguageVietnamese = "vi"
+)
+
+// LanguageInfo names a supported language in that language
+type LanguageInfo struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+var languages = []LanguageInfo{
+	{LanguageEnglish, "English"},
+	{LanguageVietnamese, "Tiếng Việt"},
+}
+
+// translations maps the English format strings used in the code to their translation.
+// A translation takes the same verbs in the same order, or explicit argument indexes
+// where the language needs another order. Missing entries fall back to English.
+var translations = map[string]map[string]string{
+	LanguageVietnamese: {
+		// Menus
+		"File":                "Tệp",
+		"Open…":               "Mở…",
+		"Save":                "Lưu",
+		"Edit":                "Sửa",
+		"Undo":                "Hoàn tác",
+		"Redo":                "Làm lại",
+		"Tools":               "Công cụ",
+		"Merge PDFs…":         "Gộp PDF…",
+		"Split…":              "Tách…",
+		"Quick Stamp":         "Đóng dấu nhanh",
+		"Show CapGo":          "Hiện CapGo",
+		"Stamp Document":      "Đóng dấu tài liệu",
+		"Stamp Clipboard PDF": "Đóng dấu PDF trong bộ nhớ tạm",
+		"Capture Signature":   "Chụp chữ ký",
+		"Save Session":        "Lưu phiên làm việc",
+		"CapGo Sessions":      "Phiên làm việc CapGo",
+
+		// Notifications
+		"CapGo: job failed":                          "CapGo: tác vụ thất bại",
+		"Stamped %s":                                 "Đã đóng dấu %s",
+		"Update download failed":                     "Tải bản cập nhật thất bại",
+		"CapGo update downloaded":                    "Đã tải bản cập nhật CapGo",
+		"Generated %d certificates":                  "Đã tạo %d chứng nhận",
+		"Generated %d certificates, %d failed":       "Đã tạo %d chứng nhận, %d thất bại",
+		"Mail merge created %d documents":            "Trộn thư đã tạo %d tài liệu",
+		"Mail merge created %d documents, %d failed": "Trộn thư đã tạo %d tài liệu, %d thất bại",
+		"Stamped %d documents":                       "Đã đóng dấu %d tài liệu",
+		"Stamped %d documents, %d failed":            "Đã đóng dấu %d tài liệu, %d thất bại",
+
+		// Cover, portfolio and sample pages
+		"between":                               "giữa",
+		"and":                                   "và",
+		"Portfolio of %d documents, created %s": "Bộ hồ sơ gồm %d tài liệu, tạo ngày %s",
+		"and %d more":                           "và %d tài liệu khác",
+		"Open the attachments panel of your PDF viewer to access the documents.": "Mở bảng tệp đính kèm trong trình xem PDF để truy cập các tài liệu.",
+		"Sample Agreement":                         "Hợp đồng mẫu",
+		"Sample Signature":                         "Chữ ký mẫu",
+		"A practice document for trying out CapGo": "Tài liệu thực hành để dùng thử CapGo",
+		"This agreement is made between CapGo and you, the reader, for the sole purpose of learning how to sign documents.": "Hợp đồng này được lập giữa CapGo và bạn, người đọc, chỉ nhằm mục đích học cách ký tài liệu.",
+		"1. Drag the sample signature onto the line below and resize it until it fits.":                                     "1. Kéo chữ ký mẫu lên dòng bên dưới và thay đổi kích thước cho vừa.",
+		"2. Add the date next to it with a text stamp.":                                                                     "2. Thêm ngày bên cạnh bằng một con dấu văn bản.",
+		"3. Save the signed copy. The original file stays untouched.":                                                       "3. Lưu bản đã ký. Tệp gốc vẫn được giữ nguyên.",
+		"Nothing in this document is binding. You can reopen it from the help menu at any time.":                            "Tài liệu này không có giá trị ràng buộc. Bạn có thể mở lại nó từ menu trợ giúp bất cứ lúc nào.",
+		"Signature": "Chữ ký",
+		"Date":      "Ngày",
+
+		// Stamp warnings
+		"stamp %d targets page %d but the document has %d pages":                                        "con dấu %d nằm trên trang %d nhưng tài liệu chỉ có %d trang",
+		"stamp %d has an invalid size":                                                                  "con dấu %d có kích thước không hợp lệ",
+		"text stamp %d has no text":                                                                     "con dấu văn bản %d không có nội dung",
+		"text stamp %d: %v":                                                                             "con dấu văn bản %d: %v",
+		"the text of stamp %d does not fit its box at %d pt":                                            "nội dung của con dấu %d không vừa khung ở cỡ chữ %d pt",
+		"stamp %d was already applied to this region of page %d on %s":                                  "con dấu %d đã được đóng vào vùng này của trang %d lúc %s",
+		"stamp %d extends beyond the edge of page %d":                                                   "con dấu %d vượt ra ngoài mép trang %d",
+		"stamp %d is closer than %.0f mm to the edge of page %d":                                        "con dấu %[1]d cách mép trang %[3]d chưa đến %.0[2]f mm",
+		"stamp %d is closer than %.0f mm to the edge of page %d and will be moved inside the safe area": "con dấu %[1]d cách mép trang %[3]d chưa đến %.0[2]f mm và sẽ được dời vào vùng an toàn",
+
+		// File errors, see FileError
+		"cannot read %s: permission denied; choose another location or grant CapGo access to the folder":  "không thể đọc %s: không có quyền truy cập; hãy chọn vị trí khác hoặc cấp quyền cho CapGo truy cập thư mục",
+		"cannot write %s: permission denied; choose another location or grant CapGo access to the folder": "không thể ghi %s: không có quyền truy cập; hãy chọn vị trí khác hoặc cấp quyền cho CapGo truy cập thư mục",
+		"cannot read %s: the volume is read-only; choose another output location":                         "không thể đọc %s: ổ đĩa chỉ cho phép đọc; hãy chọn vị trí lưu khác",
+		"cannot write %s: the volume is read-only; choose another output location":                        "không thể ghi %s: ổ đĩa chỉ cho phép đọc; hãy chọn vị trí lưu khác",
+		"cannot read %s: the network share or volume is not available; reconnect it and try again":        "không thể đọc %s: thư mục mạng hoặc ổ đĩa không khả dụng; hãy kết nối lại rồi thử lại",
+		"cannot write %s: the network share or volume is not available; reconnect it and try again":       "không thể ghi %s: thư mục mạng hoặc ổ đĩa không khả dụng; hãy kết nối lại rồi thử lại",
+		"cannot read %s: the disk is full; free up space or choose another location":                      "không thể đọc %s: ổ đĩa đã đầy; hãy giải phóng dung lượng hoặc chọn vị trí khác",
+		"cannot write %s: the disk is full; free up space or choose another location":                     "không thể ghi %s: ổ đĩa đã đầy; hãy giải phóng dung lượng hoặc chọn vị trí khác",
+		"cannot read %s: the file or folder does not exist":                                               "không thể đọc %s: tệp hoặc thư mục không tồn tại",
+		"cannot write %s: the file or folder does not exist":                                              "không thể ghi %s: tệp hoặc thư mục không tồn tại",
+		"cannot read %s: %v":  "không thể đọc %s: %v",
+		"cannot write %s: %v": "không thể ghi %s: %v",
+
+		// Common errors
+		"failed to read pdf: %v":                                          "không thể đọc tệp PDF: %v",
+		"failed to write pdf: %v":                                         "không thể ghi tệp PDF: %v",
+		"failed to optimize pdf: %v":                                      "không thể tối ưu tệp PDF: %v",
+		"failed to read %s: %v":                                           "không thể đọc %s: %v",
+		"failed to create temp folder: %v":                                "không thể tạo thư mục tạm: %v",
+		"failed to count pages: %v":                                       "không thể đếm số trang: %v",
+		"failed to get page dimensions: %v":                               "không thể lấy kích thước trang: %v",
+		"failed to get page dimensions for %s: %v":                        "không thể lấy kích thước trang của %s: %v",
+		"the document has no pages":                                       "tài liệu không có trang nào",
+		"no page dimensions found for %s":                                 "không tìm thấy kích thước trang của %s",
+		"failed to prepend cover page: %v":                                "không thể chèn trang bìa: %v",
+		"failed to place logo: %v":                                        "không thể đặt logo: %v",
+		"failed to extract pages %s: %v":                                  "không thể trích xuất các trang %s: %v",
+		"could not get home directory: %v":                                "không thể xác định thư mục người dùng: %v",
+		"could not get config directory: %v":                              "không thể xác định thư mục cấu hình: %v",
+		"no files given":                                                  "chưa chọn tệp nào",
+		"no stamps given":                                                 "chưa có con dấu nào",
+		"no page ranges given":                                            "chưa nhập khoảng trang nào",
+		"no metadata given":                                               "chưa nhập siêu dữ liệu",
+		"invalid page range %q":                                           "khoảng trang %q không hợp lệ",
+		"invalid page range %q: pages start at 1":                         "khoảng trang %q không hợp lệ: số trang bắt đầu từ 1",
+		"invalid page range %q: %d comes after %d":                        "khoảng trang %q không hợp lệ: %d đứng sau %d",
+		"invalid page range %q: the document has %d pages":                "khoảng trang %q không hợp lệ: tài liệu có %d trang",
+		"invalid color: %q":                                               "màu không hợp lệ: %q",
+		"unsupported font: %s":                                            "phông chữ không được hỗ trợ: %s",
+		"text stamp has no text":                                          "con dấu văn bản không có nội dung",
+		"cover page needs a title":                                        "trang bìa cần có tiêu đề",
+		"unknown cover template: %s":                                      "không có mẫu trang bìa %s",
+		"template name cannot be empty":                                   "tên mẫu không được để trống",
+		"rotation of %d degrees leaves the pages unchanged":               "xoay %d độ không làm thay đổi các trang",
+		"unknown operation %q":                                            "thao tác %q không xác định",
+		"step %d: %v":                                                     "bước %d: %v",
+		"step %d (%s): %v":                                                "bước %d (%s): %v",
+		"%s cancelled by hook: %v":                                        "%s đã bị hook hủy: %v",
+		"the clipboard does not hold a PDF path":                          "bộ nhớ tạm không chứa đường dẫn tệp PDF",
+		"no PDF found in Downloads":                                       "không tìm thấy tệp PDF nào trong thư mục Tải về",
+		"%s is password protected, enter its password to open it":         "%s được bảo vệ bằng mật khẩu, hãy nhập mật khẩu để mở",
+		"wrong password for %s":                                           "sai mật khẩu của %s",
+		"failed to decrypt %s: %v":                                        "không thể giải mã %s: %v",
+		"failed to encrypt %s: %v":                                        "không thể mã hóa %s: %v",
+		"unknown paper size: %s":                                          "khổ giấy không xác định: %s",
+		"failed to resize pages: %v":                                      "không thể đổi kích thước trang: %v",
+		"%s is already encrypted":                                         "%s đã được mã hóa",
+		"set a password or restrict a permission to encrypt the document": "hãy đặt mật khẩu hoặc giới hạn ít nhất một quyền để mã hóa tài liệu",
+		"the CapGo stamps on this document are flattened and cannot be removed": "các con dấu CapGo trên tài liệu này đã được gắn cố định và không thể gỡ bỏ",
+		"page %d has no removable CapGo stamps":                                 "trang %d không có con dấu CapGo nào có thể gỡ bỏ",
+		"unknown language: %s":                                                  "ngôn ngữ không xác định: %s",
+		"failed to write %s deterministically: %v":                              "không thể ghi %s ở chế độ tái lập: %v",
+		"Digitally signed by %s":                                                "Được ký số bởi %s",
+		"Date: %s":                                                              "Ngày: %s",
+		"%s is encrypted and cannot be signed":                                  "%s đã được mã hóa nên không thể ký",
+		"wrong password for the certificate":                                    "sai mật khẩu của chứng thư số",
+		"the certificate file uses encryption CapGo cannot read, export it again with legacy encryption": "tệp chứng thư số dùng kiểu mã hóa CapGo không đọc được, hãy xuất lại với mã hóa kiểu cũ",
+		"failed to read certificate: %v":                                "không thể đọc chứng thư số: %v",
+		"the certificate file holds no private key":                     "tệp chứng thư số không chứa khóa bí mật",
+		"the certificate file holds no certificate for its private key": "tệp chứng thư số không chứa chứng thư của khóa bí mật",
+		"unsupported private key, use an RSA or ECDSA key":              "khóa bí mật không được hỗ trợ, hãy dùng khóa RSA hoặc ECDSA",
+		"the certificate of %s is valid from %s to %s":                  "chứng thư số của %s có hiệu lực từ %s đến %s",
+		"page %d does not exist in the %d page document":                "trang %d không có trong tài liệu %d trang",
+		"the signature box needs a width and a height":                  "khung chữ ký cần có chiều rộng và chiều cao",
+		"the signature needs %d bytes, %d were reserved":                "chữ ký cần %d byte, chỉ %d byte được dành sẵn",
+		"failed to embed signature image: %v":                           "không thể nhúng ảnh chữ ký: %v",
+		"failed to sign: %v":                                            "không thể ký: %v",
+		"%s is damaged and cannot be processed":                         "%s bị hỏng và không thể xử lý",
+		"failed to verify signatures: %v":                               "không thể xác minh chữ ký số: %v",
+		"operation timeout cannot be negative":                          "thời gian chờ thao tác không được âm",
+		"%s of %s timed out after %d seconds":                           "%s của %s đã quá thời gian chờ %d giây",
+		"%s of %s timed out after %d seconds, retry with Ghostscript":   "%s của %s đã quá thời gian chờ %d giây, hãy thử lại với Ghostscript",
+		"template %s has an invalid size":                               "mẫu %s có kích thước không hợp lệ",
+		"template %s has an invalid opacity %g, expected 0 to 1":        "mẫu %s có độ mờ %g không hợp lệ, cần từ 0 đến 1",
+		"invalid background threshold %g, expected 0 to 1":              "ngưỡng xóa nền %g không hợp lệ, cần từ 0 đến 1",
+		"invalid background feathering %g, expected 0 to 1":             "độ mờ viền khi xóa nền %g không hợp lệ, cần từ 0 đến 1",
+		"invalid sequence format %q, write the number as %s or e.g. %s": "định dạng dãy số %q không hợp lệ, hãy viết số dạng %s hoặc ví dụ %s",
+		"sequence format %q has more than one number":                   "định dạng dãy số %q có nhiều hơn một số",
+		"template %s has no text":                                       "mẫu %s không có nội dung chữ",
+		"no image was given":                                            "chưa có hình ảnh nào",
+		"failed to decode image: %v":                                    "không thể giải mã hình ảnh: %v",
+		"%s is larger than %d MB":                                       "%s lớn hơn %d MB",
+		"image is larger than %d MB":                                    "hình ảnh lớn hơn %d MB",
+		"unknown stamp template: %s":                                    "không có mẫu dấu %s",
+		"template %s needs a page number":                               "mẫu %s cần có số trang",
+		"no document is open":                                           "chưa mở tài liệu nào",
+		"failed to store stamp %d: %v":                                  "không thể lưu dấu %d: %v",
+		"%s is not a CapGo session: %v":                                 "%s không phải phiên làm việc CapGo: %v",
+		"%s is not a CapGo session":                                     "%s không phải phiên làm việc CapGo",
+		"%s was saved by a newer version of CapGo":                      "%s được lưu bởi phiên bản CapGo mới hơn",
+		"the saved page order does not fit %s: %v":                      "thứ tự trang đã lưu không khớp với %s: %v",
+		"unknown event: %s":                                             "không có sự kiện %s",
+		"no documents given":                                            "chưa chọn tài liệu nào",
+		"unknown placement: %s":                                         "vị trí không hợp lệ: %s",
+		"unknown placement pages: %s":                                   "trang đặt dấu không hợp lệ: %s",
+		"none of the stamps fit the %d page document":                   "không dấu nào phù hợp với tài liệu %d trang",
+		"%s is not a PDF but a web page, download it again":             "%s không phải PDF mà là một trang web, hãy tải lại",
+		"%s is not a PDF, it contains %s":                               "%s không phải PDF, tệp chứa %s",
+		"unknown preview format: %s":                                    "định dạng xem trước không hợp lệ: %s",
+		"preview quality must be between 1 and 100":                     "chất lượng xem trước phải từ 1 đến 100",
+		"preview cache size cannot be negative":                         "dung lượng bộ nhớ đệm xem trước không được âm",
+		"preview is not a base64 data URL":                              "ảnh xem trước không phải data URL base64",
+		"failed to decode preview: %v":                                  "không thể giải mã ảnh xem trước: %v",
+		"failed to encode preview: %v":                                  "không thể mã hóa ảnh xem trước: %v",
+		"could not get cache directory: %v":                             "không thể lấy thư mục bộ nhớ đệm: %v",
+		"could not create preview cache directory: %v":                  "không thể tạo thư mục bộ nhớ đệm xem trước: %v",
+		"unknown job kind: %s":                                          "loại tác vụ không hợp lệ: %s",
+		"unknown job: %s":                                               "không có tác vụ %s",
+		"job %s is not running":                                         "tác vụ %s không còn chạy",
+		"the operation was cancelled":                                   "thao tác đã bị hủy",
+		"failed to read page %d: %v":                                    "không thể đọc trang %d: %v",
+		"unsupported document language: %s":                             "ngôn ngữ tài liệu không được hỗ trợ: %s",
+		"invalid output conflict mode: %q":                              "chế độ xử lý tệp trùng tên không hợp lệ: %q",
+		"the file name template cannot contain folders":                 "mẫu tên tệp không được chứa thư mục",
+		"unknown placeholder %s in the file name template":              "mẫu tên tệp có trường không hợp lệ %s",
+		"the output folder must be an absolute path":                    "thư mục lưu phải là đường dẫn tuyệt đối",
+		"CapGo has no history for %s":                                   "CapGo không có lịch sử của %s",
+		"failed to write operation log: %v":                             "không thể ghi nhật ký thao tác: %v",
+		"failed to append operation log: %v":                            "không thể thêm nhật ký thao tác: %v",
+		"Operation log":                                                 "Nhật ký thao tác",
+		"Document: %s":                                                  "Tài liệu: %s",
+		"Generated by CapGo on %s":                                      "Được CapGo tạo lúc %s",
+		"From: %s":                                                      "Từ: %s",
+		"Written to: %s":                                                "Ghi vào: %s",
+		"%d stamps removed":                                             "Đã xóa %d con dấu",
+		"Text \"%s\" on page %d":                                        "Chữ \"%s\" trên trang %d",
+		"Image on page %d":                                              "Hình ảnh trên trang %d",
+		"Stamped":                                                       "Đã đóng dấu",
+		"Document properties changed":                                   "Đã sửa thuộc tính tài liệu",
+		"Optimized":                                                     "Đã tối ưu hóa",
+		"Resized to another paper size":                                 "Đã đổi khổ giấy",
+		"Encrypted":                                                     "Đã mã hóa",
+		"Digitally signed":                                              "Đã ký số",
+		"Watermarks removed":                                            "Đã xóa hình mờ",
+		"Operation log appended":                                        "Đã thêm nhật ký thao tác",
+		"Save Stamped PDF":                                              "Lưu PDF đã đóng dấu",
+		"the save dialog needs the app window":                          "hộp thoại lưu cần cửa sổ ứng dụng",
+		"the stamped PDF cannot replace the document it is made from":   "PDF đã đóng dấu không thể thay thế tài liệu gốc",
+		"invalid file pattern %q":                                       "mẫu tên tệp không hợp lệ %q",
+		"%s is not a folder":                                            "%s không phải là thư mục",
+		"no PDF in %s matches %s":                                       "không có PDF nào trong %s khớp với %s",
+		"failed to write summary: %v":                                   "không thể ghi bảng tổng kết: %v",
+		"Processed %d documents":                                        "Đã xử lý %d tài liệu",
+		"Processed %d documents, %d failed":                             "Đã xử lý %d tài liệu, %d tài liệu lỗi",
+		"resolution must be between 1 and %d dpi":                       "độ phân giải phải từ 1 đến %d dpi",
+		"rendering pages needs Ghostscript, which is not installed":     "hiển thị trang cần Ghostscript, nhưng Ghostscript chưa được cài đặt",
+		"failed to render page %d of %s: %v":                            "không thể hiển thị trang %d của %s: %v",
+		"thumbnail width must be between 1 and %d pixels":               "chiều rộng hình thu nhỏ phải từ 1 đến %d điểm ảnh",
+		"failed to render thumbnails of %s: %v":                         "không thể tạo hình thu nhỏ của %s: %v",
+		"%s contains no PDF":                                            "%s không chứa PDF nào",
+		"the archives contain more than %d PDFs":                        "các tệp nén chứa hơn %d PDF",
+		"failed to open %s: %v":                                         "không thể mở %s: %v",
+		"failed to extract %s from %s: %v":                              "không thể giải nén %s từ %s: %v",
+		"the archives hold more than %d GB of PDFs":                     "các tệp nén chứa hơn %d GB PDF",
+		"failed to write %s: %v":                                        "không thể ghi %s: %v",
+		"failed to add %s: %v":                                          "không thể thêm %s: %v",
+		"%s is not a PDF, image or office document":                     "%s không phải là PDF, hình ảnh hay tài liệu văn phòng",
+		"failed to prepare %s: %v":                                      "không thể chuẩn bị %s: %v",
+		"retention cannot be negative":                                  "thời gian lưu giữ không thể là số âm",
+		"cleanup interval cannot be negative":                           "khoảng thời gian dọn dẹp không thể là số âm",
+		"%s is not in the recent files":                                 "%s không có trong danh sách tệp gần đây",
+		"invalid page selection %q":                                     "lựa chọn trang không hợp lệ %q",
+		"invalid page selection %q: %d comes after %d":                  "lựa chọn trang không hợp lệ %q: %d đứng sau %d",
+		"stamp %d: %v":                                                  "con dấu %d: %v",
+		"quick stamp %d: %v":                                            "con dấu nhanh %d: %v",
+		"the page selection matches no page of the %d page document":    "lựa chọn trang không khớp với trang nào của tài liệu %d trang",
+		"cannot insert after page %d of the %d page document":           "không thể chèn sau trang %d của tài liệu %d trang",
+		"cannot delete every page of the document":                      "không thể xóa tất cả các trang của tài liệu",
+		"unsupported image format: %s":                                  "định dạng ảnh không được hỗ trợ: %s",
+		"no images given":                                               "không có hình ảnh nào",
+		"failed to convert %s: %v":                                      "không thể chuyển đổi %s: %v",
+		"reading HEIC images needs heif-convert from libheif or ImageMagick, which is not installed": "cần heif-convert của libheif hoặc ImageMagick để đọc ảnh HEIC, nhưng chưa được cài đặt",
+		"failed to prepare SVG stamp %d: %v":                             "không thể chuẩn bị dấu SVG %d: %v",
+		"SVG images can only be used as stamps":                          "ảnh SVG chỉ có thể dùng làm dấu",
+		"SVG gradients and patterns are not supported, use solid colors": "không hỗ trợ dải màu và mẫu tô SVG, hãy dùng màu đơn",
+		"SVG <%s> elements are not supported":                            "không hỗ trợ phần tử SVG <%s>",
+		"unsupported image %s: %v":                                       "hình ảnh không được hỗ trợ %s: %v",
+		"failed to convert images: %v":                                   "không thể chuyển đổi hình ảnh: %v",
+		"%s is a folder":                                                 "%s là một thư mục",
+		"could not create plugins directory: %v":                         "không thể tạo thư mục plugin: %v",
+		"failed to read plugins: %v":                                     "không thể đọc danh sách plugin: %v",
+		"missing plugin.json":                                            "thiếu plugin.json",
+		"invalid plugin.json: %v":                                        "plugin.json không hợp lệ: %v",
+		"invalid plugin id %q":                                           "mã plugin %q không hợp lệ",
+		"plugin %s offers no operations":                                 "plugin %s không có thao tác nào",
+		"plugin %s: command must be a program inside the plugin folder":  "plugin %s: lệnh phải là một chương trình trong thư mục plugin",
+		"plugin %s: program %s not found":                                "plugin %s: không tìm thấy chương trình %s",
+		"plugin %s has no operation %q":                                  "plugin %s không có thao tác %q",
+		"%s needs a value for %s":                                        "%s cần giá trị cho %s",
+		"invalid value %q for %s":                                        "giá trị %q không hợp lệ cho %s",
+		"plugin %s did not write a document":                             "plugin %s không ghi ra tài liệu nào",
+		"plugin %s is not installed":                                     "plugin %s chưa được cài đặt",
+		"failed to start plugin %s: %v":                                  "không thể khởi chạy plugin %s: %v",
+		"plugin %s did not finish within %v":                             "plugin %s không hoàn tất trong %v",
+		"plugin %s failed: %v":                                           "plugin %s thất bại: %v",
+		"plugin %s sent an invalid response: %v":                         "plugin %s trả về phản hồi không hợp lệ: %v",
+		"plugin %s: %s":                                                  "plugin %s: %s",
+		"could not create scripts directory: %v":                         "không thể tạo thư mục kịch bản: %v",
+		"invalid script name %q":                                         "tên kịch bản %q không hợp lệ",
+		"script %q not found":                                            "không tìm thấy kịch bản %q",
+		"failed to delete script: %v":                                    "không thể xóa kịch bản: %v",
+		"invalid script name %q: use letters, digits, spaces, dots, dashes and underscores": "tên kịch bản %q không hợp lệ: hãy dùng chữ cái, chữ số, dấu cách, dấu chấm, gạch ngang và gạch dưới",
+		"script %s has no steps":                                                   "kịch bản %s không có bước nào",
+		"script %s has %d steps, at most %d are allowed":                           "kịch bản %s có %d bước, chỉ cho phép tối đa %d bước",
+		"script %s has both steps and code":                                        "kịch bản %s có cả các bước lẫn mã",
+		"the code of script %s is %d bytes long, at most %d are allowed":           "mã của kịch bản %s dài %d byte, chỉ cho phép tối đa %d byte",
+		"the code of script %s does not compile: %s":                               "mã của kịch bản %s không biên dịch được: %s",
+		"a script can run at most %d operations on a document":                     "một kịch bản chỉ được chạy tối đa %d thao tác trên một tài liệu",
+		"invalid stamps: %v":                                                       "con dấu không hợp lệ: %v",
+		"invalid metadata: %v":                                                     "siêu dữ liệu không hợp lệ: %v",
+		"script %s did not finish within %v":                                       "kịch bản %s không hoàn tất trong %v",
+		"line %s: %v":                                                              "dòng %s: %v",
+		"feedback text is empty":                                                   "nội dung góp ý đang trống",
+		"the feedback report is too long, shorten it or leave out the diagnostics": "báo cáo góp ý quá dài, hãy rút gọn hoặc bỏ phần chẩn đoán",
+		"batch job %s is already running":                                          "tác vụ hàng loạt %s đang chạy",
+		"invalid batch job id: %q":                                                 "mã tác vụ hàng loạt không hợp lệ: %q",
+		"batch job %s not found":                                                   "không tìm thấy tác vụ hàng loạt %s",
+		"the job input changed: %d items now, %d when the job started":             "dữ liệu của tác vụ đã thay đổi: hiện có %d mục, lúc bắt đầu có %d mục",
+		"batch job %s has no failed items":                                         "tác vụ hàng loạt %s không có mục nào lỗi",
+		"invalid checkpoint: %v":                                                   "điểm khôi phục không hợp lệ: %v",
+		"unknown batch job kind: %s":                                               "loại tác vụ hàng loạt không hợp lệ: %s",
+		"could not create batch checkpoint directory: %v":                          "không thể tạo thư mục điểm khôi phục của tác vụ hàng loạt: %v",
+
+		// Stamping
+		"stamp %d has an invalid opacity %g, expected 0 to 1":            "con dấu %d có độ mờ %g không hợp lệ, cần từ 0 đến 1",
+		"failed to prepare text stamp %d: %v":                            "không thể chuẩn bị con dấu văn bản %d: %v",
+		"failed to add watermarks: %v":                                   "không thể thêm hình mờ: %v",
+		"invalid base64 data format for stamp %d":                        "dữ liệu base64 của con dấu %d có định dạng không hợp lệ",
+		"failed to decode base64 image %d: %v":                           "không thể giải mã hình ảnh base64 %d: %v",
+		"failed to open image file %d: %v":                               "không thể mở tệp hình ảnh %d: %v",
+		"failed to decode image %d from base64: %v":                      "không thể giải mã hình ảnh %d từ base64: %v",
+		"failed to decode image file %d: %v":                             "không thể giải mã tệp hình ảnh %d: %v",
+		"failed to create temp stamp %d: %v":                             "không thể tạo con dấu tạm %d: %v",
+		"failed to encode stamp %d: %v":                                  "không thể mã hóa con dấu %d: %v",
+		"failed to parse watermark %d details: %v":                       "không thể đọc thông tin hình mờ %d: %v",
+		"failed to read stamp on page %d: %v":                            "không thể đọc con dấu trên trang %d: %v",
+		"failed to flatten stamps on page %d: %v":                        "không thể làm phẳng con dấu trên trang %d: %v",
+		"stamp group is missing an id":                                   "nhóm con dấu chưa có mã",
+		"stamp group %s has a negative scale":                            "nhóm con dấu %s có tỉ lệ âm",
+		"stamp references unknown group %s":                              "con dấu tham chiếu đến nhóm không tồn tại %s",
+		"stamp %d has unknown origin: %s":                                "con dấu %d có gốc tọa độ không hợp lệ: %s",
+		"stamp %d is in preview pixels but no preview scale is known":    "con dấu %d tính theo điểm ảnh xem trước nhưng chưa biết tỉ lệ xem trước",
+		"stamp %d has unknown units: %s":                                 "con dấu %d có đơn vị không hợp lệ: %s",
+		"preview scale must be positive":                                 "tỉ lệ xem trước phải lớn hơn 0",
+		"quick stamp %d needs a page number":                             "con dấu nhanh %d cần số trang",
+		"quick stamp %d has an invalid size":                             "con dấu nhanh %d có kích thước không hợp lệ",
+		"no quick stamps are configured":                                 "chưa thiết lập con dấu nhanh nào",
+		"failed to read Downloads: %v":                                   "không thể đọc thư mục Tải về: %v",
+		"rotation must be a multiple of 90 degrees, got %d":              "góc xoay phải là bội số của 90 độ, nhận được %d",
+		"styled text stamps support the standard PDF fonts only, got %s": "con dấu văn bản có định dạng chỉ hỗ trợ các phông chữ PDF chuẩn, nhận được %s",
+		"failed to parse font: %v":                                       "không thể đọc phông chữ: %v",
+		"failed to encode png: %v":                                       "không thể mã hóa PNG: %v",
+		"failed to encode image: %v":                                     "không thể mã hóa hình ảnh: %v",
+		"template %s: %v":                                                "mẫu %s: %v",
+		"failed to save seal: %v":                                        "không thể lưu con dấu tròn: %v",
+		"seal size is limited to 6000 pixels":                            "kích thước con dấu tròn tối đa là 6000 điểm ảnh",
+		"no CapGo stamps are recorded for this document":                 "tài liệu này không có con dấu CapGo nào được ghi nhận",
+		"failed to remove watermarks: %v":                                "không thể xóa hình mờ: %v",
+		"invalid sequence name: %q":                                      "tên dãy số không hợp lệ: %q",
+		"sequence counter cannot be negative":                            "bộ đếm dãy số không được âm",
+		"unknown sequence: %s":                                           "không có dãy số: %s",
+		"design must have a positive size":                               "thiết kế phải có kích thước lớn hơn 0",
+		"design is too large to render at %d dpi":                        "thiết kế quá lớn để hiển thị ở %d dpi",
+		"unknown type %q":                                                "loại %q không hợp lệ",
+		"design element %d: %v":                                          "thành phần thiết kế %d: %v",
+		"invalid vertical alignment %q":                                  "căn lề dọc %q không hợp lệ",
+		"invalid alignment %q":                                           "căn lề %q không hợp lệ",
+		"invalid page %q":                                                "trang %q không hợp lệ",
+		"page %d has no media box":                                       "trang %d không có khung trang",
+
+		// SVG stamps
+		"unsupported encoding %s":                                       "không hỗ trợ bảng mã %s",
+		"invalid SVG: %v":                                               "SVG không hợp lệ: %v",
+		"invalid SVG: the root element is <%s>":                         "SVG không hợp lệ: phần tử gốc là <%s>",
+		"invalid SVG view box %q":                                       "vùng hiển thị SVG %q không hợp lệ",
+		"SVG has neither a view box nor a width and height":             "SVG không có vùng hiển thị lẫn chiều rộng và chiều cao",
+		"invalid SVG stroke width %q":                                   "độ dày nét SVG %q không hợp lệ",
+		"invalid SVG miter limit %q":                                    "giới hạn góc nối SVG %q không hợp lệ",
+		"invalid SVG opacity %q":                                        "độ mờ SVG %q không hợp lệ",
+		"unsupported unit %q":                                           "không hỗ trợ đơn vị %q",
+		"unexpected %q":                                                 "không mong đợi %q",
+		"invalid SVG transform %q":                                      "phép biến đổi SVG %q không hợp lệ",
+		"expected a number at %q":                                       "cần một số tại %q",
+		"expected an arc flag at %q":                                    "cần một cờ cung tròn tại %q",
+		"invalid SVG path data at %q":                                   "dữ liệu đường SVG không hợp lệ tại %q",
+		"SVG path data must start with a move":                          "dữ liệu đường SVG phải bắt đầu bằng lệnh di chuyển",
+		"invalid SVG path command %q":                                   "lệnh đường SVG %q không hợp lệ",
+		"invalid SVG %s %s %q":                                          "thuộc tính %[2]s của phần tử SVG %[1]s có giá trị %[3]q không hợp lệ",
+		"invalid SVG path data: %v":                                     "dữ liệu đường SVG không hợp lệ: %v",
+		"invalid SVG %s points":                                         "các điểm của phần tử SVG %s không hợp lệ",
+		"SVG elements are nested too deeply":                            "các phần tử SVG lồng nhau quá sâu",
+		"SVG %ss are not supported":                                     "không hỗ trợ %s trong SVG",
+		"SVG <use> refers to a missing element %q":                      "SVG <use> tham chiếu đến phần tử không tồn tại %q",
+		"SVG clip path %q not found":                                    "không tìm thấy vùng cắt SVG %q",
+		"SVG clip paths relative to the bounding box are not supported": "không hỗ trợ vùng cắt SVG tính theo khung bao",
+
+		// Documents and pages
+		"failed to collect pages: %v":                                          "không thể gom các trang: %v",
+		"failed to rotate pages: %v":                                           "không thể xoay các trang: %v",
+		"failed to insert a blank page: %v":                                    "không thể chèn trang trắng: %v",
+		"failed to insert pages: %v":                                           "không thể chèn các trang: %v",
+		"no pages given":                                                       "chưa chọn trang nào",
+		"invalid page selection: %v":                                           "lựa chọn trang không hợp lệ: %v",
+		"none of the selected pages exist in the %d page document":             "không trang nào được chọn có trong tài liệu %d trang",
+		"failed to delete pages: %v":                                           "không thể xóa các trang: %v",
+		"failed to render pages %s of %s: %v":                                  "không thể hiển thị các trang %s của %s: %v",
+		"failed to export page %d: %v":                                         "không thể xuất trang %d: %v",
+		"select at least two PDFs to merge":                                    "hãy chọn ít nhất hai tệp PDF để gộp",
+		"failed to merge pdfs: %v":                                             "không thể gộp các tệp PDF: %v",
+		"failed to write cover page: %v":                                       "không thể ghi trang bìa: %v",
+		"failed to stage %s: %v":                                               "không thể chuẩn bị %s: %v",
+		"failed to write cover sheet: %v":                                      "không thể ghi trang bìa bộ hồ sơ: %v",
+		"failed to create portfolio: %v":                                       "không thể tạo bộ hồ sơ: %v",
+		"invalid language tag: %s":                                             "mã ngôn ngữ không hợp lệ: %s",
+		"failed to read catalog: %v":                                           "không thể đọc danh mục tài liệu: %v",
+		"a title is needed to display it":                                      "cần có tiêu đề để hiển thị tiêu đề",
+		"failed to encode %s: %v":                                              "không thể mã hóa %s: %v",
+		"failed to encode language: %v":                                        "không thể mã hóa ngôn ngữ: %v",
+		"failed to read viewer preferences: %v":                                "không thể đọc tùy chọn trình xem: %v",
+		"failed to read document info: %v":                                     "không thể đọc thông tin tài liệu: %v",
+		"unknown invoice profile: %s":                                          "hồ sơ hóa đơn không hợp lệ: %s",
+		"failed to read invoice xml: %v":                                       "không thể đọc tệp XML hóa đơn: %v",
+		"invoice is not valid xml: %v":                                         "hóa đơn không phải XML hợp lệ: %v",
+		"encrypted documents cannot be converted to PDF/A-3":                   "không thể chuyển tài liệu đã mã hóa sang PDF/A-3",
+		"failed to attach invoice: %v":                                         "không thể đính kèm hóa đơn: %v",
+		"failed to write metadata: %v":                                         "không thể ghi siêu dữ liệu: %v",
+		"failed to add output intent: %v":                                      "không thể thêm mục đích đầu ra: %v",
+		"filing profile limits cannot be negative":                             "giới hạn của hồ sơ nộp không được âm",
+		"unknown preset %q, expected %s, %s or %s":                             "cấu hình sẵn %q không hợp lệ, cần là %s, %s hoặc %s",
+		"size target must be positive":                                         "dung lượng mục tiêu phải lớn hơn 0",
+		"failed to create temp file: %v":                                       "không thể tạo tệp tạm: %v",
+		"failed to optimize pdf":                                               "không thể tối ưu tệp PDF",
+		"failed to save optimized pdf: %v":                                     "không thể lưu tệp PDF đã tối ưu: %v",
+		"failed to read object %d: %v":                                         "không thể đọc đối tượng %d: %v",
+		"failed to strip object %d: %v":                                        "không thể lược bỏ đối tượng %d: %v",
+		"failed to read pdf: no cross-reference offset at the end of the file": "không thể đọc tệp PDF: không có vị trí bảng tham chiếu chéo ở cuối tệp",
+		"signature placeholder not found":                                      "không tìm thấy chỗ dành cho chữ ký",
+		"signature byte range not found":                                       "không tìm thấy phạm vi byte của chữ ký",
+		"failed to draw signature image: %v":                                   "không thể vẽ hình chữ ký: %v",
+		"failed to read %s":                                                    "không thể đọc %s",
+		"failed to read %s: not an array":                                      "không thể đọc %s: không phải là mảng",
+		"ghostscript wrote no image":                                           "ghostscript không tạo ra hình ảnh nào",
+		"ghostscript did not finish within %v":                                 "ghostscript không hoàn tất trong %v",
+		"%s wrote no image":                                                    "%s không tạo ra hình ảnh nào",
+
+		// Mail merge and certificates
+		"csv file has no data rows":                "tệp CSV không có dòng dữ liệu nào",
+		"unknown column %q":                        "không có cột %q",
+		"row %d: %v":                               "dòng %d: %v",
+		"failed to open csv: %v":                   "không thể mở tệp CSV: %v",
+		"failed to parse csv: %v":                  "không thể đọc tệp CSV: %v",
+		"csv file is empty":                        "tệp CSV đang trống",
+		"no recipients given":                      "chưa có người nhận nào",
+		"unknown field %q":                         "không có trường %q",
+		"recipient %d: %v":                         "người nhận %d: %v",
+		"failed to combine certificates: %v":       "không thể gộp các chứng nhận: %v",
+		"failed to open template: %v":              "không thể mở mẫu: %v",
+		"unsupported template image: %v":           "hình ảnh mẫu không được hỗ trợ: %v",
+		"failed to create template pdf: %v":        "không thể tạo tệp PDF mẫu: %v",
+		"failed to convert template image: %v":     "không thể chuyển đổi hình ảnh mẫu: %v",
+		"only TrueType (.ttf) fonts are supported": "chỉ hỗ trợ phông chữ TrueType (.ttf)",
+		"failed to read font: %v":                  "không thể đọc phông chữ: %v",
+		"font has no PostScript name":              "phông chữ không có tên PostScript",
+		"failed to install font: %v":               "không thể cài đặt phông chữ: %v",
+		"failed to load fonts: %v":                 "không thể tải phông chữ: %v",
+		"font %s could not be installed":           "không thể cài đặt phông chữ %s",
+
+		// Settings, hotkeys and windows
+		"could not create config directory: %v":                           "không thể tạo thư mục cấu hình: %v",
+		"failed to parse %s: %v":                                          "không thể đọc nội dung %s: %v",
+		"failed to lock %s: %v":                                           "không thể khóa %s: %v",
+		"invalid safe area mode: %q":                                      "chế độ vùng an toàn không hợp lệ: %q",
+		"safe area margin cannot be negative":                             "lề vùng an toàn không được âm",
+		"invalid power mode: %q":                                          "chế độ năng lượng không hợp lệ: %q",
+		"max workers cannot be negative":                                  "số tiến trình tối đa không được âm",
+		"paper size name cannot be empty":                                 "tên khổ giấy không được để trống",
+		"paper size name %q cannot contain slashes or colons":             "tên khổ giấy %q không được chứa dấu gạch chéo hoặc dấu hai chấm",
+		"%s is a standard paper size":                                     "%s là khổ giấy chuẩn",
+		"paper size %s must be between %.0f and %.0f points on each side": "mỗi cạnh của khổ giấy %s phải từ %.0f đến %.0f điểm",
+		"unknown custom paper size: %s":                                   "không có khổ giấy tùy chỉnh: %s",
+		"could not create history directory: %v":                          "không thể tạo thư mục lịch sử: %v",
+		"could not create onboarding directory: %v":                       "không thể tạo thư mục hướng dẫn: %v",
+		"failed to write sample document: %v":                             "không thể ghi tài liệu mẫu: %v",
+		"failed to write sample signature: %v":                            "không thể ghi chữ ký mẫu: %v",
+		"hook %d: unknown operation %q":                                   "hook %d: không có thao tác %q",
+		"hook %d: stage must be %q or %q":                                 "hook %d: giai đoạn phải là %q hoặc %q",
+		"hook %d: no command":                                             "hook %d: chưa có lệnh",
+		"hook %d: command %s not found":                                   "hook %d: không tìm thấy lệnh %s",
+		"%s did not finish within %v":                                     "%s không hoàn tất trong %v",
+		"invalid hotkey: %v":                                              "phím tắt không hợp lệ: %v",
+		"a hotkey needs at least one modifier":                            "phím tắt cần ít nhất một phím bổ trợ",
+		"clipboard is not available":                                      "bộ nhớ tạm không khả dụng",
+		"failed to read clipboard: %v":                                    "không thể đọc bộ nhớ tạm: %v",
+		"unknown hotkey action: %s":                                       "thao tác phím tắt không hợp lệ: %s",
+		"unknown window kind: %s":                                         "loại cửa sổ không hợp lệ: %s",
+		"a preview window needs a document":                               "cửa sổ xem trước cần một tài liệu",
+		"could not locate CapGo executable: %v":                           "không thể tìm thấy tệp chạy của CapGo: %v",
+		"failed to open window: %v":                                       "không thể mở cửa sổ: %v",
+		"unknown window: %s":                                              "không có cửa sổ: %s",
+
+		// Updates and system integration
+		"no application found to open files on %s":                                                              "không tìm thấy ứng dụng để mở tệp trên %s",
+		"failed to open %s: the file is quarantined as a download, clear the quarantine flag and try again: %v": "không thể mở %s: tệp bị cách ly vì được tải về, hãy gỡ cờ cách ly rồi thử lại: %v",
+		"failed to clear quarantine: %v: %s":                                                                    "không thể gỡ cờ cách ly: %v: %s",
+		"failed to clear quarantine: %v":                                                                        "không thể gỡ cờ cách ly: %v",
+		"failed to download %s from iCloud: %v":                                                                 "không thể tải %s từ iCloud: %v",
+		"timed out downloading %s from iCloud":                                                                  "hết thời gian chờ tải %s từ iCloud",
+		"failed to download from iCloud: %v: %s":                                                                "không thể tải từ iCloud: %v: %s",
+		"iCloud Drive is not supported on this platform":                                                        "iCloud Drive không được hỗ trợ trên nền tảng này",
+		"notifications are not supported on %s":                                                                 "không hỗ trợ thông báo trên %s",
+		"failed to post notification: %v: %s":                                                                   "không thể gửi thông báo: %v: %s",
+		"developer mode detected: cannot auto-update binary outside of .app bundle":                             "đang ở chế độ phát triển: không thể tự động cập nhật tệp chạy nằm ngoài gói .app",
+		"failed to copy updater: %v":                                                                            "không thể sao chép trình cập nhật: %v",
+		"CapGo (pid %d) did not quit":                                                                           "CapGo (pid %d) không thoát",
+		"update failed: app not found in %s":                                                                    "cập nhật thất bại: không tìm thấy ứng dụng trong %s",
+		"installing into %s needs an administrator password and the prompt was cancelled":                       "cài đặt vào %s cần mật khẩu quản trị và hộp thoại đã bị hủy",
+		"failed to install with administrator rights: %v: %s":                                                   "không thể cài đặt với quyền quản trị: %v: %s",
+		"refusing to replace %s: not an application bundle":                                                     "từ chối thay thế %s: không phải là gói ứng dụng",
+		"failed to move the old app aside: %v":                                                                  "không thể chuyển ứng dụng cũ sang chỗ khác: %v",
+		"failed to move the new app into place: %v":                                                             "không thể đưa ứng dụng mới vào đúng chỗ: %v",
+		"%s failed: %v: %s":                                                                                     "%s thất bại: %v: %s",
+	},
+}
+
+var (
+	languageMu      sync.RWMutex
+	currentLanguage = LanguageEnglish
+)
+
+// language returns the language of backend generated text
+func language() string {
+	languageMu.RLock()
+	defer languageMu.RUnlock()
+	return currentLanguage
+}
+
+func setLanguage(code string) {
+	languageMu.Lock()
+	currentLanguage = code
+	languageMu.Unlock()
+}
+
+func isLanguage(code string) bool {
+	for _, l := range languages {
+		if l.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// GetLanguages returns the languages the backend can use
+func (a *App) GetLanguages() []LanguageInfo {
+	return append([]LanguageInfo{}, languages...)
+}
+
+// GetLanguage returns the code of the selected language
+func (a *App) GetLanguage() string {
+	return language()
+}
+
+// SetLanguage selects and persists the language of backend generated text and rebuilds
+// the menu bar in it
+func (a *App) SetLanguage(code string) error {
+	if !isLanguage(code) {
+		return fmt.Errorf("unknown language: %s", code)
+	}
+	a.mu.Lock()
+	a.settings.Language = code
+	err := a.saveSettings()
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	setLanguage(code)
+
+	if a.ctx != nil && a.menu.bar != nil {
+		runtime.MenuSetApplicationMenu(a.ctx, a.applicationMenu())
+		a.applyMenuState(a.GetDocumentState())
+		runtime.MenuUpdateApplicationMenu(a.ctx)
+	}
+	return nil
+}
+
+// tr formats a message in the selected language; format is the English text
+func tr(format string, args ...interface{}) string {
+	if t, ok := translations[language()][format]; ok {
+		format = t
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// formatDate writes a date the way the selected language does
+func formatDate(t time.Time) string {
+	if language() == LanguageVietnamese {
+		return fmt.Sprintf("ngày %d tháng %d năm %d", t.Day(), t.Month(), t.Year())
+	}
+	return t.Format("2 January 2006")
+}
+
+// formatDateTime writes a date and time the way the selected language does
+func formatDateTime(t time.Time) string {
+	if language() == LanguageVietnamese {
+		return t.Format("15:04 02/01/2006")
+	}
+	return t.Format("2 Jan 2006 15:04")
+}
+
+// messagePattern matches a message produced from an English format string. Each verb
+// becomes a group; the wrapped error of a %v is translated as well.
+type messagePattern struct {
+	re          *regexp.Regexp
+	literal     int    // length of the format without its verbs, to prefer specific formats
+	nested      []bool // per group, whether it holds a wrapped error
+	translation string // with every verb replaced by %s, keeping explicit argument indexes
+}
+
+var (
+	messagePatternsOnce sync.Once
+	messagePatterns     map[string][]messagePattern // per language, most specific first
+
+	formatVerb    = regexp.MustCompile(`%[-+# 0]*(\[\d+\])?\d*(\.\d*)?(\[\d+\])?[vsdqf]`)
+	argumentIndex = regexp.MustCompile(`\[\d+\]`)
+)
+
+func compileMessagePatterns() {
+	messagePatterns = map[string][]messagePattern{}
+	for lang, bundle := range translations {
+		patterns := make([]messagePattern, 0, len(bundle))
+		for format, translation := range bundle {
+			p := messagePattern{literal: len(formatVerb.ReplaceAllString(format, ""))}
+			var expr strings.Builder
+			expr.WriteString("^")
+			last := 0
+			for _, v := range formatVerb.FindAllStringIndex(format, -1) {
+				expr.WriteString(regexp.QuoteMeta(format[last:v[0]]))
+				verb := format[v[1]-1]
+				switch verb {
+				case 'd':
+					expr.WriteString(`(-?\d+)`)
+				case 'f':
+					expr.WriteString(`(-?[\d.]+)`)
+				case 'q':
+					expr.WriteString(`(".*?")`)
+				case 'v':
+					expr.WriteString(`(.*)`)
+				default:
+					expr.WriteString(`(.*?)`)
+				}
+				p.nested = append(p.nested, verb == 'v')
+				last = v[1]
+			}
+			expr.WriteString(regexp.QuoteMeta(format[last:]) + "$")
+			p.re = regexp.MustCompile(expr.String())
+			p.translation = formatVerb.ReplaceAllStringFunc(translation, func(v string) string {
+				return "%" + argumentIndex.FindString(v) + "s"
+			})
+			patterns = append(patterns, p)
+		}
+		// "cannot read %s: the disk is full; …" has to win over "cannot read %s: %v"
+		sort.Slice(patterns, func(i, j int) bool { return patterns[i].literal > patterns[j].literal })
+		messagePatterns[lang] = patterns
+	}
+}
+
+// localizeMessage translates a message built from one of the catalog formats, including
+// the errors it wraps. Messages that match no format are returned unchanged.
+func localizeMessage(msg string) string {
+	lang := language()
+	if lang == LanguageEnglish {
+		return msg
+	}
+	messagePatternsOnce.Do(compileMessagePatterns)
+	for _, p := range messagePatterns[lang] {
+		m := p.re.FindStringSubmatch(msg)
+		if m == nil {
+			continue
+		}
+		args := make([]interface{}, len(m)-1)
+		for i, s := range m[1:] {
+			if p.nested[i] {
+				s = localizeMessage(s)
+			}
+			args[i] = s
+		}
+		if len(args) == 0 {
+			return p.translation
+		}
+		return fmt.Sprintf(p.translation, args...)
+	}
+	return msg
+}
+
+// localizeError is the error formatter of the Wails bindings: errors returned to the
+// frontend are translated into the selected language
+func localizeError(err error) any {
+	return localizeMessage(err.Error())
+}
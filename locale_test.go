@@ -0,0 +1,81 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// TestTranslationsComplete walks the source for the messages CapGo shows: error formats
+// and texts passed to tr. Every one needs a Vietnamese translation.
+func TestTranslationsComplete(t *testing.T) {
+	bundle := translations[LanguageVietnamese]
+	missing := map[string]string{}
+	for _, dir := range []string{".", "internal/pdfcanvas", "pkg/stamper"} {
+		files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fset := token.NewFileSet()
+		for _, name := range files {
+			if strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			f, err := parser.ParseFile(fset, name, nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast.Inspect(f, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || len(call.Args) == 0 || !isMessageCall(call.Fun) {
+					return true
+				}
+				lit, ok := call.Args[0].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					return true
+				}
+				msg, err := strconv.Unquote(lit.Value)
+				if err != nil || !hasWords(msg) {
+					return true
+				}
+				if _, ok := bundle[msg]; !ok {
+					missing[msg] = fset.Position(lit.Pos()).String()
+				}
+				return true
+			})
+		}
+	}
+
+	var lines []string
+	for msg, pos := range missing {
+		lines = append(lines, pos+": "+strconv.Quote(msg))
+	}
+	sort.Strings(lines)
+	for _, l := range lines {
+		t.Errorf("no translation: %s", l)
+	}
+}
+
+// isMessageCall reports whether fn is fmt.Errorf, errors.New or tr
+func isMessageCall(fn ast.Expr) bool {
+	switch fn := fn.(type) {
+	case *ast.Ident:
+		return fn.Name == "tr"
+	case *ast.SelectorExpr:
+		pkg, ok := fn.X.(*ast.Ident)
+		return ok && (pkg.Name == "fmt" && fn.Sel.Name == "Errorf" || pkg.Name == "errors" && fn.Sel.Name == "New")
+	}
+	return false
+}
+
+// hasWords reports whether a format has text of its own besides verbs, like "%s: %v"
+// has not
+func hasWords(format string) bool {
+	return strings.IndexFunc(formatVerb.ReplaceAllString(format, ""), unicode.IsLetter) >= 0
+}
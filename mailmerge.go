@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// MailMergeMapping describes how CSV rows are merged into text stamps
+type MailMergeMapping struct {
+	Stamps    []StampInfo       `json:"stamps"`
+	Fields    map[string]string `json:"fields"`    // placeholder name -> CSV column; unmapped names use the column of the same name
+	OutputDir string            `json:"outputDir"` // defaults to a folder in Downloads
+	FileName  string            `json:"fileName"`  // pattern such as "certificate_{name}", without extension
+}
+
+// MailMergeProgress is emitted as "mailmerge:progress" after each row
+type MailMergeProgress struct {
+	JobID   string `json:"jobId"` // pass to ResumeBatch if the job is interrupted
+	Current int    `json:"current"`
+	Total   int    `json:"total"`
+	Output  string `json:"output"`
+	Error   string `json:"error,omitempty"` // set when this item failed
+}
+
+// fieldPlaceholder matches {name}; sequence placeholders contain a colon and are left alone
+var fieldPlaceholder = regexp.MustCompile(`\{([^{}:]+)\}`)
+
+// unsafeFileChars are replaced when row values are used in file names
+var unsafeFileChars = regexp.MustCompile(`[/\\:*?"<>|\x00-\x1f]+`)
+
+// MailMergeStamp generates one stamped PDF per CSV row, replacing {column} placeholders
+// in text stamps with the row values. Rows that fail are reported in the result while
+// the others are still generated.
+func (a *App) MailMergeStamp(pdfTemplate string, csvPath string, mapping MailMergeMapping) (BatchResult, error) {
+	req := mailMergeRequest{Template: filepath.Clean(pdfTemplate), CSV: csvPath, Mapping: mapping}
+	run, err := newBatchRun(BatchMailMerge, req)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	defer run.release()
+	return a.mailMerge(req, run)
+}
+
+// mailMerge runs the rows of a mail merge that the checkpoint has not marked done
+func (a *App) mailMerge(req mailMergeRequest, run *batchRun) (BatchResult, error) {
+	pdfTemplate, mapping := req.Template, req.Mapping
+	defer a.startJob("mailmerge")()
+
+	header, rows, err := readCSV(req.CSV)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	if len(rows) == 0 {
+		return BatchResult{}, fmt.Errorf("csv file has no data rows")
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[strings.TrimSpace(h)] = i
+	}
+
+	outDir, err := a.mergeOutputDir(pdfTemplate, mapping.OutputDir, "_merge")
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	// Output names are settled up front so parallel workers never pick the same file
+	jobs := make([]mergeJob, len(rows))
+	reserved := map[string]bool{}
+	for r, row := range rows {
+		lookup := func(name string) (string, error) {
+			col := name
+			if mapped, ok := mapping.Fields[name]; ok {
+				col = mapped
+			}
+			idx, ok := columns[col]
+			if !ok {
+				return "", fmt.Errorf("unknown column %q", col)
+			}
+			if idx >= len(row) {
+				return "", nil
+			}
+			return strings.TrimSpace(row[idx]), nil
+		}
+
+		stamps, err := mergeStamps(mapping.Stamps, lookup)
+		if err != nil {
+			return BatchResult{}, fmt.Errorf("row %d: %v", r+1, err)
+		}
+
+		name, err := mergeFileName(mapping.FileName, pdfTemplate, r+1, lookup)
+		if err != nil {
+			return BatchResult{}, fmt.Errorf("row %d: %v", r+1, err)
+		}
+		jobs[r] = mergeJob{stamps: stamps, output: reservePath(filepath.Join(outDir, name+".pdf"), reserved)}
+	}
+
+	if err := planJobs(run, jobs); err != nil {
+		return BatchResult{}, err
+	}
+	res := a.runMergeJobs(run, jobs, a.batchWorkersFor(mapping.Stamps), EventMailMergeProgress, func(r int) error {
+		applied, err := a.stampPDFTo(pdfTemplate, jobs[r].output, jobs[r].stamps, StampOptions{}, nil)
+		if err != nil {
+			return fmt.Errorf("row %d: %v", r+1, err)
+		}
+		if err := a.finishOutput(jobs[r].output); err != nil {
+			return fmt.Errorf("row %d: %v", r+1, err)
+		}
+		a.recordStamps(pdfTemplate, jobs[r].output, applied, false)
+		return nil
+	})
+
+	if len(res.Failed) > 0 {
+		a.jobFinished("mailmerge", tr("Mail merge created %d documents, %d failed", len(res.Outputs), len(res.Failed)), true)
+		return res, nil
+	}
+	if len(res.Outputs) == len(jobs) {
+		run.finish()
+	}
+	a.jobFinished("mailmerge", tr("Mail merge created %d documents", len(res.Outputs)), false)
+	return res, nil
+}
+
+// mergeOutputDir returns the output folder for a merge run, creating it if needed.
+// Without an explicit folder the results go to <output folder>/<template><suffix>; a folder
+// that cannot be written is replaced by the local fallback folder.
+func (a *App) mergeOutputDir(template, outDir, suffix string) (string, error) {
+	if outDir == "" {
+		base, err := a.outputBaseDir()
+		if err != nil {
+			return "", err
+		}
+		outDir = filepath.Join(base, outputName(template, suffix, ""))
+	}
+	return a.outputDir(filepath.Clean(outDir))
+}
+
+// mergeStamps returns a copy of the stamps with the placeholders of text stamps filled in
+func mergeStamps(stamps []StampInfo, lookup func(string) (string, error)) ([]StampInfo, error) {
+	out := make([]StampInfo, len(stamps))
+	for i, s := range stamps {
+		out[i] = s
+		if s.Kind != StampKindText {
+			continue
+		}
+		text, err := fillPlaceholders(s.Text, lookup)
+		if err != nil {
+			return nil, err
+		}
+		out[i].Text = text
+	}
+	return out, nil
+}
+
+// mergeFileName builds the file name (without extension) for one merged record.
+// Without a pattern the records are numbered after the template.
+func mergeFileName(pattern, template string, n int, lookup func(string) (string, error)) (string, error) {
+	if pattern == "" {
+		return outputName(template, fmt.Sprintf("_%03d", n), ""), nil
+	}
+	filled, err := fillPlaceholders(pattern, lookup)
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(unsafeFileChars.ReplaceAllString(filled, "_"))
+	name = fileBase(name, len(".pdf")+uniqueSuffixBytes)
+	if name == "" {
+		name = fmt.Sprintf("%03d", n)
+	}
+	return name, nil
+}
+
+// readCSV returns the header and data rows of a CSV file
+func readCSV(path string) ([]string, [][]string, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open csv: %v", err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse csv: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("csv file is empty")
+	}
+
+	header := records[0]
+	if len(header) > 0 {
+		// Excel writes a byte order mark in front of the first column name
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	return header, records[1:], nil
+}
+
+// fillPlaceholders replaces {name} placeholders using lookup. Without a field of that
+// name {date} is left for stamping, which fills in the date.
+func fillPlaceholders(text string, lookup func(string) (string, error)) (string, error) {
+	var firstErr error
+	out := fieldPlaceholder.ReplaceAllStringFunc(text, func(m string) string {
+		v, err := lookup(strings.TrimSpace(m[1 : len(m)-1]))
+		if err != nil && datePlaceholder.MatchString(m) {
+			return m
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return v
+	})
+	return out, firstErr
+}
+
+// mergeJob is one document of a merge run
+type mergeJob struct {
+	stamps []StampInfo
+	output string
+}
+
+// planJobs records the job outputs in the checkpoint, switching to the outputs of the
+// first run when the job is resumed
+func planJobs(run *batchRun, jobs []mergeJob) error {
+	outputs := make([]string, len(jobs))
+	for i, j := range jobs {
+		outputs[i] = j.output
+	}
+	planned, err := run.plan(outputs)
+	if err != nil {
+		return err
+	}
+	for i := range jobs {
+		jobs[i].output = planned[i]
+	}
+	return nil
+}
+
+// reservePath works like uniquePath but also avoids the paths already handed out in
+// this run, which do not exist on disk yet
+func reservePath(path string, reserved map[string]bool) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	candidate := path
+	for counter := 1; ; counter++ {
+		if _, err := os.Stat(candidate); os.IsNotExist(err) && !reserved[candidate] {
+			reserved[candidate] = true
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, counter, ext)
+	}
+}
+
+// uniquePath appends " (n)" before the extension until the path does not exist
+func uniquePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	candidate := path
+	for counter := 1; ; counter++ {
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, counter, ext)
+	}
+}
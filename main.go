@@ -2,6 +2,7 @@ package main
 
 import (
 	"embed"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -12,19 +13,34 @@ import (
 var assets embed.FS
 
 func main() {
+	// A copy of the binary installs updates after the app has quit
+	if runUpdater(os.Args) {
+		return
+	}
+	captureLog()
+
 	// Create an instance of the app structure
 	app := NewApp()
+	kind := windowKinds[app.window.Kind]
 
 	// Create application with options
 	err := wails.Run(&options.App{
-		Title:  "CapGo",
-		Width:  1024,
-		Height: 768,
+		Title:  app.window.Title,
+		Width:  kind.width,
+		Height: kind.height,
+		// In menu-bar mode the main window stays hidden until it is asked for
+		StartHidden: app.window.Kind == WindowMain && app.GetQuickStamp().MenuBarMode,
 		AssetServer: &assetserver.Options{
 			Assets: assets,
 		},
+		Menu:             app.applicationMenu(),
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.startup,
+		OnShutdown:       app.shutdown,
+		OnBeforeClose:    app.beforeClose,
+		ErrorFormatter:   localizeError,
+		// A second launch hands its files to the running instance and exits
+		SingleInstanceLock: app.singleInstanceLock(),
 		DragAndDrop: &options.DragAndDrop{
 			EnableFileDrop:     true,
 			DisableWebViewDrop: false,
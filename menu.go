@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/wailsapp/wails/v2/pkg/menu"
+	"github.com/wailsapp/wails/v2/pkg/menu/keys"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// DocumentState is the state of the open document that drives the menu bar
+type DocumentState struct {
+	Path    string `json:"path"`  // empty when no document is open
+	Pages   int    `json:"pages"` // counted by the backend when 0
+	Dirty   bool   `json:"dirty"` // there are stamps that have not been saved
+	CanUndo bool   `json:"canUndo"`
+	CanRedo bool   `json:"canRedo"`
+}
+
+// appMenu holds the menu items whose state follows the document
+type appMenu struct {
+	bar   *menu.Menu
+	save  *menu.MenuItem
+	undo  *menu.MenuItem
+	redo  *menu.MenuItem
+	split *menu.MenuItem
+	// hotkeys holds the item of every hotkey action, see hotkeyMenu
+	hotkeys map[string]*menu.MenuItem
+}
+
+// applicationMenu builds the menu bar. Actions that need the editor state are sent to the
+// frontend as "menu:<action>" events; file actions are handled here.
+func (a *App) applicationMenu() *menu.Menu {
+	bar := menu.NewMenu()
+	bar.Append(menu.AppMenu())
+
+	file := bar.AddSubmenu(tr("File"))
+	file.AddText(tr("Open…"), keys.CmdOrCtrl("o"), func(*menu.CallbackData) { a.menuOpen() })
+	a.menu.save = file.AddText(tr("Save"), keys.CmdOrCtrl("s"), func(*menu.CallbackData) { a.emit(EventMenuSave) })
+
+	edit := bar.AddSubmenu(tr("Edit"))
+	a.menu.undo = edit.AddText(tr("Undo"), keys.CmdOrCtrl("z"), func(*menu.CallbackData) { a.emit(EventMenuUndo) })
+	a.menu.redo = edit.AddText(tr("Redo"), keys.Combo("z", keys.CmdOrCtrlKey, keys.ShiftKey), func(*menu.CallbackData) { a.emit(EventMenuRedo) })
+
+	tools := bar.AddSubmenu(tr("Tools"))
+	tools.AddText(tr("Merge PDFs…"), keys.Combo("m", keys.CmdOrCtrlKey, keys.ShiftKey), func(*menu.CallbackData) { a.menuMerge() })
+	a.menu.split = tools.AddText(tr("Split…"), keys.Combo("e", keys.CmdOrCtrlKey, keys.ShiftKey), func(*menu.CallbackData) { a.emit(EventMenuSplit) })
+
+	a.quickStampMenu(bar)
+	bar.Append(menu.WindowMenu())
+
+	a.menu.bar = bar
+	a.applyMenuState(DocumentState{})
+	return bar
+}
+
+// SetDocumentState updates the menu bar to match the open document
+func (a *App) SetDocumentState(state DocumentState) error {
+	if state.Path != "" {
+		state.Path = filepath.Clean(state.Path)
+		if err := a.ensureLocal(state.Path); err != nil {
+			return err
+		}
+		if state.Pages == 0 {
+			pages, err := api.PageCountFile(state.Path)
+			if err != nil {
+				return fmt.Errorf("failed to count pages: %v", err)
+			}
+			state.Pages = pages
+		}
+	}
+
+	a.mu.Lock()
+	a.doc = state
+	a.mu.Unlock()
+
+	a.applyMenuState(state)
+	if a.ctx != nil && a.menu.bar != nil {
+		runtime.MenuUpdateApplicationMenu(a.ctx)
+	}
+	a.emit(EventDocumentChanged, state)
+	return nil
+}
+
+// GetDocumentState returns the document state last reported to the backend
+func (a *App) GetDocumentState() DocumentState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.doc
+}
+
+// applyMenuState enables the menu items that apply to the document
+func (a *App) applyMenuState(state DocumentState) {
+	if a.menu.bar == nil {
+		return
+	}
+	open := state.Path != ""
+	setEnabled(a.menu.save, open && state.Dirty)
+	setEnabled(a.menu.undo, open && state.CanUndo)
+	setEnabled(a.menu.redo, open && state.CanRedo)
+	setEnabled(a.menu.split, open && state.Pages > 1)
+}
+
+func setEnabled(item *menu.MenuItem, enabled bool) {
+	if enabled {
+		item.Enable()
+	} else {
+		item.Disable()
+	}
+}
+
+// menuOpen lets the user pick a PDF and hands it to the frontend
+func (a *App) menuOpen() {
+	path, err := a.SelectFile("PDF Documents", "*.pdf")
+	if err != nil || path == "" {
+		return
+	}
+	if err := a.SetDocumentState(DocumentState{Path: path}); err != nil {
+		a.emit(EventMenuError, err.Error())
+		return
+	}
+	a.emit(EventMenuOpen, path)
+}
+
+// menuMerge lets the user pick PDFs and merges them in the order they were selected
+func (a *App) menuMerge() {
+	files, err := a.SelectFiles("PDF Documents", "*.pdf")
+	if err != nil || len(files) == 0 {
+		return
+	}
+	output, err := a.MergePDFs(files)
+	if err != nil {
+		a.emit(EventMenuError, err.Error())
+		return
+	}
+	a.emit(EventMenuMerged, output)
+}
+
+// MergePDFs combines the given PDFs in order and returns the path of the new file, written
+// next to the other CapGo output
+func (a *App) MergePDFs(files []string) (string, error) {
+	return a.mergePDFs(files, nil)
+}
+
+// mergePDFs is MergePDFs with a progress that can cancel it
+func (a *App) mergePDFs(files []string, progress *jobProgress) (string, error) {
+	if len(files) < 2 {
+		return "", fmt.Errorf("select at least two PDFs to merge")
+	}
+	for i, f := range files {
+		files[i] = filepath.Clean(f)
+	}
+
+	outputPath, err := a.outputPath(files[0], "_merged")
+	if err != nil {
+		return "", err
+	}
+	_, err = a.withTimeout(progress.context(), "merging", files[0], outputPath, func(in, out string) (interface{}, error) {
+		if err := api.MergeCreateFile(append([]string{in}, files[1:]...), out, false, nil); err != nil {
+			return nil, fmt.Errorf("failed to merge pdfs: %v", err)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	if err := a.finishOutput(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	return outputPath, nil
+}
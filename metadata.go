@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// DocumentMetadata holds document properties to write into a PDF. Empty fields leave the
+// existing value alone.
+type DocumentMetadata struct {
+	Title    string `json:"title,omitempty"`
+	Author   string `json:"author,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	Keywords string `json:"keywords,omitempty"`
+	// Language sets the document language (/Lang), e.g. "en-US"
+	Language string `json:"language,omitempty"`
+	// DisplayTitle makes viewers show the title instead of the file name
+	DisplayTitle bool `json:"displayTitle,omitempty"`
+}
+
+var languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,8}(-[A-Za-z0-9]{1,8})*$`)
+
+// SetDocumentMetadata writes the given properties into a copy of the PDF next to the other
+// CapGo output
+func (a *App) SetDocumentMetadata(pdfPath string, meta DocumentMetadata) (string, error) {
+	pdfPath = filepath.Clean(pdfPath)
+
+	outputPath, err := a.outputPath(pdfPath, "_meta")
+	if err != nil {
+		return "", err
+	}
+	if err := applyDocumentMetadata(pdfPath, outputPath, meta); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	if err := a.finishOutput(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	recordDerivedHistory(pdfPath, outputPath, "metadata")
+	return outputPath, nil
+}
+
+// applyDocumentMetadata writes pdfPath with the given properties to outputPath, which may
+// be the same file
+func applyDocumentMetadata(pdfPath, outputPath string, meta DocumentMetadata) error {
+	lang := strings.TrimSpace(meta.Language)
+	if lang != "" && !languageTagPattern.MatchString(lang) {
+		return fmt.Errorf("invalid language tag: %s", meta.Language)
+	}
+
+	ctx, err := api.ReadContextFile(pdfPath)
+	if err != nil {
+		return fmt.Errorf("failed to read pdf: %v", err)
+	}
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return fmt.Errorf("failed to read catalog: %v", err)
+	}
+	if meta.DisplayTitle && strings.TrimSpace(meta.Title) == "" && documentTitle(ctx) == "" {
+		// Displaying an empty title would leave the window without any name
+		return fmt.Errorf("a title is needed to display it")
+	}
+
+	info, err := infoDict(ctx)
+	if err != nil {
+		return err
+	}
+	for key, value := range map[string]string{
+		"Title":    meta.Title,
+		"Author":   meta.Author,
+		"Subject":  meta.Subject,
+		"Keywords": meta.Keywords,
+	} {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		s, err := pdfTextString(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %v", strings.ToLower(key), err)
+		}
+		info.Update(key, s)
+	}
+
+	if lang != "" {
+		s, err := pdfTextString(lang)
+		if err != nil {
+			return fmt.Errorf("failed to encode language: %v", err)
+		}
+		catalog.Update("Lang", s)
+	}
+
+	if meta.DisplayTitle {
+		if o, found := catalog.Find("ViewerPreferences"); found {
+			prefs, err := ctx.DereferenceDict(o)
+			if err != nil {
+				return fmt.Errorf("failed to read viewer preferences: %v", err)
+			}
+			prefs.Update("DisplayDocTitle", types.Boolean(true))
+		} else {
+			catalog.Insert("ViewerPreferences", types.Dict{"DisplayDocTitle": types.Boolean(true)})
+		}
+	}
+
+	if err := api.WriteContextFile(ctx, outputPath); err != nil {
+		return fmt.Errorf("failed to write pdf: %v", err)
+	}
+	return nil
+}
+
+// infoDict returns the document information dictionary, creating it when missing
+func infoDict(ctx *model.Context) (types.Dict, error) {
+	if ctx.Info != nil {
+		d, err := ctx.DereferenceDict(*ctx.Info)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read document info: %v", err)
+		}
+		if d != nil {
+			return d, nil
+		}
+	}
+	d := types.Dict{}
+	ir, err := ctx.IndRefForNewObject(d)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Info = ir
+	return d, nil
+}
+
+// documentTitle returns the title from the document information dictionary
+func documentTitle(ctx *model.Context) string {
+	if ctx.Info == nil {
+		return ""
+	}
+	d, err := ctx.DereferenceDict(*ctx.Info)
+	if err != nil || d == nil {
+		return ""
+	}
+	return strings.TrimSpace(infoText(ctx, d, "Title"))
+}
+
+// pdfTextString encodes s as a PDF text string, using UTF-16 only when s is not ASCII
+func pdfTextString(s string) (types.StringLiteral, error) {
+	for _, r := range s {
+		if r > 0x7E {
+			e, err := types.EscapedUTF16String(s)
+			if err != nil {
+				return "", err
+			}
+			return types.StringLiteral(*e), nil
+		}
+	}
+	e, err := types.Escape(s)
+	if err != nil {
+		return "", err
+	}
+	return types.StringLiteral(*e), nil
+}
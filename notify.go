@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	goruntime "runtime"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// JobFinished is emitted as "job:finished" when a background job completes
+type JobFinished struct {
+	Job     string `json:"job"` // e.g. "mailmerge", "certificates", "download"
+	Message string `json:"message"`
+	Failed  bool   `json:"failed"`
+}
+
+// PostNotification shows a native desktop notification
+func (a *App) PostNotification(title, body string) error {
+	var cmd *exec.Cmd
+	switch goruntime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf(`display notification %s with title %s`, appleScriptString(body), appleScriptString(title))
+		cmd = exec.Command("osascript", "-e", script)
+	case "linux":
+		cmd = exec.Command("notify-send", "--app-name=CapGo", title, body)
+	case "windows":
+		script := fmt.Sprintf(`[reflection.assembly]::loadwithpartialname('System.Windows.Forms') | Out-Null;`+
+			`$n = New-Object System.Windows.Forms.NotifyIcon;`+
+			`$n.Icon = [System.Drawing.SystemIcons]::Information;`+
+			`$n.Visible = $true;`+
+			`$n.ShowBalloonTip(5000, %s, %s, 'Info');`+
+			`Start-Sleep -Seconds 6; $n.Dispose()`, powerShellString(title), powerShellString(body))
+		cmd = exec.Command("powershell", "-NoProfile", "-WindowStyle", "Hidden", "-Command", script)
+		return cmd.Start()
+	default:
+		return fmt.Errorf("notifications are not supported on %s", goruntime.GOOS)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to post notification: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// SetBadgeCount shows the number of finished jobs waiting for the user, 0 clears it.
+// Wails v2 has no dock badge API, so the count is shown in the window title.
+func (a *App) SetBadgeCount(count int) {
+	if count < 0 {
+		count = 0
+	}
+	a.mu.Lock()
+	a.badge = count
+	a.mu.Unlock()
+
+	if a.ctx == nil {
+		return
+	}
+	title := a.window.Title
+	if count > 0 {
+		title = fmt.Sprintf("%s (%d)", title, count)
+	}
+	runtime.WindowSetTitle(a.ctx, title)
+	a.emit(EventBadgeChanged, count)
+}
+
+// GetBadgeCount returns the current badge count
+func (a *App) GetBadgeCount() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.badge
+}
+
+// jobFinished reports the end of a background job. While the window is hidden or
+// minimised the user also gets a notification and the badge count goes up.
+func (a *App) jobFinished(job, message string, failed bool) {
+	a.emit(EventJobFinished, JobFinished{Job: job, Message: message, Failed: failed})
+	if !a.windowHidden() {
+		return
+	}
+	a.SetBadgeCount(a.GetBadgeCount() + 1)
+	title := "CapGo"
+	if failed {
+		title = tr("CapGo: job failed")
+	}
+	if err := a.PostNotification(title, message); err != nil {
+		fmt.Printf("Backend: %v\n", err)
+	}
+}
+
+// windowHidden reports whether the user cannot currently see the window
+func (a *App) windowHidden() bool {
+	a.mu.Lock()
+	hidden := a.hidden
+	a.mu.Unlock()
+	if hidden {
+		return true
+	}
+	return a.ctx != nil && runtime.WindowIsMinimised(a.ctx)
+}
+
+// showWindow brings the window back and clears the badge
+func (a *App) showWindow() {
+	a.mu.Lock()
+	a.hidden = false
+	a.mu.Unlock()
+	if a.ctx != nil {
+		runtime.WindowShow(a.ctx)
+	}
+	a.SetBadgeCount(0)
+}
+
+// hideWindow hides the window, keeping CapGo running
+func (a *App) hideWindow() {
+	a.mu.Lock()
+	a.hidden = true
+	a.mu.Unlock()
+	if a.ctx != nil {
+		runtime.WindowHide(a.ctx)
+	}
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func powerShellString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
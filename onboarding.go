@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"CapGo/internal/pdfcanvas"
+	"CapGo/pkg/stamper"
+)
+
+// OnboardingState tracks the guided tour shown on first launch
+type OnboardingState struct {
+	Completed   bool      `json:"completed"`
+	Step        string    `json:"step,omitempty"` // last tour step the user reached, chosen by the frontend
+	CompletedAt time.Time `json:"completedAt,omitempty"`
+}
+
+// OnboardingInfo tells the frontend whether to start or resume the guided tour
+type OnboardingInfo struct {
+	FirstRun bool `json:"firstRun"` // the tour has not been completed or skipped yet
+	OnboardingState
+}
+
+// SampleDocument is the document the guided tour stamps: a one page agreement, a
+// signature image and where to place it
+type SampleDocument struct {
+	PDF       string    `json:"pdf"`
+	Signature string    `json:"signature"`
+	Stamp     StampInfo `json:"stamp"` // suggested placement of the signature
+}
+
+// Sample document layout, in points on a US Letter page
+const (
+	sampleWidth     = 612.0
+	sampleHeight    = 792.0
+	sampleMargin    = 72.0
+	sampleSignLineY = 200.0 // baseline of the signature line, from the bottom
+)
+
+// GetOnboarding returns the onboarding state
+func (a *App) GetOnboarding() OnboardingInfo {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state := *a.settings.Onboarding
+	return OnboardingInfo{FirstRun: !state.Completed, OnboardingState: state}
+}
+
+// SetOnboardingStep remembers how far the user got, so the tour can resume after a restart
+func (a *App) SetOnboardingStep(step string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state := *a.settings.Onboarding
+	state.Step = step
+	a.settings.Onboarding = &state
+	return a.saveSettings()
+}
+
+// CompleteOnboarding marks the tour as finished or skipped
+func (a *App) CompleteOnboarding() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settings.Onboarding = &OnboardingState{Completed: true, CompletedAt: time.Now()}
+	return a.saveSettings()
+}
+
+// ResetOnboarding shows the tour again on the next call to GetOnboarding and recreates
+// the sample files
+func (a *App) ResetOnboarding() error {
+	if dir, err := onboardingDir(); err == nil {
+		os.RemoveAll(dir)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settings.Onboarding = &OnboardingState{}
+	return a.saveSettings()
+}
+
+// GetSampleDocument returns the sample PDF and signature for the guided tour, creating
+// them in the config folder on first use
+func (a *App) GetSampleDocument() (SampleDocument, error) {
+	dir, err := onboardingDir()
+	if err != nil {
+		return SampleDocument{}, err
+	}
+	// Each language gets its own files, named in that language
+	pdfPath := filepath.Join(dir, tr("Sample Agreement")+".pdf")
+	sigPath := filepath.Join(dir, tr("Sample Signature")+".png")
+
+	if _, err := os.Stat(pdfPath); err != nil {
+		if err := os.WriteFile(pdfPath, samplePDF(), 0644); err != nil {
+			return SampleDocument{}, fmt.Errorf("failed to write sample document: %v", err)
+		}
+	}
+	if _, err := os.Stat(sigPath); err != nil {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, sampleSignature()); err != nil {
+			return SampleDocument{}, err
+		}
+		if err := os.WriteFile(sigPath, buf.Bytes(), 0644); err != nil {
+			return SampleDocument{}, fmt.Errorf("failed to write sample signature: %v", err)
+		}
+	}
+
+	// The signature sits on the line, with its lower edge slightly below it
+	const w, h = 180.0, 60.0
+	return SampleDocument{
+		PDF:       pdfPath,
+		Signature: sigPath,
+		Stamp: StampInfo{
+			Stamp: stamper.Stamp{
+				Kind:    StampKindImage,
+				Image:   sigPath,
+				X:       sampleMargin + 8,
+				Y:       sampleHeight - sampleSignLineY - h + 10,
+				Width:   w,
+				Height:  h,
+				PageNum: 1,
+			},
+		},
+	}, nil
+}
+
+func onboardingDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "onboarding")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create onboarding directory: %v", err)
+	}
+	return dir, nil
+}
+
+// samplePDF lays out a short agreement with a signature and a date line
+func samplePDF() []byte {
+	const (
+		bold    = "Helvetica-Bold"
+		regular = "Helvetica"
+	)
+	c := pdfcanvas.New(sampleWidth, sampleHeight)
+	textW := sampleWidth - 2*sampleMargin
+	black := pdfcanvas.RGB{}
+	gray := pdfcanvas.RGB{R: 0.4, G: 0.4, B: 0.4}
+
+	y := sampleHeight - sampleMargin - 24
+	c.SetFillColor(black)
+	c.Text(sampleMargin, y, bold, 24, tr("Sample Agreement"), pdfcanvas.TextFill)
+	y -= 22
+	c.SetFillColor(gray)
+	c.Text(sampleMargin, y, regular, 11, tr("A practice document for trying out CapGo"), pdfcanvas.TextFill)
+
+	body := tr("This agreement is made between CapGo and you, the reader, for the sole purpose of learning how to sign documents.") + "\n\n" +
+		tr("1. Drag the sample signature onto the line below and resize it until it fits.") + "\n" +
+		tr("2. Add the date next to it with a text stamp.") + "\n" +
+		tr("3. Save the signed copy. The original file stays untouched.") + "\n\n" +
+		tr("Nothing in this document is binding. You can reopen it from the help menu at any time.")
+	y -= 40
+	c.SetFillColor(black)
+	for _, line := range wrapText(body, regular, 12, textW) {
+		c.Text(sampleMargin, y, regular, 12, line, pdfcanvas.TextFill)
+		y -= 18
+	}
+
+	// Signature and date lines
+	c.SetStrokeColor(black)
+	c.SetLineWidth(0.75)
+	c.Op("%.4f %.4f m %.4f %.4f l", sampleMargin, sampleSignLineY, sampleMargin+220, sampleSignLineY)
+	c.Op("%.4f %.4f m %.4f %.4f l", sampleWidth-sampleMargin-150, sampleSignLineY, sampleWidth-sampleMargin, sampleSignLineY)
+	c.Stroke()
+	c.SetFillColor(gray)
+	c.Text(sampleMargin, sampleSignLineY-14, regular, 10, tr("Signature"), pdfcanvas.TextFill)
+	c.Text(sampleWidth-sampleMargin-150, sampleSignLineY-14, regular, 10, tr("Date"), pdfcanvas.TextFill)
+	return pdfcanvas.Render(c)
+}
+
+// sampleSignature draws a handwritten looking scribble on a transparent background
+func sampleSignature() image.Image {
+	const w, h = 540, 180
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	ink := color.NRGBA{R: 0x1a, G: 0x2a, B: 0x6c}
+
+	// A pen of radius r is moved along a loopy curve; coverage is antialiased by
+	// distance, keeping the darkest value where strokes overlap
+	const r = 3.5
+	dot := func(cx, cy float64) {
+		for y := int(cy - r - 1); y <= int(cy+r+1); y++ {
+			for x := int(cx - r - 1); x <= int(cx+r+1); x++ {
+				if x < 0 || y < 0 || x >= w || y >= h {
+					continue
+				}
+				d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy)
+				a := math.Max(0, math.Min(1, r+0.5-d))
+				if alpha := uint8(a * 255); alpha > img.NRGBAAt(x, y).A {
+					img.SetNRGBA(x, y, color.NRGBA{R: ink.R, G: ink.G, B: ink.B, A: alpha})
+				}
+			}
+		}
+	}
+	for t := 0.0; t <= 1; t += 0.0002 {
+		x := 30 + 470*t + 28*math.Sin(t*math.Pi*14)
+		y := 95 - 45*math.Cos(t*math.Pi*14)*math.Sin(t*math.Pi*1.2+0.3) + 20*t
+		dot(x, y)
+	}
+	// Underline flourish
+	for t := 0.0; t <= 1; t += 0.0005 {
+		dot(60+420*t, 150-12*math.Sin(t*math.Pi))
+	}
+	return img
+}
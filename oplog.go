@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"CapGo/internal/pdfcanvas"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// maxOperationLogSteps bounds how far the operation log follows sources back
+const maxOperationLogSteps = 100
+
+// operationLabels names the operations recorded in the history store
+var operationLabels = map[string]string{
+	"stamp":             "Stamped",
+	"metadata":          "Document properties changed",
+	"optimize":          "Optimized",
+	"resize":            "Resized to another paper size",
+	"encrypt":           "Encrypted",
+	"sign":              "Digitally signed",
+	"remove_watermarks": "Watermarks removed",
+	"operation_log":     "Operation log appended",
+}
+
+// AppendOperationLog adds pages to the end of a document listing every CapGo operation
+// that led to it, oldest first, and returns the path of the new file. The log is built
+// from the history store, following each record to the document it was made from for
+// as long as that document is unchanged.
+func (a *App) AppendOperationLog(pdfPath string) (_ string, err error) {
+	pdfPath = filepath.Clean(pdfPath)
+	defer recoverDamaged(pdfPath, &err)
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return "", err
+	}
+	steps, err := operationSteps(pdfPath)
+	if err != nil {
+		return "", err
+	}
+	if len(steps) == 0 {
+		return "", fmt.Errorf("CapGo has no history for %s", filepath.Base(pdfPath))
+	}
+	sum, err := fileSHA256(pdfPath)
+	if err != nil {
+		return "", classifyFileError("read", pdfPath, err)
+	}
+
+	dims, err := api.PageDimsFile(pdfPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get page dimensions for %s: %v", pdfPath, err)
+	}
+	if len(dims) == 0 {
+		return "", fmt.Errorf("no page dimensions found for %s", pdfPath)
+	}
+	last := dims[len(dims)-1]
+
+	tmpDir, err := os.MkdirTemp("", "capgo_oplog_*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp folder: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	appendix := filepath.Join(tmpDir, "log.pdf")
+	pages := operationLogCanvases(last.Width, last.Height, filepath.Base(pdfPath), sum, a.now().Format("2006-01-02 15:04"), steps)
+	if err := os.WriteFile(appendix, pdfcanvas.Render(pages...), 0644); err != nil {
+		return "", fmt.Errorf("failed to write operation log: %v", err)
+	}
+
+	outputPath, err := a.outputPath(pdfPath, "_log")
+	if err != nil {
+		return "", err
+	}
+	if err := api.MergeCreateFile([]string{pdfPath, appendix}, outputPath, false, nil); err != nil {
+		os.Remove(outputPath)
+		return "", fmt.Errorf("failed to append operation log: %v", err)
+	}
+	if err := a.finishOutput(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	recordDerivedHistory(pdfPath, outputPath, "operation_log")
+	fmt.Printf("Backend: Appended a log of %d operations to %s\n", len(steps), outputPath)
+	return outputPath, nil
+}
+
+// operationSteps returns the history records that led to a document, oldest first
+func operationSteps(pdfPath string) ([]*DocumentHistory, error) {
+	h, err := lookupHistory(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+	var steps []*DocumentHistory
+	seen := map[string]bool{}
+	for h != nil && !seen[h.SHA256] && len(steps) < maxOperationLogSteps {
+		seen[h.SHA256] = true
+		steps = append(steps, h)
+		// A source that was moved, changed or never written by CapGo ends the chain
+		h, _ = lookupHistory(h.Source)
+	}
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return steps, nil
+}
+
+// addedStamps returns the stamps of a record that its source did not have yet
+func addedStamps(h, source *DocumentHistory) []HistoryStamp {
+	if source == nil {
+		return h.Stamps
+	}
+	before := map[string]int{}
+	for _, s := range source.Stamps {
+		before[historyStampKey(s)]++
+	}
+	var added []HistoryStamp
+	for _, s := range h.Stamps {
+		if k := historyStampKey(s); before[k] > 0 {
+			before[k]--
+		} else {
+			added = append(added, s)
+		}
+	}
+	return added
+}
+
+func historyStampKey(s HistoryStamp) string {
+	return fmt.Sprintf("%s\x00%d\x00%.2f\x00%.2f\x00%s", s.Template, s.PageNum, s.X, s.Y, s.AppliedAt)
+}
+
+// describeHistoryStamp writes one line about a stamp for the operation log
+func describeHistoryStamp(s HistoryStamp) string {
+	if s.Kind == StampKindText {
+		return tr("Text \"%s\" on page %d", s.Text, s.PageNum)
+	}
+	return tr("Image on page %d", s.PageNum)
+}
+
+// operationLogCanvases lays out the operation log on as many pages as it needs
+func operationLogCanvases(width, height float64, name, sum, generated string, steps []*DocumentHistory) []*pdfcanvas.Canvas {
+	const (
+		bold    = "Helvetica-Bold"
+		regular = "Helvetica"
+		size    = 9.0
+		leading = 13.0
+	)
+	margin := width * 0.1
+	textW := width - 2*margin
+	black := pdfcanvas.RGB{}
+	gray := pdfcanvas.RGB{R: 0.4, G: 0.4, B: 0.4}
+
+	var pages []*pdfcanvas.Canvas
+	var c *pdfcanvas.Canvas
+	var y float64
+	newPage := func() {
+		c = pdfcanvas.New(width, height)
+		pages = append(pages, c)
+		y = height - margin
+	}
+	// line writes one line, starting a new page when the current one is full
+	line := func(x float64, fontName string, fontSize float64, col pdfcanvas.RGB, s string) {
+		if y < margin {
+			newPage()
+		}
+		c.SetFillColor(col)
+		c.Text(x, y, fontName, fontSize, fitText(s, fontName, fontSize, textW-(x-margin)), pdfcanvas.TextFill)
+		y -= fontSize + leading - size
+	}
+
+	newPage()
+	line(margin, bold, 18, black, tr("Operation log"))
+	y -= 6
+	line(margin, regular, size, gray, tr("Document: %s", name))
+	line(margin, regular, size, gray, "SHA-256: "+sum)
+	line(margin, regular, size, gray, tr("Generated by CapGo on %s", generated))
+	y -= leading
+
+	for i, h := range steps {
+		label := operationLabels[h.Operation]
+		if label == "" {
+			label = h.Operation
+		}
+		// Keep the heading of a step together with its first detail line
+		if y-leading < margin {
+			newPage()
+		}
+		line(margin, bold, 11, black, fmt.Sprintf("%d. %s  %s", i+1, h.CreatedAt.Local().Format("2006-01-02 15:04"), tr(label)))
+		indent := margin + 14
+		if h.Source != "" {
+			line(indent, regular, size, gray, tr("From: %s", filepath.Base(h.Source)))
+		}
+		line(indent, regular, size, gray, tr("Written to: %s", filepath.Base(h.Path)))
+		var source *DocumentHistory
+		if i > 0 {
+			source = steps[i-1]
+		}
+		switch {
+		case h.Operation == "remove_watermarks" && source != nil:
+			if n := len(source.Stamps) - len(h.Stamps); n > 0 {
+				line(indent, regular, size, gray, tr("%d stamps removed", n))
+			}
+		case h.Operation == "stamp":
+			for _, s := range addedStamps(h, source) {
+				for _, l := range wrapText(describeHistoryStamp(s), regular, size, textW-(indent-margin)) {
+					line(indent, regular, size, black, l)
+				}
+			}
+		}
+		y -= leading / 2
+	}
+	return pages
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Optimization presets, named after their intended use
+const (
+	OptimizeScreen = "screen" // on-screen reading, smallest files
+	OptimizeEbook  = "ebook"  // readable when zoomed in
+	OptimizePrint  = "print"  // office printing
+)
+
+// optimizePresets set the image resolution and JPEG quality of each preset
+var optimizePresets = map[string]struct {
+	dpi     float64
+	quality int
+}{
+	OptimizeScreen: {72, 50},
+	OptimizeEbook:  {150, 70},
+	OptimizePrint:  {300, 85},
+}
+
+// OptimizeResult reports the savings of OptimizePDF
+type OptimizeResult struct {
+	Output       string `json:"output"`
+	Preset       string `json:"preset"`
+	OriginalSize int64  `json:"originalSize"`
+	Size         int64  `json:"size"`
+	Saved        int64  `json:"saved"`  // bytes, never negative
+	Images       int    `json:"images"` // images that were downsampled or recompressed
+}
+
+// OptimizePDF writes a smaller copy of the PDF to Downloads: the file structure is
+// optimized and images are downsampled to the preset's resolution and recompressed.
+// When that does not help, the copy is identical to the source.
+func (a *App) OptimizePDF(pdfPath, preset string) (OptimizeResult, error) {
+	return a.optimizePDF(pdfPath, preset, nil)
+}
+
+// optimizePDF is OptimizePDF reporting its stages to progress, which can also cancel it
+func (a *App) optimizePDF(pdfPath, preset string, progress *jobProgress) (OptimizeResult, error) {
+	pdfPath = filepath.Clean(pdfPath)
+	p, ok := optimizePresets[preset]
+	if !ok {
+		return OptimizeResult{}, fmt.Errorf("unknown preset %q, expected %s, %s or %s", preset, OptimizeScreen, OptimizeEbook, OptimizePrint)
+	}
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return OptimizeResult{}, err
+	}
+	defer a.startJob("optimize")()
+
+	if err := progress.stage(StageReading); err != nil {
+		return OptimizeResult{}, err
+	}
+	original, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return OptimizeResult{}, classifyFileError("read", pdfPath, err)
+	}
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(original), model.NewDefaultConfiguration())
+	if err != nil {
+		return OptimizeResult{}, fmt.Errorf("failed to read pdf: %v", err)
+	}
+
+	if err := progress.stage(StageImages); err != nil {
+		return OptimizeResult{}, err
+	}
+	maxDim, err := maxImageDimension(ctx, p.dpi)
+	if err != nil {
+		return OptimizeResult{}, err
+	}
+	images, err := recompressImagesScaled(ctx, p.quality, func(b image.Rectangle) float64 {
+		return math.Min(1, maxDim/float64(max(b.Dx(), b.Dy())))
+	})
+	if err != nil {
+		return OptimizeResult{}, err
+	}
+	if err := progress.stage(StageOptimizing); err != nil {
+		return OptimizeResult{}, err
+	}
+	if err := api.OptimizeContext(ctx); err != nil {
+		return OptimizeResult{}, fmt.Errorf("failed to optimize pdf: %v", err)
+	}
+	if err := progress.stage(StageWriting); err != nil {
+		return OptimizeResult{}, err
+	}
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return OptimizeResult{}, fmt.Errorf("failed to write pdf: %v", err)
+	}
+
+	data := buf.Bytes()
+	if len(data) >= len(original) {
+		data, images = original, 0
+	}
+	output, err := a.outputPath(pdfPath, "_optimized")
+	if err != nil {
+		return OptimizeResult{}, err
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return OptimizeResult{}, classifyFileError("write", output, err)
+	}
+	recordDerivedHistory(pdfPath, output, "optimize")
+
+	res := OptimizeResult{
+		Output:       output,
+		Preset:       preset,
+		OriginalSize: int64(len(original)),
+		Size:         int64(len(data)),
+		Images:       images,
+	}
+	res.Saved = res.OriginalSize - res.Size
+	fmt.Printf("Backend: Optimized %s with preset %s, %d -> %d bytes\n", pdfPath, preset, res.OriginalSize, res.Size)
+	return res, nil
+}
+
+// maxImageDimension returns the most pixels an image needs along its long side to fill
+// the largest page of the document at dpi
+func maxImageDimension(ctx *model.Context, dpi float64) (float64, error) {
+	dims, err := ctx.PageDims()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get page dimensions: %v", err)
+	}
+	longSide := 0.0
+	for _, d := range dims {
+		longSide = math.Max(longSide, math.Max(d.Width, d.Height))
+	}
+	if longSide == 0 {
+		longSide = 842 // A4
+	}
+	return longSide / 72 * dpi, nil
+}
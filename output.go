@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// What happens when an output file already exists
+const (
+	OutputIncrement = "increment" // add " (n)" to the new name
+	OutputOverwrite = "overwrite" // replace the existing file
+)
+
+// defaultOutputTemplate names output files like "contract_signed"
+const defaultOutputTemplate = "{name}_{op}"
+
+// OutputSettings controls where CapGo writes the documents it creates and how they are
+// named. The file name template knows {name}, the name of the source without extension,
+// {op}, the operation such as "signed" or "merged", {date} (2006-01-02) and {time} (150405).
+type OutputSettings struct {
+	Dir      string `json:"dir,omitempty"`      // empty writes to Downloads
+	FileName string `json:"fileName,omitempty"` // empty is "{name}_{op}"
+	Conflict string `json:"conflict"`           // OutputIncrement or OutputOverwrite
+}
+
+func defaultOutputSettings() OutputSettings {
+	return OutputSettings{Conflict: OutputIncrement}
+}
+
+// outputTemplatePlaceholder matches the placeholders of a file name template
+var outputTemplatePlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+var outputTemplateFields = map[string]bool{"name": true, "op": true, "date": true, "time": true}
+
+// GetOutputSettings returns the output folder, file name template and conflict handling
+func (a *App) GetOutputSettings() OutputSettings {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.settings.Output
+}
+
+// SetOutputSettings updates and persists the output settings. The folder is created
+// when it does not exist yet.
+func (a *App) SetOutputSettings(output OutputSettings) error {
+	if output.Conflict != OutputIncrement && output.Conflict != OutputOverwrite {
+		return fmt.Errorf("invalid output conflict mode: %q", output.Conflict)
+	}
+	output.FileName = strings.TrimSpace(output.FileName)
+	if strings.ContainsAny(output.FileName, `/\`) {
+		return fmt.Errorf("the file name template cannot contain folders")
+	}
+	for _, m := range outputTemplatePlaceholder.FindAllStringSubmatch(output.FileName, -1) {
+		if !outputTemplateFields[m[1]] {
+			return fmt.Errorf("unknown placeholder %s in the file name template", m[0])
+		}
+	}
+	if output.Dir != "" {
+		if !filepath.IsAbs(output.Dir) {
+			return fmt.Errorf("the output folder must be an absolute path")
+		}
+		output.Dir = filepath.Clean(output.Dir)
+		if err := probeWritable(output.Dir); err != nil {
+			return err
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settings.Output = output
+	return a.saveSettings()
+}
+
+// outputBaseDir returns the configured output folder, Downloads by default
+func (a *App) outputBaseDir() (string, error) {
+	if dir := a.GetOutputSettings().Dir; dir != "" {
+		return dir, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get home directory: %v", err)
+	}
+	return filepath.Join(homeDir, "Downloads"), nil
+}
+
+// outputPath returns the path for a PDF an operation derives from srcPath, named with
+// the file name template in the output folder, or in the local fallback folder when
+// that cannot be written. suffix names the operation, e.g. "_signed". An existing file
+// is only reused in overwrite mode and never when it is the source itself.
+func (a *App) outputPath(srcPath, suffix string) (string, error) {
+	base, err := a.outputBaseDir()
+	if err != nil {
+		return "", err
+	}
+	dir, err := a.outputDir(base)
+	if err != nil {
+		return "", err
+	}
+	settings := a.GetOutputSettings()
+	path := filepath.Join(dir, a.outputFileName(settings.FileName, srcPath, suffix)+".pdf")
+	if settings.Conflict == OutputOverwrite && !samePath(path, srcPath) {
+		return path, nil
+	}
+	return uniquePath(path), nil
+}
+
+// outputFileName fills in the file name template, without extension
+func (a *App) outputFileName(template, srcPath, suffix string) string {
+	if template == "" {
+		template = defaultOutputTemplate
+	}
+	now := a.now()
+	values := map[string]string{
+		"op":   strings.TrimPrefix(suffix, "_"),
+		"date": now.Format("2006-01-02"),
+		"time": now.Format("150405"),
+	}
+	fill := func() string {
+		return outputTemplatePlaceholder.ReplaceAllStringFunc(template, func(m string) string {
+			return values[m[1:len(m)-1]]
+		})
+	}
+	// A long source name is shortened so the rest of the template still fits
+	reserve := len(fill()) + len(".pdf") + uniqueSuffixBytes
+	values["name"] = fileBase(strings.TrimSuffix(filepath.Base(srcPath), filepath.Ext(srcPath)), reserve)
+	name := fill()
+	// Without an operation "{name}_{op}" would end in a separator
+	name = strings.TrimRight(unsafeFileChars.ReplaceAllString(name, "_"), "_- ")
+	name = fileBase(name, len(".pdf")+uniqueSuffixBytes)
+	if name == "" {
+		name = "output"
+	}
+	return name
+}
@@ -0,0 +1,711 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Color usage of a page
+const (
+	PageColorNone  = "none" // nothing is painted
+	PageColorGray  = "grayscale"
+	PageColorColor = "color"
+)
+
+// PageAnalysis describes what a page is made of. Coverage is the share of the page, in
+// percent, under text or images; text boxes are estimated from the font size and the
+// number of glyphs, so they are close but not exact.
+type PageAnalysis struct {
+	Page          int     `json:"page"`
+	TextCoverage  float64 `json:"textCoverage"`
+	ImageCoverage float64 `json:"imageCoverage"`
+	// ImageOnly means the page shows images and has no text at all, e.g. a scan that
+	// was never OCRed. The invisible text OCR adds counts as text.
+	ImageOnly bool       `json:"imageOnly"`
+	Fonts     []PageFont `json:"fonts"`
+	// Color is PageColorNone, PageColorGray or PageColorColor. Images count by their
+	// color space, so an RGB scan of a black and white page counts as color.
+	Color       string   `json:"color"`
+	ColorSpaces []string `json:"colorSpaces"` // color spaces of the images
+}
+
+// PageFont is a font used on a page
+type PageFont struct {
+	Name     string `json:"name"` // without the subset prefix
+	Type     string `json:"type"` // e.g. Type1, TrueType, Type0
+	Embedded bool   `json:"embedded"`
+}
+
+// coverageCells is the number of cells along each side of the grid coverage is
+// measured on
+const coverageCells = 100
+
+// maxFormDepth bounds how deep form XObjects inside form XObjects are followed
+const maxFormDepth = 8
+
+// AnalyzePage reports the text and image coverage, fonts and color usage of a page,
+// e.g. to suggest OCR for scans or grayscale for printing
+func (a *App) AnalyzePage(pdfPath string, page int) (_ PageAnalysis, err error) {
+	pdfPath = filepath.Clean(pdfPath)
+	defer recoverDamaged(pdfPath, &err)
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return PageAnalysis{}, err
+	}
+	ctx, err := api.ReadContextFile(pdfPath)
+	if err != nil {
+		return PageAnalysis{}, fmt.Errorf("failed to read pdf: %v", err)
+	}
+	if err := ctx.EnsurePageCount(); err != nil {
+		return PageAnalysis{}, fmt.Errorf("failed to read pdf: %v", err)
+	}
+	if page < 1 || page > ctx.PageCount {
+		return PageAnalysis{}, fmt.Errorf("page %d does not exist in the %d page document", page, ctx.PageCount)
+	}
+	return analyzePage(ctx, page)
+}
+
+// analyzePage runs the content of a page through a pageAnalyzer
+func analyzePage(ctx *model.Context, page int) (PageAnalysis, error) {
+	d, _, inh, err := ctx.PageDict(page, false)
+	if err != nil || d == nil {
+		return PageAnalysis{}, fmt.Errorf("failed to read page %d: %v", page, err)
+	}
+	box := inh.MediaBox
+	if inh.CropBox != nil {
+		box = inh.CropBox
+	}
+	if box == nil || box.Width() <= 0 || box.Height() <= 0 {
+		box = types.RectForFormat("A4")
+	}
+
+	p := &pageAnalyzer{
+		ctx:         ctx,
+		box:         box,
+		fonts:       map[string]PageFont{},
+		colorSpaces: map[string]bool{},
+	}
+	content, err := ctx.PageContent(d, page)
+	if err != nil && err != model.ErrNoContent {
+		return PageAnalysis{}, fmt.Errorf("failed to read page %d: %v", page, err)
+	}
+	p.run(content, inh.Resources, matrix.IdentMatrix, 0)
+
+	res := PageAnalysis{
+		Page:          page,
+		TextCoverage:  p.text.percent(),
+		ImageCoverage: p.images.percent(),
+		ImageOnly:     p.images.count > 0 && !p.hasText,
+		Fonts:         []PageFont{},
+		Color:         PageColorNone,
+		ColorSpaces:   []string{},
+	}
+	for _, f := range p.fonts {
+		res.Fonts = append(res.Fonts, f)
+	}
+	sort.Slice(res.Fonts, func(i, j int) bool { return res.Fonts[i].Name < res.Fonts[j].Name })
+	for cs := range p.colorSpaces {
+		res.ColorSpaces = append(res.ColorSpaces, cs)
+	}
+	sort.Strings(res.ColorSpaces)
+	switch {
+	case p.colored:
+		res.Color = PageColorColor
+	case p.painted:
+		res.Color = PageColorGray
+	}
+	return res, nil
+}
+
+// coverageGrid records which cells of the page something was drawn on
+type coverageGrid struct {
+	cells [coverageCells * coverageCells]bool
+	count int
+}
+
+// mark covers the cells under the rectangle, given in fractions of the page
+func (g *coverageGrid) mark(x0, y0, x1, y1 float64) {
+	cell := func(v float64) int {
+		return int(math.Max(0, math.Min(coverageCells, v)))
+	}
+	for y := cell(math.Floor(y0 * coverageCells)); y < cell(math.Ceil(y1*coverageCells)); y++ {
+		for x := cell(math.Floor(x0 * coverageCells)); x < cell(math.Ceil(x1*coverageCells)); x++ {
+			if !g.cells[y*coverageCells+x] {
+				g.cells[y*coverageCells+x] = true
+				g.count++
+			}
+		}
+	}
+}
+
+func (g *coverageGrid) percent() float64 {
+	return math.Round(float64(g.count)*1000/(coverageCells*coverageCells)) / 10
+}
+
+// pageAnalyzer follows the graphics and text state of a content stream far enough to
+// tell where text and images end up and which colors are painted
+type pageAnalyzer struct {
+	ctx         *model.Context
+	box         *types.Rectangle
+	text        coverageGrid
+	images      coverageGrid
+	hasText     bool
+	fonts       map[string]PageFont
+	colorSpaces map[string]bool
+	painted     bool
+	colored     bool
+}
+
+// graphicsState is the part of the graphics state the analyzer needs
+type graphicsState struct {
+	ctm                    matrix.Matrix
+	fillColor, strokeColor bool // whether the current colors are not gray
+	fontSize, charSpacing  float64
+	wordSpacing, scale     float64
+	leading, rise          float64
+	renderMode             int
+	twoByte                bool // the current font has two byte character codes
+}
+
+// textColored reports whether text is painted in color with the current render mode
+func (gs graphicsState) textColored() bool {
+	switch gs.renderMode {
+	case 1, 5: // stroke
+		return gs.strokeColor
+	case 2, 6: // fill and stroke
+		return gs.fillColor || gs.strokeColor
+	}
+	return gs.fillColor
+}
+
+// run interprets a content stream with the given resources and initial matrix
+func (p *pageAnalyzer) run(content []byte, resources types.Dict, ctm matrix.Matrix, depth int) {
+	gs := graphicsState{ctm: ctm, scale: 100}
+	var stack []graphicsState
+	tm, tlm := matrix.IdentMatrix, matrix.IdentMatrix
+
+	// showText marks the box of a string in text space and moves the text matrix past it
+	showText := func(s []byte, adjust float64) {
+		glyphs := len(s)
+		if gs.twoByte {
+			glyphs /= 2
+		}
+		width := 0.0
+		for _, c := range s {
+			width += gs.charSpacing
+			if c == ' ' && !gs.twoByte {
+				width += gs.wordSpacing
+			}
+		}
+		width = (float64(glyphs)*gs.fontSize*0.5 + width - adjust/1000*gs.fontSize) * gs.scale / 100
+		if glyphs > 0 {
+			p.hasText = true
+			if gs.renderMode != 3 && gs.renderMode != 7 {
+				m := tm.Multiply(gs.ctm)
+				p.markBox(&p.text, m, 0, gs.rise-0.2*gs.fontSize, width, gs.rise+0.8*gs.fontSize)
+				p.paint(gs.textColored())
+			}
+		}
+		tm = translation(width, 0).Multiply(tm)
+	}
+	nextLine := func(tx, ty float64) {
+		tlm = translation(tx, ty).Multiply(tlm)
+		tm = tlm
+	}
+
+	parseContent(content, func(op string, args []interface{}) {
+		num := func(i int) float64 {
+			if i < len(args) {
+				if f, ok := args[i].(float64); ok {
+					return f
+				}
+			}
+			return 0
+		}
+		switch op {
+		case "q":
+			stack = append(stack, gs)
+		case "Q":
+			if len(stack) > 0 {
+				gs, stack = stack[len(stack)-1], stack[:len(stack)-1]
+			}
+		case "cm":
+			gs.ctm = pdfMatrix(num(0), num(1), num(2), num(3), num(4), num(5)).Multiply(gs.ctm)
+
+		// Colors
+		case "g", "G", "rg", "RG", "k", "K", "sc", "SC", "scn", "SCN":
+			colored := isColored(args)
+			if op == "g" || op == "rg" || op == "k" || op == "sc" || op == "scn" {
+				gs.fillColor = colored
+			} else {
+				gs.strokeColor = colored
+			}
+		case "cs", "CS":
+			// A new color space starts at its initial color, black for the device spaces
+			if op == "cs" {
+				gs.fillColor = false
+			} else {
+				gs.strokeColor = false
+			}
+
+		// Paths, only for the colors they paint
+		case "S", "s":
+			p.paint(gs.strokeColor)
+		case "f", "F", "f*":
+			p.paint(gs.fillColor)
+		case "B", "B*", "b", "b*":
+			p.paint(gs.fillColor || gs.strokeColor)
+		case "sh":
+			// Shadings are almost always colored
+			p.paint(true)
+
+		// Text
+		case "BT":
+			tm, tlm = matrix.IdentMatrix, matrix.IdentMatrix
+		case "Tf":
+			gs.fontSize = num(1)
+			if len(args) > 0 {
+				if name, ok := args[0].(contentName); ok {
+					gs.twoByte = p.useFont(resources, string(name))
+				}
+			}
+		case "Tc":
+			gs.charSpacing = num(0)
+		case "Tw":
+			gs.wordSpacing = num(0)
+		case "Tz":
+			gs.scale = num(0)
+		case "TL":
+			gs.leading = num(0)
+		case "Ts":
+			gs.rise = num(0)
+		case "Tr":
+			gs.renderMode = int(num(0))
+		case "Td":
+			nextLine(num(0), num(1))
+		case "TD":
+			gs.leading = -num(1)
+			nextLine(num(0), num(1))
+		case "Tm":
+			tlm = pdfMatrix(num(0), num(1), num(2), num(3), num(4), num(5))
+			tm = tlm
+		case "T*":
+			nextLine(0, -gs.leading)
+		case "Tj":
+			if len(args) > 0 {
+				s, _ := args[0].([]byte)
+				showText(s, 0)
+			}
+		case "'", "\"":
+			if op == "\"" && len(args) == 3 {
+				gs.wordSpacing, gs.charSpacing = num(0), num(1)
+				args = args[2:]
+			}
+			nextLine(0, -gs.leading)
+			if len(args) > 0 {
+				s, _ := args[0].([]byte)
+				showText(s, 0)
+			}
+		case "TJ":
+			if len(args) > 0 {
+				items, _ := args[0].([]interface{})
+				for _, item := range items {
+					switch v := item.(type) {
+					case []byte:
+						showText(v, 0)
+					case float64:
+						showText(nil, v)
+					}
+				}
+			}
+
+		// Images and forms
+		case "Do":
+			if len(args) > 0 {
+				if name, ok := args[0].(contentName); ok {
+					p.drawXObject(resources, string(name), gs, depth)
+				}
+			}
+		case "BI":
+			p.markBox(&p.images, gs.ctm, 0, 0, 1, 1)
+			p.inlineImage(args, gs)
+		}
+	})
+}
+
+// drawXObject marks an image or runs a form
+func (p *pageAnalyzer) drawXObject(resources types.Dict, name string, gs graphicsState, depth int) {
+	sd := p.resource(resources, "XObject", name)
+	if sd == nil {
+		return
+	}
+	switch subtype := sd.Dict.NameEntry("Subtype"); {
+	case subtype != nil && *subtype == "Image":
+		p.markBox(&p.images, gs.ctm, 0, 0, 1, 1)
+		if mask := sd.Dict.BooleanEntry("ImageMask"); mask != nil && *mask {
+			// A stencil mask is painted in the fill color
+			p.paint(gs.fillColor)
+			return
+		}
+		space, colored := p.colorSpace(sd.Dict["ColorSpace"])
+		if space != "" {
+			p.colorSpaces[space] = true
+		}
+		p.paint(colored)
+	case subtype != nil && *subtype == "Form" && depth < maxFormDepth:
+		if err := sd.Decode(); err != nil {
+			return
+		}
+		ctm := gs.ctm
+		if arr := sd.Dict.ArrayEntry("Matrix"); len(arr) == 6 {
+			var v [6]float64
+			for i, o := range arr {
+				v[i] = numberValue(p.ctx, o)
+			}
+			ctm = pdfMatrix(v[0], v[1], v[2], v[3], v[4], v[5]).Multiply(ctm)
+		}
+		formResources := resources
+		if d, err := p.ctx.DereferenceDict(sd.Dict["Resources"]); err == nil && d != nil {
+			formResources = d
+		}
+		p.run(sd.Content, formResources, ctm, depth+1)
+	}
+}
+
+// inlineColorSpaces expands the abbreviated color spaces of inline images
+var inlineColorSpaces = map[string]string{"RGB": "DeviceRGB", "CMYK": "DeviceCMYK", "I": "Indexed"}
+
+// inlineImage records the color of an inline image from its abbreviated dictionary
+func (p *pageAnalyzer) inlineImage(args []interface{}, gs graphicsState) {
+	for i := 0; i+1 < len(args); i += 2 {
+		key, _ := args[i].(contentName)
+		switch key {
+		case "IM", "ImageMask":
+			if args[i+1] == true {
+				p.paint(gs.fillColor)
+				return
+			}
+		case "CS", "ColorSpace":
+			space, _ := args[i+1].(contentName)
+			switch space {
+			case "G", "DeviceGray", "CalGray":
+				p.colorSpaces["DeviceGray"] = true
+				p.paint(false)
+			default:
+				if full, ok := inlineColorSpaces[string(space)]; ok {
+					space = contentName(full)
+				}
+				p.colorSpaces[string(space)] = true
+				p.paint(true)
+			}
+			return
+		}
+	}
+	p.paint(false)
+}
+
+// useFont records the font of a Tf operator and reports whether it has two byte codes
+func (p *pageAnalyzer) useFont(resources types.Dict, name string) bool {
+	d, err := p.ctx.DereferenceDict(resourceObject(p.ctx, resources, "Font", name))
+	if err != nil || d == nil {
+		return false
+	}
+	font := PageFont{Name: name}
+	if base := d.NameEntry("BaseFont"); base != nil {
+		font.Name = *base
+		// Subsets are named like ABCDEF+Helvetica
+		if i := strings.IndexByte(font.Name, '+'); i == 6 {
+			font.Name = font.Name[i+1:]
+		}
+	}
+	if subtype := d.NameEntry("Subtype"); subtype != nil {
+		font.Type = *subtype
+	}
+	descriptorOwner := d
+	if font.Type == "Type0" {
+		if arr, err := p.ctx.DereferenceArray(d["DescendantFonts"]); err == nil && len(arr) > 0 {
+			if desc, err := p.ctx.DereferenceDict(arr[0]); err == nil && desc != nil {
+				descriptorOwner = desc
+			}
+		}
+	}
+	font.Embedded = font.Type == "Type3"
+	if fd, err := p.ctx.DereferenceDict(descriptorOwner["FontDescriptor"]); err == nil && fd != nil {
+		for _, key := range []string{"FontFile", "FontFile2", "FontFile3"} {
+			if _, ok := fd.Find(key); ok {
+				font.Embedded = true
+			}
+		}
+	}
+	p.fonts[font.Name] = font
+	return font.Type == "Type0"
+}
+
+// colorSpace returns the name of an image color space and whether it holds color
+func (p *pageAnalyzer) colorSpace(o types.Object) (string, bool) {
+	o, err := p.ctx.Dereference(o)
+	if err != nil || o == nil {
+		return "", false
+	}
+	switch cs := o.(type) {
+	case types.Name:
+		return string(cs), cs != "DeviceGray" && cs != "CalGray"
+	case types.Array:
+		if len(cs) == 0 {
+			return "", false
+		}
+		family, _ := cs[0].(types.Name)
+		switch family {
+		case "ICCBased":
+			if len(cs) > 1 {
+				if sd, _, err := p.ctx.DereferenceStreamDict(cs[1]); err == nil && sd != nil {
+					if n := sd.Dict.IntEntry("N"); n != nil && *n == 1 {
+						return "ICCBased Gray", false
+					}
+				}
+			}
+			return string(family), true
+		case "Indexed":
+			if len(cs) > 1 {
+				base, colored := p.colorSpace(cs[1])
+				return "Indexed " + base, colored
+			}
+		case "CalGray":
+			return string(family), false
+		}
+		return string(family), true
+	}
+	return "", false
+}
+
+// paint records that something was painted, in color or gray
+func (p *pageAnalyzer) paint(colored bool) {
+	p.painted = true
+	p.colored = p.colored || colored
+}
+
+// markBox marks the box from (x0, y0) to (x1, y1), transformed by m, on grid
+func (p *pageAnalyzer) markBox(grid *coverageGrid, m matrix.Matrix, x0, y0, x1, y1 float64) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, pt := range []types.Point{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x0, Y: y1}, {X: x1, Y: y1}} {
+		q := m.Transform(pt)
+		minX, minY = math.Min(minX, q.X), math.Min(minY, q.Y)
+		maxX, maxY = math.Max(maxX, q.X), math.Max(maxY, q.Y)
+	}
+	w, h := p.box.Width(), p.box.Height()
+	grid.mark((minX-p.box.LL.X)/w, (minY-p.box.LL.Y)/h, (maxX-p.box.LL.X)/w, (maxY-p.box.LL.Y)/h)
+}
+
+// resource returns a stream from a resource category, e.g. an XObject by name
+func (p *pageAnalyzer) resource(resources types.Dict, category, name string) *types.StreamDict {
+	sd, _, err := p.ctx.DereferenceStreamDict(resourceObject(p.ctx, resources, category, name))
+	if err != nil {
+		return nil
+	}
+	return sd
+}
+
+// resourceObject returns an entry of a resource category, e.g. a font by name
+func resourceObject(ctx *model.Context, resources types.Dict, category, name string) types.Object {
+	if resources == nil {
+		return nil
+	}
+	d, err := ctx.DereferenceDict(resources[category])
+	if err != nil || d == nil {
+		return nil
+	}
+	return d[name]
+}
+
+// isColored reports whether the operands of a color operator are not a shade of gray.
+// Pattern colors count as color.
+func isColored(args []interface{}) bool {
+	var v []float64
+	for _, a := range args {
+		switch a := a.(type) {
+		case float64:
+			v = append(v, a)
+		case contentName:
+			return true
+		}
+	}
+	const tolerance = 0.01
+	switch len(v) {
+	case 3:
+		return math.Abs(v[0]-v[1]) > tolerance || math.Abs(v[1]-v[2]) > tolerance
+	case 4:
+		// CMYK is gray when cyan, magenta and yellow are equal
+		return math.Abs(v[0]-v[1]) > tolerance || math.Abs(v[1]-v[2]) > tolerance
+	}
+	return false
+}
+
+func numberValue(ctx *model.Context, o types.Object) float64 {
+	o, _ = ctx.Dereference(o)
+	switch v := o.(type) {
+	case types.Integer:
+		return float64(v)
+	case types.Float:
+		return float64(v)
+	}
+	return 0
+}
+
+// pdfMatrix returns the matrix of the operands a b c d e f of cm and Tm
+func pdfMatrix(a, b, c, d, e, f float64) matrix.Matrix {
+	return matrix.Matrix{{a, b, 0}, {c, d, 0}, {e, f, 1}}
+}
+
+func translation(tx, ty float64) matrix.Matrix {
+	return pdfMatrix(1, 0, 0, 1, tx, ty)
+}
+
+// contentName is a name operand of a content stream, without the slash
+type contentName string
+
+// parseContent calls fn for every operator of a content stream with its operands.
+// Strings are []byte, numbers float64, arrays []interface{} and names contentName.
+// An inline image is passed as "BI" with its dictionary as operands.
+func parseContent(b []byte, fn func(op string, operands []interface{})) {
+	var operands []interface{}
+	for i := 0; i < len(b); {
+		switch c := b[i]; {
+		case isPDFWhitespace(c):
+			i++
+		case c == 'B' && isOperator(b, i, "BI"):
+			end := inlineImageEnd(b, i)
+			id := i + 2
+			for id < end && !isOperator(b, id, "ID") {
+				id++
+			}
+			var dict []interface{}
+			for j := i + 2; j < id; {
+				v, next, op := contentToken(b[:id], j)
+				if !op && v != nil {
+					dict = append(dict, v)
+				}
+				j = next
+			}
+			fn("BI", dict)
+			operands = nil
+			i = end
+		default:
+			v, next, op := contentToken(b, i)
+			if op {
+				fn(v.(string), operands)
+				operands = nil
+			} else if v != nil {
+				operands = append(operands, v)
+			}
+			i = next
+		}
+	}
+}
+
+// contentToken reads the token at b[i] and returns it, the index after it and whether
+// it is an operator
+func contentToken(b []byte, i int) (interface{}, int, bool) {
+	for i < len(b) && isPDFWhitespace(b[i]) {
+		i++
+	}
+	if i >= len(b) {
+		return nil, i, false
+	}
+	switch c := b[i]; {
+	case c == '%':
+		for i < len(b) && b[i] != '\n' && b[i] != '\r' {
+			i++
+		}
+		return nil, i, false
+	case c == '(':
+		s, next := literalString(b, i)
+		return s, next, false
+	case c == '<' && i+1 < len(b) && b[i+1] == '<':
+		// Dictionaries are only operands of marked content, which is of no interest
+		for i += 2; ; {
+			for i < len(b) && isPDFWhitespace(b[i]) {
+				i++
+			}
+			if i >= len(b) || b[i] == '>' && i+1 < len(b) && b[i+1] == '>' {
+				return nil, i + 2, false
+			}
+			_, i, _ = contentToken(b, i)
+		}
+	case c == '<':
+		var s []byte
+		digits := 0
+		var cur byte
+		for i++; i < len(b) && b[i] != '>'; i++ {
+			var v byte
+			switch d := b[i]; {
+			case d >= '0' && d <= '9':
+				v = d - '0'
+			case d >= 'a' && d <= 'f':
+				v = d - 'a' + 10
+			case d >= 'A' && d <= 'F':
+				v = d - 'A' + 10
+			default:
+				continue
+			}
+			cur = cur<<4 | v
+			if digits++; digits%2 == 0 {
+				s, cur = append(s, cur), 0
+			}
+		}
+		if digits%2 == 1 {
+			s = append(s, cur<<4)
+		}
+		return s, i + 1, false
+	case c == '[':
+		arr := []interface{}{}
+		for i++; i < len(b) && b[i] != ']'; {
+			v, next, op := contentToken(b, i)
+			if !op && v != nil {
+				arr = append(arr, v)
+			}
+			if next == i {
+				next++
+			}
+			i = next
+			for i < len(b) && isPDFWhitespace(b[i]) {
+				i++
+			}
+		}
+		return arr, i + 1, false
+	case c == '/':
+		j := i + 1
+		for j < len(b) && !isPDFWhitespace(b[j]) && !isPDFDelimiter(b[j]) {
+			j++
+		}
+		return contentName(b[i+1 : j]), j, false
+	case isPDFDelimiter(c):
+		// A stray closing delimiter
+		return nil, i + 1, false
+	}
+	j := i
+	for j < len(b) && !isPDFWhitespace(b[j]) && !isPDFDelimiter(b[j]) {
+		j++
+	}
+	word := string(b[i:j])
+	switch c := word[0]; {
+	case c >= '0' && c <= '9', c == '-', c == '+', c == '.':
+		f, _ := strconv.ParseFloat(word, 64)
+		return f, j, false
+	case word == "true":
+		return true, j, false
+	case word == "false":
+		return false, j, false
+	case word == "null":
+		return nil, j, false
+	}
+	return word, j, true
+}
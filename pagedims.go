@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"CapGo/pkg/stamper"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// GetCropBoxCoordinates reports whether stamp coordinates are measured from the crop box,
+// the part of the page viewers show, rather than from the media box
+func (a *App) GetCropBoxCoordinates() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.settings.CropBoxCoordinates
+}
+
+// SetCropBoxCoordinates chooses whether stamp coordinates are measured from the crop box,
+// which matches what viewers and previews show, or from the media box, as earlier
+// versions and some tools do. It only makes a difference on cropped pages.
+func (a *App) SetCropBoxCoordinates(enabled bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settings.CropBoxCoordinates = enabled
+	return a.saveSettings()
+}
+
+// cropOffsets holds the offset of every page; nil when coordinates are already measured
+// from the crop box
+type cropOffsets []stamper.Offset
+
+// pageFrame converts stamp coordinates to how CapGo places stamps: measured from the top
+// left corner of the crop box of their page as it is displayed
+type pageFrame struct {
+	// heights of the pages in the box coordinates are measured from; nil when no stamp
+	// has a bottom left origin
+	heights []float64
+	offsets cropOffsets
+}
+
+// pageFrameFor reads what converting the stamps on the document at pdfPath takes. The
+// document is only read when a stamp has a bottom left origin or coordinates are
+// measured from the media box.
+func (a *App) pageFrameFor(pdfPath string, stamps []StampInfo) (pageFrame, error) {
+	cropBox := a.GetCropBoxCoordinates()
+	bottomLeft := false
+	for _, s := range stamps {
+		bottomLeft = bottomLeft || s.Origin == StampOriginBottomLeft
+	}
+	if cropBox && !bottomLeft {
+		return pageFrame{}, nil
+	}
+
+	f, err := os.Open(pdfPath)
+	if err != nil {
+		return pageFrame{}, classifyFileError("read", pdfPath, err)
+	}
+	defer f.Close()
+	ctx, err := api.ReadAndValidate(f, model.NewDefaultConfiguration())
+	if err != nil {
+		return pageFrame{}, fmt.Errorf("failed to read pdf: %v", err)
+	}
+	boundaries, err := ctx.PageBoundaries(nil)
+	if err != nil {
+		return pageFrame{}, fmt.Errorf("failed to get page dimensions for %s: %v", pdfPath, err)
+	}
+
+	var frame pageFrame
+	for i, pb := range boundaries {
+		media, crop := pb.MediaBox(), pb.CropBox()
+		if media == nil {
+			return pageFrame{}, fmt.Errorf("page %d has no media box", i+1)
+		}
+		box := crop
+		if !cropBox {
+			box = media
+			frame.offsets = append(frame.offsets, stamper.CropOffset(media, crop, pb.Rot))
+		}
+		if bottomLeft {
+			height := box.Height()
+			if pb.Rot%180 != 0 {
+				height = box.Width()
+			}
+			frame.heights = append(frame.heights, height)
+		}
+	}
+	return frame, nil
+}
+
+// convert returns the stamps measured from the top left corner of the crop box. Stamps
+// with a page selection have to be expanded first.
+func (f pageFrame) convert(stamps []StampInfo) []StampInfo {
+	out := make([]StampInfo, len(stamps))
+	for i, s := range stamps {
+		out[i] = s
+		p := s.PageNum - 1
+		if p < 0 {
+			continue
+		}
+		if s.Origin == StampOriginBottomLeft && p < len(f.heights) {
+			out[i].Y = f.heights[p] - s.Y - s.Height
+			out[i].Origin = ""
+		}
+		if p < len(f.offsets) {
+			out[i].X -= f.offsets[p].X
+			out[i].Y -= f.offsets[p].Y
+		}
+	}
+	return out
+}
+
+// toPageCoordinates is pageFrame.convert for the document at pdfPath
+func (a *App) toPageCoordinates(pdfPath string, stamps []StampInfo) ([]StampInfo, error) {
+	frame, err := a.pageFrameFor(pdfPath, stamps)
+	if err != nil {
+		return nil, err
+	}
+	return frame.convert(stamps), nil
+}
+
+// fromCropBox moves stamps measured from the crop box to the media box
+func (o cropOffsets) fromCropBox(stamps []StampInfo) []StampInfo {
+	if o == nil {
+		return stamps
+	}
+	out := make([]StampInfo, len(stamps))
+	for i, s := range stamps {
+		out[i] = s
+		if s.PageNum >= 1 && s.PageNum <= len(o) {
+			out[i].X += o[s.PageNum-1].X
+			out[i].Y += o[s.PageNum-1].Y
+		}
+	}
+	return out
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"CapGo/pkg/stamper"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// InsertPages creates a copy of targetPdf with pages of sourcePdf spliced in, e.g. an
+// appendix or a replacement page. atIndex is the number of target pages that come before
+// the inserted ones: 0 inserts at the start and the page count of the target appends.
+// sourcePages selects and orders the pages to insert, like UpdatePDFPages; none inserts
+// the whole source. Both files are left unchanged.
+func (a *App) InsertPages(targetPdf, sourcePdf string, atIndex int, sourcePages []string) (_ string, err error) {
+	targetPdf, sourcePdf = filepath.Clean(targetPdf), filepath.Clean(sourcePdf)
+	for _, p := range []string{targetPdf, sourcePdf} {
+		if err := a.ensureLocal(p); err != nil {
+			return "", err
+		}
+	}
+	defer recoverDamaged(targetPdf, &err)
+
+	targetCount, err := api.PageCountFile(targetPdf)
+	if err != nil {
+		return "", fmt.Errorf("failed to read pdf: %v", err)
+	}
+	sourceCount, err := api.PageCountFile(sourcePdf)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", filepath.Base(sourcePdf), err)
+	}
+	if atIndex < 0 || atIndex > targetCount {
+		return "", fmt.Errorf("cannot insert after page %d of the %d page document", atIndex, targetCount)
+	}
+	var inserted []int
+	if len(sourcePages) == 0 {
+		for p := 1; p <= sourceCount; p++ {
+			inserted = append(inserted, p)
+		}
+	} else if inserted, err = api.PagesForPageCollection(sourceCount, sourcePages); err != nil {
+		return "", fmt.Errorf("invalid page selection: %v", err)
+	}
+	if len(inserted) == 0 {
+		return "", fmt.Errorf("none of the selected pages exist in the %d page document", sourceCount)
+	}
+
+	// The source pages follow the target pages in the merged file
+	var order []string
+	for p := 1; p <= atIndex; p++ {
+		order = append(order, strconv.Itoa(p))
+	}
+	for _, p := range inserted {
+		order = append(order, strconv.Itoa(targetCount+p))
+	}
+	for p := atIndex + 1; p <= targetCount; p++ {
+		order = append(order, strconv.Itoa(p))
+	}
+
+	outputPath := tempOutputPath("ins", targetPdf)
+	if _, err := os.Stat(outputPath); err == nil {
+		os.Remove(outputPath)
+	}
+
+	if err := a.runBeforeHooks("insert", targetPdf); err != nil {
+		return "", err
+	}
+	_, err = a.withTimeout(context.Background(), "page insertion", targetPdf, outputPath, func(in, out string) (interface{}, error) {
+		return nil, withRepairFallback(in, func(conf *model.Configuration) error {
+			merged := out + ".merged.pdf"
+			defer os.Remove(merged)
+			if err := api.MergeCreateFile([]string{in, sourcePdf}, merged, false, conf); err != nil {
+				return err
+			}
+			return api.CollectFile(merged, out, order, conf)
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to insert pages: %v", err)
+	}
+	if err := a.finishOutput(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	a.runAfterHooks("insert", targetPdf, outputPath)
+	fmt.Printf("Backend: Inserted %d pages of %s after page %d of %s\n", len(inserted), sourcePdf, atIndex, targetPdf)
+	return outputPath, nil
+}
+
+// InsertBlankPage creates a copy of the PDF with an empty page after afterPage, or before
+// the first page when afterPage is 0, e.g. for signatures or as a separator. size names
+// a paper size, see GetPaperSizes; empty matches the page next to the new one as it is
+// displayed.
+func (a *App) InsertBlankPage(pdfPath string, afterPage int, size string) (_ string, err error) {
+	pdfPath = filepath.Clean(pdfPath)
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return "", err
+	}
+	defer recoverDamaged(pdfPath, &err)
+
+	dims, err := stamper.VisiblePageDimsFile(pdfPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get page dimensions for %s: %v", pdfPath, err)
+	}
+	if len(dims) == 0 {
+		return "", fmt.Errorf("no page dimensions found for %s", pdfPath)
+	}
+	if afterPage < 0 || afterPage > len(dims) {
+		return "", fmt.Errorf("cannot insert after page %d of the %d page document", afterPage, len(dims))
+	}
+	// pdfcpu inserts next to a selected page
+	page, before := afterPage, false
+	if afterPage == 0 {
+		page, before = 1, true
+	}
+	dim := dims[page-1]
+	if size != "" {
+		paper, err := a.paperSize(size)
+		if err != nil {
+			return "", err
+		}
+		dim = types.Dim{Width: paper.Width, Height: paper.Height}
+	}
+
+	outputPath := tempOutputPath("blank", pdfPath)
+	if _, err := os.Stat(outputPath); err == nil {
+		os.Remove(outputPath)
+	}
+
+	if err := a.runBeforeHooks("insert", pdfPath); err != nil {
+		return "", err
+	}
+	_, err = a.withTimeout(context.Background(), "page insertion", pdfPath, outputPath, func(in, out string) (interface{}, error) {
+		return nil, withRepairFallback(in, func(conf *model.Configuration) error {
+			return api.InsertPagesFile(in, out, []string{strconv.Itoa(page)}, before, &pdfcpu.PageConfiguration{PageDim: &dim}, conf)
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to insert a blank page: %v", err)
+	}
+	if err := a.finishOutput(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	a.runAfterHooks("insert", pdfPath, outputPath)
+	fmt.Printf("Backend: Inserted a %.0fx%.0f blank page after page %d of %s\n", dim.Width, dim.Height, afterPage, pdfPath)
+	return outputPath, nil
+}
+
+// DeletePages creates a copy of the PDF without the selected pages, e.g. "3" or "10-20".
+// Unlike UpdatePDFPages it only needs the pages to remove, so the frontend does not
+// have to send the page list of a large document to drop one page.
+func (a *App) DeletePages(pdfPath string, pages []string) (_ string, err error) {
+	pdfPath = filepath.Clean(pdfPath)
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return "", err
+	}
+	defer recoverDamaged(pdfPath, &err)
+	if len(pages) == 0 {
+		return "", fmt.Errorf("no pages given")
+	}
+
+	pageCount, err := api.PageCountFile(pdfPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read pdf: %v", err)
+	}
+	selected, err := api.PagesForPageSelection(pageCount, pages, false, false)
+	if err != nil {
+		return "", fmt.Errorf("invalid page selection: %v", err)
+	}
+	if len(selected) == 0 {
+		return "", fmt.Errorf("none of the selected pages exist in the %d page document", pageCount)
+	}
+	if len(selected) >= pageCount {
+		return "", fmt.Errorf("cannot delete every page of the document")
+	}
+
+	outputPath := tempOutputPath("del", pdfPath)
+	if _, err := os.Stat(outputPath); err == nil {
+		os.Remove(outputPath)
+	}
+
+	_, err = a.withTimeout(context.Background(), "page deletion", pdfPath, outputPath, func(in, out string) (interface{}, error) {
+		return nil, withRepairFallback(in, func(conf *model.Configuration) error {
+			return api.RemovePagesFile(in, out, pages, conf)
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to delete pages: %v", err)
+	}
+	if err := restoreAccessibility(pdfPath, outputPath); err != nil {
+		fmt.Printf("Backend: Failed to restore accessibility entries for %s: %v\n", outputPath, err)
+	}
+	if err := a.finishOutput(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+	fmt.Printf("Backend: Deleted %d of %d pages of %s\n", len(selected), pageCount, pdfPath)
+	return outputPath, nil
+}
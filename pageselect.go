@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+
+	"CapGo/pkg/stamper"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// expandStampPages replaces every stamp with a page selection by a copy on each page it
+// selects. Stamps without one are kept as they are.
+func expandStampPages(stamps []StampInfo, pageCount int) ([]StampInfo, error) {
+	var out []StampInfo
+	for i, s := range stamps {
+		copies, err := stampCopies(s, pageCount)
+		if err != nil {
+			return nil, fmt.Errorf("stamp %d: %v", i, err)
+		}
+		out = append(out, copies...)
+	}
+	return out, nil
+}
+
+// stampCopies returns the stamp on each page of its page selection, or the stamp itself
+// when it has none
+func stampCopies(s StampInfo, pageCount int) ([]StampInfo, error) {
+	if s.Pages == "" {
+		return []StampInfo{s}, nil
+	}
+	sel, err := stamper.ParsePageSelection(s.Pages)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := sel.Pages(pageCount)
+	if err != nil {
+		return nil, err
+	}
+	copies := make([]StampInfo, len(pages))
+	for i, p := range pages {
+		copies[i] = s
+		copies[i].Pages = ""
+		copies[i].PageNum = p
+	}
+	return copies, nil
+}
+
+// expandStampPagesFile is expandStampPages for the document at pdfPath, which is only
+// read when a stamp has a page selection
+func expandStampPagesFile(pdfPath string, stamps []StampInfo) ([]StampInfo, error) {
+	for _, s := range stamps {
+		if s.Pages == "" {
+			continue
+		}
+		pageCount, err := api.PageCountFile(pdfPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pdf: %v", err)
+		}
+		return expandStampPages(stamps, pageCount)
+	}
+	return stamps, nil
+}
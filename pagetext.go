@@ -0,0 +1,282 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// textExtractor collects the text shown on pages in content order, which is close to
+// reading order for most documents. Strings are decoded with the ToUnicode map of their
+// font. Simple fonts without a map are read as Latin-1, which is right for the common
+// characters of Western documents; two byte codes without a map cannot be decoded and
+// are skipped.
+type textExtractor struct {
+	ctx   *model.Context
+	limit int // bytes of text to collect at most
+	text  strings.Builder
+	fonts map[types.IndirectRef]*fontDecoder
+}
+
+// fontDecoder turns the character codes of one font into text
+type fontDecoder struct {
+	codeBytes int               // 1 for simple fonts, usually 2 for Type0 fonts
+	unicode   map[uint32]string // from the ToUnicode map, nil without one
+}
+
+// maxToUnicodeCodes bounds the codes read from one ToUnicode map
+const maxToUnicodeCodes = 1 << 16
+
+func newTextExtractor(ctx *model.Context, limit int) *textExtractor {
+	return &textExtractor{ctx: ctx, limit: limit, fonts: map[types.IndirectRef]*fontDecoder{}}
+}
+
+// full reports whether the extractor collected as much text as it may
+func (x *textExtractor) full() bool {
+	return x.text.Len() >= x.limit
+}
+
+// page adds the text of a page. Pages that cannot be read add nothing.
+func (x *textExtractor) page(p int) {
+	d, _, inh, err := x.ctx.PageDict(p, false)
+	if err != nil || d == nil || inh == nil {
+		return
+	}
+	content, err := x.ctx.PageContent(d, p)
+	if err != nil {
+		return
+	}
+	x.run(content, inh.Resources, 0)
+	x.space()
+}
+
+// run collects the text of a content stream with the given resources
+func (x *textExtractor) run(content []byte, resources types.Dict, depth int) {
+	var font *fontDecoder
+	var stack []*fontDecoder
+	show := func(s []byte) {
+		if font != nil {
+			x.write(font.decode(s))
+		}
+	}
+
+	parseContent(content, func(op string, args []interface{}) {
+		if x.full() {
+			return
+		}
+		switch op {
+		case "q":
+			stack = append(stack, font)
+		case "Q":
+			if len(stack) > 0 {
+				font, stack = stack[len(stack)-1], stack[:len(stack)-1]
+			}
+		case "Tf":
+			if len(args) > 0 {
+				if name, ok := args[0].(contentName); ok {
+					font = x.font(resources, string(name))
+				}
+			}
+		case "Td", "TD", "Tm", "T*", "ET":
+			// Most generators start a new line or word with a text position operator
+			x.space()
+		case "Tj", "'", "\"":
+			if op != "Tj" {
+				x.space()
+			}
+			if len(args) > 0 {
+				s, _ := args[len(args)-1].([]byte)
+				show(s)
+			}
+		case "TJ":
+			if len(args) == 0 {
+				return
+			}
+			items, _ := args[0].([]interface{})
+			for _, item := range items {
+				switch v := item.(type) {
+				case []byte:
+					show(v)
+				case float64:
+					// A wide gap between glyphs is a space the generator did not write
+					if v < -200 {
+						x.space()
+					}
+				}
+			}
+		case "Do":
+			if len(args) > 0 && depth < maxFormDepth {
+				if name, ok := args[0].(contentName); ok {
+					x.form(resources, string(name), depth)
+				}
+			}
+		}
+	})
+}
+
+// form collects the text of a form XObject
+func (x *textExtractor) form(resources types.Dict, name string, depth int) {
+	sd, _, err := x.ctx.DereferenceStreamDict(resourceObject(x.ctx, resources, "XObject", name))
+	if err != nil || sd == nil {
+		return
+	}
+	if subtype := sd.Dict.NameEntry("Subtype"); subtype == nil || *subtype != "Form" {
+		return
+	}
+	if err := sd.Decode(); err != nil {
+		return
+	}
+	formResources := resources
+	if d, err := x.ctx.DereferenceDict(sd.Dict["Resources"]); err == nil && d != nil {
+		formResources = d
+	}
+	x.run(sd.Content, formResources, depth+1)
+}
+
+func (x *textExtractor) write(s string) {
+	x.text.WriteString(s)
+}
+
+// space separates words, without doubling spaces
+func (x *textExtractor) space() {
+	if s := x.text.String(); s != "" && s[len(s)-1] != ' ' {
+		x.text.WriteByte(' ')
+	}
+}
+
+// font returns the decoder of a page font, nil when the font cannot be read
+func (x *textExtractor) font(resources types.Dict, name string) *fontDecoder {
+	o := resourceObject(x.ctx, resources, "Font", name)
+	ref, isRef := o.(types.IndirectRef)
+	if isRef {
+		if f, ok := x.fonts[ref]; ok {
+			return f
+		}
+	}
+	d, err := x.ctx.DereferenceDict(o)
+	if err != nil || d == nil {
+		return nil
+	}
+	f := &fontDecoder{codeBytes: 1}
+	if subtype := d.NameEntry("Subtype"); subtype != nil && *subtype == "Type0" {
+		f.codeBytes = 2
+	}
+	if sd, _, err := x.ctx.DereferenceStreamDict(d["ToUnicode"]); err == nil && sd != nil && sd.Decode() == nil {
+		var codeBytes int
+		f.unicode, codeBytes = parseToUnicode(sd.Content)
+		if codeBytes == 1 || codeBytes == 2 {
+			f.codeBytes = codeBytes
+		}
+	}
+	if isRef {
+		x.fonts[ref] = f
+	}
+	return f
+}
+
+// decode returns the text of a string shown with the font
+func (f *fontDecoder) decode(s []byte) string {
+	var b strings.Builder
+	for i := 0; i+f.codeBytes <= len(s); i += f.codeBytes {
+		code := uint32(s[i])
+		if f.codeBytes == 2 {
+			code = code<<8 | uint32(s[i+1])
+		}
+		if u, ok := f.unicode[code]; ok {
+			b.WriteString(u)
+			continue
+		}
+		switch {
+		case f.codeBytes == 2:
+		case code >= 0x20 && code < 0x7f, code >= 0xa0:
+			b.WriteRune(rune(code))
+		default:
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
+
+// parseToUnicode reads the bfchar and bfrange mappings of a ToUnicode CMap. It also
+// returns the length of the codes in bytes, 0 when the map declares no code space.
+func parseToUnicode(b []byte) (map[uint32]string, int) {
+	m := map[uint32]string{}
+	codeBytes := 0
+	parseContent(b, func(op string, args []interface{}) {
+		switch op {
+		case "endcodespacerange":
+			if s, ok := firstOperand(args).([]byte); ok && codeBytes == 0 {
+				codeBytes = len(s)
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(args) && len(m) < maxToUnicodeCodes; i += 2 {
+				src, _ := args[i].([]byte)
+				dst, _ := args[i+1].([]byte)
+				m[cmapCode(src)] = utf16Text(dst)
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(args); i += 3 {
+				src, _ := args[i].([]byte)
+				srcEnd, _ := args[i+1].([]byte)
+				lo, hi := cmapCode(src), cmapCode(srcEnd)
+				if hi < lo || int(hi-lo) >= maxToUnicodeCodes-len(m) {
+					continue
+				}
+				switch dst := args[i+2].(type) {
+				case []byte:
+					// The last UTF-16 unit counts up through the range
+					units := utf16Units(dst)
+					if len(units) == 0 {
+						continue
+					}
+					for c := lo; c <= hi; c++ {
+						m[c] = string(utf16.Decode(units))
+						units[len(units)-1]++
+					}
+				case []interface{}:
+					for j, o := range dst {
+						if c := lo + uint32(j); c <= hi {
+							s, _ := o.([]byte)
+							m[c] = utf16Text(s)
+						}
+					}
+				}
+			}
+		}
+	})
+	return m, codeBytes
+}
+
+func firstOperand(args []interface{}) interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+	return args[0]
+}
+
+// cmapCode returns the character code of a CMap hex string
+func cmapCode(b []byte) uint32 {
+	var code uint32
+	for i, c := range b {
+		if i == 4 {
+			break
+		}
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+func utf16Units(b []byte) []uint16 {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return units
+}
+
+// utf16Text decodes the big endian UTF-16 of a CMap destination
+func utf16Text(b []byte) string {
+	return string(utf16.Decode(utf16Units(b)))
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// PaperSize is a portrait page format in points
+type PaperSize struct {
+	Name   string  `json:"name"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Custom bool    `json:"custom,omitempty"` // added by the user
+}
+
+// standardPaperSizes are always available and cannot be changed
+var standardPaperSizes = []PaperSize{
+	{Name: "A3", Width: 841.89, Height: 1190.55},
+	{Name: "A4", Width: 595.28, Height: 841.89},
+	{Name: "A5", Width: 419.53, Height: 595.28},
+	{Name: "Letter", Width: 612, Height: 792},
+	{Name: "Legal", Width: 612, Height: 1008},
+}
+
+// defaultPaperSize is used on first launch and when the chosen default is deleted
+const defaultPaperSize = "A4"
+
+// Page sizes PDF viewers are required to support, in points
+const (
+	minPageSide = 3.0
+	maxPageSide = 14400.0
+)
+
+// GetPaperSizes returns the standard paper sizes followed by the custom ones
+func (a *App) GetPaperSizes() []PaperSize {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append(append([]PaperSize{}, standardPaperSizes...), a.settings.CustomPaperSizes...)
+}
+
+// GetDefaultPaperSize returns the name of the paper size used when none is given
+func (a *App) GetDefaultPaperSize() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.settings.PaperSize
+}
+
+// SetDefaultPaperSize persists the paper size used when none is given
+func (a *App) SetDefaultPaperSize(name string) error {
+	size, err := a.paperSize(name)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settings.PaperSize = size.Name
+	return a.saveSettings()
+}
+
+// SavePaperSize adds a custom paper size or replaces the one with the same name
+func (a *App) SavePaperSize(size PaperSize) error {
+	size.Name = strings.TrimSpace(size.Name)
+	size.Custom = true
+	if size.Name == "" {
+		return fmt.Errorf("paper size name cannot be empty")
+	}
+	if strings.ContainsAny(size.Name, `/\:`) {
+		return fmt.Errorf("paper size name %q cannot contain slashes or colons", size.Name)
+	}
+	if findPaperSize(standardPaperSizes, size.Name) != nil {
+		return fmt.Errorf("%s is a standard paper size", size.Name)
+	}
+	for _, side := range []float64{size.Width, size.Height} {
+		if side < minPageSide || side > maxPageSide {
+			return fmt.Errorf("paper size %s must be between %.0f and %.0f points on each side", size.Name, minPageSide, maxPageSide)
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	custom := append([]PaperSize{}, a.settings.CustomPaperSizes...)
+	if existing := findPaperSize(custom, size.Name); existing != nil {
+		*existing = size
+	} else {
+		custom = append(custom, size)
+	}
+	a.settings.CustomPaperSizes = custom
+	return a.saveSettings()
+}
+
+// DeletePaperSize removes a custom paper size; a default that is removed falls back to A4
+func (a *App) DeletePaperSize(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	custom := make([]PaperSize, 0, len(a.settings.CustomPaperSizes))
+	for _, s := range a.settings.CustomPaperSizes {
+		if !strings.EqualFold(s.Name, name) {
+			custom = append(custom, s)
+		}
+	}
+	if len(custom) == len(a.settings.CustomPaperSizes) {
+		return fmt.Errorf("unknown custom paper size: %s", name)
+	}
+	a.settings.CustomPaperSizes = custom
+	if strings.EqualFold(a.settings.PaperSize, name) {
+		a.settings.PaperSize = defaultPaperSize
+	}
+	return a.saveSettings()
+}
+
+// paperSize looks up a paper size by name, ignoring case; an empty name is the default
+func (a *App) paperSize(name string) (PaperSize, error) {
+	if strings.TrimSpace(name) == "" {
+		name = a.GetDefaultPaperSize()
+	}
+	if size := findPaperSize(a.GetPaperSizes(), strings.TrimSpace(name)); size != nil {
+		return *size, nil
+	}
+	return PaperSize{}, fmt.Errorf("unknown paper size: %s", name)
+}
+
+func findPaperSize(sizes []PaperSize, name string) *PaperSize {
+	for i := range sizes {
+		if strings.EqualFold(sizes[i].Name, name) {
+			return &sizes[i]
+		}
+	}
+	return nil
+}
+
+// ResizePDF scales every page onto the paper size and writes the result to Downloads.
+// Landscape pages are placed on the landscape version of the size.
+func (a *App) ResizePDF(pdfPath, size string) (string, error) {
+	pdfPath = filepath.Clean(pdfPath)
+	paper, err := a.paperSize(size)
+	if err != nil {
+		return "", err
+	}
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return "", err
+	}
+	defer a.startJob("resize")()
+
+	output, err := a.outputPath(pdfPath, "_"+fileBase(paper.Name, 0))
+	if err != nil {
+		return "", err
+	}
+	resize := &model.Resize{
+		Unit:    types.POINTS,
+		PageDim: &types.Dim{Width: paper.Width, Height: paper.Height},
+		UserDim: true,
+	}
+	if err := api.ResizeFile(pdfPath, output, nil, resize, nil); err != nil {
+		return "", fmt.Errorf("failed to resize pages: %v", err)
+	}
+	if err := a.finishOutput(output); err != nil {
+		os.Remove(output)
+		return "", err
+	}
+	recordDerivedHistory(pdfPath, output, "resize")
+	fmt.Printf("Backend: Resized %s to %s\n", pdfPath, paper.Name)
+	return output, nil
+}
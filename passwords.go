@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// EncryptionStatus tells the frontend whether to ask for a password before working on a PDF
+type EncryptionStatus struct {
+	Encrypted bool `json:"encrypted"`
+	// PasswordRequired is false for documents that are encrypted only to restrict
+	// printing or editing, which open without a password
+	PasswordRequired bool `json:"passwordRequired"`
+}
+
+// IsEncrypted checks whether a PDF is encrypted and needs a password to be opened
+func (a *App) IsEncrypted(pdfPath string) (EncryptionStatus, error) {
+	pdfPath = filepath.Clean(pdfPath)
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return EncryptionStatus{}, err
+	}
+	f, err := os.Open(pdfPath)
+	if err != nil {
+		return EncryptionStatus{}, classifyFileError("read", pdfPath, err)
+	}
+	defer f.Close()
+
+	ctx, err := api.ReadContext(f, model.NewDefaultConfiguration())
+	if isWrongPassword(err) {
+		return EncryptionStatus{Encrypted: true, PasswordRequired: true}, nil
+	}
+	if err != nil {
+		return EncryptionStatus{}, fmt.Errorf("failed to read pdf: %v", err)
+	}
+	return EncryptionStatus{Encrypted: ctx.Encrypt != nil}, nil
+}
+
+func isWrongPassword(err error) bool {
+	return err != nil && (errors.Is(err, pdfcpu.ErrWrongPassword) || strings.Contains(err.Error(), pdfcpu.ErrWrongPassword.Error()))
+}
+
+// PDFPermissions are what readers who only know the user password may do
+type PDFPermissions struct {
+	Print  bool `json:"print"`
+	Copy   bool `json:"copy"`   // copy or extract text and images
+	Modify bool `json:"modify"` // edit pages, annotations and form fields
+}
+
+func (p PDFPermissions) flags() model.PermissionFlags {
+	flags := model.PermissionsNone
+	if p.Print {
+		flags |= model.PermissionPrintRev2 | model.PermissionPrintRev3
+	}
+	if p.Copy {
+		flags |= model.PermissionExtract | model.PermissionExtractRev3
+	}
+	if p.Modify {
+		flags |= model.PermissionModify | model.PermissionModAnnFillForm | model.PermissionFillRev3 | model.PermissionAssembleRev3
+	}
+	return flags
+}
+
+// EncryptPDF writes an AES-256 encrypted copy of the PDF to Downloads. The user password
+// is needed to open the copy and may be empty to only restrict it; the owner password
+// lifts the restrictions. Without an owner password a random one is used, so the
+// permissions cannot be changed later.
+func (a *App) EncryptPDF(pdfPath, userPw, ownerPw string, permissions PDFPermissions) (string, error) {
+	pdfPath = filepath.Clean(pdfPath)
+	if userPw == "" && ownerPw == "" && permissions == (PDFPermissions{Print: true, Copy: true, Modify: true}) {
+		return "", fmt.Errorf("set a password or restrict a permission to encrypt the document")
+	}
+	status, err := a.IsEncrypted(pdfPath)
+	if err != nil {
+		return "", err
+	}
+	if status.Encrypted {
+		return "", fmt.Errorf("%s is already encrypted", filepath.Base(pdfPath))
+	}
+	if ownerPw == "" {
+		if ownerPw, err = randomPassword(); err != nil {
+			return "", err
+		}
+	}
+	defer a.startJob("encrypt")()
+
+	output, err := a.outputPath(pdfPath, "_encrypted")
+	if err != nil {
+		return "", err
+	}
+	conf := model.NewAESConfiguration(userPw, ownerPw, 256)
+	conf.Permissions = permissions.flags()
+	if err := api.EncryptFile(pdfPath, output, conf); err != nil {
+		os.Remove(output)
+		return "", fmt.Errorf("failed to encrypt %s: %v", filepath.Base(pdfPath), err)
+	}
+	recordDerivedHistory(pdfPath, output, "encrypt")
+	fmt.Printf("Backend: Encrypted %s\n", pdfPath)
+	return output, nil
+}
+
+func randomPassword() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// lockedPDF is a decrypted working copy of a password protected PDF
+type lockedPDF struct {
+	Path        string // the copy to work on, or the source when it needs no password
+	password    string
+	permissions *int16 // of the source, nil when it is not encrypted
+	dir         string
+}
+
+// unlockPDF returns a decrypted working copy of pdfPath. Documents that open without a
+// password are used as they are. Call close when done.
+func unlockPDF(pdfPath, password string) (*lockedPDF, error) {
+	if password == "" {
+		// Fail with a clear message instead of deep inside pdfcpu
+		f, err := os.Open(pdfPath)
+		if err != nil {
+			return nil, classifyFileError("read", pdfPath, err)
+		}
+		_, err = api.ReadContext(f, model.NewDefaultConfiguration())
+		f.Close()
+		if isWrongPassword(err) {
+			return nil, fmt.Errorf("%s is password protected, enter its password to open it", filepath.Base(pdfPath))
+		}
+		return &lockedPDF{Path: pdfPath}, nil
+	}
+
+	conf := model.NewDefaultConfiguration()
+	conf.UserPW = password
+	conf.OwnerPW = password
+	permissions, err := api.GetPermissionsFile(pdfPath, conf)
+	if isWrongPassword(err) {
+		return nil, fmt.Errorf("wrong password for %s", filepath.Base(pdfPath))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %v", err)
+	}
+	if permissions == nil {
+		// Not encrypted after all
+		return &lockedPDF{Path: pdfPath}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "capgo_unlock_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp folder: %v", err)
+	}
+	l := &lockedPDF{Path: filepath.Join(dir, filepath.Base(pdfPath)), password: password, permissions: permissions, dir: dir}
+	if err := api.DecryptFile(pdfPath, l.Path, conf); err != nil {
+		l.close()
+		return nil, fmt.Errorf("failed to decrypt %s: %v", filepath.Base(pdfPath), err)
+	}
+	return l, nil
+}
+
+// lock encrypts a file derived from the copy with the password that opened the source
+// and the permissions of the source. The owner password is random, so the permissions
+// cannot be lifted by whoever receives the file.
+func (l *lockedPDF) lock(path string) error {
+	if l.permissions == nil {
+		return nil
+	}
+	owner, err := randomPassword()
+	if err != nil {
+		return err
+	}
+	conf := model.NewAESConfiguration(l.password, owner, 256)
+	conf.Permissions = model.PermissionFlags(*l.permissions)
+	if err := api.EncryptFile(path, "", conf); err != nil {
+		return fmt.Errorf("failed to encrypt %s: %v", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// close removes the decrypted copy
+func (l *lockedPDF) close() {
+	if l.dir != "" {
+		os.RemoveAll(l.dir)
+	}
+}
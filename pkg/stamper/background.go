@@ -0,0 +1,57 @@
+package stamper
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// defaultBackgroundThreshold is the lightness from which a pixel counts as background
+// when BackgroundRemoval.Threshold is 0. Paper in scans and photos is rarely pure white.
+const defaultBackgroundThreshold = 0.9
+
+// BackgroundRemoval makes the near-white background of a stamp image transparent, so a
+// scanned signature does not cover the text under it with a white rectangle
+type BackgroundRemoval struct {
+	// Threshold is the lightness of the darkest channel from which a pixel is made
+	// transparent, 0 to 1; 0 uses defaultBackgroundThreshold. Colored ink is kept
+	// because one of its channels stays dark.
+	Threshold float64 `json:"threshold,omitempty"`
+	// Feather is the width of the lightness band below Threshold in which pixels fade
+	// out instead of being cut off, which keeps the edges of strokes smooth; 0 to 1
+	Feather float64 `json:"feather,omitempty"`
+}
+
+// Check reports settings outside of their range
+func (b BackgroundRemoval) Check() error {
+	if b.Threshold < 0 || b.Threshold > 1 {
+		return fmt.Errorf("invalid background threshold %g, expected 0 to 1", b.Threshold)
+	}
+	if b.Feather < 0 || b.Feather > 1 {
+		return fmt.Errorf("invalid background feathering %g, expected 0 to 1", b.Feather)
+	}
+	return nil
+}
+
+// apply returns a copy of img with its background made transparent
+func (b BackgroundRemoval) apply(img image.Image) *image.NRGBA {
+	threshold := b.Threshold
+	if threshold == 0 {
+		threshold = defaultBackgroundThreshold
+	}
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+
+	for i := 0; i < len(dst.Pix); i += 4 {
+		p := dst.Pix[i : i+4 : i+4]
+		lightness := float64(min(p[0], p[1], p[2])) / 0xff
+		switch {
+		case lightness >= threshold:
+			p[3] = 0
+		case b.Feather > 0 && lightness > threshold-b.Feather:
+			p[3] = uint8(float64(p[3]) * (threshold - lightness) / b.Feather)
+		}
+	}
+	return dst
+}
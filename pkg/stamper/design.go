@@ -0,0 +1,171 @@
+package stamper
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"CapGo/internal/pdfcanvas"
+)
+
+// Design element types
+const (
+	DesignRect = "rect"
+	DesignText = "text"
+)
+
+// DesignElement is a drawing primitive of a custom stamp. Positions and sizes are in
+// points with a top-left origin inside the design.
+type DesignElement struct {
+	Type   string  `json:"type"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+
+	// Rectangles and text block backgrounds
+	FillColor    string  `json:"fillColor,omitempty"`
+	BorderColor  string  `json:"borderColor,omitempty"`
+	BorderWidth  float64 `json:"borderWidth,omitempty"`
+	CornerRadius float64 `json:"cornerRadius,omitempty"`
+
+	// Text blocks
+	Text        string  `json:"text,omitempty"` // lines separated by "\n"
+	FontSize    float64 `json:"fontSize,omitempty"`
+	Bold        bool    `json:"bold,omitempty"`
+	Color       string  `json:"color,omitempty"`
+	Align       string  `json:"align,omitempty"`       // left, center or right
+	VAlign      string  `json:"valign,omitempty"`      // top, middle or bottom
+	LineSpacing float64 `json:"lineSpacing,omitempty"` // multiple of the font line height
+}
+
+// Design is custom rubber-stamp artwork assembled from primitives in the stamp designer
+type Design struct {
+	Width    float64         `json:"width"`  // points
+	Height   float64         `json:"height"` // points
+	DPI      int             `json:"dpi"`    // defaults to 600
+	Elements []DesignElement `json:"elements"`
+}
+
+// RenderDesign renders the design as a transparent image at print resolution
+func RenderDesign(design Design) (*image.NRGBA, error) {
+	if design.Width <= 0 || design.Height <= 0 {
+		return nil, fmt.Errorf("design must have a positive size")
+	}
+	dpi := design.DPI
+	if dpi <= 0 {
+		dpi = 600
+	}
+	scale := float64(dpi) / 72
+	w := int(math.Ceil(design.Width * scale))
+	h := int(math.Ceil(design.Height * scale))
+	if w*h > 64_000_000 {
+		return nil, fmt.Errorf("design is too large to render at %d dpi", dpi)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i, el := range design.Elements {
+		var err error
+		switch el.Type {
+		case DesignRect:
+			err = drawDesignRect(img, el, scale)
+		case DesignText:
+			err = drawDesignRect(img, el, scale)
+			if err == nil {
+				err = drawDesignText(img, el, scale)
+			}
+		default:
+			err = fmt.Errorf("unknown type %q", el.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("design element %d: %v", i, err)
+		}
+	}
+	return img, nil
+}
+
+// drawDesignRect paints the fill and border of an element, if any
+func drawDesignRect(img *image.NRGBA, el DesignElement, scale float64) error {
+	x, y := el.X*scale, el.Y*scale
+	w, h := el.Width*scale, el.Height*scale
+	r := el.CornerRadius * scale
+
+	if el.FillColor != "" {
+		c, err := pdfcanvas.ParseHexColor(el.FillColor)
+		if err != nil {
+			return err
+		}
+		fillRoundedRect(img, c.NRGBA(), x, y, w, h, r)
+	}
+	if el.BorderColor != "" && el.BorderWidth > 0 {
+		c, err := pdfcanvas.ParseHexColor(el.BorderColor)
+		if err != nil {
+			return err
+		}
+		strokeRoundedRect(img, c.NRGBA(), x, y, w, h, r, el.BorderWidth*scale)
+	}
+	return nil
+}
+
+// drawDesignText lays out a multi-line text block inside the element box
+func drawDesignText(img *image.NRGBA, el DesignElement, scale float64) error {
+	if el.Text == "" {
+		return nil
+	}
+	size := el.FontSize
+	if size <= 0 {
+		size = 12
+	}
+	col, err := pdfcanvas.ParseHexColor(defaultString(el.Color, "#000000"))
+	if err != nil {
+		return err
+	}
+	face, err := rasterFont(el.Bold, size*scale)
+	if err != nil {
+		return err
+	}
+
+	spacing := el.LineSpacing
+	if spacing <= 0 {
+		spacing = 1
+	}
+	m := face.Metrics()
+	ascent := float64(m.Ascent) / 64
+	lineH := float64(m.Height) / 64 * spacing
+
+	// Inset the text by the border so it never overlaps it
+	inset := el.BorderWidth * scale
+	x, y := el.X*scale+inset, el.Y*scale+inset
+	w, h := el.Width*scale-2*inset, el.Height*scale-2*inset
+
+	lines := strings.Split(strings.ReplaceAll(el.Text, "\\n", "\n"), "\n")
+	blockH := lineH * float64(len(lines))
+
+	top := y
+	switch el.VAlign {
+	case "", "top":
+	case "middle":
+		top = y + (h-blockH)/2
+	case "bottom":
+		top = y + h - blockH
+	default:
+		return fmt.Errorf("invalid vertical alignment %q", el.VAlign)
+	}
+
+	for i, line := range lines {
+		lw := textWidthPx(face, line)
+		lx := x
+		switch el.Align {
+		case "", "left":
+		case "center":
+			lx = x + (w-lw)/2
+		case "right":
+			lx = x + w - lw
+		default:
+			return fmt.Errorf("invalid alignment %q", el.Align)
+		}
+		drawTextPx(img, face, col.NRGBA(), lx, top+lineH*float64(i)+ascent, line)
+	}
+	return nil
+}
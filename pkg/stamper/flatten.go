@@ -0,0 +1,130 @@
+package stamper
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// pdfcpu wraps every watermark it draws in a marked content sequence and puts its form
+// in an optional content group; watermark removal in pdfcpu and in other viewers looks
+// for both. A flattened stamp keeps neither and is ordinary page content, marked only
+// as an artifact for assistive technology.
+var watermarkMarker = regexp.MustCompile(`/Artifact <</Subtype /Watermark /Type /Pagination >>BDC( q [^Q]*? cm /[^\s/]+ gs /([^\s/]+) Do Q EMC)`)
+
+// pageXObjectNames returns the names of the XObjects each page uses, by page number
+func pageXObjectNames(ctx *model.Context) (map[int]map[string]bool, error) {
+	names := map[int]map[string]bool{}
+	for p := 1; p <= ctx.PageCount; p++ {
+		xobjects, err := pageXObjects(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		names[p] = map[string]bool{}
+		for name := range xobjects {
+			names[p][name] = true
+		}
+	}
+	return names, nil
+}
+
+func pageXObjects(ctx *model.Context, pageNr int) (types.Dict, error) {
+	_, _, inherited, err := ctx.PageDict(pageNr, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page %d: %v", pageNr, err)
+	}
+	if inherited == nil || inherited.Resources == nil {
+		return nil, nil
+	}
+	o, ok := inherited.Resources.Find("XObject")
+	if !ok {
+		return nil, nil
+	}
+	return ctx.DereferenceDict(o)
+}
+
+// flattenStamps turns the watermarks added since before was taken into page content, so
+// they can no longer be removed as watermarks. Stamps from earlier runs stay removable.
+func flattenStamps(ctx *model.Context, before map[int]map[string]bool) error {
+	for p := 1; p <= ctx.PageCount; p++ {
+		xobjects, err := pageXObjects(ctx, p)
+		if err != nil {
+			return err
+		}
+		added := map[string]bool{}
+		for name, o := range xobjects {
+			if before[p][name] {
+				continue
+			}
+			added[name] = true
+			sd, _, err := ctx.DereferenceStreamDict(o)
+			if err != nil {
+				return fmt.Errorf("failed to read stamp on page %d: %v", p, err)
+			}
+			if sd != nil {
+				sd.Delete("OC")
+			}
+		}
+		if len(added) == 0 {
+			continue
+		}
+
+		d, _, _, err := ctx.PageDict(p, false)
+		if err != nil {
+			return fmt.Errorf("failed to read page %d: %v", p, err)
+		}
+		var refs []types.IndirectRef
+		switch c := d["Contents"].(type) {
+		case types.IndirectRef:
+			refs = append(refs, c)
+		case types.Array:
+			for _, o := range c {
+				if ir, ok := o.(types.IndirectRef); ok {
+					refs = append(refs, ir)
+				}
+			}
+		}
+		for _, ir := range refs {
+			if err := unmarkWatermarks(ctx, ir, added); err != nil {
+				return fmt.Errorf("failed to flatten stamps on page %d: %v", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// unmarkWatermarks removes the watermark marking from the drawing of the added forms
+// in one content stream
+func unmarkWatermarks(ctx *model.Context, ir types.IndirectRef, added map[string]bool) error {
+	entry, ok := ctx.FindTableEntryForIndRef(&ir)
+	if !ok {
+		return nil
+	}
+	sd, ok := entry.Object.(types.StreamDict)
+	if !ok {
+		return nil
+	}
+	if err := sd.Decode(); err != nil {
+		return err
+	}
+	changed := false
+	content := watermarkMarker.ReplaceAllFunc(sd.Content, func(m []byte) []byte {
+		sub := watermarkMarker.FindSubmatch(m)
+		if !added[string(sub[2])] {
+			return m
+		}
+		changed = true
+		return append([]byte("/Artifact BMC"), sub[1]...)
+	})
+	if !changed {
+		return nil
+	}
+	sd.Content = content
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+	entry.Object = sd
+	return nil
+}
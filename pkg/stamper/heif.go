@@ -0,0 +1,120 @@
+package stamper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	_ "image/jpeg"
+
+	"CapGo/internal/pdfcanvas"
+
+	// The TIFF decoder of pdfcpu, which also reads JPEG compressed and CMYK scans, so
+	// stamps read the same scans pdfcpu imports. image.Decode takes the first image
+	// of a multi-page TIFF.
+	_ "github.com/hhrutter/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// heifTimeout bounds one conversion of a HEIC/HEIF image
+const heifTimeout = 60 * time.Second
+
+// heifConverters are the tools HEIC and HEIF images are converted to PNG with, in order
+// of preference. Go has no HEIF decoder; sips comes with macOS, heif-convert with
+// libheif, and ImageMagick reads HEIC when it is built with libheif.
+var heifConverters = []struct {
+	name string
+	args func(input, output string) []string
+}{
+	{"sips", func(input, output string) []string { return []string{"-s", "format", "png", input, "--out", output} }},
+	{"heif-convert", func(input, output string) []string { return []string{input, output} }},
+	// [0] keeps only the first image of a sequence
+	{"magick", func(input, output string) []string { return []string{input + "[0]", output} }},
+}
+
+// IsHEIF reports whether data starts like a HEIC/HEIF still image, e.g. an iPhone photo
+func IsHEIF(data []byte) bool {
+	return len(data) >= 12 && string(data[4:8]) == "ftyp" && isHEIFBrand(string(data[8:12]))
+}
+
+// IsHEIFFile is IsHEIF for the file at path
+func IsHEIFFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	head := make([]byte, 12)
+	n, _ := f.Read(head)
+	return IsHEIF(head[:n]), nil
+}
+
+// ConvertHEIF writes the HEIC/HEIF image at input to output as a PNG
+func ConvertHEIF(input, output string) error {
+	for _, c := range heifConverters {
+		path, err := exec.LookPath(c.name)
+		if err != nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), heifTimeout)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, path, c.args(input, output)...).CombinedOutput()
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%s did not finish within %v", c.name, heifTimeout)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v: %s", c.name, err, bytes.TrimSpace(out))
+		}
+		if _, err := os.Stat(output); err != nil {
+			return fmt.Errorf("%s wrote no image", c.name)
+		}
+		return nil
+	}
+	return fmt.Errorf("reading HEIC images needs heif-convert from libheif or ImageMagick, which is not installed")
+}
+
+// DecodeImage decodes an image in one of the formats Go reads, or a HEIC/HEIF image
+// converted by one of the heifConverters. SVG images are drawn as vectors instead, see
+// svgWatermark.
+func DecodeImage(data []byte) (image.Image, error) {
+	if pdfcanvas.IsSVG(data) {
+		return nil, fmt.Errorf("SVG images can only be used as stamps")
+	}
+	if !IsHEIF(data) {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		return img, err
+	}
+	dir, err := os.MkdirTemp("", "capgo_heif_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp folder: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	input, output := filepath.Join(dir, "image.heic"), filepath.Join(dir, "image.png")
+	if err := os.WriteFile(input, data, 0644); err != nil {
+		return nil, err
+	}
+	if err := ConvertHEIF(input, output); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(output)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+// isHEIFBrand reports whether an ISO media brand is one of HEIC/HEIF still images
+func isHEIFBrand(brand string) bool {
+	switch brand {
+	case "heic", "heix", "heim", "heis", "mif1", "msf1":
+		return true
+	}
+	return false
+}
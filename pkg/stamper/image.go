@@ -0,0 +1,166 @@
+package stamper
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"CapGo/internal/pdfcanvas"
+
+	"github.com/nfnt/resize"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// ImageData reads the image of stamp i from a file or a base64 data URL
+func ImageData(i int, stamp Stamp) ([]byte, error) {
+	if strings.Contains(stamp.Image, ";base64,") {
+		parts := strings.Split(stamp.Image, ",")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("invalid base64 data format for stamp %d", i)
+		}
+		data, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode base64 image %d: %v", i, err)
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(filepath.Clean(stamp.Image))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image file %d: %v", i, err)
+	}
+	return data, nil
+}
+
+// StampImage decodes the image data of stamp i and removes its background if the
+// stamp asks for it
+func StampImage(i int, stamp Stamp, data []byte) (image.Image, error) {
+	srcImage, err := DecodeImage(data)
+	if err != nil {
+		if strings.Contains(stamp.Image, ";base64,") {
+			return nil, fmt.Errorf("failed to decode image %d from base64: %v", i, err)
+		}
+		return nil, fmt.Errorf("failed to decode image file %d: %v", i, err)
+	}
+	if stamp.RemoveBackground != nil {
+		return stamp.RemoveBackground.apply(srcImage), nil
+	}
+	return srcImage, nil
+}
+
+// imageWatermark prepares the pdfcpu watermark for an image stamp.
+// It returns the temporary PNG backing the watermark, which the caller must remove;
+// SVG images are drawn as vectors and need none.
+func imageWatermark(i int, stamp Stamp, pdfHeight float64) (*model.Watermark, string, error) {
+	data, err := ImageData(i, stamp)
+	if err != nil {
+		return nil, "", err
+	}
+	if pdfcanvas.IsSVG(data) {
+		wm, err := svgWatermark(stamp, data, pdfHeight)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to prepare SVG stamp %d: %v", i, err)
+		}
+		return wm, "", nil
+	}
+	srcImage, err := StampImage(i, stamp, data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Preserve Aspect Ratio (Equivalent to object-fit: contain)
+	imgWidth := float64(srcImage.Bounds().Dx())
+	imgHeight := float64(srcImage.Bounds().Dy())
+
+	targetRatio := stamp.Width / stamp.Height
+	imgRatio := imgWidth / imgHeight
+
+	var finalW, finalH float64
+	var offX, offY float64 // Offset within the stamp.Width/Height box
+
+	if imgRatio > targetRatio {
+		// Image is wider than the target box aspect ratio, so its width will fill the box
+		finalW = stamp.Width
+		finalH = stamp.Width / imgRatio
+		offX = 0
+		offY = (stamp.Height - finalH) / 2
+	} else {
+		// Image is taller than or equal to the target box aspect ratio, so its height will fill the box
+		finalH = stamp.Height
+		finalW = stamp.Height * imgRatio
+		offX = (stamp.Width - finalW) / 2
+		offY = 0
+	}
+
+	// HD Resizing (4x for sharpness)
+	qualityFactor := 4.0
+	resizedImg := resize.Resize(uint(finalW*qualityFactor), uint(finalH*qualityFactor), srcImage, resize.Lanczos3)
+
+	// Create temp PNG for watermark
+	imgTemp, err := os.CreateTemp("", "stamp_*.png")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create temp stamp %d: %v", i, err)
+	}
+	if err := png.Encode(imgTemp, resizedImg); err != nil {
+		imgTemp.Close()
+		os.Remove(imgTemp.Name())
+		return nil, "", fmt.Errorf("failed to encode stamp %d: %v", i, err)
+	}
+	imgTemp.Close()
+
+	// pdfcpu watermark description (Back to Bottom-Left origin)
+	// pos:bl = Bottom-Left origin
+	// off: x y = Offset from bottom-left (x=right, y=up)
+	// scale: factor abs = Absolute scaling relative to native points
+	scaleStr := fmt.Sprintf("%.4f abs", 1.0/qualityFactor)
+
+	// Calculate final X and Y coordinates for pdfcpu (bottom-left origin)
+	// stamp.X and stamp.Y are from top-left (browser coordinates)
+	// pdfcpu's Y increases upwards from the bottom.
+	// So, browser Y (top-down) needs to be converted to pdfcpu Y (bottom-up).
+	// The total height of the placed image is finalH.
+	// The browser Y coordinate (stamp.Y + offY) is the top edge of the placed image.
+	// To get the bottom edge from the bottom of the PDF: pdfHeight - (browser_Y + placed_image_height)
+	finalX := stamp.X + offX
+	finalY := pdfHeight - (stamp.Y + offY + finalH)
+
+	desc := fmt.Sprintf("pos:bl, off:%f %f, scale:%s, rot:0", finalX, finalY, scaleStr) + opacityParam(stamp)
+
+	// Process staving (no log)
+
+	wm, err := api.ImageWatermark(imgTemp.Name(), desc, true, false, types.POINTS)
+	if err != nil {
+		os.Remove(imgTemp.Name())
+		return nil, "", fmt.Errorf("failed to parse watermark %d details: %v", i, err)
+	}
+
+	return wm, imgTemp.Name(), nil
+}
+
+// svgWatermark prepares the pdfcpu watermark for an SVG image stamp: the drawing as
+// vectors, fitted into the stamp box like a raster image
+func svgWatermark(stamp Stamp, data []byte, pdfHeight float64) (*model.Watermark, error) {
+	img, err := pdfcanvas.ParseSVG(data)
+	if err != nil {
+		return nil, err
+	}
+	width, height := img.Size()
+	scale := math.Min(stamp.Width/width, stamp.Height/height)
+	formW, formH := width*scale, height*scale
+	c := pdfcanvas.New(formW, formH)
+	if err := img.Draw(c, scale); err != nil {
+		return nil, err
+	}
+
+	finalX := stamp.X + (stamp.Width-formW)/2
+	finalY := pdfHeight - (stamp.Y + (stamp.Height-formH)/2 + formH)
+	desc := fmt.Sprintf("pos:bl, off:%f %f, scale:1 abs, rot:0", finalX, finalY) + opacityParam(stamp)
+	return api.PDFWatermarkForReadSeeker(bytes.NewReader(pdfcanvas.Render(c)), 1, desc, true, false, types.POINTS)
+}
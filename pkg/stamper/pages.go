@@ -0,0 +1,99 @@
+package stamper
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LastPage stands for the last page of a document in a page selection
+const LastPage = -1
+
+// PageSelection is a parsed page selection expression: a comma separated list of
+// "all", "odd", "even", "first", "last", a page number or a range like "2-10" or
+// "3-last". It is parsed without a document and resolved against each one.
+type PageSelection []PageRange
+
+// PageRange is one term of a page selection
+type PageRange struct {
+	Step     int // 1 for every page of the range, 2 for every other page
+	From, To int // 1-based, or LastPage
+}
+
+// ParsePageSelection checks the syntax of a page selection expression
+func ParsePageSelection(expr string) (PageSelection, error) {
+	var sel PageSelection
+	for _, part := range strings.Split(strings.ToLower(expr), ",") {
+		part = strings.TrimSpace(part)
+		switch part {
+		case "all":
+			sel = append(sel, PageRange{Step: 1, From: 1, To: LastPage})
+		case "odd":
+			sel = append(sel, PageRange{Step: 2, From: 1, To: LastPage})
+		case "even":
+			sel = append(sel, PageRange{Step: 2, From: 2, To: LastPage})
+		default:
+			from, to, isRange := strings.Cut(part, "-")
+			a, err := selectionPage(from)
+			if err != nil {
+				return nil, fmt.Errorf("invalid page selection %q", expr)
+			}
+			b := a
+			if isRange {
+				if b, err = selectionPage(to); err != nil {
+					return nil, fmt.Errorf("invalid page selection %q", expr)
+				}
+			}
+			if a != LastPage && b != LastPage && a > b {
+				return nil, fmt.Errorf("invalid page selection %q: %d comes after %d", expr, a, b)
+			}
+			sel = append(sel, PageRange{Step: 1, From: a, To: b})
+		}
+	}
+	return sel, nil
+}
+
+// selectionPage parses one end of a range: a page number, "first" or "last"
+func selectionPage(s string) (int, error) {
+	switch s = strings.TrimSpace(s); s {
+	case "first":
+		return 1, nil
+	case "last":
+		return LastPage, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid page %q", s)
+	}
+	return n, nil
+}
+
+// Pages returns the selected pages of a document in order, each once
+func (sel PageSelection) Pages(pageCount int) ([]int, error) {
+	seen := map[int]bool{}
+	var pages []int
+	for _, t := range sel {
+		from, to := t.From, t.To
+		if from == LastPage {
+			from = pageCount
+		}
+		if to == LastPage {
+			to = pageCount
+		}
+		if to > pageCount {
+			return nil, fmt.Errorf("page %d does not exist in the %d page document", to, pageCount)
+		}
+		for p := from; p <= to; p += t.Step {
+			if !seen[p] {
+				seen[p] = true
+				pages = append(pages, p)
+			}
+		}
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("the page selection matches no page of the %d page document", pageCount)
+	}
+	sort.Ints(pages)
+	return pages, nil
+}
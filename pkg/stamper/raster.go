@@ -0,0 +1,169 @@
+package stamper
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/f64"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// rasterFont returns a face of the bundled Go fonts at the given pixel size
+func rasterFont(bold bool, size float64) (font.Face, error) {
+	ttf := goregular.TTF
+	if bold {
+		ttf = gobold.TTF
+	}
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %v", err)
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+}
+
+// textWidthPx measures a string in pixels
+func textWidthPx(face font.Face, s string) float64 {
+	return float64(font.MeasureString(face, s)) / 64
+}
+
+// drawTextPx draws s with its baseline starting at x, y
+func drawTextPx(dst draw.Image, face font.Face, col color.Color, x, y float64, s string) {
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(col),
+		Face: face,
+		Dot:  fixed.Point26_6{X: fixed.Int26_6(x * 64), Y: fixed.Int26_6(y * 64)},
+	}
+	d.DrawString(s)
+}
+
+// drawRotatedGlyph draws s so that the centre of its baseline lands on (px, py),
+// rotated by angle radians (clockwise in image coordinates)
+func drawRotatedGlyph(dst draw.Image, face font.Face, col color.Color, px, py, angle float64, s string) {
+	metrics := face.Metrics()
+	ascent := float64(metrics.Ascent) / 64
+	descent := float64(metrics.Descent) / 64
+	w := textWidthPx(face, s)
+	pad := 2.0
+
+	tile := image.NewNRGBA(image.Rect(0, 0, int(math.Ceil(w+2*pad)), int(math.Ceil(ascent+descent+2*pad))))
+	drawTextPx(tile, face, col, pad, pad+ascent, s)
+
+	// Map the tile's baseline centre onto (px, py)
+	cx, cy := pad+w/2, pad+ascent
+	sin, cos := math.Sin(angle), math.Cos(angle)
+	m := f64.Aff3{
+		cos, -sin, px - (cos*cx - sin*cy),
+		sin, cos, py - (sin*cx + cos*cy),
+	}
+	draw.BiLinear.Transform(dst, m, tile, tile.Bounds(), draw.Over, nil)
+}
+
+// ellipsePath adds a closed ellipse to the rasterizer; reverse flips the winding to cut holes
+func ellipsePath(z *vector.Rasterizer, cx, cy, rx, ry float64, reverse bool) {
+	const steps = 360
+	for i := 0; i <= steps; i++ {
+		t := 2 * math.Pi * float64(i) / steps
+		if reverse {
+			t = -t
+		}
+		x := float32(cx + rx*math.Cos(t))
+		y := float32(cy + ry*math.Sin(t))
+		if i == 0 {
+			z.MoveTo(x, y)
+		} else {
+			z.LineTo(x, y)
+		}
+	}
+	z.ClosePath()
+}
+
+// fillEllipseRing paints an elliptical ring of the given thickness inside the ellipse (rx, ry)
+func fillEllipseRing(dst draw.Image, col color.Color, cx, cy, rx, ry, thickness float64) {
+	b := dst.Bounds()
+	z := vector.NewRasterizer(b.Dx(), b.Dy())
+	ellipsePath(z, cx, cy, rx, ry, false)
+	ellipsePath(z, cx, cy, rx-thickness, ry-thickness, true)
+	z.Draw(dst, b, image.NewUniform(col), image.Point{})
+}
+
+// roundedRectPath adds a closed rounded rectangle to the rasterizer; reverse flips the winding to cut holes
+func roundedRectPath(z *vector.Rasterizer, x, y, w, h, r float64, reverse bool) {
+	r = math.Max(0, math.Min(r, math.Min(w, h)/2))
+	k := r * 0.5523
+	f := func(v float64) float32 { return float32(v) }
+
+	if !reverse {
+		z.MoveTo(f(x+r), f(y))
+		z.LineTo(f(x+w-r), f(y))
+		z.CubeTo(f(x+w-r+k), f(y), f(x+w), f(y+r-k), f(x+w), f(y+r))
+		z.LineTo(f(x+w), f(y+h-r))
+		z.CubeTo(f(x+w), f(y+h-r+k), f(x+w-r+k), f(y+h), f(x+w-r), f(y+h))
+		z.LineTo(f(x+r), f(y+h))
+		z.CubeTo(f(x+r-k), f(y+h), f(x), f(y+h-r+k), f(x), f(y+h-r))
+		z.LineTo(f(x), f(y+r))
+		z.CubeTo(f(x), f(y+r-k), f(x+r-k), f(y), f(x+r), f(y))
+	} else {
+		z.MoveTo(f(x+r), f(y))
+		z.CubeTo(f(x+r-k), f(y), f(x), f(y+r-k), f(x), f(y+r))
+		z.LineTo(f(x), f(y+h-r))
+		z.CubeTo(f(x), f(y+h-r+k), f(x+r-k), f(y+h), f(x+r), f(y+h))
+		z.LineTo(f(x+w-r), f(y+h))
+		z.CubeTo(f(x+w-r+k), f(y+h), f(x+w), f(y+h-r+k), f(x+w), f(y+h-r))
+		z.LineTo(f(x+w), f(y+r))
+		z.CubeTo(f(x+w), f(y+r-k), f(x+w-r+k), f(y), f(x+w-r), f(y))
+	}
+	z.ClosePath()
+}
+
+// fillRoundedRect paints a filled rounded rectangle
+func fillRoundedRect(dst draw.Image, col color.Color, x, y, w, h, r float64) {
+	b := dst.Bounds()
+	z := vector.NewRasterizer(b.Dx(), b.Dy())
+	roundedRectPath(z, x, y, w, h, r, false)
+	z.Draw(dst, b, image.NewUniform(col), image.Point{})
+}
+
+// strokeRoundedRect paints a border of the given width inside the rounded rectangle
+func strokeRoundedRect(dst draw.Image, col color.Color, x, y, w, h, r, width float64) {
+	if width <= 0 || w <= 2*width || h <= 2*width {
+		fillRoundedRect(dst, col, x, y, w, h, r)
+		return
+	}
+	b := dst.Bounds()
+	z := vector.NewRasterizer(b.Dx(), b.Dy())
+	roundedRectPath(z, x, y, w, h, r, false)
+	roundedRectPath(z, x+width, y+width, w-2*width, h-2*width, math.Max(0, r-width), true)
+	z.Draw(dst, b, image.NewUniform(col), image.Point{})
+}
+
+// fillStar paints a five-pointed star with outer radius r centred on (cx, cy)
+func fillStar(dst draw.Image, col color.Color, cx, cy, r float64) {
+	b := dst.Bounds()
+	z := vector.NewRasterizer(b.Dx(), b.Dy())
+	inner := r * 0.382
+	for i := 0; i < 10; i++ {
+		rad := r
+		if i%2 == 1 {
+			rad = inner
+		}
+		t := -math.Pi/2 + float64(i)*math.Pi/5
+		x := float32(cx + rad*math.Cos(t))
+		y := float32(cy + rad*math.Sin(t))
+		if i == 0 {
+			z.MoveTo(x, y)
+		} else {
+			z.LineTo(x, y)
+		}
+	}
+	z.ClosePath()
+	z.Draw(dst, b, image.NewUniform(col), image.Point{})
+}
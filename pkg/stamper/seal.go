@@ -0,0 +1,234 @@
+package stamper
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"CapGo/internal/pdfcanvas"
+
+	"golang.org/x/image/font"
+)
+
+// Seal describes a round or oval seal
+type Seal struct {
+	Width      int    `json:"width"`  // pixels, defaults to 1200
+	Height     int    `json:"height"` // pixels, equal to width for a round seal
+	Color      string `json:"color"`
+	TopText    string `json:"topText"`    // follows the upper arc, read left to right
+	BottomText string `json:"bottomText"` // follows the lower arc, read left to right
+	CenterText string `json:"centerText"` // lines separated by "\n"
+	Stars      bool   `json:"stars"`      // star separators between the top and bottom text
+}
+
+// RenderSeal renders a classic seal as a transparent image
+func RenderSeal(opts Seal) (*image.NRGBA, error) {
+	w, h := opts.Width, opts.Height
+	if w <= 0 {
+		w = 1200
+	}
+	if h <= 0 {
+		h = w
+	}
+	if w > 6000 || h > 6000 {
+		return nil, fmt.Errorf("seal size is limited to 6000 pixels")
+	}
+
+	col, err := pdfcanvas.ParseHexColor(defaultString(opts.Color, "#b22222"))
+	if err != nil {
+		return nil, err
+	}
+	c := col.NRGBA()
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	cx, cy := float64(w)/2, float64(h)/2
+	unit := math.Min(float64(w), float64(h)) / 2
+
+	// Outer and inner rings with the text band in between
+	outerRX, outerRY := cx*0.97, cy*0.97
+	outerT := unit * 0.045
+	band := unit * 0.22
+	innerRX, innerRY := outerRX-outerT-band, outerRY-outerT-band
+	innerT := unit * 0.018
+
+	fillEllipseRing(img, c, cx, cy, outerRX, outerRY, outerT)
+	fillEllipseRing(img, c, cx, cy, innerRX, innerRY, innerT)
+
+	// Ring text sits in the middle of the band
+	midRX := outerRX - outerT - band/2
+	midRY := outerRY - outerT - band/2
+	ring := newEllipseArc(cx, cy, midRX, midRY)
+
+	if opts.TopText != "" || opts.BottomText != "" {
+		size := band * 0.62
+		face, err := rasterFont(true, size)
+		if err != nil {
+			return nil, err
+		}
+		// Shrink the text when it would not fit on its half of the ring
+		maxLen := ring.length * 0.42
+		if opts.Stars {
+			maxLen = ring.length * 0.38
+		}
+		for _, s := range []string{opts.TopText, opts.BottomText} {
+			if l := textWidthPx(face, s); l > maxLen {
+				size *= maxLen / l
+			}
+		}
+		face, err = rasterFont(true, size)
+		if err != nil {
+			return nil, err
+		}
+		capHalf := float64(face.Metrics().CapHeight) / 64 / 2
+
+		if opts.TopText != "" {
+			// Glyph tops point outwards: baseline just inside the middle of the band
+			arc := newEllipseArc(cx, cy, midRX-capHalf, midRY-capHalf)
+			drawArcText(img, face, c, arc, opts.TopText, -math.Pi/2, false)
+		}
+		if opts.BottomText != "" {
+			// Glyph tops point towards the centre: baseline just outside the middle of the band
+			arc := newEllipseArc(cx, cy, midRX+capHalf, midRY+capHalf)
+			drawArcText(img, face, c, arc, opts.BottomText, math.Pi/2, true)
+		}
+	}
+
+	if opts.Stars {
+		r := band * 0.3
+		for _, t := range []float64{0, math.Pi} {
+			x, y := ring.point(t)
+			fillStar(img, c, x, y, r)
+		}
+	}
+
+	if opts.CenterText != "" {
+		if err := drawCenterText(img, c, cx, cy, innerRX-innerT, innerRY-innerT, opts.CenterText); err != nil {
+			return nil, err
+		}
+	}
+
+	return img, nil
+}
+
+// ellipseArc supports placing text along an ellipse by arc length
+type ellipseArc struct {
+	cx, cy, rx, ry float64
+	thetas, lens   []float64 // cumulative arc length from theta = -pi
+	length         float64
+}
+
+func newEllipseArc(cx, cy, rx, ry float64) *ellipseArc {
+	const steps = 2048
+	e := &ellipseArc{cx: cx, cy: cy, rx: rx, ry: ry}
+	px, py := e.point(-math.Pi)
+	total := 0.0
+	for i := 0; i <= steps; i++ {
+		t := -math.Pi + 2*math.Pi*float64(i)/steps
+		x, y := e.point(t)
+		total += math.Hypot(x-px, y-py)
+		px, py = x, y
+		e.thetas = append(e.thetas, t)
+		e.lens = append(e.lens, total)
+	}
+	e.length = total
+	return e
+}
+
+// point returns the position at parameter t (image coordinates, y down)
+func (e *ellipseArc) point(t float64) (float64, float64) {
+	return e.cx + e.rx*math.Cos(t), e.cy + e.ry*math.Sin(t)
+}
+
+// tangent returns the direction of travel for increasing t
+func (e *ellipseArc) tangent(t float64) float64 {
+	return math.Atan2(e.ry*math.Cos(t), -e.rx*math.Sin(t))
+}
+
+// lengthAt returns the arc length at parameter t
+func (e *ellipseArc) lengthAt(t float64) float64 {
+	for i, th := range e.thetas {
+		if th >= t {
+			return e.lens[i]
+		}
+	}
+	return e.length
+}
+
+// thetaAt returns the parameter at arc length s, wrapping around the ellipse
+func (e *ellipseArc) thetaAt(s float64) float64 {
+	s = math.Mod(s, e.length)
+	if s < 0 {
+		s += e.length
+	}
+	for i, l := range e.lens {
+		if l >= s {
+			return e.thetas[i]
+		}
+	}
+	return math.Pi
+}
+
+// drawArcText lays out text centred on parameter center. Reversed text runs against
+// the direction of travel so that it reads left to right along the bottom arc.
+func drawArcText(img *image.NRGBA, face font.Face, c color.Color, arc *ellipseArc, text string, center float64, reversed bool) {
+	total := textWidthPx(face, text)
+	start := arc.lengthAt(center)
+	dir := 1.0
+	if reversed {
+		dir = -1.0
+	}
+	pos := start - dir*total/2
+	for _, r := range text {
+		ch := string(r)
+		adv := textWidthPx(face, ch)
+		t := arc.thetaAt(pos + dir*adv/2)
+		x, y := arc.point(t)
+		angle := arc.tangent(t)
+		if reversed {
+			angle += math.Pi
+		}
+		if strings.TrimSpace(ch) != "" {
+			drawRotatedGlyph(img, face, c, x, y, angle, ch)
+		}
+		pos += dir * adv
+	}
+}
+
+// drawCenterText fits the lines of text inside the inner ellipse
+func drawCenterText(img *image.NRGBA, c color.Color, cx, cy, rx, ry float64, text string) error {
+	lines := strings.Split(strings.ReplaceAll(text, "\\n", "\n"), "\n")
+
+	// Start from a size derived from the available height and shrink until every line fits
+	size := ry * 1.4 / float64(len(lines)) / 1.2
+	for {
+		face, err := rasterFont(true, size)
+		if err != nil {
+			return err
+		}
+		m := face.Metrics()
+		lineH := float64(m.Height) / 64
+		blockH := lineH * float64(len(lines))
+		fits := blockH <= ry*1.5
+		for i, line := range lines {
+			// Width available at this line's distance from the centre
+			y := -blockH/2 + lineH*(float64(i)+0.5)
+			avail := 2 * rx * 0.85 * math.Sqrt(math.Max(0, 1-(y*y)/(ry*ry)))
+			if textWidthPx(face, line) > avail {
+				fits = false
+			}
+		}
+		if fits || size < 4 {
+			ascent := float64(m.Ascent) / 64
+			descent := float64(m.Descent) / 64
+			top := cy - blockH/2
+			for i, line := range lines {
+				baseline := top + lineH*float64(i) + (lineH-ascent-descent)/2 + ascent
+				drawTextPx(img, face, c, cx-textWidthPx(face, line)/2, baseline, line)
+			}
+			return nil
+		}
+		size *= 0.9
+	}
+}
@@ -0,0 +1,168 @@
+package stamper
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Stamp kinds
+const (
+	KindImage = "image"
+	KindText  = "text"
+)
+
+// Stamp is one image or text stamp placed on a page, in points from the top left
+// corner of the page as it is displayed
+type Stamp struct {
+	Kind     string     `json:"kind,omitempty"` // "image" (default) or "text"
+	Image    string     `json:"image"`          // a file path or a base64 data URL
+	Text     string     `json:"text,omitempty"`
+	FontName string     `json:"fontName,omitempty"`
+	FontSize int        `json:"fontSize,omitempty"` // 0 fits the text to the stamp box
+	Color    string     `json:"color,omitempty"`
+	Rotation int        `json:"rotation,omitempty"` // degrees counter-clockwise, multiple of 90
+	Style    *TextStyle `json:"style,omitempty"`
+	Opacity  *float64   `json:"opacity,omitempty"` // 0 (invisible) to 1; nil means fully opaque
+	X        float64    `json:"x"`
+	Y        float64    `json:"y"`
+	Width    float64    `json:"width"`
+	Height   float64    `json:"height"`
+	PageNum  int        `json:"pageNum"`
+	// RemoveBackground makes the white background of an image stamp transparent
+	RemoveBackground *BackgroundRemoval `json:"removeBackground,omitempty"`
+}
+
+// Options holds optional settings for Apply
+type Options struct {
+	// Flatten burns the stamps into the page content. By default they are added as
+	// watermarks that pdfcpu and other PDF tools can take off again.
+	Flatten bool
+}
+
+// Apply adds the stamps to a document read with the ADDWATERMARKS command. Stamps on
+// pages the document does not have are skipped.
+func Apply(ctx *model.Context, stamps []Stamp, opts Options) error {
+	dims, err := VisiblePageDims(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get page dimensions: %v", err)
+	}
+	if len(dims) == 0 {
+		return fmt.Errorf("the document has no pages")
+	}
+
+	wms := make([]*model.Watermark, len(stamps))
+	for i, stamp := range stamps {
+		if stamp.Opacity != nil && (*stamp.Opacity < 0 || *stamp.Opacity > 1) {
+			return fmt.Errorf("stamp %d has an invalid opacity %g, expected 0 to 1", i, *stamp.Opacity)
+		}
+		if stamp.RemoveBackground != nil {
+			if err := stamp.RemoveBackground.Check(); err != nil {
+				return fmt.Errorf("stamp %d: %v", i, err)
+			}
+		}
+		// Stamps are measured from the top of their own page as it is displayed
+		pdfHeight := dims[0].Height
+		if stamp.PageNum >= 1 && stamp.PageNum <= len(dims) {
+			pdfHeight = dims[stamp.PageNum-1].Height
+		}
+		if stamp.Kind == KindText {
+			wms[i], err = textWatermark(stamp, pdfHeight)
+			if err != nil {
+				return fmt.Errorf("failed to prepare text stamp %d: %v", i, err)
+			}
+		} else {
+			var imgPath string
+			wms[i], imgPath, err = imageWatermark(i, stamp, pdfHeight)
+			if err != nil {
+				return err
+			}
+			if imgPath != "" {
+				defer os.Remove(imgPath)
+			}
+		}
+	}
+
+	var before map[int]map[string]bool
+	if opts.Flatten {
+		if before, err = pageXObjectNames(ctx); err != nil {
+			return err
+		}
+	}
+	for _, pass := range watermarkPasses(stamps, wms, ctx.PageCount) {
+		if err := pdfcpu.AddWatermarksSliceMap(ctx, pass); err != nil {
+			return fmt.Errorf("failed to add watermarks: %v", err)
+		}
+	}
+	if opts.Flatten {
+		return flattenStamps(ctx, before)
+	}
+	return nil
+}
+
+// StampFile is Apply for the document at inFile, writing the result to outFile
+func StampFile(inFile, outFile string, stamps []Stamp, opts Options) error {
+	data, err := os.ReadFile(inFile)
+	if err != nil {
+		return err
+	}
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.ADDWATERMARKS
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(data), conf)
+	if err != nil {
+		return fmt.Errorf("failed to read pdf: %v", err)
+	}
+	if err := Apply(ctx, stamps, opts); err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := api.Write(ctx, &out, conf); err != nil {
+		return fmt.Errorf("failed to write pdf: %v", err)
+	}
+	return os.WriteFile(outFile, out.Bytes(), 0644)
+}
+
+// watermarkPasses groups the watermarks by page for pdfcpu. pdfcpu applies one opacity
+// and layer setting to everything it adds at once, so a new pass starts whenever those
+// change; consecutive runs keep the stacking order of the stamps. Stamps on pages the
+// document does not have are skipped.
+func watermarkPasses(stamps []Stamp, wms []*model.Watermark, pageCount int) []map[int][]*model.Watermark {
+	var passes []map[int][]*model.Watermark
+	var current map[int][]*model.Watermark
+	var onTop bool
+	var opacity float64
+	for i, wm := range wms {
+		page := stamps[i].PageNum
+		if page < 1 || page > pageCount {
+			continue
+		}
+		if current == nil || wm.OnTop != onTop || wm.Opacity != opacity {
+			current = map[int][]*model.Watermark{}
+			passes = append(passes, current)
+			onTop, opacity = wm.OnTop, wm.Opacity
+		}
+		current[page] = append(current[page], wm)
+	}
+	return passes
+}
+
+// opacityParam returns the pdfcpu "op:" parameter for a semi-transparent or invisible stamp
+func opacityParam(stamp Stamp) string {
+	if stamp.Opacity == nil || *stamp.Opacity >= 1 {
+		return ""
+	}
+	return fmt.Sprintf(", op:%.2f", *stamp.Opacity)
+}
+
+// NormalizeRotation maps a rotation in degrees onto 0, 90, 180 or 270
+func NormalizeRotation(deg int) (int, error) {
+	r := ((deg % 360) + 360) % 360
+	if r%90 != 0 {
+		return 0, fmt.Errorf("rotation must be a multiple of 90 degrees, got %d", deg)
+	}
+	return r, nil
+}
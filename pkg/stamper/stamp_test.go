@@ -0,0 +1,58 @@
+package stamper
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+func TestStampFile(t *testing.T) {
+	input := "../../testdata/golden/mixed_sizes.pdf"
+	output := filepath.Join(t.TempDir(), "out.pdf")
+	opacity := 0.5
+	stamps := []Stamp{
+		{Kind: KindText, Text: "RECEIVED 100%", X: 20, Y: 20, Width: 120, Height: 30, PageNum: 1},
+		{Image: "../../testdata/golden/signature.png", Opacity: &opacity, X: 20, Y: 60, Width: 100, Height: 40, PageNum: 2},
+		{Kind: KindText, Text: "skipped", Width: 50, Height: 20, PageNum: 99},
+	}
+	if err := StampFile(input, output, stamps, Options{Flatten: true}); err != nil {
+		t.Fatal(err)
+	}
+	want, err := api.PageCountFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := api.PageCountFile(output); err != nil || got != want {
+		t.Errorf("got %d pages (%v), want %d", got, err, want)
+	}
+
+	opacity = 2
+	err = StampFile(input, output, stamps, Options{})
+	if err == nil || !strings.Contains(err.Error(), "invalid opacity") {
+		t.Errorf("got error %v for an opacity of 2", err)
+	}
+}
+
+// FuzzStampImage feeds malformed images through the preparation of image stamps.
+// Errors are expected, panics are not.
+func FuzzStampImage(f *testing.F) {
+	signature, err := os.ReadFile("../../testdata/golden/signature.png")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(signature)
+	f.Add(signature[:len(signature)/2])
+	f.Add([]byte("GIF89a"))
+	f.Add([]byte{0xff, 0xd8, 0xff, 0xe0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		stamp := Stamp{Image: "data:image/png;base64," + base64.StdEncoding.EncodeToString(data), Width: 100, Height: 40}
+		wm, temp, err := imageWatermark(0, stamp, 842)
+		if err == nil && wm != nil {
+			os.Remove(temp)
+		}
+	})
+}
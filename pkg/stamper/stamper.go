@@ -0,0 +1,114 @@
+// Package stamper places image, text and SVG stamps on PDF pages. It has no Wails
+// dependency, so servers and command line tools can use it, and the app calls into it.
+//
+// Apply adds stamps to a document pdfcpu has read and StampFile to a file. Stamps come
+// in points with their page number; groups, numbering placeholders, preview units and
+// safe areas are resolved by the caller. Page selections, the seal and the stamp
+// designer are here too.
+//
+// Coordinates are in points from the top left corner of a page as it is displayed: its
+// crop box, turned by the page rotation. That is also the area pdfcpu positions
+// watermarks in and what previews show.
+package stamper
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// VisiblePageDims returns the size of every page as it is displayed: its crop box, turned
+// by the page rotation
+func VisiblePageDims(ctx *model.Context) ([]types.Dim, error) {
+	boundaries, err := ctx.PageBoundaries(nil)
+	if err != nil {
+		return nil, err
+	}
+	dims := make([]types.Dim, len(boundaries))
+	for i, pb := range boundaries {
+		box := pb.CropBox()
+		if box == nil {
+			return nil, fmt.Errorf("page %d has no media box", i+1)
+		}
+		dims[i] = box.Dimensions()
+		if pb.Rot%180 != 0 {
+			dims[i].Width, dims[i].Height = dims[i].Height, dims[i].Width
+		}
+	}
+	return dims, nil
+}
+
+// VisiblePageDimsFile is VisiblePageDims for the document at pdfPath
+func VisiblePageDimsFile(pdfPath string) ([]types.Dim, error) {
+	f, err := os.Open(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ctx, err := api.ReadAndValidate(f, model.NewDefaultConfiguration())
+	if err != nil {
+		return nil, err
+	}
+	return VisiblePageDims(ctx)
+}
+
+// Offset is where the top left corner of the crop box lies from the top left corner of
+// the media box, both as displayed
+type Offset struct {
+	X, Y float64
+}
+
+// CropOffset returns the offset of the crop box on a page turned by rot degrees. The
+// displayed top left corner is a different corner of the boxes for each rotation.
+func CropOffset(media, crop *types.Rectangle, rot int) Offset {
+	left, right := crop.LL.X-media.LL.X, media.UR.X-crop.UR.X
+	bottom, top := crop.LL.Y-media.LL.Y, media.UR.Y-crop.UR.Y
+	switch (rot%360 + 360) % 360 {
+	case 90:
+		return Offset{X: bottom, Y: left}
+	case 180:
+		return Offset{X: right, Y: bottom}
+	case 270:
+		return Offset{X: top, Y: right}
+	}
+	return Offset{X: left, Y: top}
+}
+
+// ClampToPage moves a stamp inside a page of the given size, keeping margin points
+// from every edge, and shrinks it proportionally when it is larger than that area. A
+// page smaller than twice the margin leaves the stamp as it is.
+func ClampToPage(stamp Stamp, dim types.Dim, margin float64) Stamp {
+	maxW := dim.Width - 2*margin
+	maxH := dim.Height - 2*margin
+	if maxW <= 0 || maxH <= 0 {
+		return stamp
+	}
+
+	// Shrink proportionally when the stamp is larger than the safe area
+	scale := 1.0
+	if stamp.Width > maxW {
+		scale = maxW / stamp.Width
+	}
+	if stamp.Height*scale > maxH {
+		scale = maxH / stamp.Height
+	}
+	stamp.Width *= scale
+	stamp.Height *= scale
+
+	if stamp.X < margin {
+		stamp.X = margin
+	}
+	if stamp.Y < margin {
+		stamp.Y = margin
+	}
+	if stamp.X+stamp.Width > dim.Width-margin {
+		stamp.X = dim.Width - margin - stamp.Width
+	}
+	if stamp.Y+stamp.Height > dim.Height-margin {
+		stamp.Y = dim.Height - margin - stamp.Height
+	}
+	return stamp
+}
@@ -0,0 +1,55 @@
+package stamper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func TestPageSelection(t *testing.T) {
+	cases := []struct {
+		expr  string
+		pages []int // nil when the expression or its pages are invalid
+	}{
+		{"all", []int{1, 2, 3, 4, 5}},
+		{"odd", []int{1, 3, 5}},
+		{"even", []int{2, 4}},
+		{"first, last", []int{1, 5}},
+		{"4-last,2", []int{2, 4, 5}},
+		{"2-3,3", []int{2, 3}},
+		{"3-1", nil},
+		{"last-2", nil},
+		{"0", nil},
+		{"6", nil},
+		{"", nil},
+	}
+	for _, c := range cases {
+		sel, err := ParsePageSelection(c.expr)
+		var pages []int
+		if err == nil {
+			pages, _ = sel.Pages(5)
+		}
+		if !reflect.DeepEqual(pages, c.pages) {
+			t.Errorf("%q selects %v, want %v", c.expr, pages, c.pages)
+		}
+	}
+}
+
+func TestCropOffset(t *testing.T) {
+	// 10 points cropped on the left, 20 at the bottom, 30 on the right and 40 at the top
+	media := types.NewRectangle(0, 0, 600, 800)
+	crop := types.NewRectangle(10, 20, 570, 760)
+	want := map[int]Offset{
+		0:   {X: 10, Y: 40},
+		90:  {X: 20, Y: 10},
+		180: {X: 30, Y: 20},
+		270: {X: 40, Y: 30},
+		-90: {X: 40, Y: 30},
+	}
+	for rot, w := range want {
+		if got := CropOffset(media, crop, rot); got != w {
+			t.Errorf("rotation %d: got %+v, want %+v", rot, got, w)
+		}
+	}
+}
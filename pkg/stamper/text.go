@@ -0,0 +1,151 @@
+package stamper
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	"CapGo/internal/pdfcanvas"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/color"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/draw"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// DefaultFont is the font of text stamps that name none
+const DefaultFont = "Helvetica"
+
+// textWatermark prepares the pdfcpu watermark for a text stamp.
+// The text is centred in the stamp box; for 90 and 270 degrees it runs along the box height,
+// which is how spine and margin labels are placed.
+func textWatermark(stamp Stamp, pdfHeight float64) (*model.Watermark, error) {
+	if strings.TrimSpace(stamp.Text) == "" {
+		return nil, fmt.Errorf("text stamp has no text")
+	}
+
+	fontName := stamp.FontName
+	if fontName == "" {
+		fontName = DefaultFont
+	}
+	if !font.SupportedFont(fontName) {
+		return nil, fmt.Errorf("unsupported font: %s", fontName)
+	}
+
+	rotation, err := NormalizeRotation(stamp.Rotation)
+	if err != nil {
+		return nil, err
+	}
+	vertical := rotation == 90 || rotation == 270
+
+	// Length available along the reading direction, and across it
+	along, across := stamp.Width, stamp.Height
+	if vertical {
+		along, across = stamp.Height, stamp.Width
+	}
+
+	// pdfcpu text watermarks replace %p, %P, %t and %v in the text with the page number,
+	// page count, time and version, with no way to escape them. Text stamps are drawn here
+	// instead and placed as PDF watermarks, which show the text as it was typed.
+	if stamp.Style != nil || font.IsCoreFont(fontName) {
+		return styledTextWatermark(stamp, fontName, rotation, along, across, pdfHeight)
+	}
+	return userFontTextWatermark(stamp, fontName, rotation, along, across, pdfHeight)
+}
+
+// userFontTextWatermark renders a text stamp in an installed TrueType font, which
+// pdfcanvas cannot embed. pdfcpu lays out the text on a one-page document of its own
+// that is then placed as a PDF watermark.
+func userFontTextWatermark(stamp Stamp, fontName string, rotation int, along, across, pdfHeight float64) (*model.Watermark, error) {
+	fontSize := stamp.FontSize
+	if fontSize <= 0 {
+		fontSize = fitFontSize(stamp.Text, fontName, along, across)
+	}
+	col, err := pdfcanvas.ParseHexColor(defaultString(stamp.Color, "#000000"))
+	if err != nil {
+		return nil, err
+	}
+
+	form := types.RectForDim(font.TextWidth(stamp.Text, fontName, fontSize), font.LineHeight(fontName, fontSize))
+	page := model.NewPage(form, form)
+	xRefTable, err := pdfcpu.CreateXRefTableWithRootDict()
+	if err != nil {
+		return nil, err
+	}
+	model.WriteMultiLine(xRefTable, page.Buf, form, nil, model.TextDescriptor{
+		Text:     stamp.Text,
+		FontName: fontName,
+		FontKey:  page.Fm.EnsureKey(fontName),
+		FontSize: fontSize,
+		Embed:    true,
+		Scale:    1,
+		ScaleAbs: true,
+		HAlign:   types.AlignLeft,
+		VAlign:   types.AlignBottom,
+		RMode:    draw.RMFill,
+		FillCol:  color.SimpleColor{R: float32(col.R), G: float32(col.G), B: float32(col.B)},
+	})
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	if err := pdfcpu.AddPageTreeWithSamplePage(xRefTable, rootDict, page); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := api.WriteContext(pdfcpu.CreateContext(xRefTable, model.NewDefaultConfiguration()), &buf); err != nil {
+		return nil, err
+	}
+
+	// Footprint on the page, centred in the stamp box
+	boxW, boxH := form.Width(), form.Height()
+	if rotation == 90 || rotation == 270 {
+		boxW, boxH = boxH, boxW
+	}
+	finalX := stamp.X + (stamp.Width-boxW)/2
+	finalY := pdfHeight - (stamp.Y + (stamp.Height-boxH)/2 + boxH)
+
+	// pdfcpu expects -180..180 and rotates counter-clockwise
+	rot := rotation
+	if rot == 270 {
+		rot = -90
+	}
+	desc := fmt.Sprintf("pos:bl, off:%f %f, scale:1 abs, rot:%d", finalX, finalY, rot) + opacityParam(stamp)
+	return api.PDFWatermarkForReadSeeker(bytes.NewReader(buf.Bytes()), 1, desc, true, false, types.POINTS)
+}
+
+// fitFontSize returns the largest font size at which text fits the given length and thickness
+func fitFontSize(text, fontName string, along, across float64) int {
+	size := font.Size(text, fontName, along)
+	if byHeight := font.SizeForLineHeight(fontName, across); byHeight < size {
+		size = byHeight
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// TextFits reports whether the text of a stamp at its font size fits inside the stamp box
+func TextFits(stamp Stamp) bool {
+	if stamp.FontSize <= 0 {
+		return true
+	}
+	fontName := stamp.FontName
+	if fontName == "" {
+		fontName = DefaultFont
+	}
+	if !font.SupportedFont(fontName) {
+		return false
+	}
+	along, across := stamp.Width, stamp.Height
+	if r, err := NormalizeRotation(stamp.Rotation); err == nil && (r == 90 || r == 270) {
+		along, across = stamp.Height, stamp.Width
+	}
+	return font.TextWidth(stamp.Text, fontName, stamp.FontSize) <= math.Ceil(along) &&
+		font.LineHeight(fontName, stamp.FontSize) <= math.Ceil(across)
+}
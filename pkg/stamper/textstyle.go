@@ -0,0 +1,192 @@
+package stamper
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"CapGo/internal/pdfcanvas"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// TextStyle holds the optional decoration of a text stamp, e.g. an outlined
+// "RECEIVED" on a rounded background with a soft shadow
+type TextStyle struct {
+	StrokeColor     string  `json:"strokeColor,omitempty"`
+	StrokeWidth     float64 `json:"strokeWidth,omitempty"`
+	ShadowColor     string  `json:"shadowColor,omitempty"`
+	ShadowOffsetX   float64 `json:"shadowOffsetX,omitempty"`
+	ShadowOffsetY   float64 `json:"shadowOffsetY,omitempty"` // positive moves the shadow down
+	ShadowOpacity   float64 `json:"shadowOpacity,omitempty"`
+	BackgroundColor string  `json:"backgroundColor,omitempty"`
+	BorderColor     string  `json:"borderColor,omitempty"`
+	BorderWidth     float64 `json:"borderWidth,omitempty"`
+	Padding         float64 `json:"padding,omitempty"`
+	CornerRadius    float64 `json:"cornerRadius,omitempty"`
+}
+
+// styledTextWatermark renders a text stamp in a standard font, with its decoration if it
+// has one, as a one-page vector PDF and returns it as a PDF watermark, so the result
+// stays sharp when printed
+func styledTextWatermark(stamp Stamp, fontName string, rotation int, along, across, pdfHeight float64) (*model.Watermark, error) {
+	var style TextStyle
+	if stamp.Style != nil {
+		style = *stamp.Style
+	}
+	if !font.IsCoreFont(fontName) {
+		return nil, fmt.Errorf("styled text stamps support the standard PDF fonts only, got %s", fontName)
+	}
+
+	textCol, err := pdfcanvas.ParseHexColor(defaultString(stamp.Color, "#000000"))
+	if err != nil {
+		return nil, err
+	}
+
+	hasBackground := style.BackgroundColor != "" || (style.BorderColor != "" && style.BorderWidth > 0)
+	hasShadow := style.ShadowColor != ""
+
+	// Space taken by decorations around the text
+	pad := math.Max(0, style.Padding)
+	border := 0.0
+	if style.BorderColor != "" {
+		border = math.Max(0, style.BorderWidth)
+	}
+	stroke := 0.0
+	if style.StrokeColor != "" {
+		stroke = math.Max(0, style.StrokeWidth)
+	}
+	shadowDX, shadowDY := 0.0, 0.0
+	if hasShadow {
+		shadowDX, shadowDY = style.ShadowOffsetX, style.ShadowOffsetY
+	}
+	extraAlong := 2*(pad+border) + stroke + math.Abs(shadowDX)
+	extraAcross := 2*(pad+border) + stroke + math.Abs(shadowDY)
+	if rotation == 90 || rotation == 270 {
+		extraAlong = 2*(pad+border) + stroke + math.Abs(shadowDY)
+		extraAcross = 2*(pad+border) + stroke + math.Abs(shadowDX)
+	}
+
+	size := float64(stamp.FontSize)
+	if size <= 0 {
+		size = float64(fitFontSize(stamp.Text, fontName, math.Max(1, along-extraAlong), math.Max(1, across-extraAcross)))
+	}
+
+	textW := pdfcanvas.CoreTextWidth(stamp.Text, fontName, size)
+	ascent := pdfcanvas.CoreAscent(fontName, size)
+	descent := pdfcanvas.CoreDescent(fontName, size)
+
+	// The pill around the text, and the full form including the shadow
+	pillW := textW + stroke + 2*(pad+border)
+	pillH := ascent + descent + stroke + 2*(pad+border)
+	formW := pillW + math.Abs(shadowDX)
+	formH := pillH + math.Abs(shadowDY)
+
+	// Pill origin inside the form, leaving room for the shadow on its side
+	px := math.Max(0, -shadowDX)
+	py := math.Max(0, shadowDY)
+
+	c := pdfcanvas.New(formW, formH)
+
+	drawPill := func() {
+		c.RoundedRect(px+border/2, py+border/2, pillW-border, pillH-border, style.CornerRadius)
+	}
+	baseX := px + border + pad + stroke/2
+	baseY := py + border + pad + stroke/2 + descent
+
+	if hasShadow {
+		shadowCol, err := pdfcanvas.ParseHexColor(style.ShadowColor)
+		if err != nil {
+			return nil, err
+		}
+		opacity := style.ShadowOpacity
+		if opacity <= 0 || opacity > 1 {
+			opacity = 0.5
+		}
+		c.Save()
+		c.SetOpacity(opacity)
+		c.Translate(shadowDX, -shadowDY)
+		c.SetFillColor(shadowCol)
+		if hasBackground {
+			drawPill()
+			c.Fill()
+		} else {
+			c.Text(baseX, baseY, fontName, size, stamp.Text, pdfcanvas.TextFill)
+		}
+		c.Restore()
+	}
+
+	if hasBackground {
+		c.Save()
+		mode := ""
+		if style.BackgroundColor != "" {
+			bg, err := pdfcanvas.ParseHexColor(style.BackgroundColor)
+			if err != nil {
+				return nil, err
+			}
+			c.SetFillColor(bg)
+			mode = "f"
+		}
+		if border > 0 {
+			bc, err := pdfcanvas.ParseHexColor(style.BorderColor)
+			if err != nil {
+				return nil, err
+			}
+			c.SetStrokeColor(bc)
+			c.SetLineWidth(border)
+			mode += "s"
+		}
+		drawPill()
+		switch mode {
+		case "f":
+			c.Fill()
+		case "s":
+			c.Stroke()
+		default:
+			c.FillStroke()
+		}
+		c.Restore()
+	}
+
+	c.Save()
+	c.SetFillColor(textCol)
+	mode := pdfcanvas.TextFill
+	if stroke > 0 {
+		sc, err := pdfcanvas.ParseHexColor(style.StrokeColor)
+		if err != nil {
+			return nil, err
+		}
+		c.SetStrokeColor(sc)
+		c.SetLineWidth(stroke)
+		mode = pdfcanvas.TextFillStroke
+	}
+	c.Text(baseX, baseY, fontName, size, stamp.Text, mode)
+	c.Restore()
+
+	// Footprint on the page, centred in the stamp box
+	boxW, boxH := formW, formH
+	if rotation == 90 || rotation == 270 {
+		boxW, boxH = formH, formW
+	}
+	finalX := stamp.X + (stamp.Width-boxW)/2
+	finalY := pdfHeight - (stamp.Y + (stamp.Height-boxH)/2 + boxH)
+
+	rot := rotation
+	if rot == 270 {
+		rot = -90
+	}
+	desc := fmt.Sprintf("pos:bl, off:%f %f, scale:1 abs, rot:%d", finalX, finalY, rot) + opacityParam(stamp)
+
+	return api.PDFWatermarkForReadSeeker(bytes.NewReader(pdfcanvas.Render(c)), 1, desc, true, false, types.POINTS)
+}
+
+// defaultString returns s, or def when s is empty
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Plugins are separate programs that add document operations, such as OCR or company
+// specific checks. Each one lives in its own folder inside <config>/plugins with a
+// plugin.json manifest:
+//
+//	{
+//	  "id": "acme-ocr",
+//	  "name": "Acme OCR",
+//	  "version": "1.2.0",
+//	  "command": "acme-ocr",        // program inside the plugin folder
+//	  "args": ["--capgo"],
+//	  "operations": [{"id": "ocr", "name": "Recognize text", "output": true}]
+//	}
+//
+// For every run CapGo starts the program, writes one PluginRequest as JSON to its stdin
+// and reads one PluginResponse from its stdout. Anything written to stderr is logged.
+//
+// An operation with a "language" param, like most OCR engines need, gets the Tesseract
+// languages of the document (e.g. "vie+eng", see DetectDocumentLanguage) when the caller
+// leaves it out.
+
+// pluginManifest is the name of the manifest inside a plugin folder
+const pluginManifest = "plugin.json"
+
+// pluginLanguageParam is the param filled in with the languages of the document
+const pluginLanguageParam = "language"
+
+// pluginTimeout bounds a single plugin run
+const pluginTimeout = 10 * time.Minute
+
+var pluginIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Plugin describes an installed plugin
+type Plugin struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Version     string            `json:"version"`
+	Description string            `json:"description,omitempty"`
+	Command     string            `json:"command"`
+	Args        []string          `json:"args,omitempty"`
+	Operations  []PluginOperation `json:"operations"`
+	Dir         string            `json:"dir"`             // set by CapGo
+	Error       string            `json:"error,omitempty"` // why the plugin cannot be used
+}
+
+// PluginOperation is one operation a plugin offers
+type PluginOperation struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Output      bool          `json:"output"` // writes a new document; validators leave it false
+	Params      []PluginParam `json:"params,omitempty"`
+}
+
+// PluginParam is a setting the frontend asks for before running an operation
+type PluginParam struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Default  string   `json:"default,omitempty"`
+	Options  []string `json:"options,omitempty"` // allowed values; free text when empty
+	Required bool     `json:"required,omitempty"`
+}
+
+// PluginRequest is sent to the plugin on stdin
+type PluginRequest struct {
+	Operation string            `json:"operation"`
+	Input     string            `json:"input"`
+	Output    string            `json:"output,omitempty"` // where to write the new document
+	Params    map[string]string `json:"params"`
+}
+
+// PluginResponse is read from the plugin's stdout
+type PluginResponse struct {
+	OK      bool     `json:"ok"`
+	Message string   `json:"message,omitempty"`
+	Issues  []string `json:"issues,omitempty"` // findings of a validator
+	Error   string   `json:"error,omitempty"`
+}
+
+// PluginResult is the outcome of RunPlugin
+type PluginResult struct {
+	Output  string   `json:"output,omitempty"` // the new document, empty for validators
+	Message string   `json:"message,omitempty"`
+	Issues  []string `json:"issues"`
+}
+
+func pluginsDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "plugins")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create plugins directory: %v", err)
+	}
+	return dir, nil
+}
+
+// ListPlugins returns the installed plugins. Plugins with a broken manifest are listed
+// with an Error so the user can see why they do not show up.
+func (a *App) ListPlugins() ([]Plugin, error) {
+	dir, err := pluginsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugins: %v", err)
+	}
+	plugins := []Plugin{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		p, err := loadPlugin(filepath.Join(dir, e.Name()))
+		if err != nil {
+			p = Plugin{ID: e.Name(), Name: e.Name(), Dir: filepath.Join(dir, e.Name()), Error: err.Error()}
+		}
+		plugins = append(plugins, p)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins, nil
+}
+
+// PluginsFolder returns the folder plugins are installed into
+func (a *App) PluginsFolder() (string, error) {
+	return pluginsDir()
+}
+
+// loadPlugin reads and checks the manifest in dir
+func loadPlugin(dir string) (Plugin, error) {
+	data, err := os.ReadFile(filepath.Join(dir, pluginManifest))
+	if err != nil {
+		return Plugin{}, fmt.Errorf("missing plugin.json")
+	}
+	var p Plugin
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Plugin{}, fmt.Errorf("invalid plugin.json: %v", err)
+	}
+	p.Dir, p.Error = dir, ""
+	if !pluginIDPattern.MatchString(p.ID) {
+		return Plugin{}, fmt.Errorf("invalid plugin id %q", p.ID)
+	}
+	if p.Name == "" {
+		p.Name = p.ID
+	}
+	if len(p.Operations) == 0 {
+		return Plugin{}, fmt.Errorf("plugin %s offers no operations", p.ID)
+	}
+	if _, err := p.executable(); err != nil {
+		return Plugin{}, err
+	}
+	return p, nil
+}
+
+// executable returns the plugin program, which must be inside the plugin folder
+func (p Plugin) executable() (string, error) {
+	if p.Command == "" || filepath.IsAbs(p.Command) {
+		return "", fmt.Errorf("plugin %s: command must be a program inside the plugin folder", p.ID)
+	}
+	path := filepath.Join(p.Dir, p.Command)
+	if rel, err := filepath.Rel(p.Dir, path); err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("plugin %s: command must be a program inside the plugin folder", p.ID)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return "", fmt.Errorf("plugin %s: program %s not found", p.ID, p.Command)
+	}
+	return path, nil
+}
+
+func (p Plugin) operation(id string) (PluginOperation, bool) {
+	for _, op := range p.Operations {
+		if op.ID == id {
+			return op, true
+		}
+	}
+	return PluginOperation{}, false
+}
+
+// RunPlugin runs a plugin operation on a document. Operations that write a document
+// produce a new file next to the other CapGo output; the source is never changed.
+func (a *App) RunPlugin(pluginID, operation, pdfPath string, params map[string]string) (PluginResult, error) {
+	dir, err := pluginsDir()
+	if err != nil {
+		return PluginResult{}, err
+	}
+	if !pluginIDPattern.MatchString(pluginID) {
+		return PluginResult{}, fmt.Errorf("invalid plugin id %q", pluginID)
+	}
+	p, err := findPlugin(dir, pluginID)
+	if err != nil {
+		return PluginResult{}, err
+	}
+	op, ok := p.operation(operation)
+	if !ok {
+		return PluginResult{}, fmt.Errorf("plugin %s has no operation %q", p.Name, operation)
+	}
+
+	if params == nil {
+		params = map[string]string{}
+	}
+	for _, param := range op.Params {
+		v, set := params[param.ID]
+		if !set && param.ID == pluginLanguageParam {
+			if ocr := a.documentLanguage(pdfPath).OCR; len(param.Options) == 0 || containsString(param.Options, ocr) {
+				params[param.ID], v, set = ocr, ocr, true
+			}
+		}
+		if !set && param.Default != "" {
+			params[param.ID], v = param.Default, param.Default
+		}
+		if param.Required && v == "" {
+			return PluginResult{}, fmt.Errorf("%s needs a value for %s", op.Name, param.Name)
+		}
+		if len(param.Options) > 0 && v != "" && !containsString(param.Options, v) {
+			return PluginResult{}, fmt.Errorf("invalid value %q for %s", v, param.Name)
+		}
+	}
+
+	pdfPath = filepath.Clean(pdfPath)
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return PluginResult{}, err
+	}
+
+	defer a.startJob("plugin:" + p.ID)()
+	if err := a.runBeforeHooks("plugin", pdfPath); err != nil {
+		return PluginResult{}, err
+	}
+
+	req := PluginRequest{Operation: op.ID, Input: pdfPath, Params: params}
+	var staging string
+	if op.Output {
+		staging, err = os.MkdirTemp("", "capgo_plugin_*")
+		if err != nil {
+			return PluginResult{}, fmt.Errorf("failed to create temp folder: %v", err)
+		}
+		defer os.RemoveAll(staging)
+		req.Output = filepath.Join(staging, "output.pdf")
+	}
+
+	resp, err := runPluginProcess(p, req)
+	if err != nil {
+		return PluginResult{}, err
+	}
+	res := PluginResult{Message: resp.Message, Issues: resp.Issues}
+	if res.Issues == nil {
+		res.Issues = []string{}
+	}
+	if !op.Output {
+		return res, nil
+	}
+
+	data, err := os.ReadFile(req.Output)
+	if err != nil {
+		return PluginResult{}, fmt.Errorf("plugin %s did not write a document", p.Name)
+	}
+	output, err := a.outputPath(pdfPath, "_"+op.ID)
+	if err != nil {
+		return PluginResult{}, err
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return PluginResult{}, classifyFileError("write", output, err)
+	}
+	if err := a.finishOutput(output); err != nil {
+		os.Remove(output)
+		return PluginResult{}, err
+	}
+	res.Output = output
+	a.runAfterHooks("plugin", pdfPath, output)
+	return res, nil
+}
+
+// findPlugin looks up an installed plugin by id
+func findPlugin(dir, id string) (Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return Plugin{}, fmt.Errorf("failed to read plugins: %v", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if p, err := loadPlugin(filepath.Join(dir, e.Name())); err == nil && p.ID == id {
+			return p, nil
+		}
+	}
+	return Plugin{}, fmt.Errorf("plugin %s is not installed", id)
+}
+
+// runPluginProcess sends req to a new plugin process and reads its response
+func runPluginProcess(p Plugin, req PluginRequest) (PluginResponse, error) {
+	exe, err := p.executable()
+	if err != nil {
+		return PluginResponse{}, err
+	}
+	input, err := json.Marshal(req)
+	if err != nil {
+		return PluginResponse{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, exe, p.Args...)
+	cmd.Dir = p.Dir
+	cmd.Stdin = bytes.NewReader(append(input, '\n'))
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return PluginResponse{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return PluginResponse{}, fmt.Errorf("failed to start plugin %s: %v", p.Name, err)
+	}
+	logPluginOutput(p.ID, stderr)
+	err = cmd.Wait()
+	if ctx.Err() == context.DeadlineExceeded {
+		return PluginResponse{}, fmt.Errorf("plugin %s did not finish within %v", p.Name, pluginTimeout)
+	}
+
+	var resp PluginResponse
+	if derr := json.Unmarshal(bytes.TrimSpace(stdout.Bytes()), &resp); derr != nil {
+		if err != nil {
+			return PluginResponse{}, fmt.Errorf("plugin %s failed: %v", p.Name, err)
+		}
+		return PluginResponse{}, fmt.Errorf("plugin %s sent an invalid response: %v", p.Name, derr)
+	}
+	if !resp.OK {
+		msg := resp.Error
+		if msg == "" {
+			msg = "unknown error"
+		}
+		return PluginResponse{}, fmt.Errorf("plugin %s: %s", p.Name, msg)
+	}
+	if err != nil {
+		return PluginResponse{}, fmt.Errorf("plugin %s failed: %v", p.Name, err)
+	}
+	return resp, nil
+}
+
+// logPluginOutput copies the plugin's stderr into the log until it is closed
+func logPluginOutput(id string, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fmt.Printf("Plugin %s: %s\n", id, scanner.Text())
+	}
+	// Keep draining after an overlong line so the plugin does not block
+	io.Copy(io.Discard, r)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"CapGo/internal/pdfcanvas"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// CreatePortfolio bundles the files into a PDF collection (portfolio). The container
+// document is a generated cover sheet listing its contents, so viewers without portfolio
+// support still show what is inside. An empty output writes to Downloads.
+func (a *App) CreatePortfolio(files []string, output string) (string, error) {
+	if len(files) == 0 {
+		return "", fmt.Errorf("no files given")
+	}
+
+	if output == "" {
+		var err error
+		output, err = a.outputPath(files[0], "_portfolio")
+		if err != nil {
+			return "", err
+		}
+	}
+	output = filepath.Clean(output)
+	if !strings.EqualFold(filepath.Ext(output), ".pdf") {
+		output += ".pdf"
+	}
+	dir, err := a.outputDir(filepath.Dir(output))
+	if err != nil {
+		return "", err
+	}
+	if dir != filepath.Dir(output) {
+		output = uniquePath(filepath.Join(dir, filepath.Base(output)))
+	}
+
+	defer a.startJob("portfolio")()
+	for _, f := range files {
+		if err := a.runBeforeHooks("portfolio", filepath.Clean(f)); err != nil {
+			return "", err
+		}
+	}
+
+	staging, err := os.MkdirTemp("", "capgo_portfolio_*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp folder: %v", err)
+	}
+	defer os.RemoveAll(staging)
+
+	// Attachments are keyed by file name, so duplicate names are staged under a unique name
+	attachments := make([]string, 0, len(files))
+	seen := map[string]bool{}
+	var infos []os.FileInfo
+	for _, f := range files {
+		f = filepath.Clean(f)
+		info, err := os.Stat(f)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %v", f, err)
+		}
+		if info.IsDir() {
+			return "", fmt.Errorf("%s is a folder", f)
+		}
+
+		name := info.Name()
+		if seen[strings.ToLower(name)] {
+			ext := filepath.Ext(name)
+			base := strings.TrimSuffix(name, ext)
+			for n := 2; seen[strings.ToLower(name)]; n++ {
+				name = fmt.Sprintf("%s (%d)%s", base, n, ext)
+			}
+			data, err := os.ReadFile(f)
+			if err != nil {
+				return "", fmt.Errorf("failed to read %s: %v", f, err)
+			}
+			staged := filepath.Join(staging, name)
+			if err := os.WriteFile(staged, data, 0644); err != nil {
+				return "", fmt.Errorf("failed to stage %s: %v", f, err)
+			}
+			os.Chtimes(staged, info.ModTime(), info.ModTime())
+			f = staged
+		}
+		seen[strings.ToLower(name)] = true
+		attachments = append(attachments, f)
+		infos = append(infos, info)
+	}
+
+	title := strings.TrimSuffix(filepath.Base(output), filepath.Ext(output))
+	cover := filepath.Join(staging, "cover.pdf")
+	paper, err := a.paperSize("")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(cover, pdfcanvas.Render(portfolioCover(paper, title, a.now(), attachments, infos)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write cover sheet: %v", err)
+	}
+
+	if err := api.AddAttachmentsFile(cover, output, attachments, true, nil); err != nil {
+		os.Remove(output)
+		return "", fmt.Errorf("failed to create portfolio: %v", err)
+	}
+	if err := a.finishOutput(output); err != nil {
+		os.Remove(output)
+		return "", err
+	}
+	a.runAfterHooks("portfolio", filepath.Clean(files[0]), output)
+	return output, nil
+}
+
+// portfolioCover lays out a cover sheet with the title and a table of the bundled files
+func portfolioCover(paper PaperSize, title string, created time.Time, files []string, infos []os.FileInfo) *pdfcanvas.Canvas {
+	const (
+		margin  = 56.0
+		bold    = "Helvetica-Bold"
+		regular = "Helvetica"
+	)
+	width, height := paper.Width, paper.Height
+	c := pdfcanvas.New(width, height)
+	gray := pdfcanvas.RGB{R: 0.4, G: 0.4, B: 0.4}
+	black := pdfcanvas.RGB{}
+
+	y := height - margin - 24
+	c.SetFillColor(black)
+	c.Text(margin, y, bold, 24, fitText(title, bold, 24, width-2*margin), pdfcanvas.TextFill)
+
+	y -= 22
+	c.SetFillColor(gray)
+	subtitle := tr("Portfolio of %d documents, created %s", len(files), formatDate(created))
+	c.Text(margin, y, regular, 11, subtitle, pdfcanvas.TextFill)
+
+	y -= 18
+	c.SetStrokeColor(gray)
+	c.SetLineWidth(0.5)
+	c.Op("%.4f %.4f m %.4f %.4f l S", margin, y, width-margin, y)
+
+	sizeCol := width - margin - 70
+	for i, f := range files {
+		y -= 24
+		if y < margin {
+			c.SetFillColor(gray)
+			c.Text(margin, y+8, regular, 10, tr("and %d more", len(files)-i), pdfcanvas.TextFill)
+			break
+		}
+		c.SetFillColor(black)
+		c.Text(margin, y, regular, 11, fmt.Sprintf("%d.", i+1), pdfcanvas.TextFill)
+		c.Text(margin+24, y, regular, 11, fitText(filepath.Base(f), regular, 11, sizeCol-margin-34), pdfcanvas.TextFill)
+		c.SetFillColor(gray)
+		size := formatFileSize(infos[i].Size())
+		c.Text(width-margin-pdfcanvas.CoreTextWidth(size, regular, 10), y, regular, 10, size, pdfcanvas.TextFill)
+	}
+
+	c.SetFillColor(gray)
+	c.Text(margin, margin-20, regular, 9, tr("Open the attachments panel of your PDF viewer to access the documents."), pdfcanvas.TextFill)
+	return c
+}
+
+// fitText shortens s with an ellipsis until it fits maxWidth
+func fitText(s, fontName string, size, maxWidth float64) string {
+	if pdfcanvas.CoreTextWidth(s, fontName, size) <= maxWidth {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && pdfcanvas.CoreTextWidth(string(r)+"…", fontName, size) > maxWidth {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "…"
+}
+
+// formatFileSize renders a byte count for display
+func formatFileSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.0f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
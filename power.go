@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Power modes for batch jobs
+const (
+	PowerModeAuto  = "auto"  // throttle on battery and in low power mode
+	PowerModeFull  = "full"  // always use every core
+	PowerModeSaver = "saver" // always run one file at a time
+)
+
+// PowerSettings controls how many files batch jobs process in parallel
+type PowerSettings struct {
+	Mode       string `json:"mode"`
+	MaxWorkers int    `json:"maxWorkers"` // upper limit in every mode, 0 for no limit
+}
+
+// PowerState describes the current power source and the resulting batch parallelism
+type PowerState struct {
+	OnBattery bool   `json:"onBattery"`
+	LowPower  bool   `json:"lowPower"`
+	Mode      string `json:"mode"`
+	Workers   int    `json:"workers"`
+}
+
+func defaultPowerSettings() PowerSettings {
+	return PowerSettings{Mode: PowerModeAuto}
+}
+
+// powerProbe caches the detected power source, since checking it runs external tools
+var powerProbe struct {
+	mu        sync.Mutex
+	checked   time.Time
+	onBattery bool
+	lowPower  bool
+}
+
+const powerProbeInterval = 30 * time.Second
+
+// GetPowerSettings returns the batch throttling settings
+func (a *App) GetPowerSettings() PowerSettings {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.settings.Power
+}
+
+// SetPowerSettings updates and persists the batch throttling settings
+func (a *App) SetPowerSettings(ps PowerSettings) error {
+	switch ps.Mode {
+	case PowerModeAuto, PowerModeFull, PowerModeSaver:
+	default:
+		return fmt.Errorf("invalid power mode: %q", ps.Mode)
+	}
+	if ps.MaxWorkers < 0 {
+		return fmt.Errorf("max workers cannot be negative")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settings.Power = ps
+	return a.saveSettings()
+}
+
+// GetPowerState returns the detected power source and the number of files batch jobs
+// currently process in parallel
+func (a *App) GetPowerState() PowerState {
+	ps := a.GetPowerSettings()
+	onBattery, lowPower := detectPower()
+	return PowerState{
+		OnBattery: onBattery,
+		LowPower:  lowPower,
+		Mode:      ps.Mode,
+		Workers:   batchWorkers(ps, onBattery, lowPower),
+	}
+}
+
+// batchWorkerCount returns the worker pool size for a batch job right now
+func (a *App) batchWorkerCount() int {
+	return a.GetPowerState().Workers
+}
+
+// batchWorkers sizes the worker pool: every core on mains power, a quarter of them on
+// battery and a single worker in low power mode
+func batchWorkers(ps PowerSettings, onBattery, lowPower bool) int {
+	n := goruntime.NumCPU()
+	switch ps.Mode {
+	case PowerModeSaver:
+		n = 1
+	case PowerModeAuto:
+		if lowPower {
+			n = 1
+		} else if onBattery {
+			n /= 4
+		}
+	}
+	if ps.MaxWorkers > 0 && n > ps.MaxWorkers {
+		n = ps.MaxWorkers
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// detectPower reports whether the machine runs on battery and whether low power mode
+// (or a power saving profile) is active
+func detectPower() (onBattery, lowPower bool) {
+	powerProbe.mu.Lock()
+	defer powerProbe.mu.Unlock()
+	if time.Since(powerProbe.checked) < powerProbeInterval {
+		return powerProbe.onBattery, powerProbe.lowPower
+	}
+
+	switch goruntime.GOOS {
+	case "darwin":
+		if out, err := exec.Command("pmset", "-g", "batt").Output(); err == nil {
+			onBattery = strings.Contains(string(out), "'Battery Power'")
+		}
+		if out, err := exec.Command("pmset", "-g").Output(); err == nil {
+			for _, line := range strings.Split(string(out), "\n") {
+				f := strings.Fields(line)
+				if len(f) == 2 && f[0] == "lowpowermode" && f[1] == "1" {
+					lowPower = true
+				}
+			}
+		}
+	case "linux":
+		supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+		for _, dir := range supplies {
+			kind, _ := os.ReadFile(filepath.Join(dir, "type"))
+			if strings.TrimSpace(string(kind)) != "Battery" {
+				continue
+			}
+			status, _ := os.ReadFile(filepath.Join(dir, "status"))
+			if strings.TrimSpace(string(status)) == "Discharging" {
+				onBattery = true
+			}
+		}
+		if profile, err := os.ReadFile("/sys/firmware/acpi/platform_profile"); err == nil {
+			lowPower = strings.TrimSpace(string(profile)) == "low-power"
+		}
+	case "windows":
+		// BatteryStatus 1 means the battery is discharging
+		out, err := exec.Command("powershell", "-NoProfile", "-Command",
+			"(Get-CimInstance Win32_Battery).BatteryStatus").Output()
+		if err == nil {
+			onBattery = strings.TrimSpace(string(out)) == "1"
+		}
+	}
+
+	powerProbe.checked = time.Now()
+	powerProbe.onBattery, powerProbe.lowPower = onBattery, lowPower
+	return onBattery, lowPower
+}
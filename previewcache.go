@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Preview image formats
+const (
+	PreviewFormatJPEG = "jpeg"
+	PreviewFormatPNG  = "png"
+)
+
+// PreviewSettings controls how page previews are encoded and how much disk the preview
+// cache may use
+type PreviewSettings struct {
+	Format     string `json:"format"`     // PreviewFormatJPEG or PreviewFormatPNG
+	Quality    int    `json:"quality"`    // JPEG quality from 1 to 100
+	MaxCacheMB int    `json:"maxCacheMB"` // 0 turns the cache off
+	// CacheDir is where previews are kept; empty uses a folder in the user cache directory
+	CacheDir string `json:"cacheDir,omitempty"`
+}
+
+func defaultPreviewSettings() PreviewSettings {
+	return PreviewSettings{Format: PreviewFormatJPEG, Quality: 80, MaxCacheMB: 200}
+}
+
+// previewFilePattern matches the files the preview cache writes, so clearing a cache
+// folder the user picked never removes anything else
+var previewFilePattern = regexp.MustCompile(`^[0-9a-f]{64}\.(jpg|png)$`)
+
+// previewCacheMu serializes writing, evicting and clearing cache files
+var previewCacheMu sync.Mutex
+
+// GetPreviewSettings returns the preview quality and cache settings
+func (a *App) GetPreviewSettings() PreviewSettings {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.settings.Previews
+}
+
+// SetPreviewSettings updates and persists the preview settings. Moving the cache
+// removes the previews from the old location.
+func (a *App) SetPreviewSettings(settings PreviewSettings) error {
+	if settings.Format != PreviewFormatJPEG && settings.Format != PreviewFormatPNG {
+		return fmt.Errorf("unknown preview format: %s", settings.Format)
+	}
+	if settings.Quality < 1 || settings.Quality > 100 {
+		return fmt.Errorf("preview quality must be between 1 and 100")
+	}
+	if settings.MaxCacheMB < 0 {
+		return fmt.Errorf("preview cache size cannot be negative")
+	}
+	if settings.CacheDir != "" {
+		settings.CacheDir = filepath.Clean(settings.CacheDir)
+		if err := os.MkdirAll(settings.CacheDir, 0755); err != nil {
+			return classifyFileError("write", settings.CacheDir, err)
+		}
+	}
+
+	old := a.GetPreviewSettings()
+	a.mu.Lock()
+	a.settings.Previews = settings
+	err := a.saveSettings()
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if oldDir, err := previewCacheDir(old); err == nil {
+		if newDir, err := previewCacheDir(settings); err == nil && !samePath(oldDir, newDir) {
+			clearPreviewDir(oldDir)
+		}
+	}
+	// A smaller limit or another format applies to the previews already cached
+	if settings.Format != old.Format || settings.Quality != old.Quality {
+		_, err = a.ClearPreviewCache()
+		return err
+	}
+	return a.trimPreviewCache()
+}
+
+// ClearPreviewCache removes every cached preview and returns the bytes freed
+func (a *App) ClearPreviewCache() (int64, error) {
+	dir, err := previewCacheDir(a.GetPreviewSettings())
+	if err != nil {
+		return 0, err
+	}
+	freed := clearPreviewDir(dir)
+	fmt.Printf("Backend: Cleared preview cache, freed %d bytes\n", freed)
+	return freed, nil
+}
+
+// GetCachedPreview returns the cached preview of a page as a data URL, or "" when
+// there is none for the current version of the document
+func (a *App) GetCachedPreview(pdfPath string, page, width int) (string, error) {
+	path, err := a.previewPath(pdfPath, page, width)
+	if err != nil || path == "" {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", classifyFileError("read", path, err)
+	}
+	// The modification time orders the previews for eviction
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return "data:" + previewMIME(path) + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// StorePreview adds a page preview rendered by the frontend to the cache. The image is
+// encoded again with the preview settings.
+func (a *App) StorePreview(pdfPath string, page, width int, dataURL string) error {
+	_, encoded, ok := strings.Cut(dataURL, ";base64,")
+	if !ok {
+		return fmt.Errorf("preview is not a base64 data URL")
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("failed to decode preview: %v", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to decode preview: %v", err)
+	}
+	return a.cachePreview(pdfPath, page, width, img)
+}
+
+// cachePreview encodes a page preview with the preview settings and stores it
+func (a *App) cachePreview(pdfPath string, page, width int, img image.Image) error {
+	path, err := a.previewPath(pdfPath, page, width)
+	if err != nil || path == "" {
+		return err
+	}
+	data, err := encodePreview(img, a.GetPreviewSettings())
+	if err != nil {
+		return err
+	}
+	previewCacheMu.Lock()
+	err = os.WriteFile(path, data, 0644)
+	previewCacheMu.Unlock()
+	if err != nil {
+		return classifyFileError("write", path, err)
+	}
+	return a.trimPreviewCache()
+}
+
+// encodePreview encodes a preview in the configured format
+func encodePreview(img image.Image, settings PreviewSettings) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if settings.Format == PreviewFormatPNG {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: settings.Quality})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode preview: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// previewPath returns the cache file of a page preview, or "" when the cache is off.
+// The key includes the size and modification time of the document, so previews of an
+// older version are never returned.
+func (a *App) previewPath(pdfPath string, page, width int) (string, error) {
+	settings := a.GetPreviewSettings()
+	if settings.MaxCacheMB == 0 {
+		return "", nil
+	}
+	pdfPath = filepath.Clean(pdfPath)
+	info, err := os.Stat(pdfPath)
+	if err != nil {
+		return "", classifyFileError("read", pdfPath, err)
+	}
+	dir, err := previewCacheDir(settings)
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("%s\x00%d\x00%d\x00%d\x00%d\x00%s\x00%d", pdfPath, info.Size(), info.ModTime().UnixNano(), page, width, settings.Format, settings.Quality)
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+previewExt(settings)), nil
+}
+
+// previewExt returns the file extension of previews encoded with settings
+func previewExt(settings PreviewSettings) string {
+	if settings.Format == PreviewFormatPNG {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+// previewCacheDir returns the cache folder, creating it if needed
+func previewCacheDir(settings PreviewSettings) (string, error) {
+	dir := settings.CacheDir
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("could not get cache directory: %v", err)
+		}
+		dir = filepath.Join(base, "CapGo", "previews")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create preview cache directory: %v", err)
+	}
+	return dir, nil
+}
+
+func previewMIME(path string) string {
+	if strings.HasSuffix(path, ".png") {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
+
+type previewFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// previewFiles lists the previews in a cache folder, oldest first
+func previewFiles(dir string) []previewFile {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []previewFile
+	for _, e := range entries {
+		if e.IsDir() || !previewFilePattern.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, previewFile{path: filepath.Join(dir, e.Name()), size: info.Size(), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	return files
+}
+
+// clearPreviewDir removes the previews in a cache folder and returns the bytes freed
+func clearPreviewDir(dir string) int64 {
+	previewCacheMu.Lock()
+	defer previewCacheMu.Unlock()
+	var freed int64
+	for _, f := range previewFiles(dir) {
+		if os.Remove(f.path) == nil {
+			freed += f.size
+		}
+	}
+	return freed
+}
+
+// trimPreviewCache removes the least recently used previews until the cache fits its limit
+func (a *App) trimPreviewCache() error {
+	settings := a.GetPreviewSettings()
+	dir, err := previewCacheDir(settings)
+	if err != nil {
+		return err
+	}
+	previewCacheMu.Lock()
+	defer previewCacheMu.Unlock()
+	files := previewFiles(dir)
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	limit := int64(settings.MaxCacheMB) << 20
+	for _, f := range files {
+		if total <= limit {
+			break
+		}
+		if os.Remove(f.path) == nil {
+			total -= f.size
+		}
+	}
+	return nil
+}
+
+// previewCacheSize returns the bytes used by the preview cache
+func previewCacheSize(settings PreviewSettings) int64 {
+	dir, err := previewCacheDir(settings)
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for _, f := range previewFiles(dir) {
+		total += f.size
+	}
+	return total
+}
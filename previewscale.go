@@ -0,0 +1,96 @@
+package main
+
+import "fmt"
+
+// PreviewScale describes how the frontend currently renders PDF pages
+type PreviewScale struct {
+	Scale            float64 `json:"scale"`            // preview CSS pixels per PDF point
+	DevicePixelRatio float64 `json:"devicePixelRatio"` // device pixels per CSS pixel on the window's display
+}
+
+// Stamp coordinate units
+const (
+	StampUnitsPoints = ""       // PDF points, the default
+	StampUnitsCSS    = "css"    // CSS pixels of the preview
+	StampUnitsDevice = "device" // device pixels of the preview
+)
+
+// Stamp coordinate origins. With a top left origin Y is the distance from the top of the
+// page to the top of the stamp, as in the browser; with a bottom left origin it is the
+// distance from the bottom of the page to the bottom of the stamp, as in PDF.
+const (
+	StampOriginTopLeft    = "tl" // the default, also when empty
+	StampOriginBottomLeft = "bl"
+)
+
+// SetPreviewScale records the render scale of the preview. The frontend calls it whenever
+// the zoom changes or the window moves to a display with a different pixel ratio.
+func (a *App) SetPreviewScale(scale PreviewScale) error {
+	if scale.Scale <= 0 {
+		return fmt.Errorf("preview scale must be positive")
+	}
+	if scale.DevicePixelRatio <= 0 {
+		scale.DevicePixelRatio = 1
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.preview = scale
+	return nil
+}
+
+// GetPreviewScale returns the last recorded preview render scale
+func (a *App) GetPreviewScale() PreviewScale {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.preview
+}
+
+// NormalizeStampCoordinates converts stamps given in preview pixels to PDF points
+func (a *App) NormalizeStampCoordinates(stamps []StampInfo) ([]StampInfo, error) {
+	return normalizeStampUnits(stamps, a.GetPreviewScale())
+}
+
+// normalizeStampUnits converts stamp geometry to PDF points. A stamp that carries its own
+// preview scale was measured at that scale, so a display change between measuring and
+// stamping does not move it; other stamps use the recorded scale.
+func normalizeStampUnits(stamps []StampInfo, recorded PreviewScale) ([]StampInfo, error) {
+	out := make([]StampInfo, len(stamps))
+	for i, s := range stamps {
+		out[i] = s
+		if s.Origin != "" && s.Origin != StampOriginTopLeft && s.Origin != StampOriginBottomLeft {
+			return nil, fmt.Errorf("stamp %d has unknown origin: %s", i, s.Origin)
+		}
+		if s.Units == StampUnitsPoints {
+			continue
+		}
+
+		scale := recorded
+		if s.Preview != nil {
+			scale = *s.Preview
+		}
+		if scale.Scale <= 0 {
+			return nil, fmt.Errorf("stamp %d is in preview pixels but no preview scale is known", i)
+		}
+		if scale.DevicePixelRatio <= 0 {
+			scale.DevicePixelRatio = 1
+		}
+
+		var factor float64
+		switch s.Units {
+		case StampUnitsCSS:
+			factor = scale.Scale
+		case StampUnitsDevice:
+			factor = scale.Scale * scale.DevicePixelRatio
+		default:
+			return nil, fmt.Errorf("stamp %d has unknown units: %s", i, s.Units)
+		}
+		out[i].X = s.X / factor
+		out[i].Y = s.Y / factor
+		out[i].Width = s.Width / factor
+		out[i].Height = s.Height / factor
+		out[i].Units = StampUnitsPoints
+		out[i].Preview = nil
+	}
+	return out, nil
+}
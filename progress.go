@@ -0,0 +1,133 @@
+package main
+
+import "context"
+
+// Stages reported in JobProgress
+const (
+	StageReading    = "reading"
+	StageStamping   = "stamping"
+	StageImages     = "images" // downsampling and recompressing images
+	StageOptimizing = "optimizing"
+	StageWriting    = "writing"
+	StageFinishing  = "finishing" // size target, metadata, accessibility and hooks
+)
+
+var (
+	stampStages    = []string{StageReading, StageStamping, StageWriting, StageFinishing}
+	optimizeStages = []string{StageReading, StageImages, StageOptimizing, StageWriting}
+)
+
+// JobProgress is sent when a background job enters a stage. Current is the 1-based
+// number of that stage out of Total.
+type JobProgress struct {
+	JobID   string `json:"jobId"`
+	Stage   string `json:"stage"`
+	Current int    `json:"current"`
+	Total   int    `json:"total"`
+}
+
+// JobResult is sent when a background job is done. Result holds what the blocking
+// call returns, e.g. the output path of StartStampPDF.
+type JobResult struct {
+	JobID     string      `json:"jobId"`
+	Kind      string      `json:"kind"`
+	Result    interface{} `json:"result,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Cancelled bool        `json:"cancelled,omitempty"`
+}
+
+// jobProgress reports the progress of one background job and tells it when it was
+// cancelled. A nil *jobProgress reports nothing and is never cancelled, so the
+// blocking calls share the code.
+type jobProgress struct {
+	a      *App
+	ctx    context.Context
+	job    *backgroundJob
+	event  string   // sent when a stage starts, none when empty
+	stages []string // stages of the operation, in order
+}
+
+// stage reports that the job entered a stage. It returns errCancelled once the job was
+// cancelled, so the operation stops between stages.
+func (p *jobProgress) stage(name string) error {
+	if p == nil {
+		return nil
+	}
+	if err := p.cancelled(); err != nil {
+		return err
+	}
+	for i, s := range p.stages {
+		if s == name {
+			p.update(name, i+1, len(p.stages))
+			if p.event != "" {
+				p.a.emit(p.event, JobProgress{JobID: p.id(), Stage: name, Current: i + 1, Total: len(p.stages)})
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// update records the progress for JobStatus
+func (p *jobProgress) update(stage string, current, total int) {
+	if p == nil {
+		return
+	}
+	jobManager.mu.Lock()
+	p.job.info.Stage, p.job.info.Current, p.job.info.Total = stage, current, total
+	jobManager.mu.Unlock()
+}
+
+// id returns the job id, "" for the blocking calls
+func (p *jobProgress) id() string {
+	if p == nil {
+		return ""
+	}
+	return p.job.info.ID
+}
+
+// context returns the context that is cancelled by CancelJob
+func (p *jobProgress) context() context.Context {
+	if p == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+// quiet returns a progress for a part of the job, which is cancelled with the job but
+// reports nothing of its own
+func (p *jobProgress) quiet() *jobProgress {
+	if p == nil {
+		return nil
+	}
+	return &jobProgress{a: p.a, ctx: p.ctx, job: p.job}
+}
+
+// cancelled returns errCancelled once the job was cancelled
+func (p *jobProgress) cancelled() error {
+	if p != nil && p.ctx.Err() != nil {
+		return errCancelled
+	}
+	return nil
+}
+
+// StartStampPDF stamps a PDF like StampPDFWithOptions but returns a job id at once.
+// Progress is sent as EventStampProgress and the output path as EventJobResult.
+func (a *App) StartStampPDF(pdfPath string, stamps []StampInfo, opts StampOptions) string {
+	id, _ := a.StartJob(JobRequest{Kind: JobStamp, PdfPath: pdfPath, Stamps: stamps, Options: opts})
+	return id
+}
+
+// StartOptimizePDF optimizes a PDF like OptimizePDF but returns a job id at once.
+// Progress is sent as EventOptimizeProgress and the OptimizeResult as EventJobResult.
+func (a *App) StartOptimizePDF(pdfPath, preset string) string {
+	id, _ := a.StartJob(JobRequest{Kind: JobOptimize, PdfPath: pdfPath, Preset: preset})
+	return id
+}
+
+// StartBatchStampPDFs stamps many PDFs like BatchStampPDFs but returns a job id at
+// once. Every file is reported as EventBatchStampProgress and the items as EventJobResult.
+func (a *App) StartBatchStampPDFs(pdfPaths []string, stamps []StampInfo, placementSpec string) string {
+	id, _ := a.StartJob(JobRequest{Kind: JobBatchStamp, Files: pdfPaths, Stamps: stamps, Placement: placementSpec})
+	return id
+}
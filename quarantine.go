@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QuarantineInfo describes the download flag the operating system puts on files from
+// the internet: the com.apple.quarantine attribute on macOS and the Zone.Identifier
+// stream (Mark of the Web) on Windows
+type QuarantineInfo struct {
+	Quarantined  bool      `json:"quarantined"`
+	Agent        string    `json:"agent,omitempty"`        // application that downloaded the file, e.g. "Safari"
+	DownloadedAt time.Time `json:"downloadedAt,omitempty"` // zero when unknown
+	Source       string    `json:"source,omitempty"`       // download URL, when recorded
+}
+
+const quarantineAttr = "com.apple.quarantine"
+
+// GetQuarantine reports whether a file carries the download quarantine flag
+func (a *App) GetQuarantine(path string) (QuarantineInfo, error) {
+	path = filepath.Clean(path)
+	if _, err := os.Stat(path); err != nil {
+		return QuarantineInfo{}, err
+	}
+	return readQuarantine(path), nil
+}
+
+// ClearQuarantine removes the download quarantine flag from a file. Files without the
+// flag are left alone.
+func (a *App) ClearQuarantine(path string) error {
+	path = filepath.Clean(path)
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	if !readQuarantine(path).Quarantined {
+		return nil
+	}
+
+	switch goruntime.GOOS {
+	case "darwin":
+		if out, err := exec.Command("xattr", "-d", quarantineAttr, path).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to clear quarantine: %v: %s", err, strings.TrimSpace(string(out)))
+		}
+	case "windows":
+		if err := os.Remove(path + ":Zone.Identifier"); err != nil {
+			return fmt.Errorf("failed to clear quarantine: %v", err)
+		}
+	}
+	fmt.Printf("Backend: Cleared quarantine flag on %s\n", path)
+	return nil
+}
+
+// readQuarantine reads the quarantine flag; files whose flag cannot be read are
+// reported as not quarantined
+func readQuarantine(path string) QuarantineInfo {
+	switch goruntime.GOOS {
+	case "darwin":
+		// xattr exits with an error when the attribute is missing
+		out, err := exec.Command("xattr", "-p", quarantineAttr, path).Output()
+		if err != nil {
+			return QuarantineInfo{}
+		}
+		return parseAppleQuarantine(strings.TrimSpace(string(out)))
+	case "windows":
+		data, err := os.ReadFile(path + ":Zone.Identifier")
+		if err != nil {
+			return QuarantineInfo{}
+		}
+		return parseZoneIdentifier(string(data))
+	}
+	return QuarantineInfo{}
+}
+
+// parseAppleQuarantine parses "flags;hex timestamp;agent;event id"
+func parseAppleQuarantine(value string) QuarantineInfo {
+	info := QuarantineInfo{Quarantined: true}
+	fields := strings.Split(value, ";")
+	if len(fields) > 1 {
+		if secs, err := strconv.ParseInt(fields[1], 16, 64); err == nil && secs > 0 {
+			info.DownloadedAt = time.Unix(secs, 0)
+		}
+	}
+	if len(fields) > 2 {
+		info.Agent = fields[2]
+	}
+	return info
+}
+
+// parseZoneIdentifier parses the Zone.Identifier stream. Zones 3 (internet) and 4
+// (restricted) are the ones Windows treats as downloaded.
+func parseZoneIdentifier(data string) QuarantineInfo {
+	var info QuarantineInfo
+	for _, line := range strings.Split(data, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "ZoneId":
+			info.Quarantined = value == "3" || value == "4"
+		case "HostUrl":
+			info.Source = value
+		}
+	}
+	return info
+}
+
+// quarantineCheck is the preflight check for the download flag
+func quarantineCheck(path string) FilingCheck {
+	q := readQuarantine(path)
+	check := FilingCheck{Rule: FilingRuleQuarantine, Passed: !q.Quarantined, Message: "file is not flagged as downloaded"}
+	if q.Quarantined {
+		check.Message = "file is quarantined as a download"
+		if q.Agent != "" {
+			check.Message += " from " + q.Agent
+		}
+		check.Message += "; other apps may refuse to open it until the quarantine flag is cleared"
+	}
+	return check
+}
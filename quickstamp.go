@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"CapGo/pkg/stamper"
+
+	"github.com/wailsapp/wails/v2/pkg/menu"
+	"github.com/wailsapp/wails/v2/pkg/menu/keys"
+)
+
+// QuickStamp configures the menu-bar mode, in which CapGo keeps running with its window
+// hidden and stamps documents with a default set of stamps straight from the menu
+type QuickStamp struct {
+	MenuBarMode bool        `json:"menuBarMode"`
+	Stamps      []StampInfo `json:"stamps"` // applied by QuickStampDocument
+}
+
+// GetQuickStamp returns the quick stamp configuration
+func (a *App) GetQuickStamp() QuickStamp {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.settings.QuickStamp
+}
+
+// SetQuickStamp updates and persists the quick stamp configuration. Menu-bar mode takes
+// effect the next time the window is closed.
+func (a *App) SetQuickStamp(qs QuickStamp) error {
+	for i, s := range qs.Stamps {
+		if s.Pages != "" {
+			if _, err := stamper.ParsePageSelection(s.Pages); err != nil {
+				return fmt.Errorf("quick stamp %d: %v", i, err)
+			}
+		} else if s.PageNum < 1 {
+			return fmt.Errorf("quick stamp %d needs a page number", i)
+		}
+		if s.Width <= 0 || s.Height <= 0 {
+			return fmt.Errorf("quick stamp %d has an invalid size", i)
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settings.QuickStamp = qs
+	return a.saveSettings()
+}
+
+// QuickStampDocument stamps the open document, or the most recently downloaded PDF when
+// none is open, with the default quick stamps and returns the path of the result
+func (a *App) QuickStampDocument() (string, error) {
+	target := a.GetDocumentState().Path
+	if target == "" {
+		var err error
+		if target, err = lastDownloadedPDF(); err != nil {
+			return "", err
+		}
+	}
+	return a.quickStamp(target)
+}
+
+// quickStamp applies the default quick stamps to a document
+func (a *App) quickStamp(pdfPath string) (string, error) {
+	qs := a.GetQuickStamp()
+	if len(qs.Stamps) == 0 {
+		return "", fmt.Errorf("no quick stamps are configured")
+	}
+
+	outputPath, err := a.StampPDF(pdfPath, qs.Stamps)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Backend: Quick stamped %s\n", pdfPath)
+	a.emit(EventQuickStampDone, outputPath)
+	a.jobFinished("quickstamp", tr("Stamped %s", filepath.Base(pdfPath)), false)
+	return outputPath, nil
+}
+
+// lastDownloadedPDF returns the newest PDF in Downloads that CapGo did not write itself
+func lastDownloadedPDF() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get home directory: %v", err)
+	}
+	dir := filepath.Join(homeDir, "Downloads")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Downloads: %v", err)
+	}
+
+	var newest string
+	var newestTime time.Time
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".pdf") {
+			continue
+		}
+		if strings.Contains(e.Name(), "_capgo") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if newest == "" || info.ModTime().After(newestTime) {
+			newest, newestTime = filepath.Join(dir, e.Name()), info.ModTime()
+		}
+	}
+	if newest == "" {
+		return "", fmt.Errorf("no PDF found in Downloads")
+	}
+	return newest, nil
+}
+
+// quickStampMenu adds the quick stamp actions to the menu bar, so they stay reachable
+// while the window is hidden
+func (a *App) quickStampMenu(bar *menu.Menu) {
+	sub := bar.AddSubmenu(tr("Quick Stamp"))
+	a.hotkeyMenu(sub)
+	sub.AddSeparator()
+	sub.AddText(tr("Show CapGo"), keys.CmdOrCtrl("1"), func(*menu.CallbackData) { a.showWindow() })
+}
+
+// beforeClose hides the window instead of quitting when menu-bar mode is on
+func (a *App) beforeClose(ctx context.Context) bool {
+	if a.window.Kind == WindowMain && a.GetQuickStamp().MenuBarMode {
+		a.hideWindow()
+		return true
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recentFilesFile keeps the recent documents in the config directory
+const recentFilesFile = "recent.json"
+
+// maxRecentFiles is how many documents that are not pinned the list keeps
+const maxRecentFiles = 20
+
+// RecentFile is a document that was opened recently
+type RecentFile struct {
+	Path     string    `json:"path"`
+	Name     string    `json:"name"`
+	OpenedAt time.Time `json:"openedAt"`
+	Pinned   bool      `json:"pinned"` // kept at the top and never dropped
+	// Exists is false when the file was moved or deleted, or is on a drive that is
+	// not connected; it is checked every time the list is read
+	Exists bool `json:"exists"`
+}
+
+// recentFilesMu serializes changes to the recent files list
+var recentFilesMu sync.Mutex
+
+// GetRecentFiles returns the recent documents, pinned ones first, then the most
+// recently opened
+func (a *App) GetRecentFiles() ([]RecentFile, error) {
+	recentFilesMu.Lock()
+	files, err := readRecentFiles()
+	recentFilesMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	for i := range files {
+		info, err := os.Stat(files[i].Path)
+		files[i].Exists = err == nil && !info.IsDir()
+	}
+	return files, nil
+}
+
+// ClearRecentFiles empties the recent documents list, except for pinned documents
+func (a *App) ClearRecentFiles() error {
+	return updateRecentFiles(func(files []RecentFile) []RecentFile {
+		pinned := []RecentFile{}
+		for _, f := range files {
+			if f.Pinned {
+				pinned = append(pinned, f)
+			}
+		}
+		return pinned
+	})
+}
+
+// PinRecentFile pins or unpins a document of the recent documents list
+func (a *App) PinRecentFile(path string, pinned bool) error {
+	found := false
+	err := updateRecentFiles(func(files []RecentFile) []RecentFile {
+		for i := range files {
+			if samePath(files[i].Path, path) {
+				files[i].Pinned = pinned
+				found = true
+			}
+		}
+		return files
+	})
+	if err == nil && !found {
+		return fmt.Errorf("%s is not in the recent files", filepath.Base(path))
+	}
+	return err
+}
+
+// RemoveRecentFile takes a document off the recent documents list, pinned or not
+func (a *App) RemoveRecentFile(path string) error {
+	return updateRecentFiles(func(files []RecentFile) []RecentFile {
+		kept := files[:0]
+		for _, f := range files {
+			if !samePath(f.Path, path) {
+				kept = append(kept, f)
+			}
+		}
+		return kept
+	})
+}
+
+// addRecentFile moves a document to the top of the recent documents list. A list
+// that cannot be updated is not worth failing the open for.
+func addRecentFile(path string) {
+	path = filepath.Clean(path)
+	if !filepath.IsAbs(path) || !strings.EqualFold(filepath.Ext(path), ".pdf") {
+		return
+	}
+	err := updateRecentFiles(func(files []RecentFile) []RecentFile {
+		entry := RecentFile{Path: path, Name: filepath.Base(path), OpenedAt: time.Now()}
+		kept := []RecentFile{}
+		for _, f := range files {
+			if samePath(f.Path, path) {
+				entry.Pinned = f.Pinned
+				continue
+			}
+			kept = append(kept, f)
+		}
+		return append([]RecentFile{entry}, kept...)
+	})
+	if err != nil {
+		fmt.Printf("Backend: Could not update recent files: %v\n", err)
+	}
+}
+
+// updateRecentFiles applies change to the stored list, then sorts, trims and saves it
+func updateRecentFiles(change func([]RecentFile) []RecentFile) error {
+	recentFilesMu.Lock()
+	defer recentFilesMu.Unlock()
+	files, err := readRecentFiles()
+	if err != nil {
+		return err
+	}
+	files = change(files)
+	sortRecentFiles(files)
+	kept := []RecentFile{}
+	unpinned := 0
+	for _, f := range files {
+		if !f.Pinned {
+			if unpinned == maxRecentFiles {
+				continue
+			}
+			unpinned++
+		}
+		f.Exists = false
+		kept = append(kept, f)
+	}
+	return writeConfigJSON(recentFilesFile, kept)
+}
+
+func readRecentFiles() ([]RecentFile, error) {
+	files := []RecentFile{}
+	if err := readConfigJSON(recentFilesFile, &files); err != nil {
+		return nil, err
+	}
+	sortRecentFiles(files)
+	return files, nil
+}
+
+func sortRecentFiles(files []RecentFile) {
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].Pinned != files[j].Pinned {
+			return files[i].Pinned
+		}
+		return files[i].OpenedAt.After(files[j].OpenedAt)
+	})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// Resolutions RenderPage accepts, in dots per inch
+const (
+	defaultRenderDPI = 96
+	maxRenderDPI     = 600
+)
+
+// RenderPage rasterizes a page (1-based) to PNG at dpi, 96 when 0. Pages are rendered
+// in the backend by Ghostscript, so the preview shows the crop box and rotation the
+// stamper works with instead of what a browser renderer makes of them.
+func (a *App) RenderPage(pdfPath string, page int, dpi int) (_ []byte, err error) {
+	pdfPath = filepath.Clean(pdfPath)
+	if dpi == 0 {
+		dpi = defaultRenderDPI
+	}
+	if dpi < 0 || dpi > maxRenderDPI {
+		return nil, fmt.Errorf("resolution must be between 1 and %d dpi", maxRenderDPI)
+	}
+	gs := ghostscriptPath()
+	if gs == "" {
+		return nil, fmt.Errorf("rendering pages needs Ghostscript, which is not installed")
+	}
+	defer recoverDamaged(pdfPath, &err)
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return nil, err
+	}
+	pageCount, err := api.PageCountFile(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", filepath.Base(pdfPath), err)
+	}
+	if page < 1 || page > pageCount {
+		return nil, fmt.Errorf("page %d does not exist in the %d page document", page, pageCount)
+	}
+
+	timeout := time.Duration(a.GetOperationTimeouts().Seconds) * time.Second
+	data, err := ghostscriptPNG(gs, pdfPath, page, page, dpi, "-", timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render page %d of %s: %v", page, filepath.Base(pdfPath), err)
+	}
+	return data, nil
+}
+
+// ghostscriptPNG renders pages first to last to PNG at output, a file name pattern with
+// %d for the page counted from first, or "-" to return the image of a single page.
+// There is no time limit when timeout is 0.
+func ghostscriptPNG(gs, input string, first, last, dpi int, output string, timeout time.Duration) ([]byte, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, gs, "-q", "-dNOPAUSE", "-dBATCH", "-dSAFER",
+		"-sDEVICE=png16m", "-dUseCropBox", "-dTextAlphaBits=4", "-dGraphicsAlphaBits=4",
+		"-r"+strconv.Itoa(dpi), "-dFirstPage="+strconv.Itoa(first), "-dLastPage="+strconv.Itoa(last),
+		"-sOutputFile="+output, input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("ghostscript did not finish within %v", timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if output == "-" && stdout.Len() == 0 {
+		return nil, fmt.Errorf("ghostscript wrote no image")
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ResourceStats reports the memory and disk footprint of CapGo
+type ResourceStats struct {
+	HeapBytes  uint64       `json:"heapBytes"` // memory in use by Go objects
+	SysBytes   uint64       `json:"sysBytes"`  // memory obtained from the operating system
+	Goroutines int          `json:"goroutines"`
+	TempBytes  int64        `json:"tempBytes"` // CapGo files in the temp folder
+	TempFiles  int          `json:"tempFiles"`
+	ActiveJobs []ActiveJob  `json:"activeJobs"`
+	Caches     []CacheStats `json:"caches"`
+}
+
+// ActiveJob is a running operation
+type ActiveJob struct {
+	ID        int       `json:"id"`
+	Kind      string    `json:"kind"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// CacheStats reports the size of one cache
+type CacheStats struct {
+	Name   string `json:"name"`
+	Bytes  int64  `json:"bytes"`
+	OnDisk bool   `json:"onDisk"`
+}
+
+// resourceCache is a cache that can report its size and be emptied to free memory
+type resourceCache struct {
+	name string
+	size func() int64
+	drop func()
+}
+
+var resources struct {
+	mu     sync.Mutex
+	jobs   map[int]ActiveJob
+	nextID int
+	caches []resourceCache
+}
+
+// tempPatterns match the files and folders CapGo creates in the temp folder
+var tempPatterns = []string{"intermediate_*.pdf", "stamp_*.png", "capgo_*"}
+
+// staleTempAge is how old a temp file must be before ReduceMemory removes it
+const staleTempAge = time.Hour
+
+// registerCache adds an in-memory cache to the resource report and to ReduceMemory
+func registerCache(name string, size func() int64, drop func()) {
+	resources.mu.Lock()
+	defer resources.mu.Unlock()
+	resources.caches = append(resources.caches, resourceCache{name: name, size: size, drop: drop})
+}
+
+// startJob records a running operation; the returned function marks it finished
+func (a *App) startJob(kind string) func() {
+	resources.mu.Lock()
+	if resources.jobs == nil {
+		resources.jobs = map[int]ActiveJob{}
+	}
+	resources.nextID++
+	job := ActiveJob{ID: resources.nextID, Kind: kind, StartedAt: time.Now()}
+	resources.jobs[job.ID] = job
+	resources.mu.Unlock()
+
+	a.emit(EventJobStarted, job)
+	return func() {
+		resources.mu.Lock()
+		delete(resources.jobs, job.ID)
+		resources.mu.Unlock()
+		a.emit(EventJobEnded, JobEnded{ActiveJob: job, EndedAt: time.Now()})
+	}
+}
+
+// GetResourceStats returns the current memory use, temp disk use, running jobs and cache sizes
+func (a *App) GetResourceStats() ResourceStats {
+	var mem goruntime.MemStats
+	goruntime.ReadMemStats(&mem)
+
+	stats := ResourceStats{
+		HeapBytes:  mem.HeapAlloc,
+		SysBytes:   mem.Sys,
+		Goroutines: goruntime.NumGoroutine(),
+		ActiveJobs: []ActiveJob{},
+		Caches:     []CacheStats{},
+	}
+	for _, f := range capgoTempFiles() {
+		stats.TempBytes += f.size
+		stats.TempFiles++
+	}
+
+	resources.mu.Lock()
+	for _, j := range resources.jobs {
+		stats.ActiveJobs = append(stats.ActiveJobs, j)
+	}
+	caches := append([]resourceCache{}, resources.caches...)
+	resources.mu.Unlock()
+	sort.Slice(stats.ActiveJobs, func(i, j int) bool { return stats.ActiveJobs[i].ID < stats.ActiveJobs[j].ID })
+
+	for _, c := range caches {
+		stats.Caches = append(stats.Caches, CacheStats{Name: c.name, Bytes: c.size()})
+	}
+	if dir, err := historyDir(); err == nil {
+		stats.Caches = append(stats.Caches, CacheStats{Name: "history", Bytes: dirSize(dir), OnDisk: true})
+	}
+	stats.Caches = append(stats.Caches, CacheStats{Name: "previews", Bytes: previewCacheSize(a.GetPreviewSettings()), OnDisk: true})
+	return stats
+}
+
+// ReduceMemory empties the in-memory caches, removes stale temp files and returns
+// freed memory to the operating system
+func (a *App) ReduceMemory() ResourceStats {
+	resources.mu.Lock()
+	caches := append([]resourceCache{}, resources.caches...)
+	resources.mu.Unlock()
+	for _, c := range caches {
+		c.drop()
+	}
+
+	// Files of running jobs are recent, so only old leftovers are removed. The open
+	// document may itself be a temp file, e.g. after pages were rearranged.
+	open := a.GetDocumentState().Path
+	removed := 0
+	for _, f := range capgoTempFiles() {
+		if !samePath(f.path, open) && time.Since(f.modTime) > staleTempAge {
+			if err := os.RemoveAll(f.path); err == nil {
+				removed++
+			}
+		}
+	}
+
+	debug.FreeOSMemory()
+	stats := a.GetResourceStats()
+	fmt.Printf("Backend: Reduced memory, removed %d stale temp files, heap now %d bytes\n", removed, stats.HeapBytes)
+	return stats
+}
+
+type tempFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// capgoTempFiles lists the CapGo files and folders in the temp folder
+func capgoTempFiles() []tempFile {
+	var files []tempFile
+	for _, pattern := range tempPatterns {
+		matches, _ := filepath.Glob(filepath.Join(os.TempDir(), pattern))
+		for _, m := range matches {
+			info, err := os.Stat(m)
+			if err != nil {
+				continue
+			}
+			size := info.Size()
+			if info.IsDir() {
+				size = dirSize(m)
+			}
+			files = append(files, tempFile{path: m, size: size, modTime: info.ModTime()})
+		}
+	}
+	return files
+}
+
+// dirSize returns the total size of the files below dir
+func dirSize(dir string) int64 {
+	var total int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Kinds of file CapGo keeps on its own behalf
+const (
+	ArtifactHistory     = "history"     // version history records, see GetDocumentHistory
+	ArtifactPreviews    = "previews"    // cached page previews and thumbnails
+	ArtifactTemp        = "temp"        // leftovers in the temp folder
+	ArtifactUpdates     = "updates"     // downloaded update images
+	ArtifactCheckpoints = "checkpoints" // interrupted batch jobs, see ListBatches
+)
+
+// artifactKinds lists the kinds in the order the storage report shows them
+var artifactKinds = []string{ArtifactHistory, ArtifactPreviews, ArtifactTemp, ArtifactUpdates, ArtifactCheckpoints}
+
+// updateDownloadPrefix starts the name of downloaded updates
+const updateDownloadPrefix = "CapGo-Update-"
+
+// retentionStatusFile records when the retention cleanup last ran
+const retentionStatusFile = "retention_status.json"
+
+// The scheduled cleanup waits a little after launch, so it never slows down the start,
+// and then checks every hour whether it is due
+const (
+	retentionStartDelay    = 2 * time.Minute
+	retentionCheckInterval = time.Hour
+)
+
+// RetentionSettings says how many days each kind of file is kept after it was last
+// written or used; 0 keeps it forever
+type RetentionSettings struct {
+	HistoryDays    int `json:"historyDays"`
+	PreviewDays    int `json:"previewDays"`
+	TempDays       int `json:"tempDays"`
+	UpdateDays     int `json:"updateDays"`
+	CheckpointDays int `json:"checkpointDays"`
+	// IntervalHours is how often the cleanup runs while CapGo is open; 0 only runs it
+	// on request
+	IntervalHours int `json:"intervalHours"`
+}
+
+func defaultRetentionSettings() RetentionSettings {
+	return RetentionSettings{HistoryDays: 365, PreviewDays: 30, TempDays: 1, UpdateDays: 14, CheckpointDays: 30, IntervalHours: 24}
+}
+
+// days returns the retention of a kind
+func (r RetentionSettings) days(kind string) int {
+	switch kind {
+	case ArtifactHistory:
+		return r.HistoryDays
+	case ArtifactPreviews:
+		return r.PreviewDays
+	case ArtifactTemp:
+		return r.TempDays
+	case ArtifactUpdates:
+		return r.UpdateDays
+	case ArtifactCheckpoints:
+		return r.CheckpointDays
+	}
+	return 0
+}
+
+// StorageUsage is the disk use of one kind of file
+type StorageUsage struct {
+	Kind          string    `json:"kind"`
+	Folder        string    `json:"folder,omitempty"`
+	Bytes         int64     `json:"bytes"`
+	Files         int       `json:"files"`
+	Oldest        time.Time `json:"oldest"` // zero when there are no files
+	RetentionDays int       `json:"retentionDays"`
+}
+
+// StorageReport is the outcome of GetStorageUsage
+type StorageReport struct {
+	Usage       []StorageUsage `json:"usage"`
+	TotalBytes  int64          `json:"totalBytes"`
+	LastCleanup time.Time      `json:"lastCleanup"` // zero before the first cleanup
+}
+
+// CleanupResult is the outcome of RunRetentionCleanup
+type CleanupResult struct {
+	Removed    []StorageUsage `json:"removed"` // what was removed of each kind
+	FreedBytes int64          `json:"freedBytes"`
+	At         time.Time      `json:"at"`
+}
+
+// retentionStatus is stored in retentionStatusFile
+type retentionStatus struct {
+	LastRun time.Time `json:"lastRun"`
+}
+
+// storedArtifact is one file or folder of a kind, with how to remove it
+type storedArtifact struct {
+	tempFile
+	remove func() error
+}
+
+// GetRetentionSettings returns how long CapGo keeps the files it manages
+func (a *App) GetRetentionSettings() RetentionSettings {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.settings.Retention
+}
+
+// SetRetentionSettings updates and persists the retention settings
+func (a *App) SetRetentionSettings(r RetentionSettings) error {
+	for _, kind := range artifactKinds {
+		if r.days(kind) < 0 {
+			return fmt.Errorf("retention cannot be negative")
+		}
+	}
+	if r.IntervalHours < 0 {
+		return fmt.Errorf("cleanup interval cannot be negative")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settings.Retention = r
+	return a.saveSettings()
+}
+
+// GetStorageUsage returns how much disk each kind of file CapGo manages takes up
+func (a *App) GetStorageUsage() (StorageReport, error) {
+	retention := a.GetRetentionSettings()
+	report := StorageReport{Usage: []StorageUsage{}}
+	for _, kind := range artifactKinds {
+		folder, files, err := a.storedArtifacts(kind)
+		if err != nil {
+			return StorageReport{}, err
+		}
+		u := StorageUsage{Kind: kind, Folder: folder, Files: len(files), RetentionDays: retention.days(kind)}
+		for _, f := range files {
+			u.Bytes += f.size
+			if u.Oldest.IsZero() || f.modTime.Before(u.Oldest) {
+				u.Oldest = f.modTime
+			}
+		}
+		report.Usage = append(report.Usage, u)
+		report.TotalBytes += u.Bytes
+	}
+	var status retentionStatus
+	readConfigJSON(retentionStatusFile, &status)
+	report.LastCleanup = status.LastRun
+	return report, nil
+}
+
+// RunRetentionCleanup removes the files that are older than their retention. Files
+// that cannot be removed are skipped and tried again on the next run.
+func (a *App) RunRetentionCleanup() (CleanupResult, error) {
+	retention := a.GetRetentionSettings()
+	now := time.Now()
+	res := CleanupResult{Removed: []StorageUsage{}, At: now}
+	// The open document may itself be a temp file, e.g. after pages were rearranged
+	open := a.GetDocumentState().Path
+	for _, kind := range artifactKinds {
+		days := retention.days(kind)
+		if days == 0 {
+			continue
+		}
+		cutoff := now.AddDate(0, 0, -days)
+		folder, files, err := a.storedArtifacts(kind)
+		if err != nil {
+			return res, err
+		}
+		removed := StorageUsage{Kind: kind, Folder: folder, RetentionDays: days}
+		for _, f := range files {
+			if !f.modTime.Before(cutoff) || (open != "" && samePath(f.path, open)) {
+				continue
+			}
+			if err := f.remove(); err != nil {
+				fmt.Printf("Backend: Could not remove %s: %v\n", f.path, err)
+				continue
+			}
+			removed.Files++
+			removed.Bytes += f.size
+		}
+		if removed.Files > 0 {
+			res.Removed = append(res.Removed, removed)
+			res.FreedBytes += removed.Bytes
+		}
+	}
+	if err := writeConfigJSON(retentionStatusFile, retentionStatus{LastRun: now}); err != nil {
+		return res, err
+	}
+	fmt.Printf("Backend: Retention cleanup freed %d bytes\n", res.FreedBytes)
+	return res, nil
+}
+
+// scheduleRetention runs the cleanup in the background whenever it is due, until ctx ends
+func (a *App) scheduleRetention(ctx context.Context) {
+	go func() {
+		timer := time.NewTimer(retentionStartDelay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			if a.retentionDue() {
+				if _, err := a.RunRetentionCleanup(); err != nil {
+					fmt.Printf("Backend: Retention cleanup failed: %v\n", err)
+				}
+			}
+			timer.Reset(retentionCheckInterval)
+		}
+	}()
+}
+
+// retentionDue reports whether the interval has passed since the last cleanup
+func (a *App) retentionDue() bool {
+	hours := a.GetRetentionSettings().IntervalHours
+	if hours <= 0 {
+		return false
+	}
+	var status retentionStatus
+	if err := readConfigJSON(retentionStatusFile, &status); err != nil {
+		fmt.Printf("Backend: %v\n", err)
+	}
+	return time.Since(status.LastRun) >= time.Duration(hours)*time.Hour
+}
+
+// storedArtifacts returns the folder of a kind, if it has one, and its files, oldest first
+func (a *App) storedArtifacts(kind string) (string, []storedArtifact, error) {
+	var folder string
+	var files []storedArtifact
+	removeAll := func(f tempFile) storedArtifact {
+		return storedArtifact{tempFile: f, remove: func() error { return os.RemoveAll(f.path) }}
+	}
+
+	switch kind {
+	case ArtifactHistory:
+		dir, err := historyDir()
+		if err != nil {
+			return "", nil, err
+		}
+		folder = dir
+		matches, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && !info.IsDir() {
+				files = append(files, removeAll(tempFile{path: m, size: info.Size(), modTime: info.ModTime()}))
+			}
+		}
+	case ArtifactPreviews:
+		dir, err := previewCacheDir(a.GetPreviewSettings())
+		if err != nil {
+			return "", nil, err
+		}
+		folder = dir
+		for _, p := range previewFiles(dir) {
+			path := p.path
+			files = append(files, storedArtifact{
+				tempFile: tempFile{path: p.path, size: p.size, modTime: p.modTime},
+				remove: func() error {
+					previewCacheMu.Lock()
+					defer previewCacheMu.Unlock()
+					return os.Remove(path)
+				},
+			})
+		}
+	case ArtifactTemp:
+		folder = os.TempDir()
+		for _, f := range capgoTempFiles() {
+			files = append(files, removeAll(f))
+		}
+	case ArtifactUpdates:
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", nil, fmt.Errorf("could not get home directory: %v", err)
+		}
+		folder = filepath.Join(home, "Downloads")
+		matches, _ := filepath.Glob(filepath.Join(folder, updateDownloadPrefix+"*.dmg"))
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && !info.IsDir() {
+				files = append(files, removeAll(tempFile{path: m, size: info.Size(), modTime: info.ModTime()}))
+			}
+		}
+	case ArtifactCheckpoints:
+		dir, err := checkpointDir()
+		if err != nil {
+			return "", nil, err
+		}
+		folder = dir
+		batches, err := a.ListBatches()
+		if err != nil {
+			return "", nil, err
+		}
+		for _, cp := range batches {
+			f := tempFile{path: filepath.Join(dir, cp.ID+".json"), modTime: cp.UpdatedAt}
+			if info, err := os.Stat(f.path); err == nil {
+				f.size = info.Size()
+			}
+			if cp.WorkDir != "" {
+				f.size += dirSize(cp.WorkDir)
+			}
+			files = append(files, storedArtifact{tempFile: f, remove: func() error { return a.DiscardBatch(cp.ID) }})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	return folder, files, nil
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// Lua scripts run in a state of their own per document. The state only gets the base,
+// table, string and math libraries without the functions that load files, and the
+// document operations below. There is no os, io, package or debug library, so a script
+// cannot reach the file system, the network or other programs.
+//
+//	document.name      file name of the document
+//	document.pages()   its current page count
+//	rotate(degrees, pages)
+//	stamp(stamps)      a stamp or a list of stamps, with the fields of StampInfo
+//	extract(ranges)
+//	metadata(fields)   the fields of DocumentMetadata
+//	print(...)         writes to the log
+//
+// Pages and ranges are a string or a list of strings. Every operation works on the
+// output of the previous one. gopher-lua cannot limit the memory of a state, so only
+// the time a script runs and the length of string.rep are bounded.
+
+const (
+	// maxScriptCode limits the size of the Lua code of a script
+	maxScriptCode = 64 << 10
+	// maxScriptString limits the strings string.rep builds
+	maxScriptString = 1 << 20
+	// luaChunk names the code in Lua error messages, see luaErrorPosition
+	luaChunk = "script"
+)
+
+// scriptTimeLimit bounds how long the code of a script runs on one document, not
+// counting the document operations it calls
+var scriptTimeLimit = 10 * time.Second
+
+// luaErrorPosition matches the position Lua puts in front of runtime errors
+var luaErrorPosition = regexp.MustCompile(`^` + luaChunk + `:(\d+): `)
+
+// luaUnsafeGlobals are the functions of the base library that read files or load modules
+var luaUnsafeGlobals = []string{"dofile", "loadfile", "module", "require", "_printregs"}
+
+// checkLuaScript checks the size and syntax of the code of a script
+func checkLuaScript(s Script) error {
+	if len(s.Code) > maxScriptCode {
+		return fmt.Errorf("the code of script %s is %d bytes long, at most %d are allowed", s.Name, len(s.Code), maxScriptCode)
+	}
+	chunk, err := parse.Parse(strings.NewReader(s.Code), luaChunk)
+	if err == nil {
+		_, err = lua.Compile(chunk, luaChunk)
+	}
+	if err != nil {
+		return fmt.Errorf("the code of script %s does not compile: %s", s.Name, strings.TrimSpace(err.Error()))
+	}
+	return nil
+}
+
+// newLuaState returns a state with only the sandboxed libraries opened
+func newLuaState() *lua.LState {
+	L := lua.NewState(lua.Options{
+		SkipOpenLibs:    true,
+		CallStackSize:   200,
+		RegistrySize:    1024,
+		RegistryMaxSize: 64 * 1024,
+	})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range luaUnsafeGlobals {
+		L.SetGlobal(name, lua.LNil)
+	}
+	if str, ok := L.GetGlobal(lua.StringLibName).(*lua.LTable); ok {
+		str.RawSetString("rep", L.NewFunction(luaStringRep))
+	}
+	return L
+}
+
+// luaStringRep is string.rep with the length of the result limited
+func luaStringRep(L *lua.LState) int {
+	s, n := L.CheckString(1), L.CheckInt(2)
+	if n <= 0 {
+		L.Push(lua.LString(""))
+		return 1
+	}
+	if len(s) > 0 && n > maxScriptString/len(s) {
+		L.RaiseError("string.rep result is longer than %d bytes", maxScriptString)
+	}
+	L.Push(lua.LString(strings.Repeat(s, n)))
+	return 1
+}
+
+// runLuaScript runs the code of a script on source and returns the last file an
+// operation wrote, or source when the script ran none
+func (a *App) runLuaScript(s Script, source, staging string) (string, error) {
+	L := newLuaState()
+	defer L.Close()
+
+	// The time limit only counts the Lua code: the deadline moves on by the time every
+	// operation took
+	deadline := time.Now().Add(scriptTimeLimit)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer func() { cancel() }()
+	L.SetContext(ctx)
+
+	current, ops := source, 0
+	apply := func(L *lua.LState, step ScriptStep) int {
+		ops++
+		if ops > maxScriptSteps {
+			L.RaiseError("%s", fmt.Errorf("a script can run at most %d operations on a document", maxScriptSteps))
+		}
+		start := time.Now()
+		err := validateScriptStep(step)
+		if err == nil {
+			var pageCount int
+			if pageCount, err = api.PageCountFile(current); err == nil {
+				next := filepath.Join(staging, fmt.Sprintf("op%d.pdf", ops))
+				if err = a.runScriptStep(step, current, next, pageCount); err == nil {
+					current = next
+				}
+			} else {
+				err = fmt.Errorf("failed to read pdf: %v", err)
+			}
+		}
+		deadline = deadline.Add(time.Since(start))
+		cancel()
+		ctx, cancel = context.WithDeadline(context.Background(), deadline)
+		L.SetContext(ctx)
+		if err != nil {
+			L.RaiseError("%s", err)
+		}
+		return 0
+	}
+
+	doc := L.NewTable()
+	doc.RawSetString("name", lua.LString(filepath.Base(source)))
+	doc.RawSetString("pages", L.NewFunction(func(L *lua.LState) int {
+		n, err := api.PageCountFile(current)
+		if err != nil {
+			L.RaiseError("%s", fmt.Errorf("failed to read pdf: %v", err))
+		}
+		L.Push(lua.LNumber(n))
+		return 1
+	}))
+	L.SetGlobal("document", doc)
+
+	L.SetGlobal("rotate", L.NewFunction(func(L *lua.LState) int {
+		step := ScriptStep{Op: ScriptRotate, Degrees: L.CheckInt(1)}
+		if L.GetTop() > 1 {
+			step.Pages = luaStrings(L, 2)
+		}
+		return apply(L, step)
+	}))
+	L.SetGlobal("stamp", L.NewFunction(func(L *lua.LState) int {
+		t, step := L.CheckTable(1), ScriptStep{Op: ScriptStamp}
+		if k, _ := t.Next(lua.LNil); k != lua.LNil {
+			if t.MaxN() == 0 {
+				// A single stamp rather than a list
+				list := L.NewTable()
+				list.Append(t)
+				t = list
+			}
+			if err := luaDecode(t, &step.Stamps); err != nil {
+				L.RaiseError("%s", fmt.Errorf("invalid stamps: %v", err))
+			}
+		}
+		return apply(L, step)
+	}))
+	L.SetGlobal("extract", L.NewFunction(func(L *lua.LState) int {
+		return apply(L, ScriptStep{Op: ScriptExtract, Ranges: luaStrings(L, 1)})
+	}))
+	L.SetGlobal("metadata", L.NewFunction(func(L *lua.LState) int {
+		step := ScriptStep{Op: ScriptMetadata, Metadata: &DocumentMetadata{}}
+		if err := luaDecode(L.CheckTable(1), step.Metadata); err != nil {
+			L.RaiseError("%s", fmt.Errorf("invalid metadata: %v", err))
+		}
+		return apply(L, step)
+	}))
+	L.SetGlobal("print", L.NewFunction(func(L *lua.LState) int {
+		args := make([]string, L.GetTop())
+		for i := range args {
+			args[i] = L.ToStringMeta(L.Get(i + 1)).String()
+		}
+		fmt.Printf("Backend: Script %s: %s\n", s.Name, strings.Join(args, " "))
+		return 0
+	}))
+
+	fn, err := L.Load(strings.NewReader(s.Code), luaChunk)
+	if err == nil {
+		L.Push(fn)
+		err = L.PCall(0, 0, nil)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("script %s did not finish within %v", s.Name, scriptTimeLimit)
+		}
+		return "", luaError(err)
+	}
+	return current, nil
+}
+
+// luaError turns the position Lua puts in front of an error into a line number
+func luaError(err error) error {
+	msg := err.Error()
+	if apiErr, ok := err.(*lua.ApiError); ok && apiErr.Object != nil {
+		msg = apiErr.Object.String()
+	}
+	if m := luaErrorPosition.FindStringSubmatch(msg); m != nil {
+		return fmt.Errorf("line %s: %v", m[1], msg[len(m[0]):])
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// luaStrings reads argument n as a string or a list of strings
+func luaStrings(L *lua.LState, n int) []string {
+	switch v := L.Get(n).(type) {
+	case lua.LString, lua.LNumber:
+		return []string{v.String()}
+	case *lua.LTable:
+		var list []string
+		for i := 1; i <= v.MaxN(); i++ {
+			switch e := v.RawGetInt(i).(type) {
+			case lua.LString, lua.LNumber:
+				list = append(list, e.String())
+			default:
+				L.ArgError(n, "expected a list of strings")
+			}
+		}
+		return list
+	}
+	L.ArgError(n, "expected a string or a list of strings")
+	return nil
+}
+
+// luaDecode fills v from a Lua table the way it would be filled from the same JSON
+func luaDecode(t *lua.LTable, v interface{}) error {
+	data, err := json.Marshal(luaValue(t, 0))
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// luaValue converts a Lua value into one encoding/json understands. Tables with
+// elements at 1, 2, … become lists, other tables objects.
+func luaValue(v lua.LValue, depth int) interface{} {
+	switch v := v.(type) {
+	case lua.LBool:
+		return bool(v)
+	case lua.LNumber:
+		return float64(v)
+	case lua.LString:
+		return string(v)
+	case *lua.LTable:
+		if depth > 20 {
+			return nil
+		}
+		if n := v.MaxN(); n > 0 {
+			list := make([]interface{}, n)
+			for i := range list {
+				list[i] = luaValue(v.RawGetInt(i+1), depth+1)
+			}
+			return list
+		}
+		obj := map[string]interface{}{}
+		v.ForEach(func(k, e lua.LValue) {
+			obj[k.String()] = luaValue(e, depth+1)
+		})
+		return obj
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLuaScript(t *testing.T) {
+	a := goldenApp(t)
+	input, _ := filepath.Abs(filepath.Join(goldenDir, "mixed_sizes.pdf"))
+	output := filepath.Join(t.TempDir(), "out.pdf")
+
+	s := Script{Name: "sandbox", Code: `
+for _, name in ipairs({"os", "io", "package", "debug", "require", "dofile", "loadfile", "module"}) do
+	assert(_G[name] == nil, name .. " is available")
+end
+assert(not pcall(string.rep, "x", 1e9), "string.rep is not limited")
+assert(document.name == "mixed_sizes.pdf")
+local pages = document.pages()
+if pages > 1 then
+	extract({"1", tostring(pages)})
+end
+rotate(90, "1")
+metadata({title = "Scripted " .. document.pages()})
+`}
+	if err := validateScript(s); err != nil {
+		t.Fatal(err)
+	}
+	res := a.runScriptFile(s, input, output)
+	if res.Error != "" {
+		t.Fatal(res.Error)
+	}
+	info, err := a.GetPDFInfo(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Pages != 2 || info.Title != "Scripted 2" {
+		t.Errorf("got %d pages titled %q", info.Pages, info.Title)
+	}
+}
+
+func TestLuaScriptErrors(t *testing.T) {
+	a := goldenApp(t)
+	input, _ := filepath.Abs(filepath.Join(goldenDir, "mixed_sizes.pdf"))
+	defer func(limit time.Duration) { scriptTimeLimit = limit }(scriptTimeLimit)
+	scriptTimeLimit = 200 * time.Millisecond
+
+	for _, tc := range []struct{ code, err string }{
+		{"while true do end", "did not finish within"},
+		{"rotate(90)\nrotate(45)", "line 2: rotation must be a multiple of 90 degrees"},
+		{"stamp({text = 'x', colour = 'red'})", `unknown field "colour"`},
+		{"error('stop')", "line 1: stop"},
+	} {
+		res := a.runScriptFile(Script{Name: "fail", Code: tc.code}, input, filepath.Join(t.TempDir(), "out.pdf"))
+		if !strings.Contains(res.Error, tc.err) {
+			t.Errorf("%q: got error %q, want %q", tc.code, res.Error, tc.err)
+		}
+	}
+
+	if err := validateScript(Script{Name: "broken", Code: "rotate(90"}); err == nil {
+		t.Error("code with a syntax error was accepted")
+	}
+}
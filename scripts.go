@@ -0,0 +1,365 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"CapGo/pkg/stamper"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// Scripts run document operations on one or more files. A script is either a list of
+// steps, each of which can be limited to matching documents with a condition, or Lua
+// code calling the same operations with logic of its own, see scriptlua.go.
+
+// Script operations
+const (
+	ScriptRotate   = "rotate"   // Pages, Degrees
+	ScriptStamp    = "stamp"    // Stamps
+	ScriptExtract  = "extract"  // Ranges: keeps only these pages, in this order
+	ScriptMetadata = "metadata" // Metadata
+)
+
+// maxScriptSteps keeps runaway scripts out of the library
+const maxScriptSteps = 100
+
+var scriptNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _.-]{0,63}$`)
+
+// Script is a named list of steps or Lua code
+type Script struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Steps       []ScriptStep `json:"steps"`
+	// Code is a Lua script run instead of the steps
+	Code      string    `json:"code,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ScriptStep is one operation of a script
+type ScriptStep struct {
+	Op       string            `json:"op"`
+	When     *ScriptCondition  `json:"when,omitempty"`
+	Pages    []string          `json:"pages,omitempty"`
+	Degrees  int               `json:"degrees,omitempty"`
+	Stamps   []StampInfo       `json:"stamps,omitempty"`
+	Ranges   []string          `json:"ranges,omitempty"`
+	Metadata *DocumentMetadata `json:"metadata,omitempty"`
+}
+
+// ScriptCondition limits a step to some documents; empty fields always match
+type ScriptCondition struct {
+	MinPages     int    `json:"minPages,omitempty"`
+	MaxPages     int    `json:"maxPages,omitempty"`
+	NameContains string `json:"nameContains,omitempty"` // case-insensitive, on the source file name
+}
+
+// ScriptFileResult is the outcome of a script for one file
+type ScriptFileResult struct {
+	Source  string `json:"source"`
+	Output  string `json:"output,omitempty"`
+	Skipped []int  `json:"skipped"` // steps whose condition did not match
+	Error   string `json:"error,omitempty"`
+}
+
+// ScriptProgress is emitted as "script:progress" after each file
+type ScriptProgress struct {
+	Script string           `json:"script"`
+	Done   int              `json:"done"`
+	Total  int              `json:"total"`
+	Result ScriptFileResult `json:"result"`
+}
+
+func scriptsDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "scripts")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create scripts directory: %v", err)
+	}
+	return dir, nil
+}
+
+// ListScripts returns the saved scripts sorted by name
+func (a *App) ListScripts() ([]Script, error) {
+	dir, err := scriptsDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	scripts := []Script{}
+	for _, f := range files {
+		var s Script
+		if err := readConfigJSON(filepath.Join("scripts", filepath.Base(f)), &s); err != nil {
+			fmt.Printf("Backend: Skipping script %s: %v\n", filepath.Base(f), err)
+			continue
+		}
+		scripts = append(scripts, s)
+	}
+	sort.Slice(scripts, func(i, j int) bool { return strings.ToLower(scripts[i].Name) < strings.ToLower(scripts[j].Name) })
+	return scripts, nil
+}
+
+// GetScript returns a saved script
+func (a *App) GetScript(name string) (Script, error) {
+	if !scriptNamePattern.MatchString(name) {
+		return Script{}, fmt.Errorf("invalid script name %q", name)
+	}
+	if _, err := scriptsDir(); err != nil {
+		return Script{}, err
+	}
+	var s *Script
+	if err := readConfigJSON(scriptFile(name), &s); err != nil {
+		return Script{}, err
+	}
+	if s == nil {
+		return Script{}, fmt.Errorf("script %q not found", name)
+	}
+	return *s, nil
+}
+
+// SaveScript checks and stores a script, replacing one with the same name
+func (a *App) SaveScript(s Script) error {
+	if err := validateScript(s); err != nil {
+		return err
+	}
+	if _, err := scriptsDir(); err != nil {
+		return err
+	}
+	s.UpdatedAt = time.Now()
+	return writeConfigJSON(scriptFile(s.Name), s)
+}
+
+// DeleteScript removes a saved script
+func (a *App) DeleteScript(name string) error {
+	if !scriptNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid script name %q", name)
+	}
+	dir, err := scriptsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, name+".json")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete script: %v", err)
+	}
+	return nil
+}
+
+func scriptFile(name string) string {
+	return filepath.Join("scripts", name+".json")
+}
+
+// validateScript checks a script before it is saved or run
+func validateScript(s Script) error {
+	if !scriptNamePattern.MatchString(s.Name) {
+		return fmt.Errorf("invalid script name %q: use letters, digits, spaces, dots, dashes and underscores", s.Name)
+	}
+	if s.Code != "" {
+		if len(s.Steps) > 0 {
+			return fmt.Errorf("script %s has both steps and code", s.Name)
+		}
+		return checkLuaScript(s)
+	}
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("script %s has no steps", s.Name)
+	}
+	if len(s.Steps) > maxScriptSteps {
+		return fmt.Errorf("script %s has %d steps, at most %d are allowed", s.Name, len(s.Steps), maxScriptSteps)
+	}
+	for i, step := range s.Steps {
+		if err := validateScriptStep(step); err != nil {
+			return fmt.Errorf("step %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+func validateScriptStep(step ScriptStep) error {
+	switch step.Op {
+	case ScriptRotate:
+		r, err := stamper.NormalizeRotation(step.Degrees)
+		if err != nil {
+			return err
+		}
+		if r == 0 {
+			return fmt.Errorf("rotation of %d degrees leaves the pages unchanged", step.Degrees)
+		}
+	case ScriptStamp:
+		if len(step.Stamps) == 0 {
+			return fmt.Errorf("no stamps given")
+		}
+	case ScriptExtract:
+		if len(step.Ranges) == 0 {
+			return fmt.Errorf("no page ranges given")
+		}
+	case ScriptMetadata:
+		if step.Metadata == nil {
+			return fmt.Errorf("no metadata given")
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", step.Op)
+	}
+	return nil
+}
+
+// RunScript runs a saved script on each file and writes one output per file. A failing
+// file does not stop the others. A .zip among the files stands for the PDFs in it.
+func (a *App) RunScript(name string, files []string) ([]ScriptFileResult, error) {
+	s, err := a.GetScript(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateScript(s); err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files given")
+	}
+	files, zipped, err := expandZipInputs(files)
+	if err != nil {
+		return nil, err
+	}
+	defer zipped.close()
+
+	defer a.startJob("script")()
+
+	results := make([]ScriptFileResult, len(files))
+	for i, f := range files {
+		results[i] = a.runScriptFile(s, filepath.Clean(f), "")
+		results[i].Source = zipped.source(results[i].Source)
+		if results[i].Error != "" {
+			fmt.Printf("Backend: Script %s failed on %s: %s\n", s.Name, f, results[i].Error)
+		}
+		a.emit(EventScriptProgress, ScriptProgress{Script: s.Name, Done: i + 1, Total: len(files), Result: results[i]})
+	}
+	return results, nil
+}
+
+// runScriptFile runs the script on a working copy of one file and writes the result
+// to output, or next to the other CapGo output when output is empty
+func (a *App) runScriptFile(s Script, source, output string) ScriptFileResult {
+	res := ScriptFileResult{Source: source, Skipped: []int{}}
+	fail := func(err error) ScriptFileResult {
+		res.Error = err.Error()
+		return res
+	}
+	if err := a.ensureLocal(source); err != nil {
+		return fail(err)
+	}
+
+	staging, err := os.MkdirTemp("", "capgo_script_*")
+	if err != nil {
+		return fail(fmt.Errorf("failed to create temp folder: %v", err))
+	}
+	defer os.RemoveAll(staging)
+
+	var current string
+	if s.Code != "" {
+		current, err = a.runLuaScript(s, source, staging)
+	} else {
+		current, err = a.runScriptSteps(s, source, staging, &res)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	if output == "" {
+		output, err = a.outputPath(source, "_"+fileBase(s.Name, 0))
+		if err != nil {
+			return fail(err)
+		}
+	}
+	data, err := os.ReadFile(current)
+	if err != nil {
+		return fail(err)
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return fail(classifyFileError("write", output, err))
+	}
+	if err := a.finishOutput(output); err != nil {
+		os.Remove(output)
+		return fail(err)
+	}
+	res.Output = output
+	return res
+}
+
+// runScriptSteps applies the steps of s to source and returns the last file a step
+// wrote, or source when every step was skipped
+func (a *App) runScriptSteps(s Script, source, staging string, res *ScriptFileResult) (string, error) {
+	current := source
+	for i, step := range s.Steps {
+		pageCount, err := api.PageCountFile(current)
+		if err != nil {
+			return "", fmt.Errorf("failed to read pdf: %v", err)
+		}
+		if !step.When.matches(source, pageCount) {
+			res.Skipped = append(res.Skipped, i+1)
+			continue
+		}
+		next := filepath.Join(staging, fmt.Sprintf("step%d.pdf", i+1))
+		if err := a.runScriptStep(step, current, next, pageCount); err != nil {
+			return "", fmt.Errorf("step %d (%s): %v", i+1, step.Op, err)
+		}
+		current = next
+	}
+	return current, nil
+}
+
+func (a *App) runScriptStep(step ScriptStep, in, out string, pageCount int) error {
+	switch step.Op {
+	case ScriptRotate:
+		rotation, err := stamper.NormalizeRotation(step.Degrees)
+		if err != nil {
+			return err
+		}
+		pages := step.Pages
+		if len(pages) == 0 {
+			pages = nil
+		}
+		return api.RotateFile(in, out, rotation, pages, nil)
+	case ScriptStamp:
+		_, err := a.stampPDFTo(in, out, step.Stamps, StampOptions{}, nil)
+		return err
+	case ScriptExtract:
+		ranges := make([]string, len(step.Ranges))
+		for i, r := range step.Ranges {
+			pr, err := parsePageRange(r, pageCount)
+			if err != nil {
+				return err
+			}
+			ranges[i] = pr.String()
+		}
+		return api.CollectFile(in, out, ranges, nil)
+	case ScriptMetadata:
+		return applyDocumentMetadata(in, out, *step.Metadata)
+	}
+	return fmt.Errorf("unknown operation %q", step.Op)
+}
+
+// matches reports whether a document satisfies the condition; nil always matches
+func (c *ScriptCondition) matches(source string, pageCount int) bool {
+	if c == nil {
+		return true
+	}
+	if c.MinPages > 0 && pageCount < c.MinPages {
+		return false
+	}
+	if c.MaxPages > 0 && pageCount > c.MaxPages {
+		return false
+	}
+	if c.NameContains != "" && !strings.Contains(strings.ToLower(filepath.Base(source)), strings.ToLower(c.NameContains)) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+
+	"CapGo/pkg/stamper"
+)
+
+// SealOptions describes a round or oval seal and where to save it
+type SealOptions struct {
+	stamper.Seal
+	OutputPath string `json:"outputPath,omitempty"`
+}
+
+// GenerateSeal renders a classic seal as a transparent PNG and returns it as a data URL
+// that can be used directly as a stamp image. The PNG is also written to OutputPath when set.
+func (a *App) GenerateSeal(opts SealOptions) (string, error) {
+	img, err := stamper.RenderSeal(opts.Seal)
+	if err != nil {
+		return "", err
+	}
+	if opts.OutputPath != "" {
+		if err := writePNG(opts.OutputPath, img); err != nil {
+			return "", fmt.Errorf("failed to save seal: %v", err)
+		}
+	}
+	return pngDataURL(img)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Sequence is a named, persistent counter used for document numbering
+type Sequence struct {
+	Name   string `json:"name"`
+	Format string `json:"format"` // one %d verb for the number, e.g. "INV-%05d", see checkSequenceFormat
+	Next   int64  `json:"next"`
+}
+
+// sequenceStore keeps the counters in the config directory. All access goes through
+// lock so concurrent stamping runs never hand out the same number twice, also when they
+// run in different windows.
+type sequenceStore struct {
+	mu sync.Mutex
+}
+
+var sequences = &sequenceStore{}
+
+// seqPlaceholder matches {seq:NAME}, {seq:NAME:FORMAT} and {seq:FORMAT}
+var seqPlaceholder = regexp.MustCompile(`\{seq:([^{}:]+)(?::([^{}]+))?\}`)
+
+const sequencesFile = "sequences.json"
+
+// lock serializes access to the counters within this process and across windows
+func (s *sequenceStore) lock() (unlock func(), err error) {
+	s.mu.Lock()
+	unlockConfig, err := lockConfigFile(sequencesFile)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlockConfig()
+		s.mu.Unlock()
+	}, nil
+}
+
+func (s *sequenceStore) load() (map[string]Sequence, error) {
+	seqs := map[string]Sequence{}
+	if err := readConfigJSON(sequencesFile, &seqs); err != nil {
+		return nil, err
+	}
+	return seqs, nil
+}
+
+func (s *sequenceStore) save(seqs map[string]Sequence) error {
+	return writeConfigJSON(sequencesFile, seqs)
+}
+
+// seqFormatVerb matches the escaped percent sign, the number verb with an optional zero
+// padding and width, and any other verb, in that order
+var seqFormatVerb = regexp.MustCompile(`%%|%0?(?:[1-9][0-9]?)?d|%`)
+
+// checkSequenceFormat makes sure a format has at most one number verb like %d or %05d
+// and no other verbs, which would print Go's %!s(int64=…) errors on the documents
+func checkSequenceFormat(format string) error {
+	verbs := 0
+	for _, m := range seqFormatVerb.FindAllString(format, -1) {
+		switch {
+		case m == "%%":
+		case strings.HasSuffix(m, "d"):
+			verbs++
+		default:
+			return fmt.Errorf("invalid sequence format %q, write the number as %s or e.g. %s", format, "%d", "%05d")
+		}
+	}
+	if verbs > 1 {
+		return fmt.Errorf("sequence format %q has more than one number", format)
+	}
+	return nil
+}
+
+// formatSequence renders a counter value using the sequence format. A format without
+// a number verb is a prefix the number is appended to.
+func formatSequence(format string, n int64) (string, error) {
+	if err := checkSequenceFormat(format); err != nil {
+		return "", err
+	}
+	if !strings.Contains(strings.ReplaceAll(format, "%%", ""), "%") {
+		format += "%d"
+	}
+	return fmt.Sprintf(format, n), nil
+}
+
+// expand replaces every sequence placeholder in the given texts. Each sequence is
+// incremented once per call, so all placeholders of one stamping run share the same number.
+func (s *sequenceStore) expand(texts []string) ([]string, error) {
+	needed := false
+	for _, t := range texts {
+		if seqPlaceholder.MatchString(t) {
+			needed = true
+			break
+		}
+	}
+	if !needed {
+		return texts, nil
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	seqs, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]int64{}
+	out := make([]string, len(texts))
+	var formatErr error
+	for i, t := range texts {
+		out[i] = seqPlaceholder.ReplaceAllStringFunc(t, func(m string) string {
+			parts := seqPlaceholder.FindStringSubmatch(m)
+			name, format := parts[1], parts[2]
+			if format == "" && strings.Contains(name, "%") {
+				// {seq:INV-%05d} names the sequence after its format
+				format = name
+			}
+
+			seq, ok := seqs[name]
+			if !ok {
+				seq = Sequence{Name: name, Format: format, Next: 1}
+			}
+			if format == "" {
+				format = seq.Format
+			}
+
+			n, taken := values[name]
+			if !taken {
+				n = seq.Next
+				values[name] = n
+				seq.Next++
+				seqs[name] = seq
+			}
+			s, err := formatSequence(format, n)
+			if err != nil && formatErr == nil {
+				formatErr = err
+			}
+			return s
+		})
+	}
+	if formatErr != nil {
+		return nil, formatErr
+	}
+
+	if err := s.save(seqs); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// expandStampPlaceholders fills in sequence numbers in text stamps
+func expandStampPlaceholders(stamps []StampInfo) ([]StampInfo, error) {
+	texts := make([]string, len(stamps))
+	for i, s := range stamps {
+		if s.Kind == StampKindText {
+			texts[i] = s.Text
+		}
+	}
+	expanded, err := sequences.expand(texts)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]StampInfo, len(stamps))
+	for i, s := range stamps {
+		out[i] = s
+		if s.Kind == StampKindText {
+			out[i].Text = expanded[i]
+		}
+	}
+	return out, nil
+}
+
+// ListSequences returns all numbering sequences sorted by name
+func (a *App) ListSequences() ([]Sequence, error) {
+	unlock, err := sequences.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	seqs, err := sequences.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Sequence, 0, len(seqs))
+	for _, s := range seqs {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// SaveSequence creates or updates a sequence, e.g. to change its format or reset its counter
+func (a *App) SaveSequence(seq Sequence) error {
+	if strings.TrimSpace(seq.Name) == "" || strings.ContainsAny(seq.Name, "{}:") {
+		return fmt.Errorf("invalid sequence name: %q", seq.Name)
+	}
+	if seq.Next < 0 {
+		return fmt.Errorf("sequence counter cannot be negative")
+	}
+	if err := checkSequenceFormat(seq.Format); err != nil {
+		return err
+	}
+
+	unlock, err := sequences.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	seqs, err := sequences.load()
+	if err != nil {
+		return err
+	}
+	seqs[seq.Name] = seq
+	return sequences.save(seqs)
+}
+
+// DeleteSequence removes a sequence
+func (a *App) DeleteSequence(name string) error {
+	unlock, err := sequences.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	seqs, err := sequences.load()
+	if err != nil {
+		return err
+	}
+	delete(seqs, name)
+	return sequences.save(seqs)
+}
+
+// PeekSequence returns the next formatted value of a sequence without consuming it
+func (a *App) PeekSequence(name string) (string, error) {
+	unlock, err := sequences.lock()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	seqs, err := sequences.load()
+	if err != nil {
+		return "", err
+	}
+	seq, ok := seqs[name]
+	if !ok {
+		return "", fmt.Errorf("unknown sequence: %s", name)
+	}
+	return formatSequence(seq.Format, seq.Next)
+}
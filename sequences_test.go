@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestFormatSequence(t *testing.T) {
+	for _, c := range []struct {
+		format string
+		want   string // "" when the format is refused
+	}{
+		{"", "42"},
+		{"INV-", "INV-42"},
+		{"INV-%05d", "INV-00042"},
+		{"%d/2026", "42/2026"},
+		{"100%% paid %d", "100% paid 42"},
+		{"100%% paid", "100% paid42"},
+		{"INV-%s-%d", ""},
+		{"INV-%x", ""},
+		{"%d-%d", ""},
+		{"%-5d", ""},
+		{"%1000000d", ""},
+		{"INV-%", ""},
+	} {
+		got, err := formatSequence(c.format, 42)
+		switch {
+		case c.want == "" && err == nil:
+			t.Errorf("%q: got %q, want an error", c.format, got)
+		case c.want != "" && err != nil:
+			t.Errorf("%q: %v", c.format, err)
+		case got != c.want:
+			t.Errorf("%q: got %q, want %q", c.format, got, c.want)
+		}
+	}
+}
+
+func TestSaveSequenceFormat(t *testing.T) {
+	a := goldenApp(t)
+	if err := a.SaveSequence(Sequence{Name: "invoices", Format: "INV-%s-%d", Next: 1}); err == nil {
+		t.Fatal("a format with two verbs was saved")
+	}
+	if err := a.SaveSequence(Sequence{Name: "invoices", Format: "INV-%05d", Next: 7}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := sequences.expand([]string{"No. {seq:invoices}", "{seq:invoices:%s}"})
+	if err == nil {
+		t.Fatalf("a placeholder format with %%s was expanded to %q", got)
+	}
+	// The refused run must not use up a number
+	got, err = sequences.expand([]string{"No. {seq:invoices}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != "No. INV-00007" {
+		t.Errorf("got %q, want No. INV-00007", got[0])
+	}
+}
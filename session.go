@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// sessionExt is the extension of CapGo project files
+const sessionExt = ".capgo"
+
+// sessionVersion is the project file format written by SaveSession
+const sessionVersion = 1
+
+// Session is a review in progress: the document, its page order and the stamps that
+// were placed but not applied yet
+type Session struct {
+	Version  int    `json:"version"`
+	Document string `json:"document"` // the original PDF
+	// Pages is the page order as passed to UpdatePDFPages, relative to Document; empty
+	// keeps the order of the document
+	Pages   []string     `json:"pages,omitempty"`
+	Stamps  []StampInfo  `json:"stamps"`
+	Groups  []StampGroup `json:"groups,omitempty"`
+	SavedAt time.Time    `json:"savedAt"`
+}
+
+// LoadedSession is a session reopened by LoadSession
+type LoadedSession struct {
+	Session
+	Project string `json:"project"` // the project file
+	// Path is the document to show, with the saved page order applied
+	Path string `json:"path"`
+}
+
+// SaveSession writes the session to a project file and returns its path. Without a
+// path the user picks one, next to the document; "" is returned when they cancel.
+// Stamp images are stored in the file, since they often live in temp files.
+func (a *App) SaveSession(projectPath string, session Session) (string, error) {
+	if session.Document == "" {
+		return "", fmt.Errorf("no document is open")
+	}
+	session.Document = filepath.Clean(session.Document)
+	if projectPath == "" {
+		base := strings.TrimSuffix(filepath.Base(session.Document), filepath.Ext(session.Document))
+		path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+			Title:            tr("Save Session"),
+			DefaultDirectory: filepath.Dir(session.Document),
+			DefaultFilename:  base + sessionExt,
+			Filters:          []runtime.FileFilter{{DisplayName: tr("CapGo Sessions"), Pattern: "*" + sessionExt}},
+		})
+		if err != nil || path == "" {
+			return "", err
+		}
+		projectPath = path
+	}
+	projectPath = filepath.Clean(projectPath)
+	if !strings.EqualFold(filepath.Ext(projectPath), sessionExt) {
+		projectPath += sessionExt
+	}
+
+	stamps := make([]StampInfo, len(session.Stamps))
+	for i, s := range session.Stamps {
+		if s.Kind != StampKindText && s.Image != "" {
+			img, err := imageDataURL(s.Image)
+			if err != nil {
+				return "", fmt.Errorf("failed to store stamp %d: %v", i, err)
+			}
+			s.Image = img
+		}
+		stamps[i] = s
+	}
+	session.Stamps = stamps
+	session.Version = sessionVersion
+	session.SavedAt = time.Now()
+
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	// Write through a temp file so a crash never leaves a truncated project behind
+	tmp := projectPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return "", classifyFileError("write", projectPath, err)
+	}
+	if err := os.Rename(tmp, projectPath); err != nil {
+		os.Remove(tmp)
+		return "", classifyFileError("write", projectPath, err)
+	}
+	fmt.Printf("Backend: Saved session of %s with %d stamps to %s\n", session.Document, len(stamps), projectPath)
+	return projectPath, nil
+}
+
+// LoadSession reopens a project file written by SaveSession. The saved page order is
+// applied to a copy of the document, and the document becomes the open document.
+func (a *App) LoadSession(projectPath string) (LoadedSession, error) {
+	projectPath = filepath.Clean(projectPath)
+	data, err := os.ReadFile(projectPath)
+	if err != nil {
+		return LoadedSession{}, classifyFileError("read", projectPath, err)
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return LoadedSession{}, fmt.Errorf("%s is not a CapGo session: %v", filepath.Base(projectPath), err)
+	}
+	if session.Version > sessionVersion {
+		return LoadedSession{}, fmt.Errorf("%s was saved by a newer version of CapGo", filepath.Base(projectPath))
+	}
+	if session.Document == "" {
+		return LoadedSession{}, fmt.Errorf("%s is not a CapGo session", filepath.Base(projectPath))
+	}
+	if _, err := os.Stat(session.Document); err != nil {
+		return LoadedSession{}, classifyFileError("read", session.Document, err)
+	}
+	if session.Stamps == nil {
+		session.Stamps = []StampInfo{}
+	}
+
+	loaded := LoadedSession{Session: session, Project: projectPath, Path: session.Document}
+	if len(session.Pages) > 0 {
+		pageCount, err := api.PageCountFile(session.Document)
+		if err != nil {
+			return LoadedSession{}, fmt.Errorf("failed to read pdf: %v", err)
+		}
+		if _, err := api.PagesForPageSelection(pageCount, session.Pages, false, false); err != nil {
+			return LoadedSession{}, fmt.Errorf("the saved page order does not fit %s: %v", filepath.Base(session.Document), err)
+		}
+		if loaded.Path, err = a.UpdatePDFPages(session.Document, session.Pages); err != nil {
+			return LoadedSession{}, err
+		}
+	}
+	if err := a.SetDocumentState(DocumentState{Path: loaded.Path, Dirty: len(session.Stamps) > 0}); err != nil {
+		return LoadedSession{}, err
+	}
+	fmt.Printf("Backend: Loaded session %s with %d stamps\n", projectPath, len(session.Stamps))
+	return loaded, nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+)
+
+// Safe area enforcement modes
+const (
+	SafeAreaOff   = "off"
+	SafeAreaWarn  = "warn"
+	SafeAreaClamp = "clamp"
+)
+
+// SafeArea describes the minimum distance stamps must keep from the page edges
+type SafeArea struct {
+	MarginMM float64 `json:"marginMM"`
+	Mode     string  `json:"mode"`
+}
+
+// Settings holds the user preferences persisted between sessions
+type Settings struct {
+	SafeArea      SafeArea          `json:"safeArea"`
+	FilingProfile FilingProfile     `json:"filingProfile"`
+	QuickStamp    QuickStamp        `json:"quickStamp"`
+	Hotkeys       map[string]string `json:"hotkeys"` // hotkey action to accelerator
+	Power         PowerSettings     `json:"power"`
+	// Onboarding is nil only while loading a settings file written before the guided tour
+	// existed, see loadSettings
+	Onboarding *OnboardingState `json:"onboarding,omitempty"`
+	Hooks      []Hook           `json:"hooks,omitempty"`
+	Language   string           `json:"language"` // see languages
+	// PaperSize names the default page format of generated pages, see GetPaperSizes
+	PaperSize        string      `json:"paperSize"`
+	CustomPaperSizes []PaperSize `json:"customPaperSizes,omitempty"`
+	// DeterministicOutput fixes dates and IDs in generated PDFs, see SetDeterministicOutput
+	DeterministicOutput bool `json:"deterministicOutput"`
+	// Timeouts bounds single operations on a document, see SetOperationTimeouts
+	Timeouts OperationTimeouts `json:"timeouts"`
+	// Previews sets the preview quality and the size and place of their cache
+	Previews PreviewSettings `json:"previews"`
+	// DocumentLanguage replaces the detected language of documents, see DetectDocumentLanguage
+	DocumentLanguage string `json:"documentLanguage,omitempty"`
+	// Output sets the folder, file names and conflict handling of created documents
+	Output OutputSettings `json:"output"`
+	// Retention says how long history, previews, temp files and downloads are kept
+	Retention RetentionSettings `json:"retention"`
+	// CropBoxCoordinates measures stamp coordinates from the crop box instead of the media
+	// box, see SetCropBoxCoordinates
+	CropBoxCoordinates bool `json:"cropBoxCoordinates"`
+}
+
+// defaultSettings returns the settings used on first launch
+func defaultSettings() Settings {
+	return Settings{
+		SafeArea:           SafeArea{MarginMM: 10, Mode: SafeAreaWarn},
+		FilingProfile:      defaultFilingProfile(),
+		Hotkeys:            defaultHotkeys(),
+		Power:              defaultPowerSettings(),
+		Onboarding:         &OnboardingState{},
+		Language:           LanguageEnglish,
+		PaperSize:          defaultPaperSize,
+		Timeouts:           defaultOperationTimeouts(),
+		Previews:           defaultPreviewSettings(),
+		Output:             defaultOutputSettings(),
+		Retention:          defaultRetentionSettings(),
+		CropBoxCoordinates: true,
+	}
+}
+
+// configDir returns the CapGo folder inside the user config directory, creating it if needed
+func configDir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get config directory: %v", err)
+	}
+	dir := filepath.Join(base, "CapGo")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create config directory: %v", err)
+	}
+	return dir, nil
+}
+
+const settingsFile = "settings.json"
+
+func settingsPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, settingsFile), nil
+}
+
+// loadSettings reads the settings file, falling back to defaults when it is missing
+func loadSettings() Settings {
+	settings := defaultSettings()
+	path, err := settingsPath()
+	if err != nil {
+		return settings
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return settings
+	}
+	// Users upgrading from a version without onboarding have seen the app already
+	settings.Onboarding = nil
+	if err := json.Unmarshal(data, &settings); err != nil {
+		fmt.Printf("Backend: Ignoring invalid settings file: %v\n", err)
+		return defaultSettings()
+	}
+	if settings.Onboarding == nil {
+		settings.Onboarding = &OnboardingState{Completed: true}
+	}
+	return settings
+}
+
+// saveSettings writes the settings changed in this process to the settings file; a.mu
+// must be held. Every additional window runs in its own process with its own copy of the
+// settings (see OpenWindow), so the file is locked and read again, and only the sections
+// changed since this process last read or wrote it replace the ones on disk. The merged
+// settings become the copy of this process.
+func (a *App) saveSettings() error {
+	unlock, err := lockConfigFile(settingsFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	merged := loadSettings()
+	m, changed, saved := reflect.ValueOf(&merged).Elem(), reflect.ValueOf(a.settings), reflect.ValueOf(a.saved)
+	for i := 0; i < m.NumField(); i++ {
+		if !reflect.DeepEqual(changed.Field(i).Interface(), saved.Field(i).Interface()) {
+			m.Field(i).Set(changed.Field(i))
+		}
+	}
+	if err := writeConfigJSON(settingsFile, merged); err != nil {
+		return err
+	}
+	a.settings, a.saved = merged, merged
+	return nil
+}
+
+// readConfigJSON decodes a JSON file from the config directory into v.
+// A missing file leaves v untouched and is not an error.
+func readConfigJSON(name string, v interface{}) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", name, err)
+	}
+	return nil
+}
+
+// lockConfigFile waits until no other CapGo window reads and writes the config file of
+// the given name, and keeps it that way until unlock is called. Files that are read,
+// changed and written back take the lock, so windows do not undo each other's changes.
+func lockConfigFile(name string) (unlock func(), err error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, name+".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %v", name, err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock %s: %v", name, err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// writeConfigJSON stores v as JSON in the config directory. It writes through a temp
+// file so a crash never leaves a truncated file behind, and readers in other windows
+// never see a half written one.
+func writeConfigJSON(name string, v interface{}) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name)
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	return nil
+}
+
+// GetSettings returns all user preferences, e.g. for a settings page
+func (a *App) GetSettings() Settings {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	settings := a.settings
+	settings.Hotkeys = map[string]string{}
+	for k, v := range a.settings.Hotkeys {
+		settings.Hotkeys[k] = v
+	}
+	return settings
+}
+
+// SetSettings updates and persists the preferences that changed. Each section goes
+// through its own setter and is checked the same way; an invalid section stops the
+// update and the sections before it are kept. Onboarding progress and custom paper
+// sizes have their own calls and are left alone.
+func (a *App) SetSettings(settings Settings) error {
+	old := a.GetSettings()
+	sections := []struct {
+		old, new interface{}
+		set      func() error
+	}{
+		{old.SafeArea, settings.SafeArea, func() error { return a.SetSafeArea(settings.SafeArea) }},
+		{old.FilingProfile, settings.FilingProfile, func() error { return a.SetFilingProfile(settings.FilingProfile) }},
+		{old.QuickStamp, settings.QuickStamp, func() error { return a.SetQuickStamp(settings.QuickStamp) }},
+		{old.Power, settings.Power, func() error { return a.SetPowerSettings(settings.Power) }},
+		{old.Hooks, settings.Hooks, func() error { return a.SetHooks(settings.Hooks) }},
+		{old.Language, settings.Language, func() error { return a.SetLanguage(settings.Language) }},
+		{old.PaperSize, settings.PaperSize, func() error { return a.SetDefaultPaperSize(settings.PaperSize) }},
+		{old.DeterministicOutput, settings.DeterministicOutput, func() error { return a.SetDeterministicOutput(settings.DeterministicOutput) }},
+		{old.Timeouts, settings.Timeouts, func() error { return a.SetOperationTimeouts(settings.Timeouts) }},
+		{old.Previews, settings.Previews, func() error { return a.SetPreviewSettings(settings.Previews) }},
+		{old.DocumentLanguage, settings.DocumentLanguage, func() error { return a.SetDocumentLanguageOverride(settings.DocumentLanguage) }},
+		{old.Output, settings.Output, func() error { return a.SetOutputSettings(settings.Output) }},
+		{old.Retention, settings.Retention, func() error { return a.SetRetentionSettings(settings.Retention) }},
+		{old.CropBoxCoordinates, settings.CropBoxCoordinates, func() error { return a.SetCropBoxCoordinates(settings.CropBoxCoordinates) }},
+	}
+	for _, s := range sections {
+		if reflect.DeepEqual(s.old, s.new) {
+			continue
+		}
+		if err := s.set(); err != nil {
+			return err
+		}
+	}
+
+	actions := make([]string, 0, len(settings.Hotkeys))
+	for action := range settings.Hotkeys {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		if settings.Hotkeys[action] == old.Hotkeys[action] {
+			continue
+		}
+		if _, err := a.SetHotkey(action, settings.Hotkeys[action]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSafeArea returns the configured stamp safe area
+func (a *App) GetSafeArea() SafeArea {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.settings.SafeArea
+}
+
+// SetSafeArea updates and persists the stamp safe area
+func (a *App) SetSafeArea(area SafeArea) error {
+	switch area.Mode {
+	case SafeAreaOff, SafeAreaWarn, SafeAreaClamp:
+	default:
+		return fmt.Errorf("invalid safe area mode: %q", area.Mode)
+	}
+	if area.MarginMM < 0 {
+		return fmt.Errorf("safe area margin cannot be negative")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settings.SafeArea = area
+	return a.saveSettings()
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"CapGo/internal/pdfcanvas"
+	"CapGo/pkg/stamper"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// SignRequest describes a digital signature
+type SignRequest struct {
+	// Certificate is a .p12 or .pfx file with the private key and its certificate chain
+	Certificate         string `json:"certificate"`
+	CertificatePassword string `json:"certificatePassword"`
+	Reason              string `json:"reason,omitempty"`
+	Location            string `json:"location,omitempty"`
+	ContactInfo         string `json:"contactInfo,omitempty"`
+	// Visible shows the signature in the box of a stamp: the image of an image stamp,
+	// otherwise the stamp text or the signer and date. Nil signs invisibly.
+	Visible *StampInfo `json:"visible,omitempty"`
+}
+
+// signatureSpace is the room reserved for the CMS signature besides the certificates
+const signatureSpace = 8192
+
+// byteRangePlaceholder is written before the signed ranges are known; they are
+// patched in, padded with spaces, once the file is complete
+var byteRangePlaceholder = types.Array{types.Integer(0), types.Integer(9999999999), types.Integer(9999999999), types.Integer(9999999999)}
+
+var startXRef = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+
+// SignPDF applies a PAdES baseline B-B digital signature to a copy of the PDF in
+// Downloads. The signature is added as an incremental update, so earlier signatures
+// stay valid.
+func (a *App) SignPDF(pdfPath string, req SignRequest) (string, error) {
+	pdfPath = filepath.Clean(pdfPath)
+	status, err := a.IsEncrypted(pdfPath)
+	if err != nil {
+		return "", err
+	}
+	if status.Encrypted {
+		return "", fmt.Errorf("%s is encrypted and cannot be signed", filepath.Base(pdfPath))
+	}
+	id, err := loadSigningIdentity(filepath.Clean(req.Certificate), req.CertificatePassword)
+	if err != nil {
+		return "", err
+	}
+	now := a.now()
+	if now.Before(id.cert.NotBefore) || now.After(id.cert.NotAfter) {
+		return "", fmt.Errorf("the certificate of %s is valid from %s to %s", id.cert.Subject.CommonName,
+			formatDate(id.cert.NotBefore), formatDate(id.cert.NotAfter))
+	}
+	defer a.startJob("sign")()
+
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return "", classifyFileError("read", pdfPath, err)
+	}
+	signed, err := a.signPDFData(data, id, req, now)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := a.outputPath(pdfPath, "_signed")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(output, signed, 0644); err != nil {
+		return "", classifyFileError("write", output, err)
+	}
+	recordDerivedHistory(pdfPath, output, "sign")
+	fmt.Printf("Backend: Signed %s as %s\n", pdfPath, id.cert.Subject.CommonName)
+	return output, nil
+}
+
+// signPDFData appends the signature field, its appearance and the signature to data
+func (a *App) signPDFData(data []byte, id *signingIdentity, req SignRequest, now time.Time) ([]byte, error) {
+	m := startXRef.FindSubmatch(data)
+	if m == nil {
+		return nil, fmt.Errorf("failed to read pdf: no cross-reference offset at the end of the file")
+	}
+	prev, _ := strconv.Atoi(string(m[1]))
+	ctx, err := api.ReadContext(bytes.NewReader(data), model.NewDefaultConfiguration())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %v", err)
+	}
+	if err := ctx.EnsurePageCount(); err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %v", err)
+	}
+	u := newPDFUpdate(ctx)
+
+	pageNr := 1
+	if req.Visible != nil {
+		pageNr = req.Visible.PageNum
+	}
+	if pageNr < 1 || pageNr > ctx.PageCount {
+		return nil, fmt.Errorf("page %d does not exist in the %d page document", pageNr, ctx.PageCount)
+	}
+	page, pageRef, inherited, err := ctx.PageDict(pageNr, false)
+	if err != nil || page == nil || pageRef == nil {
+		return nil, fmt.Errorf("failed to read page %d: %v", pageNr, err)
+	}
+
+	// The signature value, with room for the CMS signature
+	space := signatureSpace
+	for _, c := range append(id.chain, id.cert) {
+		space += len(c.Raw)
+	}
+	sig := types.NewDict()
+	sig.InsertName("Type", "Sig")
+	sig.InsertName("Filter", "Adobe.PPKLite")
+	sig.InsertName("SubFilter", "ETSI.CAdES.detached")
+	sig.Insert("ByteRange", byteRangePlaceholder)
+	sig.Insert("Contents", types.HexLiteral(strings.Repeat("0", 2*space)))
+	sig.InsertString("M", types.DateString(now))
+	for key, value := range map[string]string{"Name": id.cert.Subject.CommonName, "Reason": req.Reason, "Location": req.Location, "ContactInfo": req.ContactInfo} {
+		if value == "" {
+			continue
+		}
+		text, err := pdfTextString(value)
+		if err != nil {
+			return nil, err
+		}
+		sig.Insert(key, text)
+	}
+	sigRef, err := ctx.IndRefForNewObject(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	// The field is merged with its widget annotation
+	rect := types.Array{types.Integer(0), types.Integer(0), types.Integer(0), types.Integer(0)}
+	widget := types.NewDict()
+	widget.InsertName("Type", "Annot")
+	widget.InsertName("Subtype", "Widget")
+	widget.InsertName("FT", "Sig")
+	widget.Insert("F", types.Integer(132)) // print and locked
+	widget.Insert("P", *pageRef)
+	widget.Insert("V", *sigRef)
+	if req.Visible != nil {
+		frame := inherited.MediaBox
+		if inherited.CropBox != nil && a.GetCropBoxCoordinates() {
+			frame = inherited.CropBox
+		}
+		box, err := a.signatureBox(*req.Visible, frame)
+		if err != nil {
+			return nil, err
+		}
+		rect = types.Array{types.Float(box.LL.X), types.Float(box.LL.Y), types.Float(box.UR.X), types.Float(box.UR.Y)}
+		ap, err := signatureAppearance(ctx, *req.Visible, box, id, now)
+		if err != nil {
+			return nil, err
+		}
+		widget.Insert("AP", types.Dict{"N": *ap})
+	}
+	widget.Insert("Rect", rect)
+	widgetRef, err := ctx.IndRefForNewObject(widget)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.appendTo(page, pageRef, "Annots", *widgetRef); err != nil {
+		return nil, err
+	}
+	form, formRef, err := u.dict(ctx.RootDict, ctx.Root, "AcroForm")
+	if err != nil {
+		return nil, err
+	}
+	form["SigFlags"] = types.Integer(3) // signatures exist, append only
+	fields, _ := ctx.DereferenceArray(form["Fields"])
+	widget.InsertString("T", signatureFieldName(ctx, fields))
+	if err := u.appendTo(form, formRef, "Fields", *widgetRef); err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	update, err := u.write(data, prev)
+	if err != nil {
+		return nil, err
+	}
+	out := append(data, update...)
+
+	// Sign everything but the hex string of the signature
+	placeholder := []byte(types.HexLiteral(strings.Repeat("0", 2*space)).PDFString())
+	start := bytes.LastIndex(out, placeholder)
+	if start < 0 {
+		return nil, fmt.Errorf("signature placeholder not found")
+	}
+	end := start + len(placeholder)
+	byteRange := types.Array{types.Integer(0), types.Integer(start), types.Integer(end), types.Integer(len(out) - end)}
+	rangeAt := bytes.LastIndex(out, []byte(byteRangePlaceholder.PDFString()))
+	if rangeAt < 0 {
+		return nil, fmt.Errorf("signature byte range not found")
+	}
+	padded := fmt.Sprintf("%-*s", len(byteRangePlaceholder.PDFString()), byteRange.PDFString())
+	copy(out[rangeAt:], padded)
+
+	cms, err := id.signDetached(append(append([]byte{}, out[:start]...), out[end:]...))
+	if err != nil {
+		return nil, err
+	}
+	if len(cms) > space {
+		return nil, fmt.Errorf("the signature needs %d bytes, %d were reserved", len(cms), space)
+	}
+	copy(out[start+1:], strings.ToUpper(hex.EncodeToString(cms)))
+	return out, nil
+}
+
+// signatureBox returns the rectangle of a visible signature in PDF coordinates, given
+// the box its coordinates are measured from
+func (a *App) signatureBox(stamp StampInfo, frame *types.Rectangle) (*types.Rectangle, error) {
+	stamps, err := a.NormalizeStampCoordinates([]StampInfo{stamp})
+	if err != nil {
+		return nil, err
+	}
+	s := stamps[0]
+	if s.Width <= 0 || s.Height <= 0 {
+		return nil, fmt.Errorf("the signature box needs a width and a height")
+	}
+	top := frame.UR.Y - s.Y
+	if s.Origin == StampOriginBottomLeft {
+		top = frame.LL.Y + s.Y + s.Height
+	}
+	left := frame.LL.X + s.X
+	return types.NewRectangle(left, top-s.Height, left+s.Width, top), nil
+}
+
+// signatureAppearance draws the visible signature into a form XObject
+func signatureAppearance(ctx *model.Context, stamp StampInfo, box *types.Rectangle, id *signingIdentity, now time.Time) (*types.IndirectRef, error) {
+	w, h := box.Width(), box.Height()
+	c := pdfcanvas.New(w, h)
+	resources := types.NewDict()
+
+	if stamp.Kind != StampKindText && stamp.Image != "" {
+		data, err := stamper.ImageData(0, stamp.Stamp)
+		if err != nil {
+			return nil, err
+		}
+		if pdfcanvas.IsSVG(data) {
+			if err := drawSignatureSVG(c, data, resources); err != nil {
+				return nil, fmt.Errorf("failed to draw signature image: %v", err)
+			}
+			return signatureForm(ctx, c, resources)
+		}
+		img, err := stamper.StampImage(0, stamp.Stamp, data)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		imgRef, iw, ih, err := model.CreateImageResource(ctx.XRefTable, &buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed signature image: %v", err)
+		}
+		// Fit the image into the box like an image stamp
+		scale := w / float64(iw)
+		if s := h / float64(ih); s < scale {
+			scale = s
+		}
+		dw, dh := float64(iw)*scale, float64(ih)*scale
+		c.Op("q %.4f 0 0 %.4f %.4f %.4f cm /Im0 Do Q", dw, dh, (w-dw)/2, (h-dh)/2)
+		resources.Insert("XObject", types.Dict{"Im0": *imgRef})
+	} else {
+		lines := strings.Split(strings.TrimSpace(stamp.Text), "\n")
+		if stamp.Text == "" {
+			lines = []string{tr("Digitally signed by %s", id.cert.Subject.CommonName), tr("Date: %s", formatDateTime(now))}
+		}
+		const font = "Helvetica"
+		size := h / (1.25 * float64(len(lines)))
+		for _, l := range lines {
+			if tw := pdfcanvas.CoreTextWidth(l, font, 1); tw*size > w-4 {
+				size = (w - 4) / tw
+			}
+		}
+		color := pdfcanvas.RGB{}
+		if stamp.Color != "" {
+			if col, err := pdfcanvas.ParseHexColor(stamp.Color); err == nil {
+				color = col
+			}
+		}
+		c.SetFillColor(color)
+		y := h - 2 - pdfcanvas.CoreAscent(font, size)
+		for _, l := range lines {
+			c.Text(2, y, font, size, l, pdfcanvas.TextFill)
+			y -= 1.25 * size
+		}
+		fonts := types.Dict{}
+		for base, name := range c.Fonts() {
+			fonts.Insert(name, types.Dict{"Type": types.Name("Font"), "Subtype": types.Name("Type1"),
+				"BaseFont": types.Name(base), "Encoding": types.Name("WinAnsiEncoding")})
+		}
+		resources.Insert("Font", fonts)
+	}
+
+	return signatureForm(ctx, c, resources)
+}
+
+// signatureForm stores the drawing of the canvas as a form XObject
+func signatureForm(ctx *model.Context, c *pdfcanvas.Canvas, resources types.Dict) (*types.IndirectRef, error) {
+	sd, err := ctx.NewStreamDictForBuf(c.Content())
+	if err != nil {
+		return nil, err
+	}
+	sd.InsertName("Type", "XObject")
+	sd.InsertName("Subtype", "Form")
+	sd.Insert("BBox", types.NewRectangle(0, 0, c.Width(), c.Height()).Array())
+	sd.Insert("Resources", resources)
+	if err := sd.Encode(); err != nil {
+		return nil, err
+	}
+	return ctx.IndRefForNewObject(*sd)
+}
+
+// drawSignatureSVG draws an SVG signature image as vectors, fitted into the canvas
+// like a raster image
+func drawSignatureSVG(c *pdfcanvas.Canvas, data []byte, resources types.Dict) error {
+	img, err := pdfcanvas.ParseSVG(data)
+	if err != nil {
+		return err
+	}
+	w, h := img.Size()
+	scale := math.Min(c.Width()/w, c.Height()/h)
+	c.Save()
+	c.Translate((c.Width()-w*scale)/2, (c.Height()-h*scale)/2)
+	if err := img.Draw(c, scale); err != nil {
+		return err
+	}
+	c.Restore()
+	if len(c.Opacities()) > 0 {
+		states := types.Dict{}
+		for alpha, name := range c.Opacities() {
+			states.Insert(name, types.Dict{"Type": types.Name("ExtGState"), "ca": types.Float(alpha), "CA": types.Float(alpha)})
+		}
+		resources.Insert("ExtGState", states)
+	}
+	return nil
+}
+
+// signatureFieldName returns the first unused "SignatureN" among the form fields
+func signatureFieldName(ctx *model.Context, fields types.Array) string {
+	used := map[string]bool{}
+	for _, f := range fields {
+		if d, err := ctx.DereferenceDict(f); err == nil && d != nil {
+			if t := d.StringEntry("T"); t != nil {
+				used[*t] = true
+			}
+		}
+	}
+	for n := 1; ; n++ {
+		name := fmt.Sprintf("Signature%d", n)
+		if !used[name] {
+			return name
+		}
+	}
+}
+
+// pdfUpdate collects the objects of an incremental update: everything created after
+// first plus the existing objects that were changed
+type pdfUpdate struct {
+	ctx     *model.Context
+	first   int
+	changed map[int]bool
+}
+
+// newPDFUpdate starts an update of ctx. New objects always get new numbers: reusing a
+// freed number needs a higher generation, which pdfcpu does not assign.
+func newPDFUpdate(ctx *model.Context) *pdfUpdate {
+	if head, ok := ctx.Table[0]; ok {
+		var none int64
+		head.Offset = &none
+	}
+	return &pdfUpdate{ctx: ctx, first: *ctx.Size, changed: map[int]bool{}}
+}
+
+// dict returns the dictionary under key in parent, creating it if needed, with the
+// object that holds it, which is marked as changed. parentRef holds parent.
+func (u *pdfUpdate) dict(parent types.Dict, parentRef *types.IndirectRef, key string) (types.Dict, *types.IndirectRef, error) {
+	switch o := parent[key].(type) {
+	case types.IndirectRef:
+		d, err := u.ctx.DereferenceDict(o)
+		if err != nil || d == nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %v", key, err)
+		}
+		u.changed[o.ObjectNumber.Value()] = true
+		return d, &o, nil
+	case types.Dict:
+		u.changed[parentRef.ObjectNumber.Value()] = true
+		return o, parentRef, nil
+	}
+	d := types.NewDict()
+	parent[key] = d
+	u.changed[parentRef.ObjectNumber.Value()] = true
+	return d, parentRef, nil
+}
+
+// appendTo appends o to the array under key in parent, creating the array if needed,
+// and marks the object that holds the array as changed
+func (u *pdfUpdate) appendTo(parent types.Dict, parentRef *types.IndirectRef, key string, o types.Object) error {
+	if ir, ok := parent[key].(types.IndirectRef); ok {
+		entry, found := u.ctx.FindTableEntryForIndRef(&ir)
+		if !found {
+			return fmt.Errorf("failed to read %s", key)
+		}
+		arr, ok := entry.Object.(types.Array)
+		if !ok {
+			return fmt.Errorf("failed to read %s: not an array", key)
+		}
+		entry.Object = append(arr, o)
+		u.changed[ir.ObjectNumber.Value()] = true
+		return nil
+	}
+	arr, _ := parent[key].(types.Array)
+	parent[key] = append(arr, o)
+	u.changed[parentRef.ObjectNumber.Value()] = true
+	return nil
+}
+
+// write serializes the update for a file of the given length whose last
+// cross-reference section starts at prev. The new section has the same form as that
+// one, a table or a stream.
+func (u *pdfUpdate) write(data []byte, prev int) ([]byte, error) {
+	var nrs []int
+	for nr := range u.changed {
+		nrs = append(nrs, nr)
+	}
+	for nr := u.first; nr < *u.ctx.Size; nr++ {
+		if entry, ok := u.ctx.Table[nr]; ok && !entry.Free && !u.changed[nr] {
+			nrs = append(nrs, nr)
+		}
+	}
+	sort.Ints(nrs)
+
+	var buf bytes.Buffer
+	offsets := map[int]int{}
+	for _, nr := range nrs {
+		offsets[nr] = len(data) + buf.Len()
+		if err := writeIndirectObject(&buf, nr, u.ctx.Table[nr].Object); err != nil {
+			return nil, err
+		}
+	}
+
+	trailer := types.NewDict()
+	trailer.Insert("Root", *u.ctx.Root)
+	if u.ctx.Info != nil {
+		trailer.Insert("Info", *u.ctx.Info)
+	}
+	if u.ctx.ID != nil {
+		trailer.Insert("ID", u.ctx.ID)
+	}
+	trailer.Insert("Prev", types.Integer(prev))
+
+	if bytes.HasPrefix(data[prev:], []byte("xref")) {
+		trailer.Insert("Size", types.Integer(*u.ctx.Size))
+		xref := len(data) + buf.Len()
+		buf.WriteString("xref\n")
+		for i := 0; i < len(nrs); {
+			j := i + 1
+			for j < len(nrs) && nrs[j] == nrs[j-1]+1 {
+				j++
+			}
+			fmt.Fprintf(&buf, "%d %d\n", nrs[i], j-i)
+			for _, nr := range nrs[i:j] {
+				fmt.Fprintf(&buf, "%010d 00000 n \n", offsets[nr])
+			}
+			i = j
+		}
+		fmt.Fprintf(&buf, "trailer\n%s\nstartxref\n%d\n%%%%EOF\n", trailer.PDFString(), xref)
+		return buf.Bytes(), nil
+	}
+
+	// A cross-reference stream lists itself too
+	self := *u.ctx.Size
+	offsets[self] = len(data) + buf.Len()
+	nrs = append(nrs, self)
+	var rows bytes.Buffer
+	index := types.Array{}
+	for _, nr := range nrs {
+		off := offsets[nr]
+		rows.Write([]byte{1, byte(off >> 24), byte(off >> 16), byte(off >> 8), byte(off), 0, 0})
+		index = append(index, types.Integer(nr), types.Integer(1))
+	}
+	trailer.InsertName("Type", "XRef")
+	trailer.Insert("Size", types.Integer(self+1))
+	trailer.Insert("W", types.Array{types.Integer(1), types.Integer(4), types.Integer(2)})
+	trailer.Insert("Index", index)
+	if err := writeIndirectObject(&buf, self, types.StreamDict{Dict: trailer, Raw: rows.Bytes()}); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&buf, "startxref\n%d\n%%%%EOF\n", offsets[self])
+	return buf.Bytes(), nil
+}
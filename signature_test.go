@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"CapGo/pkg/stamper"
+)
+
+// testSigningIdentity returns a self-signed ECDSA identity; x/crypto cannot write
+// .p12 files, so SignPDF itself is not covered
+func testSigningIdentity(t *testing.T, name string) *signingIdentity {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &signingIdentity{key: key, cert: cert}
+}
+
+// TestSignAndVerify signs the golden input twice, the second time visibly, and checks
+// what VerifySignatures reports before and after the file is tampered with
+func TestSignAndVerify(t *testing.T) {
+	a := goldenApp(t)
+	data, err := os.ReadFile(filepath.Join(goldenDir, "mixed_sizes.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	sign := func(data []byte, name string, req SignRequest) string {
+		signed, err := a.signPDFData(data, testSigningIdentity(t, name), req, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name+".pdf")
+		if err := os.WriteFile(path, signed, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	first := sign(data, "First Signer", SignRequest{Reason: "Approved"})
+	firstData, _ := os.ReadFile(first)
+	second := sign(firstData, "Second Signer", SignRequest{
+		Visible: &StampInfo{Stamp: stamper.Stamp{Kind: StampKindText, X: 72, Y: 72, Width: 180, Height: 50, PageNum: 2}},
+	})
+
+	report, err := a.VerifySignatures(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Signatures) != 2 {
+		t.Fatalf("got %d signatures, want 2", len(report.Signatures))
+	}
+	for i, want := range []struct {
+		signer   string
+		page     int
+		modified bool
+	}{{"First Signer", 0, true}, {"Second Signer", 2, false}} {
+		s := report.Signatures[i]
+		if s.SignerName != want.signer || s.PageNum != want.page || s.ModifiedAfterSigning != want.modified {
+			t.Errorf("signature %d: %s on page %d, modified %v; want %s on page %d, modified %v",
+				i, s.SignerName, s.PageNum, s.ModifiedAfterSigning, want.signer, want.page, want.modified)
+		}
+		if !s.Intact {
+			t.Errorf("signature %d is not intact: %v", i, s.Problems)
+		}
+		// The test certificates are self-signed and not trusted
+		if s.ChainValid || s.ChainProblem == "" {
+			t.Errorf("signature %d: chain valid %v, problem %q", i, s.ChainValid, s.ChainProblem)
+		}
+	}
+	if report.Signatures[0].Field == report.Signatures[1].Field {
+		t.Errorf("both signatures use the field %s", report.Signatures[0].Field)
+	}
+	if report.Valid {
+		t.Error("report is valid with untrusted certificates")
+	}
+
+	// Change the reason, which the first signature covers
+	tampered := bytes.Replace(firstData, []byte("(Approved)"), []byte("(Rejected)"), 1)
+	if bytes.Equal(tampered, firstData) {
+		t.Fatal("reason not found in the signed file")
+	}
+	path := filepath.Join(dir, "tampered.pdf")
+	if err := os.WriteFile(path, tampered, 0644); err != nil {
+		t.Fatal(err)
+	}
+	report, err = a.VerifySignatures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Signatures) != 1 || report.Signatures[0].Intact {
+		t.Errorf("tampered file: %+v", report.Signatures)
+	}
+}
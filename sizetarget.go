@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+
+	"github.com/nfnt/resize"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// SizeTargetResult reports how a document was brought under a size limit
+type SizeTargetResult struct {
+	Output     string  `json:"output"`
+	Size       int64   `json:"size"`
+	TargetSize int64   `json:"targetSize"`
+	Reached    bool    `json:"reached"` // false when even the strongest settings were not enough
+	Quality    int     `json:"quality"` // JPEG quality of recompressed images, 0 when images were left alone
+	Scale      float64 `json:"scale"`   // factor applied to image dimensions
+	Images     int     `json:"images"`  // number of images rewritten
+}
+
+// sizeTargetSteps are tried in order until the output fits. The first step only
+// optimizes the file structure, later ones trade image quality for size.
+var sizeTargetSteps = []struct {
+	quality int
+	scale   float64
+}{
+	{0, 1},
+	{85, 1},
+	{75, 1},
+	{75, 0.75},
+	{65, 0.6},
+	{55, 0.5},
+	{45, 0.35},
+	{35, 0.25},
+}
+
+// OptimizeToSize writes a copy of the PDF to Downloads that is smaller than maxMB,
+// recompressing and downsampling images as far as needed
+func (a *App) OptimizeToSize(pdfPath string, maxMB float64) (SizeTargetResult, error) {
+	pdfPath = filepath.Clean(pdfPath)
+	if maxMB <= 0 {
+		return SizeTargetResult{}, fmt.Errorf("size target must be positive")
+	}
+	defer a.startJob("optimize")()
+	outputPath, err := a.outputPath(pdfPath, "_small")
+	if err != nil {
+		return SizeTargetResult{}, err
+	}
+	res, err := optimizeToSize(pdfPath, outputPath, int64(maxMB*1024*1024))
+	if err != nil {
+		return res, err
+	}
+	recordDerivedHistory(pdfPath, outputPath, "optimize")
+	return res, nil
+}
+
+// optimizeToSize rewrites pdfPath into outputPath (which may be the same file) with the
+// mildest settings that fit target. When nothing fits, the smallest attempt is kept.
+func optimizeToSize(pdfPath, outputPath string, target int64) (SizeTargetResult, error) {
+	res := SizeTargetResult{Output: outputPath, TargetSize: target}
+
+	var best string
+	defer func() {
+		if best != "" {
+			os.Remove(best)
+		}
+	}()
+
+	for _, step := range sizeTargetSteps {
+		ctx, err := api.ReadContextFile(pdfPath)
+		if err != nil {
+			return res, fmt.Errorf("failed to read pdf: %v", err)
+		}
+
+		images := 0
+		if step.quality > 0 {
+			if images, err = recompressImages(ctx, step.quality, step.scale); err != nil {
+				return res, err
+			}
+			if images == 0 {
+				// Nothing left to trade for size
+				break
+			}
+		}
+		if err := api.OptimizeContext(ctx); err != nil {
+			return res, fmt.Errorf("failed to optimize pdf: %v", err)
+		}
+
+		tmp, err := os.CreateTemp(filepath.Dir(outputPath), ".capgo_size_*.pdf")
+		if err != nil {
+			return res, fmt.Errorf("failed to create temp file: %v", err)
+		}
+		tmp.Close()
+		if err := api.WriteContextFile(ctx, tmp.Name()); err != nil {
+			os.Remove(tmp.Name())
+			return res, fmt.Errorf("failed to write pdf: %v", err)
+		}
+		info, err := os.Stat(tmp.Name())
+		if err != nil {
+			os.Remove(tmp.Name())
+			return res, err
+		}
+
+		if best == "" || info.Size() < res.Size {
+			if best != "" {
+				os.Remove(best)
+			}
+			best = tmp.Name()
+			res.Size, res.Quality, res.Scale, res.Images = info.Size(), step.quality, step.scale, images
+		} else {
+			os.Remove(tmp.Name())
+		}
+		if res.Size <= target {
+			res.Reached = true
+			break
+		}
+	}
+
+	if best == "" {
+		return res, fmt.Errorf("failed to optimize pdf")
+	}
+	if err := os.Rename(best, outputPath); err != nil {
+		return res, fmt.Errorf("failed to save optimized pdf: %v", err)
+	}
+	best = ""
+	return res, nil
+}
+
+// recompressImages re-encodes the 8-bit RGB and gray images of the document as JPEG at the
+// given quality, scaling their dimensions by scale. Images that would grow, masks and
+// colour spaces that JPEG cannot carry faithfully are left alone.
+func recompressImages(ctx *model.Context, quality int, scale float64) (int, error) {
+	return recompressImagesScaled(ctx, quality, func(image.Rectangle) float64 { return scale })
+}
+
+// recompressImagesScaled is recompressImages with a scale chosen per image
+func recompressImagesScaled(ctx *model.Context, quality int, scaleFor func(image.Rectangle) float64) (int, error) {
+	// Soft masks and stencil masks must keep their exact pixels
+	masks := map[int]bool{}
+	for _, entry := range ctx.Table {
+		if entry == nil || entry.Free {
+			continue
+		}
+		if sd, ok := entry.Object.(types.StreamDict); ok {
+			for _, key := range []string{"SMask", "Mask"} {
+				if ir := sd.IndirectRefEntry(key); ir != nil {
+					masks[ir.ObjectNumber.Value()] = true
+				}
+			}
+		}
+	}
+
+	count := 0
+	for objNr, entry := range ctx.Table {
+		if entry == nil || entry.Free || masks[objNr] {
+			continue
+		}
+		sd, ok := entry.Object.(types.StreamDict)
+		if !ok || sd.Subtype() == nil || *sd.Subtype() != "Image" {
+			continue
+		}
+
+		img, ok := decodePDFImage(ctx, &sd)
+		if !ok {
+			continue
+		}
+
+		b := img.Bounds()
+		scale := scaleFor(b)
+		w := uint(float64(b.Dx()) * scale)
+		h := uint(float64(b.Dy()) * scale)
+		if scale < 1 && w >= 16 && h >= 16 {
+			img = resize.Resize(w, h, img, resize.Lanczos3)
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return count, fmt.Errorf("failed to encode image: %v", err)
+		}
+		if buf.Len() >= len(sd.Raw) {
+			continue
+		}
+
+		nb := img.Bounds()
+		sd.Raw = buf.Bytes()
+		sd.Content = nil
+		sd.FilterPipeline = []types.PDFFilter{{Name: filter.DCT}}
+		l := int64(len(sd.Raw))
+		sd.StreamLength = &l
+		sd.Update("Length", types.Integer(l))
+		sd.Update("Filter", types.Name(filter.DCT))
+		sd.Delete("DecodeParms")
+		sd.Update("Width", types.Integer(nb.Dx()))
+		sd.Update("Height", types.Integer(nb.Dy()))
+		sd.Update("BitsPerComponent", types.Integer(8))
+		entry.Object = sd
+		count++
+	}
+	return count, nil
+}
+
+// decodePDFImage decodes an image XObject with 8 bits per component in DeviceRGB,
+// DeviceGray or an equivalent ICC colour space
+func decodePDFImage(ctx *model.Context, sd *types.StreamDict) (image.Image, bool) {
+	if m := sd.BooleanEntry("ImageMask"); m != nil && *m {
+		return nil, false
+	}
+	if _, found := sd.Find("Decode"); found {
+		return nil, false
+	}
+	if bpc := sd.IntEntry("BitsPerComponent"); bpc == nil || *bpc != 8 {
+		return nil, false
+	}
+	w, h := sd.IntEntry("Width"), sd.IntEntry("Height")
+	if w == nil || h == nil || *w <= 0 || *h <= 0 {
+		return nil, false
+	}
+
+	comps := imageComponents(ctx, sd)
+	if comps != 1 && comps != 3 {
+		return nil, false
+	}
+
+	if len(sd.FilterPipeline) == 1 && sd.FilterPipeline[0].Name == filter.DCT {
+		img, err := jpeg.Decode(bytes.NewReader(sd.Raw))
+		if err != nil {
+			return nil, false
+		}
+		return img, true
+	}
+	if len(sd.FilterPipeline) > 1 || (len(sd.FilterPipeline) == 1 && sd.FilterPipeline[0].Name != filter.Flate) {
+		return nil, false
+	}
+
+	if err := sd.Decode(); err != nil {
+		return nil, false
+	}
+	if len(sd.Content) < *w**h*comps {
+		return nil, false
+	}
+
+	rect := image.Rect(0, 0, *w, *h)
+	if comps == 1 {
+		img := image.NewGray(rect)
+		copy(img.Pix, sd.Content)
+		return img, true
+	}
+	img := image.NewRGBA(rect)
+	for i, j := 0, 0; i < *w**h*3; i, j = i+3, j+4 {
+		img.Pix[j] = sd.Content[i]
+		img.Pix[j+1] = sd.Content[i+1]
+		img.Pix[j+2] = sd.Content[i+2]
+		img.Pix[j+3] = 0xFF
+	}
+	return img, true
+}
+
+// imageComponents returns the number of colour components of an image's colour space,
+// or 0 for colour spaces that are not plain gray or RGB
+func imageComponents(ctx *model.Context, sd *types.StreamDict) int {
+	o, found := sd.Find("ColorSpace")
+	if !found {
+		return 0
+	}
+	o, err := ctx.Dereference(o)
+	if err != nil {
+		return 0
+	}
+	switch cs := o.(type) {
+	case types.Name:
+		switch cs {
+		case "DeviceRGB":
+			return 3
+		case "DeviceGray":
+			return 1
+		}
+	case types.Array:
+		if len(cs) == 2 {
+			if name, ok := cs[0].(types.Name); ok && name == "ICCBased" {
+				profile, _, err := ctx.DereferenceStreamDict(cs[1])
+				if err == nil && profile != nil {
+					if n := profile.IntEntry("N"); n != nil {
+						return *n
+					}
+				}
+			}
+		}
+	}
+	return 0
+}
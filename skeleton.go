@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// SkeletonReport describes what ExportSkeleton removed
+type SkeletonReport struct {
+	Path           string `json:"path"`
+	Strings        int    `json:"strings"`        // text strings replaced, in objects and page content
+	ContentStreams int    `json:"contentStreams"` // page, form and appearance streams rewritten
+	Images         int    `json:"images"`         // images replaced by gray ones of the same size
+	Attachments    int    `json:"attachments"`    // attachments and XMP metadata emptied
+}
+
+// skeletonKeepStrings are strings that carry structure rather than content
+var skeletonKeepStrings = map[string]bool{
+	"DA":   true, // default appearance of form fields, e.g. "/Helv 12 Tf 0 g"
+	"Lang": true,
+}
+
+// ExportSkeleton writes a copy of a document for bug reports that keeps its object
+// structure, page boxes, fonts and resources but none of its content: text strings are
+// replaced by placeholders of the same length, images by flat gray ones, and
+// attachments and metadata are emptied. Embedded fonts are kept, since they are often
+// what is broken, and only hold glyph shapes. The placeholders are derived
+// from a random key, so equal strings stay equal (keeping named destinations working)
+// without revealing the original text.
+func (a *App) ExportSkeleton(pdfPath string) (SkeletonReport, error) {
+	pdfPath = filepath.Clean(pdfPath)
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return SkeletonReport{}, err
+	}
+	ctx, err := api.ReadContextFile(pdfPath)
+	if err != nil {
+		return SkeletonReport{}, fmt.Errorf("failed to read pdf: %v", err)
+	}
+
+	s, err := newSkeleton(ctx)
+	if err != nil {
+		return SkeletonReport{}, err
+	}
+	if err := s.strip(); err != nil {
+		return SkeletonReport{}, err
+	}
+
+	output, err := a.outputPath(pdfPath, "_skeleton")
+	if err != nil {
+		return SkeletonReport{}, err
+	}
+	// Plain objects keep the structure readable in a text editor
+	ctx.Configuration.WriteObjectStream = false
+	ctx.Configuration.WriteXRefStream = false
+	if err := api.WriteContextFile(ctx, output); err != nil {
+		return SkeletonReport{}, classifyFileError("write", output, err)
+	}
+
+	s.report.Path = output
+	fmt.Printf("Backend: Exported skeleton of %s to %s\n", pdfPath, output)
+	return s.report, nil
+}
+
+// skeleton strips the content from a document in place
+type skeleton struct {
+	ctx     *model.Context
+	key     []byte
+	content map[int]bool // content streams: page contents, Type 3 glyphs, appearances
+	report  SkeletonReport
+}
+
+func newSkeleton(ctx *model.Context) (*skeleton, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &skeleton{ctx: ctx, key: key, content: map[int]bool{}}, nil
+}
+
+func (s *skeleton) strip() error {
+	// Objects inside object streams are only parsed on first use
+	for objNr, entry := range s.ctx.Table {
+		if entry == nil || entry.Free || entry.Generation == nil {
+			continue
+		}
+		if _, err := s.ctx.Dereference(*types.NewIndirectRef(objNr, *entry.Generation)); err != nil {
+			return fmt.Errorf("failed to read object %d: %v", objNr, err)
+		}
+	}
+
+	// Find which streams are drawn as content
+	for _, entry := range s.ctx.Table {
+		if entry == nil || entry.Free {
+			continue
+		}
+		switch o := entry.Object.(type) {
+		case types.Dict:
+			s.classify(o)
+		case types.StreamDict:
+			s.classify(o.Dict)
+		}
+	}
+
+	var encrypt int
+	if s.ctx.Encrypt != nil {
+		encrypt = s.ctx.Encrypt.ObjectNumber.Value()
+	}
+	for objNr, entry := range s.ctx.Table {
+		if entry == nil || entry.Free || objNr == encrypt {
+			continue
+		}
+		switch o := entry.Object.(type) {
+		case types.StreamDict:
+			s.redactObject(o.Dict)
+			if err := s.stripStream(objNr, &o); err != nil {
+				return fmt.Errorf("failed to strip object %d: %v", objNr, err)
+			}
+			entry.Object = o
+		case types.Dict, types.Array, types.StringLiteral, types.HexLiteral:
+			entry.Object = s.redactObject(o)
+		}
+	}
+	return nil
+}
+
+// classify records the streams a dictionary draws as content
+func (s *skeleton) classify(d types.Dict) {
+	addRefs := func(set map[int]bool, o types.Object) {
+		switch o := o.(type) {
+		case types.IndirectRef:
+			set[o.ObjectNumber.Value()] = true
+		case types.Array:
+			for _, e := range o {
+				if ir, ok := e.(types.IndirectRef); ok {
+					set[ir.ObjectNumber.Value()] = true
+				}
+			}
+		}
+	}
+	if t := d.Type(); t != nil && *t == "Page" {
+		addRefs(s.content, d["Contents"])
+	}
+	if st := d.Subtype(); st != nil && *st == "Type3" {
+		if procs, err := s.ctx.DereferenceDict(d["CharProcs"]); err == nil {
+			for _, v := range procs {
+				addRefs(s.content, v)
+			}
+		}
+	}
+	// Appearance streams: /N, /R and /D hold a stream or a dictionary of states
+	if ap, err := s.ctx.DereferenceDict(d["AP"]); err == nil {
+		for _, v := range ap {
+			addRefs(s.content, v)
+			if states, err := s.ctx.DereferenceDict(v); err == nil {
+				for _, sv := range states {
+					addRefs(s.content, sv)
+				}
+			}
+		}
+	}
+}
+
+func (s *skeleton) stripStream(objNr int, sd *types.StreamDict) error {
+	typ, subtype := "", ""
+	if t := sd.Type(); t != nil {
+		typ = *t
+	}
+	if st := sd.Subtype(); st != nil {
+		subtype = *st
+	}
+	pt := sd.IntEntry("PatternType")
+
+	switch {
+	case typ == "XRef" || typ == "ObjStm":
+		return nil
+	case subtype == "Image":
+		s.report.Images++
+		return setStreamContent(sd, grayImage(sd.Dict))
+	case typ == "Metadata" || typ == "EmbeddedFile":
+		s.report.Attachments++
+		sd.Delete("Params")
+		return setStreamContent(sd, nil)
+	case s.content[objNr] || subtype == "Form" || (pt != nil && *pt == 1):
+		s.report.ContentStreams++
+		if err := sd.Decode(); err != nil {
+			// Unreadable content cannot be checked, so none of it is kept
+			return setStreamContent(sd, nil)
+		}
+		return setStreamContent(sd, s.redactContent(sd.Content))
+	}
+	return nil
+}
+
+// setStreamContent replaces the data of a stream, compressed with Flate
+func setStreamContent(sd *types.StreamDict, content []byte) error {
+	sd.Delete("DecodeParms")
+	sd.Delete("DL")
+	sd.FilterPipeline = []types.PDFFilter{{Name: filter.Flate}}
+	sd.Update("Filter", types.Name(filter.Flate))
+	if content == nil {
+		content = []byte{}
+	}
+	sd.Content = content
+	return sd.Encode()
+}
+
+// grayImage returns mid-gray pixel data for an image dictionary, adjusting the
+// dictionary to a color space whose size is known
+func grayImage(d types.Dict) []byte {
+	w, h := d.IntEntry("Width"), d.IntEntry("Height")
+	if w == nil || h == nil || *w <= 0 || *h <= 0 {
+		return nil
+	}
+	d.Delete("SMaskInData")
+	comps, bpc := 1, 8
+	if mask := d.BooleanEntry("ImageMask"); mask != nil && *mask {
+		bpc = 1
+	} else {
+		switch cs := d["ColorSpace"].(type) {
+		case types.Name:
+			switch cs {
+			case "DeviceRGB", "CalRGB", "Lab":
+				comps = 3
+			case "DeviceCMYK":
+				comps = 4
+			case "DeviceGray", "CalGray":
+			default:
+				d.Update("ColorSpace", types.Name("DeviceGray"))
+			}
+		default:
+			d.Update("ColorSpace", types.Name("DeviceGray"))
+			d.Delete("Decode")
+		}
+		d.Update("BitsPerComponent", types.Integer(bpc))
+	}
+	rowBytes := (*w*comps*bpc + 7) / 8
+	return bytes.Repeat([]byte{0x80}, rowBytes**h)
+}
+
+// redactObject replaces the strings inside an object
+func (s *skeleton) redactObject(o types.Object) types.Object {
+	switch o := o.(type) {
+	case types.Dict:
+		for k, v := range o {
+			if !skeletonKeepStrings[k] {
+				o[k] = s.redactObject(v)
+			}
+		}
+		return o
+	case types.Array:
+		for i, v := range o {
+			o[i] = s.redactObject(v)
+		}
+		return o
+	case types.StringLiteral:
+		n := len(o)
+		if b, err := types.Unescape(string(o)); err == nil {
+			n = len(b)
+		}
+		s.report.Strings++
+		return types.StringLiteral(s.placeholder([]byte(o), n))
+	case types.HexLiteral:
+		s.report.Strings++
+		return types.HexLiteral(s.placeholder([]byte(o), len(o)))
+	}
+	return o
+}
+
+// placeholder returns n hex digits derived from the key and the original string
+func (s *skeleton) placeholder(orig []byte, n int) string {
+	var out []byte
+	block := append(append([]byte{}, s.key...), orig...)
+	for len(out) < n {
+		sum := sha256.Sum256(block)
+		out = append(out, hex.EncodeToString(sum[:])...)
+		block = sum[:]
+	}
+	return string(out[:n])
+}
+
+// redactContent replaces the string operands of a content stream and drops comments
+// and inline images, leaving the operators and their numeric operands in place
+func (s *skeleton) redactContent(b []byte) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == '%':
+			for i < len(b) && b[i] != '\n' && b[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			raw, end := literalString(b, i)
+			s.report.Strings++
+			out.WriteString("(" + s.placeholder(raw, len(raw)) + ")")
+			i = end
+		case c == '<' && i+1 < len(b) && b[i+1] == '<':
+			out.WriteString("<<")
+			i += 2
+		case c == '<':
+			end := bytes.IndexByte(b[i:], '>')
+			if end < 0 {
+				end = len(b) - i - 1
+			}
+			digits := 0
+			for _, d := range b[i+1 : i+end] {
+				if !isPDFWhitespace(d) {
+					digits++
+				}
+			}
+			s.report.Strings++
+			out.WriteString("<" + s.placeholder(b[i+1:i+end], digits) + ">")
+			i += end + 1
+		case c == 'B' && isOperator(b, i, "BI"):
+			// Inline images are dropped as a whole
+			i = inlineImageEnd(b, i)
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
+
+// literalString decodes the literal string starting at b[start] == '(' and returns its
+// bytes and the index after the closing parenthesis
+func literalString(b []byte, start int) ([]byte, int) {
+	var raw []byte
+	depth := 0
+	i := start
+	for i < len(b) {
+		c := b[i]
+		switch c {
+		case '(':
+			depth++
+			if depth > 1 {
+				raw = append(raw, c)
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				return raw, i + 1
+			}
+			raw = append(raw, c)
+		case '\\':
+			i++
+			if i >= len(b) {
+				return raw, i
+			}
+			switch e := b[i]; {
+			case e >= '0' && e <= '7':
+				v := 0
+				for k := 0; k < 3 && i < len(b) && b[i] >= '0' && b[i] <= '7'; k++ {
+					v = v*8 + int(b[i]-'0')
+					i++
+				}
+				raw = append(raw, byte(v))
+				continue
+			case e == '\r' || e == '\n':
+				// Line continuation
+				if e == '\r' && i+1 < len(b) && b[i+1] == '\n' {
+					i++
+				}
+			default:
+				raw = append(raw, e)
+			}
+		default:
+			raw = append(raw, c)
+		}
+		i++
+	}
+	return raw, i
+}
+
+// inlineImageEnd returns the index after the "EI" that ends the inline image at b[start]
+func inlineImageEnd(b []byte, start int) int {
+	id := start
+	for id < len(b) && !isOperator(b, id, "ID") {
+		id++
+	}
+	for i := id + 3; i+1 < len(b); i++ {
+		if b[i] == 'E' && b[i+1] == 'I' && isPDFWhitespace(b[i-1]) &&
+			(i+2 == len(b) || isPDFWhitespace(b[i+2])) {
+			return i + 2
+		}
+	}
+	return len(b)
+}
+
+// isOperator reports whether the operator op stands on its own at b[i]
+func isOperator(b []byte, i int, op string) bool {
+	if !bytes.HasPrefix(b[i:], []byte(op)) {
+		return false
+	}
+	if i > 0 && !isPDFWhitespace(b[i-1]) && !isPDFDelimiter(b[i-1]) {
+		return false
+	}
+	j := i + len(op)
+	return j == len(b) || isPDFWhitespace(b[j]) || isPDFDelimiter(b[j])
+}
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
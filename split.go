@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// pageRange is an inclusive range of 1-based page numbers
+type pageRange struct {
+	From, To int
+}
+
+func (r pageRange) String() string {
+	if r.From == r.To {
+		return strconv.Itoa(r.From)
+	}
+	return fmt.Sprintf("%d-%d", r.From, r.To)
+}
+
+// parsePageRange parses "4" or "5-10" and checks it against the page count
+func parsePageRange(s string, pageCount int) (pageRange, error) {
+	s = strings.TrimSpace(s)
+	from, to, isRange := strings.Cut(s, "-")
+	a, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return pageRange{}, fmt.Errorf("invalid page range %q", s)
+	}
+	b := a
+	if isRange {
+		if b, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+			return pageRange{}, fmt.Errorf("invalid page range %q", s)
+		}
+	}
+	switch {
+	case a < 1 || b < 1:
+		return pageRange{}, fmt.Errorf("invalid page range %q: pages start at 1", s)
+	case a > b:
+		return pageRange{}, fmt.Errorf("invalid page range %q: %d comes after %d", s, a, b)
+	case b > pageCount:
+		return pageRange{}, fmt.Errorf("invalid page range %q: the document has %d pages", s, pageCount)
+	}
+	return pageRange{From: a, To: b}, nil
+}
+
+// SplitPDF writes one document per page range, e.g. "1-3", "4" and "5-10", and returns
+// their paths in the order of the ranges. The source is left unchanged.
+func (a *App) SplitPDF(pdfPath string, ranges []string) ([]string, error) {
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no page ranges given")
+	}
+	pdfPath = filepath.Clean(pdfPath)
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return nil, classifyFileError("read", pdfPath, err)
+	}
+	pageCount, err := api.PageCount(bytes.NewReader(data), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %v", err)
+	}
+
+	// Check every range before writing anything
+	parsed := make([]pageRange, len(ranges))
+	for i, r := range ranges {
+		if parsed[i], err = parsePageRange(r, pageCount); err != nil {
+			return nil, err
+		}
+	}
+
+	defer a.startJob("split")()
+	if err := a.runBeforeHooks("split", pdfPath); err != nil {
+		return nil, err
+	}
+
+	outputs := make([]string, 0, len(parsed))
+	for _, r := range parsed {
+		var buf bytes.Buffer
+		if err := api.Trim(bytes.NewReader(data), &buf, []string{r.String()}, nil); err != nil {
+			return outputs, fmt.Errorf("failed to extract pages %s: %v", r, err)
+		}
+		suffix := "_pages_" + r.String()
+		if r.From == r.To {
+			suffix = "_page_" + r.String()
+		}
+		output, err := a.outputPath(pdfPath, suffix)
+		if err != nil {
+			return outputs, err
+		}
+		if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+			return outputs, classifyFileError("write", output, err)
+		}
+		if err := a.finishOutput(output); err != nil {
+			return outputs, err
+		}
+		outputs = append(outputs, output)
+	}
+	fmt.Printf("Backend: Split %s into %d files\n", pdfPath, len(outputs))
+	a.runAfterHooks("split", pdfPath, outputs...)
+	return outputs, nil
+}
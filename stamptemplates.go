@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"CapGo/internal/pdfcanvas"
+	"CapGo/pkg/stamper"
+)
+
+// StampTemplate is a named, reusable stamp such as a signature image. Templates only
+// hold what a stamp looks like; ApplyTemplate places one on a page.
+type StampTemplate struct {
+	Name string `json:"name"`
+	Kind string `json:"kind,omitempty"` // "image" (default) or "text"
+	// Image is stored as a data URL, so the template keeps working when the original
+	// file is moved; SaveTemplate also accepts a path
+	Image    string             `json:"image,omitempty"`
+	Text     string             `json:"text,omitempty"`
+	FontName string             `json:"fontName,omitempty"`
+	FontSize int                `json:"fontSize,omitempty"`
+	Color    string             `json:"color,omitempty"`
+	Style    *stamper.TextStyle `json:"style,omitempty"`
+	Width    float64            `json:"width"`  // default size in PDF points
+	Height   float64            `json:"height"` // default size in PDF points
+	Opacity  *float64           `json:"opacity,omitempty"`
+	Rotation int                `json:"rotation,omitempty"`
+	// RemoveBackground is applied to the image when the template is stamped; the
+	// stored image keeps its background
+	RemoveBackground *stamper.BackgroundRemoval `json:"removeBackground,omitempty"`
+}
+
+const stampTemplatesFile = "stamp_templates.json"
+
+// maxEmbeddedImageBytes bounds the images stored in templates and session files
+const maxEmbeddedImageBytes = 10 << 20
+
+var stampTemplatesMu sync.Mutex
+
+func loadStampTemplates() (map[string]StampTemplate, error) {
+	templates := map[string]StampTemplate{}
+	if err := readConfigJSON(stampTemplatesFile, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// ListTemplates returns the saved stamp templates sorted by name
+func (a *App) ListTemplates() ([]StampTemplate, error) {
+	stampTemplatesMu.Lock()
+	defer stampTemplatesMu.Unlock()
+
+	templates, err := loadStampTemplates()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]StampTemplate, 0, len(templates))
+	for name, t := range templates {
+		t.Name = name
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// SaveTemplate stores a stamp template under its name, replacing a template of the
+// same name. An image given as a path is read and stored with the template.
+func (a *App) SaveTemplate(t StampTemplate) error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return fmt.Errorf("template name cannot be empty")
+	}
+	if t.Width <= 0 || t.Height <= 0 {
+		return fmt.Errorf("template %s has an invalid size", t.Name)
+	}
+	if t.Opacity != nil && (*t.Opacity < 0 || *t.Opacity > 1) {
+		return fmt.Errorf("template %s has an invalid opacity %g, expected 0 to 1", t.Name, *t.Opacity)
+	}
+	if _, err := stamper.NormalizeRotation(t.Rotation); err != nil {
+		return err
+	}
+	if t.RemoveBackground != nil {
+		if err := t.RemoveBackground.Check(); err != nil {
+			return fmt.Errorf("template %s: %v", t.Name, err)
+		}
+	}
+	if t.Kind == StampKindText {
+		if strings.TrimSpace(t.Text) == "" {
+			return fmt.Errorf("template %s has no text", t.Name)
+		}
+		t.Image = ""
+	} else {
+		img, err := imageDataURL(t.Image)
+		if err != nil {
+			return err
+		}
+		t.Image = img
+	}
+
+	stampTemplatesMu.Lock()
+	defer stampTemplatesMu.Unlock()
+	// The template manager window runs in a process of its own
+	unlock, err := lockConfigFile(stampTemplatesFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	templates, err := loadStampTemplates()
+	if err != nil {
+		return err
+	}
+	templates[t.Name] = t
+	if err := writeConfigJSON(stampTemplatesFile, templates); err != nil {
+		return err
+	}
+	fmt.Printf("Backend: Saved stamp template %s\n", t.Name)
+	return nil
+}
+
+// DeleteTemplate removes a saved stamp template
+func (a *App) DeleteTemplate(name string) error {
+	stampTemplatesMu.Lock()
+	defer stampTemplatesMu.Unlock()
+	unlock, err := lockConfigFile(stampTemplatesFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	templates, err := loadStampTemplates()
+	if err != nil {
+		return err
+	}
+	delete(templates, name)
+	return writeConfigJSON(stampTemplatesFile, templates)
+}
+
+// ApplyTemplate returns a stamp made from a saved template, placed with its bottom-left
+// corner at x, y in PDF points on the given page
+func (a *App) ApplyTemplate(name string, pageNum int, x, y float64) (StampInfo, error) {
+	stampTemplatesMu.Lock()
+	templates, err := loadStampTemplates()
+	stampTemplatesMu.Unlock()
+	if err != nil {
+		return StampInfo{}, err
+	}
+	t, ok := templates[name]
+	if !ok {
+		return StampInfo{}, fmt.Errorf("unknown stamp template: %s", name)
+	}
+	if pageNum < 1 {
+		return StampInfo{}, fmt.Errorf("template %s needs a page number", name)
+	}
+	return StampInfo{
+		Stamp: stamper.Stamp{
+			Kind:             t.Kind,
+			Image:            t.Image,
+			Text:             t.Text,
+			FontName:         t.FontName,
+			FontSize:         t.FontSize,
+			Color:            t.Color,
+			Rotation:         t.Rotation,
+			Style:            t.Style,
+			Opacity:          t.Opacity,
+			RemoveBackground: t.RemoveBackground,
+			X:                x,
+			Y:                y,
+			Width:            t.Width,
+			Height:           t.Height,
+			PageNum:          pageNum,
+		},
+		TemplateID: name,
+		Units:      StampUnitsPoints,
+	}, nil
+}
+
+// templateImage checks the image of a template and returns it as a data URL
+func imageDataURL(src string) (string, error) {
+	if src == "" {
+		return "", fmt.Errorf("no image was given")
+	}
+	var data []byte
+	if _, encoded, ok := strings.Cut(src, ";base64,"); ok {
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return "", fmt.Errorf("failed to decode image: %v", err)
+		}
+	} else {
+		path := filepath.Clean(src)
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", classifyFileError("read", path, err)
+		}
+		if info.Size() > maxEmbeddedImageBytes {
+			return "", fmt.Errorf("%s is larger than %d MB", filepath.Base(path), maxEmbeddedImageBytes>>20)
+		}
+		if data, err = os.ReadFile(path); err != nil {
+			return "", classifyFileError("read", path, err)
+		}
+	}
+	if len(data) > maxEmbeddedImageBytes {
+		return "", fmt.Errorf("image is larger than %d MB", maxEmbeddedImageBytes>>20)
+	}
+	if pdfcanvas.IsSVG(data) {
+		if err := pdfcanvas.CheckSVG(data); err != nil {
+			return "", err
+		}
+		return "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString(data), nil
+	}
+	// Only Safari shows HEIC and TIFF, so the template keeps a PNG of an iPhone photo or
+	// the first page of a scan
+	if stamper.IsHEIF(data) || isTIFF(data) {
+		img, err := stamper.DecodeImage(data)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode image: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return "", fmt.Errorf("failed to encode image: %v", err)
+		}
+		data = buf.Bytes()
+	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return "", fmt.Errorf("failed to decode image: %v", err)
+	}
+	return "data:" + http.DetectContentType(data) + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"CapGo/pkg/stamper"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+)
+
+// Stamp kinds
+const (
+	StampKindImage = stamper.KindImage
+	StampKindText  = stamper.KindText
+)
+
+// GetStampFonts returns the fonts text stamps can use: the standard PDF fonts followed
+// by any fonts installed into pdfcpu
+func (a *App) GetStampFonts() []string {
+	api.LoadConfiguration()
+	if err := font.LoadUserFonts(); err != nil {
+		fmt.Printf("Backend: Failed to load user fonts: %v\n", err)
+	}
+	core, user := font.CoreFontNames(), font.UserFontNames()
+	sort.Strings(core)
+	sort.Strings(user)
+	return append(core, user...)
+}
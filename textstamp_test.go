@@ -0,0 +1,36 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"CapGo/pkg/stamper"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// pdfcpu replaces %p, %P and %t in text watermarks; text stamps must show what was typed
+func TestTextStampKeepsPercent(t *testing.T) {
+	a := goldenApp(t)
+	input, _ := filepath.Abs(filepath.Join(goldenDir, "mixed_sizes.pdf"))
+	const text = "%p %t 100%"
+	output, err := a.StampPDF(input, []StampInfo{
+		{Stamp: stamper.Stamp{Kind: StampKindText, Text: text, X: 72, Y: 72, Width: 200, Height: 40, PageNum: 1}},
+		{Stamp: stamper.Stamp{Kind: StampKindText, Text: "5%tax %P", Rotation: 90, X: 72, Y: 200, Width: 40, Height: 200, PageNum: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := api.ReadContextFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := newTextExtractor(ctx, 1<<16)
+	x.page(1)
+	for _, want := range []string{text, "5%tax %P"} {
+		if !strings.Contains(x.text.String(), want) {
+			t.Errorf("page text %q does not contain %q", x.text.String(), want)
+		}
+	}
+}
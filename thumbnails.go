@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nfnt/resize"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// Widths GenerateThumbnails accepts, in pixels
+const (
+	defaultThumbnailWidth = 160
+	maxThumbnailWidth     = 1024
+)
+
+// GenerateThumbnails returns a small image of every page as a data URL, at most
+// maxWidth pixels wide (160 when 0), for the reorder grid. Thumbnails are kept in the
+// preview cache under the hash of the document, so reopening it, even after it was
+// moved or copied, only renders the pages that are not cached yet.
+func (a *App) GenerateThumbnails(pdfPath string, maxWidth int) (_ []string, err error) {
+	pdfPath = filepath.Clean(pdfPath)
+	if maxWidth == 0 {
+		maxWidth = defaultThumbnailWidth
+	}
+	if maxWidth < 0 || maxWidth > maxThumbnailWidth {
+		return nil, fmt.Errorf("thumbnail width must be between 1 and %d pixels", maxThumbnailWidth)
+	}
+	defer recoverDamaged(pdfPath, &err)
+	if err := a.ensureLocal(pdfPath); err != nil {
+		return nil, err
+	}
+	sum, err := fileSHA256(pdfPath)
+	if err != nil {
+		return nil, classifyFileError("read", pdfPath, err)
+	}
+	dims, err := api.PageDimsFile(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page dimensions for %s: %v", pdfPath, err)
+	}
+	if len(dims) == 0 {
+		return nil, fmt.Errorf("no page dimensions found for %s", pdfPath)
+	}
+
+	settings := a.GetPreviewSettings()
+	thumbs := make([]string, len(dims))
+	first, last := 0, 0
+	for i := range dims {
+		if thumbs[i] = cachedThumbnail(settings, sum, i+1, maxWidth); thumbs[i] == "" {
+			if first == 0 {
+				first = i + 1
+			}
+			last = i + 1
+		}
+	}
+	if first == 0 {
+		return thumbs, nil
+	}
+
+	gs := ghostscriptPath()
+	if gs == "" {
+		return nil, fmt.Errorf("rendering pages needs Ghostscript, which is not installed")
+	}
+	// Render at the resolution that makes the largest page side maxWidth pixels, so no
+	// page comes out smaller than its thumbnail whatever its rotation
+	var side float64
+	for _, d := range dims[first-1 : last] {
+		side = math.Max(side, math.Max(d.Width, d.Height))
+	}
+	dpi := int(math.Ceil(float64(maxWidth) * 72 / math.Max(side, 1)))
+	dpi = min(max(dpi, 1), maxRenderDPI)
+
+	tmpDir, err := os.MkdirTemp("", "capgo_thumbs_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp folder: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	fmt.Printf("Backend: Rendering thumbnails of pages %d to %d of %s\n", first, last, pdfPath)
+	timeout := time.Duration(a.GetOperationTimeouts().Seconds) * time.Second
+	if _, err := ghostscriptPNG(gs, pdfPath, first, last, dpi, filepath.Join(tmpDir, "%d.png"), timeout); err != nil {
+		return nil, fmt.Errorf("failed to render thumbnails of %s: %v", filepath.Base(pdfPath), err)
+	}
+
+	for p := first; p <= last; p++ {
+		if thumbs[p-1] != "" {
+			continue
+		}
+		img, err := readPNG(filepath.Join(tmpDir, fmt.Sprintf("%d.png", p-first+1)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to render page %d of %s: %v", p, filepath.Base(pdfPath), err)
+		}
+		if img.Bounds().Dx() > maxWidth {
+			img = resize.Resize(uint(maxWidth), 0, img, resize.Lanczos3)
+		}
+		data, err := encodePreview(img, settings)
+		if err != nil {
+			return nil, err
+		}
+		if path := thumbnailPath(settings, sum, p, maxWidth); path != "" {
+			previewCacheMu.Lock()
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				fmt.Printf("Backend: Could not cache thumbnail %s: %v\n", path, err)
+			}
+			previewCacheMu.Unlock()
+		}
+		thumbs[p-1] = "data:" + previewMIME(previewExt(settings)) + ";base64," + base64.StdEncoding.EncodeToString(data)
+	}
+	return thumbs, a.trimPreviewCache()
+}
+
+// cachedThumbnail returns the cached thumbnail of a page as a data URL, or "" when
+// there is none
+func cachedThumbnail(settings PreviewSettings, sum string, page, width int) string {
+	path := thumbnailPath(settings, sum, page, width)
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	// The modification time orders the previews for eviction
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return "data:" + previewMIME(path) + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// thumbnailPath returns the cache file of a page thumbnail, or "" when the cache is off.
+// Thumbnails share the preview cache, so its size limit and clearing cover them too.
+func thumbnailPath(settings PreviewSettings, sum string, page, width int) string {
+	if settings.MaxCacheMB == 0 {
+		return ""
+	}
+	dir, err := previewCacheDir(settings)
+	if err != nil {
+		return ""
+	}
+	key := fmt.Sprintf("thumbnail\x00%s\x00%d\x00%d\x00%s\x00%d", sum, page, width, settings.Format, settings.Quality)
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, hex.EncodeToString(h[:])+previewExt(settings))
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
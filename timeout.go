@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"time"
+)
+
+// OperationTimeouts bounds how long a single operation on a document may run.
+// Some malformed PDFs make pdfcpu spin for minutes instead of failing.
+type OperationTimeouts struct {
+	Seconds int `json:"seconds"` // 0 for no limit
+	// GhostscriptFallback retries an operation that timed out once more on a copy of the
+	// document rewritten by Ghostscript, when Ghostscript is installed
+	GhostscriptFallback bool `json:"ghostscriptFallback"`
+}
+
+func defaultOperationTimeouts() OperationTimeouts {
+	return OperationTimeouts{Seconds: 300}
+}
+
+// OperationTimeoutError is returned when an operation runs past the operation timeout
+type OperationTimeoutError struct {
+	Operation string
+	Path      string
+	Timeout   time.Duration
+	// CanRetry means Ghostscript is installed but the fallback is turned off
+	CanRetry bool
+}
+
+func (e *OperationTimeoutError) Error() string {
+	if e.CanRetry {
+		return fmt.Sprintf("%s of %s timed out after %d seconds, retry with Ghostscript",
+			e.Operation, filepath.Base(e.Path), int(e.Timeout.Seconds()))
+	}
+	return fmt.Sprintf("%s of %s timed out after %d seconds", e.Operation, filepath.Base(e.Path), int(e.Timeout.Seconds()))
+}
+
+// GetOperationTimeouts returns the operation timeout settings
+func (a *App) GetOperationTimeouts() OperationTimeouts {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.settings.Timeouts
+}
+
+// SetOperationTimeouts updates and persists the operation timeout settings
+func (a *App) SetOperationTimeouts(timeouts OperationTimeouts) error {
+	if timeouts.Seconds < 0 {
+		return fmt.Errorf("operation timeout cannot be negative")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.settings.Timeouts = timeouts
+	return a.saveSettings()
+}
+
+// GhostscriptAvailable reports whether the Ghostscript fallback can be used
+func (a *App) GhostscriptAvailable() bool {
+	return ghostscriptPath() != ""
+}
+
+// ghostscriptPath returns the Ghostscript command, or "" when it is not installed.
+// Apps started from the Finder do not get the Homebrew folders in their PATH.
+func ghostscriptPath() string {
+	names := []string{"gs"}
+	if goruntime.GOOS == "windows" {
+		names = []string{"gswin64c", "gswin32c"}
+	}
+	for _, name := range names {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	if goruntime.GOOS == "darwin" {
+		for _, dir := range []string{"/opt/homebrew/bin", "/usr/local/bin"} {
+			if _, err := os.Stat(filepath.Join(dir, "gs")); err == nil {
+				return filepath.Join(dir, "gs")
+			}
+		}
+	}
+	return ""
+}
+
+// withTimeout runs op from input to output within the operation timeout and returns
+// what op returned. pdfcpu cannot be interrupted, so a run that timed out is left to
+// finish in the background. Every run writes into its own temp folder and the file is
+// only moved to output when the run finished in time, so an abandoned run never
+// touches output. Cancelling ctx abandons the run the same way.
+func (a *App) withTimeout(ctx context.Context, operation, input, output string, op func(in, out string) (interface{}, error)) (interface{}, error) {
+	timeouts := a.GetOperationTimeouts()
+	if timeouts.Seconds <= 0 && ctx.Done() == nil {
+		return op(input, output)
+	}
+	timeout := time.Duration(max(timeouts.Seconds, 0)) * time.Second
+
+	result, err := runWithTimeout(ctx, input, output, timeout, op)
+	var timedOut *OperationTimeoutError
+	if !errors.As(err, &timedOut) {
+		return result, err
+	}
+	timedOut.Operation = operation
+	gs := ghostscriptPath()
+	if gs == "" {
+		return nil, err
+	}
+	if !timeouts.GhostscriptFallback {
+		timedOut.CanRetry = true
+		return nil, err
+	}
+
+	fmt.Printf("Backend: %s of %s timed out, retrying on a copy rewritten by Ghostscript\n", operation, input)
+	dir, rerr := os.MkdirTemp("", "capgo_gs_*")
+	if rerr != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	// Keep the name, since outputs and history are named after the input
+	rewritten := filepath.Join(dir, filepath.Base(input))
+	if rerr := ghostscriptRewrite(gs, input, rewritten, timeout); rerr != nil {
+		fmt.Printf("Backend: Ghostscript could not rewrite %s: %v\n", input, rerr)
+		return nil, err
+	}
+	result, err = runWithTimeout(ctx, rewritten, output, timeout, op)
+	if errors.As(err, &timedOut) {
+		timedOut.Operation = operation
+		timedOut.Path = input
+	}
+	return result, err
+}
+
+// runWithTimeout runs op once in the background and gives up after timeout, or when
+// ctx is cancelled. A timeout of 0 means no limit.
+func runWithTimeout(ctx context.Context, input, output string, timeout time.Duration, op func(in, out string) (interface{}, error)) (interface{}, error) {
+	dir, err := os.MkdirTemp("", "capgo_run_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp folder: %v", err)
+	}
+	tmp := filepath.Join(dir, filepath.Base(output))
+
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	var runCtx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		runCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	done := make(chan outcome, 1)
+	go func() {
+		// A panic here would not reach the recoverDamaged of the caller
+		var result interface{}
+		err := catchCrash(func() (err error) {
+			result, err = op(input, tmp)
+			return err
+		})
+		var crash *pdfCrash
+		if errors.As(err, &crash) {
+			err = damagedFileError(input)
+		}
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case o := <-done:
+		defer os.RemoveAll(dir)
+		if o.err != nil {
+			return nil, o.err
+		}
+		if err := moveFile(tmp, output); err != nil {
+			return nil, classifyFileError("write", output, err)
+		}
+		return o.result, nil
+	case <-runCtx.Done():
+		go func() {
+			<-done
+			os.RemoveAll(dir)
+			fmt.Printf("Backend: Abandoned run on %s finished\n", input)
+		}()
+		if ctx.Err() != nil {
+			return nil, errCancelled
+		}
+		return nil, &OperationTimeoutError{Path: input, Timeout: timeout}
+	}
+}
+
+// ghostscriptRewrite writes a copy of input through Ghostscript's PDF writer, which
+// rebuilds the document structure that makes pdfcpu spin
+func ghostscriptRewrite(gs, input, output string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, gs, "-q", "-dNOPAUSE", "-dBATCH", "-dSAFER",
+		"-sDEVICE=pdfwrite", "-sOutputFile="+output, input).CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("ghostscript did not finish within %v", timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, out)
+	}
+	return nil
+}
+
+// moveFile renames src to dst, copying when they are on different volumes
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
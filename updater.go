@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// updaterArg starts the binary as the update installer instead of the app:
+// <binary> --capgo-install-update <pid> <dmg> <CapGo.app>
+const updaterArg = "--capgo-install-update"
+
+// swapArg replaces the bundle with administrator rights when its folder is not
+// writable: <binary> --capgo-swap-update <new CapGo.app> <CapGo.app>
+const swapArg = "--capgo-swap-update"
+
+// Update install outcomes
+const (
+	UpdateInstalled = "installed"
+	UpdateManual    = "manual" // the disk image was opened for the user to drag-install
+	UpdateFailed    = "failed"
+)
+
+// UpdateStatus is the outcome of the last update install, reported on the next launch
+type UpdateStatus struct {
+	Status  string    `json:"status"`
+	Message string    `json:"message"`
+	At      time.Time `json:"at"`
+}
+
+const updateStatusFile = "update_status.json"
+
+// updaterWait is how long the installer waits for the app to quit
+const updaterWait = time.Minute
+
+// copyUpdaterBinary copies the running binary to a temp folder, since the one inside
+// the bundle is about to be replaced
+func copyUpdaterBinary(exePath string) (string, error) {
+	dir, err := os.MkdirTemp("", "capgo_updater_*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp folder: %v", err)
+	}
+	src, err := os.Open(exePath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	helper := filepath.Join(dir, "capgo-updater")
+	dst, err := os.OpenFile(helper, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return "", fmt.Errorf("failed to copy updater: %v", err)
+	}
+	if err := dst.Close(); err != nil {
+		return "", err
+	}
+	return helper, nil
+}
+
+// GetUpdateStatus returns the outcome of the last update install once, so the frontend
+// can tell the user after the relaunch; nil when there is nothing to report
+func (a *App) GetUpdateStatus() (*UpdateStatus, error) {
+	var status *UpdateStatus
+	if err := readConfigJSON(updateStatusFile, &status); err != nil {
+		return nil, err
+	}
+	if status != nil {
+		if dir, err := configDir(); err == nil {
+			os.Remove(filepath.Join(dir, updateStatusFile))
+		}
+	}
+	return status, nil
+}
+
+// runUpdater installs an update when the binary was started with updaterArg or
+// swapArg. It reports whether it handled the command line.
+func runUpdater(args []string) bool {
+	if len(args) < 2 {
+		return false
+	}
+	if args[1] == swapArg {
+		if len(args) != 4 {
+			fmt.Printf("Updater: expected <source> <app>, got %d arguments\n", len(args)-2)
+			os.Exit(2)
+		}
+		if err := swapBundle(filepath.Clean(args[2]), filepath.Clean(args[3])); err != nil {
+			fmt.Printf("Updater: %v\n", err)
+			os.Exit(1)
+		}
+		return true
+	}
+	if args[1] != updaterArg {
+		return false
+	}
+	if len(args) != 5 {
+		fmt.Printf("Updater: expected <pid> <dmg> <app>, got %d arguments\n", len(args)-2)
+		os.Exit(2)
+	}
+	pid, err := strconv.Atoi(args[2])
+	if err != nil {
+		fmt.Printf("Updater: invalid pid %q\n", args[2])
+		os.Exit(2)
+	}
+	dmgPath := filepath.Clean(args[3])
+	status := UpdateStatus{Status: UpdateInstalled, Message: "CapGo was updated", At: time.Now()}
+	if err := installUpdate(pid, dmgPath, filepath.Clean(args[4])); err != nil {
+		fmt.Printf("Updater: %v\n", err)
+		status.Status, status.Message = UpdateFailed, err.Error()
+		// Leave the disk image open so the user can drag CapGo to Applications
+		if runCommand("open", dmgPath) == nil {
+			status.Status = UpdateManual
+			status.Message = fmt.Sprintf("%v. The update was opened so you can drag CapGo to Applications yourself.", err)
+		}
+	}
+	if err := writeConfigJSON(updateStatusFile, status); err != nil {
+		fmt.Printf("Updater: %v\n", err)
+	}
+	if status.Status == UpdateFailed {
+		os.Exit(1)
+	}
+	return true
+}
+
+// installUpdate waits for the app to quit, mounts the disk image, swaps the bundle and
+// relaunches it
+func installUpdate(pid int, dmgPath, destApp string) error {
+	if filepath.Ext(destApp) != ".app" || !filepath.IsAbs(destApp) {
+		return fmt.Errorf("refusing to replace %s: not an application bundle", destApp)
+	}
+
+	// 1. Wait for the main app to terminate
+	deadline := time.Now().Add(updaterWait)
+	for processRunning(pid) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("CapGo (pid %d) did not quit", pid)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	// 2. Mount the DMG
+	mountPoint, err := os.MkdirTemp("", "capgo_update_mnt_*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(mountPoint)
+	if err := runCommand("hdiutil", "attach", dmgPath, "-mountpoint", mountPoint, "-nobrowse", "-readonly"); err != nil {
+		return err
+	}
+	defer runCommand("hdiutil", "detach", mountPoint, "-force")
+
+	// 3. Swap the Application. Bundles in a folder the user cannot write, such as an
+	// admin-owned /Applications, are swapped by the helper with administrator rights.
+	sourceApp := filepath.Join(mountPoint, "CapGo.app")
+	if info, err := os.Stat(sourceApp); err != nil || !info.IsDir() {
+		return fmt.Errorf("update failed: app not found in %s", filepath.Base(dmgPath))
+	}
+	if probeWritable(filepath.Dir(destApp)) == nil {
+		err = swapBundle(sourceApp, destApp)
+	} else {
+		err = elevatedSwap(sourceApp, destApp)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 4. Relaunch
+	return runCommand("open", destApp)
+}
+
+// elevatedSwap runs swapBundle through an administrator password prompt. Every
+// argument goes through AppleScript's quoted form, so the shell sees them as literals.
+func elevatedSwap(sourceApp, destApp string) error {
+	helper, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	script := fmt.Sprintf(`do shell script (quoted form of %s) & " %s " & (quoted form of %s) & " " & (quoted form of %s) with administrator privileges`,
+		appleScriptString(helper), swapArg, appleScriptString(sourceApp), appleScriptString(destApp))
+	out, err := exec.Command("osascript", "-e", script).CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(out), "-128") {
+			return fmt.Errorf("installing into %s needs an administrator password and the prompt was cancelled", filepath.Dir(destApp))
+		}
+		return fmt.Errorf("failed to install with administrator rights: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// swapBundle replaces destApp with a copy of sourceApp. The old bundle is kept until
+// the new one is in place, so a failed copy leaves the installed version working.
+func swapBundle(sourceApp, destApp string) error {
+	if filepath.Ext(destApp) != ".app" || !filepath.IsAbs(destApp) {
+		return fmt.Errorf("refusing to replace %s: not an application bundle", destApp)
+	}
+	staged := destApp + ".update"
+	backup := destApp + ".previous"
+	os.RemoveAll(staged)
+	os.RemoveAll(backup)
+	if err := runCommand("ditto", sourceApp, staged); err != nil {
+		os.RemoveAll(staged)
+		return err
+	}
+	if err := os.Rename(destApp, backup); err != nil {
+		os.RemoveAll(staged)
+		return fmt.Errorf("failed to move the old app aside: %v", err)
+	}
+	if err := os.Rename(staged, destApp); err != nil {
+		os.Rename(backup, destApp)
+		os.RemoveAll(staged)
+		return fmt.Errorf("failed to move the new app into place: %v", err)
+	}
+	os.RemoveAll(backup)
+	fmt.Printf("Updater: Replaced %s\n", destApp)
+
+	// Fix Permissions (Quarantine)
+	if err := runCommand("xattr", "-cr", destApp); err != nil {
+		fmt.Printf("Updater: %v\n", err)
+	}
+	return nil
+}
+
+// processRunning reports whether a process with the given pid still exists
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// runCommand executes a command without a shell and includes its output in the error
+func runCommand(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %v: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"CapGo/pkg/stamper"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// pointsPerMM converts millimetres to PDF points
+const pointsPerMM = 72.0 / 25.4
+
+// StampWarning describes a problem found with a stamp before it is applied
+type StampWarning struct {
+	Index   int    `json:"index"`
+	PageNum int    `json:"pageNum"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// SafeAreaReport is the payload of EventStampSafeArea
+type SafeAreaReport struct {
+	Document string         `json:"document"`
+	Warnings []StampWarning `json:"warnings"`
+}
+
+// ValidateStamps checks the stamps against the PDF and returns any warnings
+func (a *App) ValidateStamps(pdfPath string, stamps []StampInfo) (_ []StampWarning, err error) {
+	pdfPath = filepath.Clean(pdfPath)
+	defer recoverDamaged(pdfPath, &err)
+
+	dims, err := stamper.VisiblePageDimsFile(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page dimensions for %s: %v", pdfPath, err)
+	}
+
+	stamps, err = a.NormalizeStampCoordinates(stamps)
+	if err != nil {
+		return nil, err
+	}
+
+	area := a.GetSafeArea()
+	warnings := []StampWarning{}
+
+	// Stamps CapGo already applied to this document, if it wrote it
+	var applied []HistoryStamp
+	if history, err := lookupHistory(pdfPath); err == nil && history != nil {
+		applied = history.Stamps
+	}
+
+	frame, err := a.pageFrameFor(pdfPath, stamps)
+	if err != nil {
+		return nil, err
+	}
+
+	// A stamp with a page selection is checked on every page it selects
+	type check struct {
+		index int
+		stamp StampInfo
+	}
+	var checks []check
+	for i, stamp := range stamps {
+		copies, err := stampCopies(stamp, len(dims))
+		if err != nil {
+			warnings = append(warnings, StampWarning{
+				Index:   i,
+				Code:    "invalid_pages",
+				Message: tr("stamp %d: %v", i, localizeMessage(err.Error())),
+			})
+			continue
+		}
+		for _, s := range frame.convert(copies) {
+			checks = append(checks, check{i, s})
+		}
+	}
+
+	for _, c := range checks {
+		i, stamp := c.index, c.stamp
+		if stamp.PageNum < 1 || stamp.PageNum > len(dims) {
+			warnings = append(warnings, StampWarning{
+				Index:   i,
+				PageNum: stamp.PageNum,
+				Code:    "page_out_of_range",
+				Message: tr("stamp %d targets page %d but the document has %d pages", i, stamp.PageNum, len(dims)),
+			})
+			continue
+		}
+		if stamp.Width <= 0 || stamp.Height <= 0 {
+			warnings = append(warnings, StampWarning{
+				Index:   i,
+				PageNum: stamp.PageNum,
+				Code:    "invalid_size",
+				Message: tr("stamp %d has an invalid size", i),
+			})
+			continue
+		}
+
+		if stamp.Kind == StampKindText {
+			if strings.TrimSpace(stamp.Text) == "" {
+				warnings = append(warnings, StampWarning{
+					Index:   i,
+					PageNum: stamp.PageNum,
+					Code:    "empty_text",
+					Message: tr("text stamp %d has no text", i),
+				})
+				continue
+			}
+			if _, err := stamper.NormalizeRotation(stamp.Rotation); err != nil {
+				warnings = append(warnings, StampWarning{
+					Index:   i,
+					PageNum: stamp.PageNum,
+					Code:    "invalid_rotation",
+					Message: tr("text stamp %d: %v", i, localizeMessage(err.Error())),
+				})
+				continue
+			}
+			if !stamper.TextFits(stamp.Stamp) {
+				warnings = append(warnings, StampWarning{
+					Index:   i,
+					PageNum: stamp.PageNum,
+					Code:    "text_overflow",
+					Message: tr("the text of stamp %d does not fit its box at %d pt", i, stamp.FontSize),
+				})
+			}
+		}
+
+		if prev, ok := alreadyApplied(stamp, applied); ok {
+			warnings = append(warnings, StampWarning{
+				Index:   i,
+				PageNum: stamp.PageNum,
+				Code:    "already_applied",
+				Message: tr("stamp %d was already applied to this region of page %d on %s", i, stamp.PageNum, formatDateTime(prev.AppliedAt)),
+			})
+		}
+
+		dim := dims[stamp.PageNum-1]
+		if stamp.X < 0 || stamp.Y < 0 || stamp.X+stamp.Width > dim.Width || stamp.Y+stamp.Height > dim.Height {
+			warnings = append(warnings, StampWarning{
+				Index:   i,
+				PageNum: stamp.PageNum,
+				Code:    "outside_page",
+				Message: tr("stamp %d extends beyond the edge of page %d", i, stamp.PageNum),
+			})
+			continue
+		}
+
+		if area.Mode != SafeAreaOff && !insideSafeArea(stamp, dim, area) {
+			msg := tr("stamp %d is closer than %.0f mm to the edge of page %d", i, area.MarginMM, stamp.PageNum)
+			if area.Mode == SafeAreaClamp {
+				msg = tr("stamp %d is closer than %.0f mm to the edge of page %d and will be moved inside the safe area", i, area.MarginMM, stamp.PageNum)
+			}
+			warnings = append(warnings, StampWarning{
+				Index:   i,
+				PageNum: stamp.PageNum,
+				Code:    "outside_safe_area",
+				Message: msg,
+			})
+		}
+	}
+
+	return warnings, nil
+}
+
+// warnOutsideSafeArea tells the user about stamps that were placed closer to the page
+// edges than the safe area allows when it only warns. pdfPath is the document as the
+// user knows it, source the copy that was stamped, e.g. after removing its password.
+func (a *App) warnOutsideSafeArea(pdfPath, source string, stamps []StampInfo) {
+	if a.GetSafeArea().Mode != SafeAreaWarn {
+		return
+	}
+	all, err := a.ValidateStamps(source, stamps)
+	if err != nil {
+		fmt.Printf("Backend: Failed to check the safe area of %s: %v\n", pdfPath, err)
+		return
+	}
+	report := SafeAreaReport{Document: pdfPath, Warnings: []StampWarning{}}
+	for _, w := range all {
+		if w.Code == "outside_safe_area" {
+			report.Warnings = append(report.Warnings, w)
+		}
+	}
+	if len(report.Warnings) > 0 {
+		a.emit(EventStampSafeArea, report)
+	}
+}
+
+// insideSafeArea reports whether the stamp keeps the configured margin from every page edge
+func insideSafeArea(stamp StampInfo, dim types.Dim, area SafeArea) bool {
+	margin := area.MarginMM * pointsPerMM
+	return stamp.X >= margin &&
+		stamp.Y >= margin &&
+		stamp.X+stamp.Width <= dim.Width-margin &&
+		stamp.Y+stamp.Height <= dim.Height-margin
+}
+
+// clampToSafeArea moves (and if necessary shrinks) a stamp so it lies within the safe area
+func clampToSafeArea(stamp StampInfo, dim types.Dim, area SafeArea) StampInfo {
+	stamp.Stamp = stamper.ClampToPage(stamp.Stamp, dim, area.MarginMM*pointsPerMM)
+	return stamp
+}
+
+// alreadyApplied looks for the same stamp template in the same region of the page
+// among the stamps recorded for the document
+func alreadyApplied(stamp StampInfo, applied []HistoryStamp) (HistoryStamp, bool) {
+	if len(applied) == 0 {
+		return HistoryStamp{}, false
+	}
+	key := stampTemplateKey(stamp)
+	if key == "" {
+		return HistoryStamp{}, false
+	}
+	for _, h := range applied {
+		if h.PageNum == stamp.PageNum && h.Template == key &&
+			regionsOverlap(stamp.X, stamp.Y, stamp.Width, stamp.Height, h.X, h.Y, h.Width, h.Height) {
+			return h, true
+		}
+	}
+	return HistoryStamp{}, false
+}
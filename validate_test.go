@@ -0,0 +1,45 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"CapGo/pkg/stamper"
+)
+
+func TestStampWarnsOutsideSafeArea(t *testing.T) {
+	input, _ := filepath.Abs(filepath.Join(goldenDir, "mixed_sizes.pdf"))
+	stamps := []StampInfo{
+		{Stamp: stamper.Stamp{Kind: StampKindText, Text: "INSIDE", X: 100, Y: 100, Width: 120, Height: 40, PageNum: 1}},
+		{Stamp: stamper.Stamp{Kind: StampKindText, Text: "EDGE", X: 5, Y: 5, Width: 120, Height: 40, PageNum: 1}},
+	}
+	for _, mode := range []string{SafeAreaWarn, SafeAreaClamp, SafeAreaOff} {
+		a := goldenApp(t)
+		if err := a.SetSafeArea(SafeArea{MarginMM: 10, Mode: mode}); err != nil {
+			t.Fatal(err)
+		}
+		var reports []SafeAreaReport
+		a.onEmit = func(name string, data ...interface{}) {
+			if name == EventStampSafeArea {
+				reports = append(reports, data[0].(SafeAreaReport))
+			}
+		}
+		if _, err := a.StampPDF(input, stamps); err != nil {
+			t.Fatal(err)
+		}
+
+		if mode != SafeAreaWarn {
+			if len(reports) != 0 {
+				t.Errorf("%s: got %v, want no warning", mode, reports)
+			}
+			continue
+		}
+		if len(reports) != 1 || len(reports[0].Warnings) != 1 {
+			t.Fatalf("%s: got %v, want one warning", mode, reports)
+		}
+		w := reports[0].Warnings[0]
+		if reports[0].Document != input || w.Index != 1 || w.PageNum != 1 || w.Code != "outside_safe_area" {
+			t.Errorf("%s: got %+v for %s", mode, w, reports[0].Document)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// SignatureCertificate is one certificate of a signer's chain
+type SignatureCertificate struct {
+	Subject    string    `json:"subject"`
+	Issuer     string    `json:"issuer"`
+	ValidFrom  time.Time `json:"validFrom"`
+	ValidTo    time.Time `json:"validTo"`
+	Expired    bool      `json:"expired"`
+	SelfSigned bool      `json:"selfSigned"`
+}
+
+// SignatureInfo is the verification result of one digital signature
+type SignatureInfo struct {
+	Field       string    `json:"field"`
+	SignerName  string    `json:"signerName"`
+	SigningTime time.Time `json:"signingTime,omitempty"` // zero when the signature does not say
+	Reason      string    `json:"reason,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	PageNum     int       `json:"pageNum,omitempty"` // page of a visible signature
+	// Intact means the signed bytes are unchanged and the signature is authentic
+	Intact bool `json:"intact"`
+	// ChainValid means the certificate chain leads to a trusted root and was valid
+	// when the document was signed
+	ChainValid   bool   `json:"chainValid"`
+	ChainProblem string `json:"chainProblem,omitempty"`
+	// ModifiedAfterSigning means the file was changed after this signature, by a later
+	// signature or by other edits
+	ModifiedAfterSigning bool                   `json:"modifiedAfterSigning"`
+	Certificates         []SignatureCertificate `json:"certificates"` // signer first
+	Problems             []string               `json:"problems,omitempty"`
+}
+
+// SignatureReport is the outcome of VerifySignatures, oldest signature first
+type SignatureReport struct {
+	Signatures []SignatureInfo `json:"signatures"`
+	// Valid is shown as "Signed & valid": every signature is intact and trusted and the
+	// latest one covers the whole file
+	Valid bool `json:"valid"`
+}
+
+// VerifySignatures checks the digital signatures of a PDF. A document without
+// signatures gives an empty report.
+func (a *App) VerifySignatures(pdfPath string) (report SignatureReport, err error) {
+	pdfPath = filepath.Clean(pdfPath)
+	defer recoverDamaged(pdfPath, &err)
+	report.Signatures = []SignatureInfo{}
+
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return report, classifyFileError("read", pdfPath, err)
+	}
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.VALIDATESIGNATURE
+	loadSignatureRoots()
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(data), conf)
+	if err != nil {
+		return report, fmt.Errorf("failed to read pdf: %v", err)
+	}
+	if len(ctx.Signatures) == 0 && !ctx.SignatureExist && !ctx.AppendOnly {
+		return report, nil
+	}
+	results, err := pdfcpu.ValidateSignatures(bytes.NewReader(data), ctx, true)
+	if err != nil {
+		return report, fmt.Errorf("failed to verify signatures: %v", err)
+	}
+
+	signedLength := len(bytes.TrimRight(data, "\x00\t\n\r "))
+	for _, r := range results {
+		if !r.Signed {
+			continue
+		}
+		info := signatureInfo(r)
+		if end, ok := signedEnd(ctx, r.ObjNr); ok {
+			info.ModifiedAfterSigning = end < signedLength
+		}
+		report.Signatures = append(report.Signatures, info)
+	}
+	// pdfcpu lists the latest increment first
+	for i, j := 0, len(report.Signatures)-1; i < j; i, j = i+1, j-1 {
+		report.Signatures[i], report.Signatures[j] = report.Signatures[j], report.Signatures[i]
+	}
+
+	report.Valid = len(report.Signatures) > 0 && !report.Signatures[len(report.Signatures)-1].ModifiedAfterSigning
+	for _, s := range report.Signatures {
+		if !s.Intact || !s.ChainValid {
+			report.Valid = false
+		}
+	}
+	fmt.Printf("Backend: Verified %d signatures of %s, valid: %v\n", len(report.Signatures), pdfPath, report.Valid)
+	return report, nil
+}
+
+// hasSignatures reports whether the interactive form says signatures exist, which is
+// cheap to check when a document is opened
+func hasSignatures(ctx *model.Context) bool {
+	if ctx.RootDict == nil {
+		return false
+	}
+	form, err := ctx.DereferenceDict(ctx.RootDict["AcroForm"])
+	if err != nil || form == nil {
+		return false
+	}
+	flags, err := ctx.DereferenceInteger(form["SigFlags"])
+	return err == nil && flags != nil && *flags&1 != 0
+}
+
+// signatureInfo converts a pdfcpu validation result
+func signatureInfo(r *model.SignatureValidationResult) SignatureInfo {
+	info := SignatureInfo{
+		Field:        r.Details.FieldName,
+		SignerName:   r.Details.SignerName,
+		SigningTime:  r.Details.SigningTime,
+		Reason:       r.Details.Reason,
+		Location:     r.Details.Location,
+		Intact:       r.DocModified == model.False && r.Reason != model.SignatureReasonSignatureForged,
+		ChainValid:   r.Status == model.SignatureStatusValid,
+		Certificates: []SignatureCertificate{},
+	}
+	if r.Visible {
+		info.PageNum = r.PageNr
+	}
+	if info.Intact && !info.ChainValid {
+		info.ChainProblem = r.Reason.String()
+	}
+	problems := r.Problems
+	for _, signer := range r.Details.Signers {
+		problems = append(problems, signer.Problems...)
+		if signer.HasTimestamp {
+			info.SigningTime = signer.Timestamp
+		}
+		for c := signer.Certificate; c != nil; c = c.IssuerCertificate {
+			info.Certificates = append(info.Certificates, SignatureCertificate{
+				Subject:    c.Subject,
+				Issuer:     c.Issuer,
+				ValidFrom:  c.ValidFrom,
+				ValidTo:    c.ValidThru,
+				Expired:    c.Expired,
+				SelfSigned: c.SelfSigned,
+			})
+		}
+	}
+	for _, p := range problems {
+		info.Problems = append(info.Problems, strings.TrimSpace(p))
+	}
+	if info.SignerName == "" && len(info.Certificates) > 0 {
+		info.SignerName = info.Certificates[0].Subject
+	}
+	return info
+}
+
+// signedEnd returns where the bytes covered by the signature of a field end
+func signedEnd(ctx *model.Context, fieldNr int) (int, bool) {
+	field, err := ctx.DereferenceDict(*types.NewIndirectRef(fieldNr, 0))
+	if err != nil || field == nil {
+		return 0, false
+	}
+	sig, err := ctx.DereferenceDict(field["V"])
+	if err != nil || sig == nil {
+		return 0, false
+	}
+	byteRange, err := ctx.DereferenceArray(sig["ByteRange"])
+	if err != nil || len(byteRange) != 4 {
+		return 0, false
+	}
+	start, ok1 := byteRange[2].(types.Integer)
+	length, ok2 := byteRange[3].(types.Integer)
+	return int(start) + int(length), ok1 && ok2
+}
+
+var signatureRootsOnce sync.Once
+
+// loadSignatureRoots lets signature chains lead to the roots the operating system
+// trusts as well as the certificates imported into pdfcpu; pdfcpu alone only knows the
+// latter
+func loadSignatureRoots() {
+	signatureRootsOnce.Do(func() {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if _, err := os.Stat(model.CertDir); err == nil {
+			if _, err := pdfcpu.LoadCertificatesToCertPool(model.CertDir, pool); err != nil {
+				fmt.Printf("Backend: Failed to load certificates from %s: %v\n", model.CertDir, err)
+			}
+		}
+		model.UserCertPool = pool
+	})
+}
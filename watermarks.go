@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// RemoveCapGoWatermarks strips the stamps CapGo applied from the given pages (all stamped
+// pages when empty), e.g. to drop a DRAFT watermark before issuing the final version.
+// Only documents with a CapGo history record are accepted, so watermarks added by other
+// tools are never touched. All CapGo stamps on a selected page are removed together.
+func (a *App) RemoveCapGoWatermarks(pdfPath string, pages []int) (string, error) {
+	pdfPath = filepath.Clean(pdfPath)
+
+	history, err := lookupHistory(pdfPath)
+	if err != nil {
+		return "", err
+	}
+	if history == nil || len(history.Stamps) == 0 {
+		return "", fmt.Errorf("no CapGo stamps are recorded for this document")
+	}
+
+	stamped := map[int]bool{}
+	for _, s := range history.Stamps {
+		if !s.Flattened {
+			stamped[s.PageNum] = true
+		}
+	}
+	if len(stamped) == 0 {
+		return "", fmt.Errorf("the CapGo stamps on this document are flattened and cannot be removed")
+	}
+	if len(pages) == 0 {
+		for p := range stamped {
+			pages = append(pages, p)
+		}
+		sort.Ints(pages)
+	}
+
+	remove := map[int]bool{}
+	selected := make([]string, 0, len(pages))
+	for _, p := range pages {
+		if !stamped[p] {
+			return "", fmt.Errorf("page %d has no removable CapGo stamps", p)
+		}
+		if !remove[p] {
+			remove[p] = true
+			selected = append(selected, strconv.Itoa(p))
+		}
+	}
+
+	outputPath, err := a.outputPath(pdfPath, "_final")
+	if err != nil {
+		return "", err
+	}
+	if err := api.RemoveWatermarksFile(pdfPath, outputPath, selected, nil); err != nil {
+		os.Remove(outputPath)
+		return "", fmt.Errorf("failed to remove watermarks: %v", err)
+	}
+	if err := a.finishOutput(outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
+	}
+
+	// The new document keeps the record of the stamps that are still on it
+	var remaining []HistoryStamp
+	for _, s := range history.Stamps {
+		if s.Flattened || !remove[s.PageNum] {
+			remaining = append(remaining, s)
+		}
+	}
+	if err := recordHistory(pdfPath, outputPath, "remove_watermarks", remaining); err != nil {
+		fmt.Printf("Backend: Failed to record history for %s: %v\n", outputPath, err)
+	}
+	return outputPath, nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Window kinds
+const (
+	WindowMain      = "main"
+	WindowPreview   = "preview"   // detached preview of a document
+	WindowTemplates = "templates" // template manager
+)
+
+// WindowInfo describes a CapGo window
+type WindowInfo struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	Title    string `json:"title"`
+	Document string `json:"document,omitempty"` // document shown by a preview window
+	PID      int    `json:"pid"`
+}
+
+// windowKinds holds the title and size of every kind of window
+var windowKinds = map[string]struct {
+	title         string
+	width, height int
+}{
+	WindowMain:      {"CapGo", 1024, 768},
+	WindowPreview:   {"CapGo Preview", 800, 1000},
+	WindowTemplates: {"CapGo Templates", 640, 720},
+}
+
+const (
+	windowArg   = "--capgo-window="
+	documentArg = "--capgo-document="
+	windowIDArg = "--capgo-window-id="
+)
+
+// windowManager tracks the windows opened from this one. Wails v2 hosts a single native
+// window per process, so every additional window runs in its own CapGo process with its
+// own runtime context; dialogs and events of a window therefore always target that window.
+// The windows share settings, sequences and templates through their files, which are
+// locked while they change, see saveSettings and lockConfigFile.
+type windowManager struct {
+	mu       sync.Mutex
+	children map[string]*exec.Cmd
+	info     map[string]WindowInfo
+	next     int
+}
+
+// windowFromArgs returns the window this process hosts, based on its command line
+func windowFromArgs(args []string) WindowInfo {
+	w := WindowInfo{ID: WindowMain, Kind: WindowMain, PID: os.Getpid()}
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, windowArg):
+			if kind := strings.TrimPrefix(arg, windowArg); windowKinds[kind].title != "" {
+				w.Kind = kind
+			}
+		case strings.HasPrefix(arg, documentArg):
+			w.Document = filepath.Clean(strings.TrimPrefix(arg, documentArg))
+		case strings.HasPrefix(arg, windowIDArg):
+			w.ID = strings.TrimPrefix(arg, windowIDArg)
+		}
+	}
+	if w.Kind == WindowMain {
+		w.ID = WindowMain
+	}
+	w.Title = windowKinds[w.Kind].title
+	if w.Document != "" {
+		w.Title += " - " + filepath.Base(w.Document)
+	}
+	return w
+}
+
+// GetWindow returns the window this instance of the frontend runs in, so it can pick the view to show
+func (a *App) GetWindow() WindowInfo {
+	return a.window
+}
+
+// OpenWindow opens an additional window of the given kind. document is the PDF shown by
+// a preview window and is ignored by other kinds.
+func (a *App) OpenWindow(kind string, document string) (WindowInfo, error) {
+	if kind == WindowMain || windowKinds[kind].title == "" {
+		return WindowInfo{}, fmt.Errorf("unknown window kind: %s", kind)
+	}
+	if kind == WindowPreview {
+		if document == "" {
+			return WindowInfo{}, fmt.Errorf("a preview window needs a document")
+		}
+		document = filepath.Clean(document)
+		if _, err := os.Stat(document); err != nil {
+			return WindowInfo{}, err
+		}
+	} else {
+		document = ""
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return WindowInfo{}, fmt.Errorf("could not locate CapGo executable: %v", err)
+	}
+
+	a.windows.mu.Lock()
+	defer a.windows.mu.Unlock()
+	if a.windows.children == nil {
+		a.windows.children = map[string]*exec.Cmd{}
+		a.windows.info = map[string]WindowInfo{}
+	}
+	a.windows.next++
+	id := fmt.Sprintf("%s-%d", kind, a.windows.next)
+
+	args := []string{windowArg + kind, windowIDArg + id}
+	if document != "" {
+		args = append(args, documentArg+document)
+	}
+	cmd := exec.Command(exe, args...)
+	if err := cmd.Start(); err != nil {
+		return WindowInfo{}, fmt.Errorf("failed to open window: %v", err)
+	}
+
+	w := windowFromArgs(args)
+	w.PID = cmd.Process.Pid
+	a.windows.children[id] = cmd
+	a.windows.info[id] = w
+
+	go func() {
+		cmd.Wait()
+		a.windows.mu.Lock()
+		delete(a.windows.children, id)
+		delete(a.windows.info, id)
+		a.windows.mu.Unlock()
+		a.emit(EventWindowClosed, w)
+	}()
+
+	fmt.Printf("Backend: Opened %s window %s (pid %d)\n", kind, id, w.PID)
+	return w, nil
+}
+
+// ListWindows returns this window and the windows opened from it
+func (a *App) ListWindows() []WindowInfo {
+	a.windows.mu.Lock()
+	defer a.windows.mu.Unlock()
+
+	children := make([]WindowInfo, 0, len(a.windows.info))
+	for _, w := range a.windows.info {
+		children = append(children, w)
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].ID < children[j].ID })
+	return append([]WindowInfo{a.window}, children...)
+}
+
+// CloseWindow closes a window opened from this one
+func (a *App) CloseWindow(id string) error {
+	a.windows.mu.Lock()
+	cmd, ok := a.windows.children[id]
+	a.windows.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown window: %s", id)
+	}
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		return cmd.Process.Kill()
+	}
+	// Give the window a moment to close cleanly before forcing it
+	go func() {
+		time.Sleep(3 * time.Second)
+		a.windows.mu.Lock()
+		_, running := a.windows.children[id]
+		a.windows.mu.Unlock()
+		if running {
+			cmd.Process.Kill()
+		}
+	}()
+	return nil
+}
+
+// closeChildWindows closes every window opened from this one, e.g. when the main window quits
+func (a *App) closeChildWindows() {
+	a.windows.mu.Lock()
+	defer a.windows.mu.Unlock()
+	for _, cmd := range a.windows.children {
+		cmd.Process.Kill()
+	}
+}
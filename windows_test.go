@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Every additional window is a process with its own App and its own copy of the
+// settings, like the two Apps here that share one config directory
+func TestWindowsShareSettings(t *testing.T) {
+	goldenApp(t)
+	main, preview := NewApp(), NewApp()
+	if err := main.SetDefaultPaperSize("Letter"); err != nil {
+		t.Fatal(err)
+	}
+	// The preview window still has the settings from before and must not undo the change
+	if err := preview.SetDeterministicOutput(true); err != nil {
+		t.Fatal(err)
+	}
+	for name, s := range map[string]Settings{"file": loadSettings(), "preview": preview.GetSettings()} {
+		if s.PaperSize != "Letter" || !s.DeterministicOutput {
+			t.Errorf("%s: paper size %s, deterministic output %v, want Letter and true", name, s.PaperSize, s.DeterministicOutput)
+		}
+	}
+}
+
+func TestWindowsShareSequences(t *testing.T) {
+	goldenApp(t)
+	// The store of another window has a mutex of its own
+	stores := []*sequenceStore{sequences, {}}
+	const runs = 20
+	numbers := make(chan string, 2*runs)
+	var wg sync.WaitGroup
+	for _, s := range stores {
+		for i := 0; i < runs; i++ {
+			wg.Add(1)
+			go func(s *sequenceStore) {
+				defer wg.Done()
+				out, err := s.expand([]string{"{seq:invoices}"})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				numbers <- out[0]
+			}(s)
+		}
+	}
+	wg.Wait()
+	close(numbers)
+
+	seen := map[string]bool{}
+	for n := range numbers {
+		if seen[n] {
+			t.Errorf("number %s was handed out twice", n)
+		}
+		seen[n] = true
+	}
+	if want := fmt.Sprint(2 * runs); !seen[want] {
+		t.Errorf("the last number %s was not handed out", want)
+	}
+}
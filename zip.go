@@ -0,0 +1,245 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// zipManifestFile lists the SHA-256 of every file in an archive ZipOutputs writes, in
+// the format "sha256sum -c" checks
+const zipManifestFile = "manifest.sha256"
+
+// Limits on the PDFs extracted from the archives of one batch, so a zip bomb cannot
+// fill the disk
+const (
+	maxZipInputBytes = 4 << 30
+	maxZipInputFiles = 10000
+)
+
+// zipInputs holds the PDFs extracted from archives given as batch input
+type zipInputs struct {
+	dir     string
+	sources map[string]string // extracted path to "archive.zip/entry.pdf"
+}
+
+// expandZipInputs returns paths with every .zip replaced by the PDFs in it, extracted
+// to a temp folder that close removes. Other files in the archives are ignored.
+func expandZipInputs(paths []string) ([]string, *zipInputs, error) {
+	z := &zipInputs{sources: map[string]string{}}
+	var files []string
+	var total int64
+	for i, p := range paths {
+		if !strings.EqualFold(filepath.Ext(p), ".zip") {
+			files = append(files, p)
+			continue
+		}
+		if z.dir == "" {
+			dir, err := os.MkdirTemp("", "capgo_zip_*")
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to create temp folder: %v", err)
+			}
+			z.dir = dir
+		}
+		extracted, err := z.extract(filepath.Clean(p), filepath.Join(z.dir, fmt.Sprint(i)), &total)
+		if err != nil {
+			z.close()
+			return nil, nil, err
+		}
+		if len(extracted) == 0 {
+			z.close()
+			return nil, nil, fmt.Errorf("%s contains no PDF", filepath.Base(p))
+		}
+		files = append(files, extracted...)
+	}
+	return files, z, nil
+}
+
+// extract writes the PDFs of an archive to dir, one folder per entry so entries with
+// the same name keep it, and adds their size to total
+func (z *zipInputs) extract(archive, dir string, total *int64) ([]string, error) {
+	if _, err := os.Stat(archive); err != nil {
+		return nil, classifyFileError("read", archive, err)
+	}
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", filepath.Base(archive), err)
+	}
+	defer r.Close()
+
+	var files []string
+	for i, f := range r.File {
+		// Some archivers write Windows separators
+		name := strings.ReplaceAll(f.Name, `\`, "/")
+		base := path.Base(name)
+		if f.FileInfo().IsDir() || !strings.EqualFold(path.Ext(base), ".pdf") ||
+			strings.HasPrefix(base, ".") || strings.HasPrefix(name, "__MACOSX/") {
+			continue
+		}
+		if len(z.sources) >= maxZipInputFiles {
+			return nil, fmt.Errorf("the archives contain more than %d PDFs", maxZipInputFiles)
+		}
+		// Only the base name is used, so entries cannot point outside the folder
+		out := filepath.Join(dir, fmt.Sprint(i), base)
+		if err := extractZipFile(f, out, maxZipInputBytes-*total); err != nil {
+			return nil, fmt.Errorf("failed to extract %s from %s: %v", name, filepath.Base(archive), err)
+		}
+		info, _ := os.Stat(out)
+		*total += info.Size()
+		z.sources[out] = filepath.Base(archive) + "/" + name
+		files = append(files, out)
+	}
+	return files, nil
+}
+
+func extractZipFile(f *zip.File, out string, limit int64) error {
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	w, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	// The sizes in the archive can lie, so the limit is checked on what is written
+	n, err := io.Copy(w, io.LimitReader(rc, limit+1))
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > limit {
+		err = fmt.Errorf("the archives hold more than %d GB of PDFs", maxZipInputBytes>>30)
+	}
+	return err
+}
+
+// source names a batch input for the user, as the archive entry for extracted PDFs
+func (z *zipInputs) source(path string) string {
+	if s, ok := z.sources[path]; ok {
+		return s
+	}
+	return path
+}
+
+func (z *zipInputs) close() {
+	if z.dir != "" {
+		os.RemoveAll(z.dir)
+	}
+}
+
+// ZipOutputs packs files into one archive for delivery, together with a manifest of
+// their SHA-256 hashes, and returns its path. Files with the same name are numbered.
+// An empty output writes to the output folder, named after the first file.
+func (a *App) ZipOutputs(files []string, output string) (string, error) {
+	if len(files) == 0 {
+		return "", fmt.Errorf("no files given")
+	}
+	if output == "" {
+		base, err := a.outputBaseDir()
+		if err != nil {
+			return "", err
+		}
+		output = filepath.Join(base, outputName(files[0], "_files", ".zip"))
+	}
+	output = filepath.Clean(output)
+	if !strings.EqualFold(filepath.Ext(output), ".zip") {
+		output += ".zip"
+	}
+	dir, err := a.outputDir(filepath.Dir(output))
+	if err != nil {
+		return "", err
+	}
+	output = uniquePath(filepath.Join(dir, filepath.Base(output)))
+
+	defer a.startJob("zip")()
+	if err := writeZipOutputs(files, output); err != nil {
+		os.Remove(output)
+		return "", err
+	}
+	fmt.Printf("Backend: Zipped %d files into %s\n", len(files), output)
+	return output, nil
+}
+
+func writeZipOutputs(files []string, output string) error {
+	out, err := os.Create(output)
+	if err != nil {
+		return classifyFileError("write", output, err)
+	}
+	defer out.Close()
+	zw := zip.NewWriter(out)
+
+	var manifest strings.Builder
+	seen := map[string]bool{zipManifestFile: true}
+	for _, f := range files {
+		f = filepath.Clean(f)
+		name := filepath.Base(f)
+		if seen[strings.ToLower(name)] {
+			ext := filepath.Ext(name)
+			base := strings.TrimSuffix(name, ext)
+			for n := 2; seen[strings.ToLower(name)]; n++ {
+				name = fmt.Sprintf("%s (%d)%s", base, n, ext)
+			}
+		}
+		seen[strings.ToLower(name)] = true
+		sum, err := addZipFile(zw, f, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&manifest, "%s  %s\n", sum, name)
+	}
+
+	w, err := zw.Create(zipManifestFile)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", output, err)
+	}
+	if _, err := io.WriteString(w, manifest.String()); err != nil {
+		return fmt.Errorf("failed to write %s: %v", output, err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", output, err)
+	}
+	if err := out.Close(); err != nil {
+		return classifyFileError("write", output, err)
+	}
+	return nil
+}
+
+// addZipFile copies a file into the archive and returns its SHA-256
+func addZipFile(zw *zip.Writer, path, name string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", classifyFileError("read", path, err)
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return "", classifyFileError("read", path, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a folder", path)
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return "", fmt.Errorf("failed to add %s: %v", path, err)
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return "", fmt.Errorf("failed to add %s: %v", path, err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), in); err != nil {
+		return "", fmt.Errorf("failed to add %s: %v", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}